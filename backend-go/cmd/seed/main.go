@@ -7,7 +7,6 @@ import (
 	"myway-backend/internal/models"
 	"time"
 
-	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -21,7 +20,7 @@ func main() {
 	}
 
 	// Run migrations
-	if err := database.AutoMigrate(); err != nil {
+	if err := database.Migrate(); err != nil {
 		log.Fatalf("Failed to migrate database: %v", err)
 	}
 
@@ -33,23 +32,26 @@ func main() {
 	organizerPassword, _ := bcrypt.GenerateFromPassword([]byte("organizer123"), bcrypt.DefaultCost)
 
 	student := models.User{
-		Email:        "student@example.com",
-		PasswordHash: string(studentPassword),
-		Name:         "John Student",
+		Email:           "student@example.com",
+		PasswordHash:    string(studentPassword),
+		EmailVerifiedAt: timePtr(time.Now()),
+		Name:            "John Student",
 	}
 	database.GetDB().FirstOrCreate(&student, models.User{Email: "student@example.com"})
 
 	teacher := models.User{
-		Email:        "teacher@example.com",
-		PasswordHash: string(teacherPassword),
-		Name:         "Jane Teacher",
+		Email:           "teacher@example.com",
+		PasswordHash:    string(teacherPassword),
+		EmailVerifiedAt: timePtr(time.Now()),
+		Name:            "Jane Teacher",
 	}
 	database.GetDB().FirstOrCreate(&teacher, models.User{Email: "teacher@example.com"})
 
 	organizer := models.User{
-		Email:        "organizer@example.com",
-		PasswordHash: string(organizerPassword),
-		Name:         "Admin Organizer",
+		Email:           "organizer@example.com",
+		PasswordHash:    string(organizerPassword),
+		EmailVerifiedAt: timePtr(time.Now()),
+		Name:            "Admin Organizer",
 	}
 	database.GetDB().FirstOrCreate(&organizer, models.User{Email: "organizer@example.com"})
 
@@ -92,18 +94,20 @@ func main() {
 
 	log.Printf("Created course: %s", course.Title)
 
-	// Create enrollment
-	enrollment := models.Enrollment{
-		CourseID: course.ID,
-		UserID:   student.ID,
-		Role:     "STUDENT",
+	// Create enrollments
+	enrollments := []models.Enrollment{
+		{CourseID: course.ID, UserID: student.ID, Role: "STUDENT"},
+		{CourseID: course.ID, UserID: teacher.ID, Role: "TEACHER"},
 	}
-	var existingEnrollment models.Enrollment
-	if err := database.GetDB().Where("course_id = ? AND user_id = ?", course.ID, student.ID).First(&existingEnrollment).Error; err != nil {
-		database.GetDB().Create(&enrollment)
+
+	for _, enrollment := range enrollments {
+		var existing models.Enrollment
+		if err := database.GetDB().Where("course_id = ? AND user_id = ?", enrollment.CourseID, enrollment.UserID).First(&existing).Error; err != nil {
+			database.GetDB().Create(&enrollment)
+		}
 	}
 
-	log.Printf("Created enrollment")
+	log.Printf("Created enrollments")
 
 	// Create modules
 	modules := []models.Module{
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"myway-backend/internal/database"
+	"myway-backend/internal/repository"
+	"os"
+	"text/tabwriter"
+)
+
+const auditRolesUsage = `usage: server audit-roles [--demote]
+
+Lists every account holding the TEACHER, ORGANIZER or ADMIN platform role.
+Before sign-up stopped accepting a role from the client, every such role was
+self-assigned: after upgrading, run with --demote once to make all of them
+STUDENT, then grant roles again with set-role.`
+
+func runAuditRoles(args []string) error {
+	demote := false
+	for _, arg := range args {
+		if arg != "--demote" {
+			return fmt.Errorf("unknown argument %q\n%s", arg, auditRolesUsage)
+		}
+		demote = true
+	}
+
+	users := repository.NewPostgres(database.GetDB()).Users
+	elevated, err := users.ListByRoles([]string{repository.RoleTeacher, repository.RoleOrganizer, repository.RoleAdmin})
+	if err != nil {
+		return fmt.Errorf("list users: %w", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "EMAIL\tROLE\tSIGNED UP")
+	for _, user := range elevated {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", user.Email, user.Role, user.CreatedAt.Format("2006-01-02 15:04:05 MST"))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if !demote {
+		return nil
+	}
+
+	for i := range elevated {
+		elevated[i].Role = repository.RoleStudent
+		if err := users.Update(&elevated[i]); err != nil {
+			return fmt.Errorf("demote %s: %w", elevated[i].Email, err)
+		}
+	}
+	log.Printf("Demoted %d users to %s", len(elevated), repository.RoleStudent)
+	return nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"myway-backend/internal/database"
+	"myway-backend/internal/handlers"
+	"myway-backend/internal/repository"
+	"strings"
+)
+
+const setRoleUsage = `usage: server set-role <email> <STUDENT|TEACHER|ORGANIZER|ADMIN>
+
+Grants a platform role from the command line, which is how the first ADMIN
+is created.`
+
+func runSetRole(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("expected an email and a role\n%s", setRoleUsage)
+	}
+	role, ok := handlers.ParsePlatformRole(args[1])
+	if !ok {
+		return fmt.Errorf("unknown role %q\n%s", args[1], setRoleUsage)
+	}
+
+	users := repository.NewPostgres(database.GetDB()).Users
+	user, err := users.GetByEmail(strings.ToLower(strings.TrimSpace(args[0])))
+	if err != nil {
+		return fmt.Errorf("find user %s: %w", args[0], err)
+	}
+	user.Role = role
+	if err := users.Update(user); err != nil {
+		return fmt.Errorf("update role: %w", err)
+	}
+	log.Printf("%s is now %s", user.Email, role)
+	return nil
+}
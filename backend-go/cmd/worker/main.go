@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"log"
+	"myway-backend/internal/config"
+	"myway-backend/internal/database"
+	"myway-backend/internal/jobs"
+	"myway-backend/internal/llm"
+	"myway-backend/internal/pipeline"
+	"myway-backend/internal/playlist"
+	"myway-backend/internal/repository"
+	"myway-backend/internal/storage"
+	"myway-backend/internal/studypack"
+	"myway-backend/internal/transcript"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+func main() {
+	// Load configuration
+	cfg := config.LoadConfig()
+
+	// Connect to database
+	if err := database.Connect(cfg.DatabaseURL); err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+
+	// Run migrations
+	if err := database.Migrate(); err != nil {
+		log.Fatalf("Failed to migrate database: %v", err)
+	}
+
+	llmProvider, err := llm.New(llm.Config{
+		Provider: cfg.LLMProvider,
+		Model:    cfg.LLMModel,
+		BaseURL:  cfg.LLMBaseURL,
+		APIKey:   cfg.LLMAPIKey,
+	})
+	if err != nil {
+		log.Fatalf("Failed to configure LLM provider: %v", err)
+	}
+	log.Printf("Using LLM provider %s (%s)", llmProvider.Name(), llmProvider.Model())
+
+	fileStorage, err := storage.New(cfg.Storage())
+	if err != nil {
+		log.Fatalf("Failed to configure file storage: %v", err)
+	}
+
+	worker := jobs.NewWorker(database.GetDB(), cfg.WorkerConcurrency)
+	transcripts := transcript.NewCache(database.GetDB(), transcript.NewYouTube())
+	processor := pipeline.NewProcessor(database.GetDB(), studypack.NewGenerator(llmProvider), fileStorage, transcripts, cfg.TranscriptLanguages)
+	processor.Register(worker)
+	pipeline.NewPlaylistImporter(database.GetDB(), playlist.NewYouTube()).Register(worker)
+
+	repos := repository.NewPostgres(database.GetDB())
+	worker.Every("token cleanup", time.Hour, func(ctx context.Context) error {
+		now := time.Now()
+		deleted, err := repos.Users.DeleteExpiredRefreshTokens(now)
+		if err != nil {
+			return err
+		}
+		emailed, err := repos.Users.DeleteExpiredUserTokens(now)
+		if err != nil {
+			return err
+		}
+		logins, err := repos.SSO.DeleteExpiredLogins(now)
+		if err != nil {
+			return err
+		}
+		if deleted+emailed+logins > 0 {
+			log.Printf("Deleted %d expired refresh tokens, %d expired email tokens and %d abandoned SSO logins", deleted, emailed, logins)
+		}
+		return nil
+	})
+
+	// Stop claiming jobs on SIGINT/SIGTERM; in-flight jobs are released back to the queue
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	worker.Run(ctx)
+}
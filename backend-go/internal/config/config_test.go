@@ -0,0 +1,52 @@
+package config
+
+import "testing"
+
+func TestValidateRelease(t *testing.T) {
+	valid := Config{GinMode: "release", JWTSecret: "s3cret", StorageSigningKey: "0ther", MailDriver: "smtp"}
+
+	tests := []struct {
+		name    string
+		change  func(*Config)
+		wantErr bool
+	}{
+		{"configured", func(*Config) {}, false},
+		{"default secret", func(c *Config) { c.JWTSecret = DefaultJWTSecret }, true},
+		{"default signing key", func(c *Config) { c.StorageSigningKey = DefaultJWTSecret }, true},
+		{"signing key reuses the secret", func(c *Config) { c.StorageSigningKey = c.JWTSecret }, true},
+		{"derived signing key", func(c *Config) { c.StorageSigningKey = "" }, false},
+		{"log mail driver", func(c *Config) { c.MailDriver = "log" }, true},
+		{"no mail driver", func(c *Config) { c.MailDriver = "" }, true},
+		{"file mail driver", func(c *Config) { c.MailDriver = "file" }, false},
+		{"private SSO issuers", func(c *Config) { c.SSOAllowPrivateIssuers = true }, true},
+		{"debug mode", func(c *Config) { c.GinMode = "debug"; c.JWTSecret = DefaultJWTSecret; c.MailDriver = "log" }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid
+			tt.change(&cfg)
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDownloadSigningKey(t *testing.T) {
+	cfg := Config{JWTSecret: "s3cret"}
+	derived := cfg.DownloadSigningKey()
+	if derived == cfg.JWTSecret || len(derived) != 32 {
+		t.Fatalf("derived key %q, want 32 bytes that differ from JWT_SECRET", derived)
+	}
+	if again := cfg.DownloadSigningKey(); again != derived {
+		t.Fatal("derived key changes between calls")
+	}
+	if other := (&Config{JWTSecret: "other"}).DownloadSigningKey(); other == derived {
+		t.Fatal("derived key does not depend on JWT_SECRET")
+	}
+
+	cfg.StorageSigningKey = "0ther"
+	if got := cfg.DownloadSigningKey(); got != "0ther" {
+		t.Fatalf("DownloadSigningKey() = %q, want STORAGE_SIGNING_KEY", got)
+	}
+}
@@ -1,9 +1,9 @@
 package database
 
 import (
+	"context"
 	"fmt"
 	"log"
-	"myway-backend/internal/models"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -25,36 +25,14 @@ func Connect(databaseURL string) error {
 	return nil
 }
 
-func AutoMigrate() error {
-	// Enable UUID extension
-	DB.Exec("CREATE EXTENSION IF NOT EXISTS \"uuid-ossp\";")
-
-	err := DB.AutoMigrate(
-		&models.User{},
-		&models.RefreshToken{},
-		&models.Organization{},
-		&models.OrgMembership{},
-		&models.Course{},
-		&models.Enrollment{},
-		&models.Module{},
-		&models.Material{},
-		&models.StudyPack{},
-		&models.Summary{},
-		&models.Quiz{},
-		&models.QuizQuestion{},
-		&models.Flashcard{},
-		&models.QuizAttempt{},
-		&models.FlashcardSession{},
-		&models.ProgressEvent{},
-		&models.Assignment{},
-		&models.Submission{},
-		&models.Thread{},
-		&models.Reply{},
-		&models.DailyOrgMetric{},
-		&models.CourseMetric{},
-	)
+// Migrate applies pending SQL migrations from internal/database/migrations.
+func Migrate() error {
+	migrator, err := NewMigrator(DB)
 	if err != nil {
-		return fmt.Errorf("failed to auto-migrate: %w", err)
+		return fmt.Errorf("failed to load migrations: %w", err)
+	}
+	if err := migrator.Up(context.Background()); err != nil {
+		return err
 	}
 
 	log.Println("Database migration completed")
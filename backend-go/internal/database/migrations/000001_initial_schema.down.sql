@@ -0,0 +1,27 @@
+DROP TABLE IF EXISTS
+    messages,
+    conversations,
+    material_chunks,
+    jobs,
+    course_metrics,
+    daily_org_metrics,
+    replies,
+    threads,
+    submissions,
+    assignments,
+    progress_events,
+    flashcard_sessions,
+    quiz_attempts,
+    flashcards,
+    quiz_questions,
+    quizzes,
+    summaries,
+    study_packs,
+    materials,
+    modules,
+    enrollments,
+    courses,
+    org_memberships,
+    organizations,
+    refresh_tokens,
+    users;
@@ -0,0 +1,324 @@
+-- Baseline schema, matching what GORM AutoMigrate created before versioned
+-- migrations. IF NOT EXISTS lets existing databases adopt it as version 1.
+
+CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
+
+CREATE TABLE IF NOT EXISTS users (
+    id uuid DEFAULT uuid_generate_v4(),
+    email text NOT NULL UNIQUE,
+    password_hash text NOT NULL,
+    name text NOT NULL,
+    role text NOT NULL DEFAULT 'STUDENT',
+    created_at timestamptz,
+    last_login timestamptz,
+    PRIMARY KEY (id)
+);
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id uuid DEFAULT uuid_generate_v4(),
+    user_id uuid NOT NULL,
+    token text NOT NULL UNIQUE,
+    expires_at timestamptz NOT NULL,
+    created_at timestamptz,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_users_refresh_tokens FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS organizations (
+    id uuid DEFAULT uuid_generate_v4(),
+    name text NOT NULL,
+    plan text DEFAULT 'Free',
+    created_at timestamptz,
+    PRIMARY KEY (id)
+);
+
+CREATE TABLE IF NOT EXISTS org_memberships (
+    id uuid DEFAULT uuid_generate_v4(),
+    org_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    role text NOT NULL,
+    status text DEFAULT 'Active',
+    PRIMARY KEY (id),
+    CONSTRAINT fk_organizations_memberships FOREIGN KEY (org_id) REFERENCES organizations(id),
+    CONSTRAINT fk_users_memberships FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS courses (
+    id uuid DEFAULT uuid_generate_v4(),
+    org_id uuid NOT NULL,
+    code text NOT NULL,
+    title text NOT NULL,
+    description text NOT NULL,
+    created_by uuid NOT NULL,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_organizations_courses FOREIGN KEY (org_id) REFERENCES organizations(id),
+    CONSTRAINT fk_users_created_courses FOREIGN KEY (created_by) REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS enrollments (
+    id uuid DEFAULT uuid_generate_v4(),
+    course_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    role text NOT NULL,
+    created_at timestamptz,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_courses_enrollments FOREIGN KEY (course_id) REFERENCES courses(id),
+    CONSTRAINT fk_users_enrollments FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS modules (
+    id uuid DEFAULT uuid_generate_v4(),
+    course_id uuid NOT NULL,
+    title text NOT NULL,
+    "order" bigint NOT NULL,
+    locked_rule text,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_courses_modules FOREIGN KEY (course_id) REFERENCES courses(id)
+);
+
+CREATE TABLE IF NOT EXISTS materials (
+    id uuid DEFAULT uuid_generate_v4(),
+    module_id uuid NOT NULL,
+    type text NOT NULL,
+    title text NOT NULL,
+    source_url text,
+    file_url text,
+    transcript_text text,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_modules_materials FOREIGN KEY (module_id) REFERENCES modules(id)
+);
+
+CREATE TABLE IF NOT EXISTS study_packs (
+    id uuid DEFAULT uuid_generate_v4(),
+    material_id uuid NOT NULL,
+    created_by text NOT NULL,
+    status text NOT NULL,
+    created_at timestamptz,
+    published_at timestamptz,
+    requires_approval boolean DEFAULT false,
+    approved_by text,
+    error_message text,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_materials_study_packs FOREIGN KEY (material_id) REFERENCES materials(id)
+);
+
+CREATE TABLE IF NOT EXISTS summaries (
+    id uuid DEFAULT uuid_generate_v4(),
+    study_pack_id uuid NOT NULL UNIQUE,
+    content jsonb NOT NULL,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_study_packs_summary FOREIGN KEY (study_pack_id) REFERENCES study_packs(id)
+);
+
+CREATE TABLE IF NOT EXISTS quizzes (
+    id uuid DEFAULT uuid_generate_v4(),
+    study_pack_id uuid NOT NULL,
+    version bigint DEFAULT 1,
+    metadata jsonb,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_study_packs_quizzes FOREIGN KEY (study_pack_id) REFERENCES study_packs(id)
+);
+
+CREATE TABLE IF NOT EXISTS quiz_questions (
+    id uuid DEFAULT uuid_generate_v4(),
+    quiz_id uuid NOT NULL,
+    type text NOT NULL,
+    prompt text NOT NULL,
+    options jsonb NOT NULL,
+    answer_key jsonb NOT NULL,
+    explanation text,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_quizzes_questions FOREIGN KEY (quiz_id) REFERENCES quizzes(id)
+);
+
+CREATE TABLE IF NOT EXISTS flashcards (
+    id uuid DEFAULT uuid_generate_v4(),
+    study_pack_id uuid NOT NULL,
+    front text NOT NULL,
+    back text NOT NULL,
+    tags jsonb,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_study_packs_flashcards FOREIGN KEY (study_pack_id) REFERENCES study_packs(id)
+);
+
+CREATE TABLE IF NOT EXISTS quiz_attempts (
+    id uuid DEFAULT uuid_generate_v4(),
+    quiz_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    score bigint NOT NULL,
+    answers jsonb NOT NULL,
+    created_at timestamptz,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_quizzes_attempts FOREIGN KEY (quiz_id) REFERENCES quizzes(id),
+    CONSTRAINT fk_users_quiz_attempts FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS flashcard_sessions (
+    id uuid DEFAULT uuid_generate_v4(),
+    study_pack_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    known_count bigint NOT NULL,
+    unknown_count bigint NOT NULL,
+    duration_sec bigint NOT NULL,
+    created_at timestamptz,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_study_packs_sessions FOREIGN KEY (study_pack_id) REFERENCES study_packs(id),
+    CONSTRAINT fk_users_flashcard_sessions FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS progress_events (
+    id uuid DEFAULT uuid_generate_v4(),
+    user_id uuid NOT NULL,
+    course_id text NOT NULL,
+    event_type text NOT NULL,
+    payload jsonb NOT NULL,
+    created_at timestamptz,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_users_progress_events FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS assignments (
+    id uuid DEFAULT uuid_generate_v4(),
+    course_id uuid NOT NULL,
+    title text NOT NULL,
+    due_at timestamptz NOT NULL,
+    points bigint NOT NULL,
+    instructions text NOT NULL,
+    status text NOT NULL,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_courses_assignments FOREIGN KEY (course_id) REFERENCES courses(id)
+);
+
+CREATE TABLE IF NOT EXISTS submissions (
+    id uuid DEFAULT uuid_generate_v4(),
+    assignment_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    status text NOT NULL,
+    file_url text,
+    submitted_at timestamptz,
+    grade text,
+    feedback text,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_assignments_submissions FOREIGN KEY (assignment_id) REFERENCES assignments(id),
+    CONSTRAINT fk_users_submissions FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS threads (
+    id uuid DEFAULT uuid_generate_v4(),
+    course_id uuid NOT NULL,
+    created_by uuid NOT NULL,
+    title text NOT NULL,
+    body text NOT NULL,
+    created_at timestamptz,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_courses_threads FOREIGN KEY (course_id) REFERENCES courses(id),
+    CONSTRAINT fk_users_threads FOREIGN KEY (created_by) REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS replies (
+    id uuid DEFAULT uuid_generate_v4(),
+    thread_id uuid NOT NULL,
+    created_by uuid NOT NULL,
+    body text NOT NULL,
+    created_at timestamptz,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_threads_replies FOREIGN KEY (thread_id) REFERENCES threads(id),
+    CONSTRAINT fk_users_replies FOREIGN KEY (created_by) REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS daily_org_metrics (
+    id uuid DEFAULT uuid_generate_v4(),
+    org_id uuid NOT NULL,
+    date timestamptz NOT NULL,
+    dau bigint NOT NULL,
+    wau bigint NOT NULL,
+    activation_rate decimal NOT NULL,
+    retention7d decimal NOT NULL,
+    runs_count bigint NOT NULL,
+    quizzes_taken bigint NOT NULL,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_organizations_daily_metrics FOREIGN KEY (org_id) REFERENCES organizations(id)
+);
+
+CREATE TABLE IF NOT EXISTS course_metrics (
+    id uuid DEFAULT uuid_generate_v4(),
+    course_id uuid NOT NULL,
+    date timestamptz NOT NULL,
+    avg_progress decimal NOT NULL,
+    avg_score decimal NOT NULL,
+    engagement_rate decimal NOT NULL,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_courses_metrics FOREIGN KEY (course_id) REFERENCES courses(id)
+);
+
+CREATE TABLE IF NOT EXISTS jobs (
+    id uuid DEFAULT uuid_generate_v4(),
+    type text NOT NULL,
+    payload jsonb NOT NULL,
+    status text NOT NULL,
+    attempts bigint NOT NULL DEFAULT 0,
+    max_attempts bigint NOT NULL DEFAULT 5,
+    run_at timestamptz NOT NULL,
+    locked_by text,
+    locked_until timestamptz,
+    last_error text,
+    progress bigint NOT NULL DEFAULT 0,
+    progress_message text,
+    material_id uuid,
+    created_at timestamptz,
+    updated_at timestamptz,
+    completed_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS idx_jobs_type ON jobs (type);
+CREATE INDEX IF NOT EXISTS idx_jobs_material_id ON jobs (material_id);
+CREATE INDEX IF NOT EXISTS idx_jobs_run_at ON jobs (run_at);
+CREATE INDEX IF NOT EXISTS idx_jobs_status ON jobs (status);
+
+CREATE TABLE IF NOT EXISTS material_chunks (
+    id uuid DEFAULT uuid_generate_v4(),
+    material_id uuid NOT NULL,
+    ordinal bigint NOT NULL,
+    source text NOT NULL,
+    content text NOT NULL,
+    start_sec decimal,
+    end_sec decimal,
+    created_at timestamptz,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_material_chunks_material FOREIGN KEY (material_id) REFERENCES materials(id)
+);
+CREATE INDEX IF NOT EXISTS idx_material_chunks_material_id ON material_chunks (material_id);
+
+CREATE TABLE IF NOT EXISTS conversations (
+    id uuid DEFAULT uuid_generate_v4(),
+    user_id uuid NOT NULL,
+    course_id uuid NOT NULL,
+    title text NOT NULL,
+    summary text,
+    summarized_count bigint NOT NULL DEFAULT 0,
+    last_message_at timestamptz NOT NULL,
+    created_at timestamptz,
+    updated_at timestamptz,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_conversations_user FOREIGN KEY (user_id) REFERENCES users(id),
+    CONSTRAINT fk_conversations_course FOREIGN KEY (course_id) REFERENCES courses(id)
+);
+CREATE INDEX IF NOT EXISTS idx_conversations_course_id ON conversations (course_id);
+CREATE INDEX IF NOT EXISTS idx_conversations_user_id ON conversations (user_id);
+
+CREATE TABLE IF NOT EXISTS messages (
+    id uuid DEFAULT uuid_generate_v4(),
+    conversation_id uuid NOT NULL,
+    role text NOT NULL,
+    content text NOT NULL,
+    source_references jsonb,
+    prompt_tokens bigint NOT NULL DEFAULT 0,
+    completion_tokens bigint NOT NULL DEFAULT 0,
+    created_at timestamptz,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_conversations_messages FOREIGN KEY (conversation_id) REFERENCES conversations(id)
+);
+CREATE INDEX IF NOT EXISTS idx_messages_conversation_id ON messages (conversation_id);
+
+-- Full-text index used by the tutor's course material retrieval
+CREATE INDEX IF NOT EXISTS idx_material_chunks_fts ON material_chunks USING GIN (to_tsvector('english', content));
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_enrollments_course_user;
+
+ALTER TABLE courses DROP COLUMN IF EXISTS enrollment_key;
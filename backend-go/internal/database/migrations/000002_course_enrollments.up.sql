@@ -0,0 +1,24 @@
+ALTER TABLE courses ADD COLUMN IF NOT EXISTS enrollment_key text;
+
+-- The seed command could enroll the same user twice.
+DELETE FROM enrollments a
+USING enrollments b
+WHERE a.course_id = b.course_id
+  AND a.user_id = b.user_id
+  AND a.ctid > b.ctid;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_enrollments_course_user ON enrollments (course_id, user_id);
+
+-- Access now follows enrollment, so keep course creators and the
+-- organization's teachers teaching the courses they could already manage.
+INSERT INTO enrollments (course_id, user_id, role, created_at)
+SELECT id, created_by, 'TEACHER', now()
+FROM courses
+ON CONFLICT (course_id, user_id) DO UPDATE SET role = 'TEACHER';
+
+INSERT INTO enrollments (course_id, user_id, role, created_at)
+SELECT c.id, m.user_id, 'TEACHER', now()
+FROM courses c
+JOIN org_memberships m ON m.org_id = c.org_id
+WHERE m.role = 'TEACHER' AND m.status = 'Active'
+ON CONFLICT (course_id, user_id) DO UPDATE SET role = 'TEACHER';
@@ -0,0 +1,36 @@
+CREATE TABLE IF NOT EXISTS flashcard_schedules (
+    id uuid DEFAULT uuid_generate_v4(),
+    flashcard_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    ease decimal NOT NULL,
+    interval_days bigint NOT NULL,
+    repetitions bigint NOT NULL,
+    lapses bigint NOT NULL,
+    due_at timestamptz NOT NULL,
+    last_reviewed_at timestamptz NOT NULL,
+    created_at timestamptz,
+    updated_at timestamptz,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_flashcard_schedules_flashcard FOREIGN KEY (flashcard_id) REFERENCES flashcards(id),
+    CONSTRAINT fk_flashcard_schedules_user FOREIGN KEY (user_id) REFERENCES users(id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_flashcard_schedules_card_user ON flashcard_schedules (flashcard_id, user_id);
+CREATE INDEX IF NOT EXISTS idx_flashcard_schedules_user_due ON flashcard_schedules (user_id, due_at);
+
+CREATE TABLE IF NOT EXISTS flashcard_reviews (
+    id uuid DEFAULT uuid_generate_v4(),
+    flashcard_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    session_id uuid,
+    grade text NOT NULL,
+    ease decimal NOT NULL,
+    interval_days bigint NOT NULL,
+    due_at timestamptz NOT NULL,
+    reviewed_at timestamptz NOT NULL,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_flashcard_reviews_flashcard FOREIGN KEY (flashcard_id) REFERENCES flashcards(id),
+    CONSTRAINT fk_flashcard_reviews_user FOREIGN KEY (user_id) REFERENCES users(id),
+    CONSTRAINT fk_flashcard_reviews_session FOREIGN KEY (session_id) REFERENCES flashcard_sessions(id)
+);
+CREATE INDEX IF NOT EXISTS idx_flashcard_reviews_flashcard_id ON flashcard_reviews (flashcard_id);
+CREATE INDEX IF NOT EXISTS idx_flashcard_reviews_user_id ON flashcard_reviews (user_id);
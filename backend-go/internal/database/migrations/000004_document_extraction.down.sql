@@ -0,0 +1,4 @@
+ALTER TABLE material_chunks DROP COLUMN IF EXISTS page;
+
+ALTER TABLE materials DROP COLUMN IF EXISTS extracted_text;
+ALTER TABLE materials DROP COLUMN IF EXISTS mime_type;
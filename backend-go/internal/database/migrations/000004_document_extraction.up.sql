@@ -0,0 +1,4 @@
+ALTER TABLE materials ADD COLUMN IF NOT EXISTS mime_type text;
+ALTER TABLE materials ADD COLUMN IF NOT EXISTS extracted_text text;
+
+ALTER TABLE material_chunks ADD COLUMN IF NOT EXISTS page bigint;
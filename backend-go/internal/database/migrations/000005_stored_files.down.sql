@@ -0,0 +1,7 @@
+ALTER TABLE submissions DROP CONSTRAINT IF EXISTS fk_submissions_file;
+ALTER TABLE submissions DROP COLUMN IF EXISTS file_id;
+
+ALTER TABLE materials DROP CONSTRAINT IF EXISTS fk_materials_file;
+ALTER TABLE materials DROP COLUMN IF EXISTS file_id;
+
+DROP TABLE IF EXISTS stored_files;
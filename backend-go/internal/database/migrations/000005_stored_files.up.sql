@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS stored_files (
+    id uuid DEFAULT uuid_generate_v4(),
+    org_id uuid NOT NULL,
+    course_id uuid NOT NULL,
+    uploaded_by uuid NOT NULL,
+    purpose text NOT NULL,
+    backend text NOT NULL,
+    storage_key text NOT NULL,
+    file_name text NOT NULL,
+    content_type text NOT NULL,
+    size bigint NOT NULL,
+    sha256 text NOT NULL,
+    created_at timestamptz,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_stored_files_organization FOREIGN KEY (org_id) REFERENCES organizations(id),
+    CONSTRAINT fk_stored_files_course FOREIGN KEY (course_id) REFERENCES courses(id),
+    CONSTRAINT fk_stored_files_uploader FOREIGN KEY (uploaded_by) REFERENCES users(id)
+);
+CREATE INDEX IF NOT EXISTS idx_stored_files_org_id ON stored_files (org_id);
+CREATE INDEX IF NOT EXISTS idx_stored_files_course_id ON stored_files (course_id);
+CREATE INDEX IF NOT EXISTS idx_stored_files_storage_key ON stored_files (storage_key);
+
+ALTER TABLE materials ADD COLUMN IF NOT EXISTS file_id uuid;
+ALTER TABLE materials ADD CONSTRAINT fk_materials_file FOREIGN KEY (file_id) REFERENCES stored_files(id);
+
+ALTER TABLE submissions ADD COLUMN IF NOT EXISTS file_id uuid;
+ALTER TABLE submissions ADD CONSTRAINT fk_submissions_file FOREIGN KEY (file_id) REFERENCES stored_files(id);
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS transcript_segments (
+    id uuid DEFAULT uuid_generate_v4(),
+    material_id uuid NOT NULL,
+    video_id text NOT NULL,
+    language text NOT NULL,
+    ordinal bigint NOT NULL,
+    start_ms bigint NOT NULL,
+    duration_ms bigint NOT NULL,
+    text text NOT NULL,
+    created_at timestamptz,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_transcript_segments_material FOREIGN KEY (material_id) REFERENCES materials(id)
+);
+CREATE INDEX IF NOT EXISTS idx_transcript_segments_material_id ON transcript_segments (material_id);
+CREATE INDEX IF NOT EXISTS idx_transcript_segments_video_id ON transcript_segments (video_id);
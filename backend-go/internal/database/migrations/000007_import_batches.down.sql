@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_materials_batch_id;
+ALTER TABLE materials DROP CONSTRAINT IF EXISTS fk_import_batches_materials;
+ALTER TABLE materials DROP COLUMN IF EXISTS batch_id;
+ALTER TABLE materials DROP COLUMN IF EXISTS duration_sec;
+
+DROP TABLE IF EXISTS import_batches;
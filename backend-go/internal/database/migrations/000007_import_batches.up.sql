@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS import_batches (
+    id uuid DEFAULT uuid_generate_v4(),
+    course_id uuid NOT NULL,
+    module_id uuid,
+    created_by uuid NOT NULL,
+    source_url text NOT NULL,
+    title text,
+    language text,
+    status text NOT NULL DEFAULT 'QUEUED',
+    error_message text,
+    video_count bigint NOT NULL DEFAULT 0,
+    skipped_count bigint NOT NULL DEFAULT 0,
+    created_at timestamptz,
+    updated_at timestamptz,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_import_batches_course FOREIGN KEY (course_id) REFERENCES courses(id),
+    CONSTRAINT fk_import_batches_module FOREIGN KEY (module_id) REFERENCES modules(id),
+    CONSTRAINT fk_import_batches_creator FOREIGN KEY (created_by) REFERENCES users(id)
+);
+CREATE INDEX IF NOT EXISTS idx_import_batches_course_id ON import_batches (course_id);
+
+ALTER TABLE materials ADD COLUMN IF NOT EXISTS duration_sec bigint;
+ALTER TABLE materials ADD COLUMN IF NOT EXISTS batch_id uuid;
+ALTER TABLE materials ADD CONSTRAINT fk_import_batches_materials FOREIGN KEY (batch_id) REFERENCES import_batches(id);
+CREATE INDEX IF NOT EXISTS idx_materials_batch_id ON materials (batch_id);
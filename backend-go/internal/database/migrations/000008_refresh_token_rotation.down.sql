@@ -0,0 +1,13 @@
+-- Raw tokens cannot be recovered from their hashes, so every session ends.
+DELETE FROM refresh_tokens;
+
+DROP INDEX IF EXISTS idx_refresh_tokens_expires_at;
+DROP INDEX IF EXISTS idx_refresh_tokens_family_id;
+DROP INDEX IF EXISTS idx_refresh_tokens_user_id;
+
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS token text NOT NULL UNIQUE;
+ALTER TABLE refresh_tokens DROP CONSTRAINT IF EXISTS uni_refresh_tokens_token_hash;
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS revoked_at;
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS used_at;
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS token_hash;
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS family_id;
@@ -0,0 +1,19 @@
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS family_id uuid;
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS token_hash text;
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS used_at timestamptz;
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS revoked_at timestamptz;
+
+-- Existing sessions keep working: each stored token becomes a family of its
+-- own and is looked up by the hash of the value the client already holds.
+UPDATE refresh_tokens
+SET family_id = id,
+    token_hash = encode(sha256(convert_to(token, 'UTF8')), 'hex');
+
+ALTER TABLE refresh_tokens ALTER COLUMN family_id SET NOT NULL;
+ALTER TABLE refresh_tokens ALTER COLUMN token_hash SET NOT NULL;
+ALTER TABLE refresh_tokens ADD CONSTRAINT uni_refresh_tokens_token_hash UNIQUE (token_hash);
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS token;
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_id ON refresh_tokens (user_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family_id ON refresh_tokens (family_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_expires_at ON refresh_tokens (expires_at);
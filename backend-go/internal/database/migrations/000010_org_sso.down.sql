@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS sso_logins;
+DROP TABLE IF EXISTS user_identities;
+DROP TABLE IF EXISTS org_identity_providers;
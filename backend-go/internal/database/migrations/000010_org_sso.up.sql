@@ -0,0 +1,48 @@
+CREATE TABLE IF NOT EXISTS org_identity_providers (
+    id uuid DEFAULT uuid_generate_v4(),
+    org_id uuid NOT NULL,
+    issuer text NOT NULL,
+    client_id text NOT NULL,
+    client_secret text NOT NULL,
+    scopes text NOT NULL DEFAULT 'email profile',
+    role_claim text,
+    role_mapping jsonb NOT NULL DEFAULT '{}',
+    default_role text NOT NULL DEFAULT 'STUDENT',
+    allowed_domains text NOT NULL DEFAULT '',
+    enabled boolean NOT NULL DEFAULT true,
+    created_at timestamptz,
+    updated_at timestamptz,
+    PRIMARY KEY (id),
+    CONSTRAINT uni_org_identity_providers_org_id UNIQUE (org_id),
+    CONSTRAINT fk_org_identity_providers_organization FOREIGN KEY (org_id) REFERENCES organizations(id)
+);
+
+CREATE TABLE IF NOT EXISTS user_identities (
+    id uuid DEFAULT uuid_generate_v4(),
+    user_id uuid NOT NULL,
+    provider_id uuid NOT NULL,
+    subject text NOT NULL,
+    email text,
+    created_at timestamptz,
+    last_login timestamptz,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_user_identities_user FOREIGN KEY (user_id) REFERENCES users(id),
+    CONSTRAINT fk_user_identities_provider FOREIGN KEY (provider_id) REFERENCES org_identity_providers(id)
+);
+CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_identities_provider_subject ON user_identities (provider_id, subject);
+
+CREATE TABLE IF NOT EXISTS sso_logins (
+    id uuid DEFAULT uuid_generate_v4(),
+    provider_id uuid NOT NULL,
+    state text NOT NULL,
+    nonce text NOT NULL,
+    code_verifier text NOT NULL,
+    return_to text,
+    expires_at timestamptz NOT NULL,
+    created_at timestamptz,
+    PRIMARY KEY (id),
+    CONSTRAINT uni_sso_logins_state UNIQUE (state),
+    CONSTRAINT fk_sso_logins_provider FOREIGN KEY (provider_id) REFERENCES org_identity_providers(id)
+);
+CREATE INDEX IF NOT EXISTS idx_sso_logins_expires_at ON sso_logins (expires_at);
@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS recovery_codes;
+
+ALTER TABLE organizations DROP COLUMN IF EXISTS require_mfa;
+
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS mfa;
+
+ALTER TABLE users DROP COLUMN IF EXISTS totp_last_step;
+ALTER TABLE users DROP COLUMN IF EXISTS mfa_enabled_at;
+ALTER TABLE users DROP COLUMN IF EXISTS totp_secret;
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS api_tokens (
+    id uuid DEFAULT uuid_generate_v4(),
+    user_id uuid NOT NULL,
+    org_id uuid,
+    name text NOT NULL,
+    prefix text NOT NULL,
+    token_hash text NOT NULL,
+    scopes text NOT NULL,
+    mfa boolean NOT NULL DEFAULT false,
+    expires_at timestamptz,
+    last_used_at timestamptz,
+    revoked_at timestamptz,
+    created_at timestamptz,
+    PRIMARY KEY (id),
+    CONSTRAINT uni_api_tokens_token_hash UNIQUE (token_hash),
+    CONSTRAINT fk_api_tokens_user FOREIGN KEY (user_id) REFERENCES users(id),
+    CONSTRAINT fk_api_tokens_organization FOREIGN KEY (org_id) REFERENCES organizations(id)
+);
+CREATE INDEX IF NOT EXISTS idx_api_tokens_user_id ON api_tokens (user_id);
+CREATE INDEX IF NOT EXISTS idx_api_tokens_org_id ON api_tokens (org_id);
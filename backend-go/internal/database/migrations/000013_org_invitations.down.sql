@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS org_invitations;
+ALTER TABLE organizations DROP COLUMN IF EXISTS allowed_domains;
+ALTER TABLE organizations DROP COLUMN IF EXISTS join_code;
@@ -0,0 +1,22 @@
+ALTER TABLE organizations ADD COLUMN IF NOT EXISTS join_code text;
+ALTER TABLE organizations ADD COLUMN IF NOT EXISTS allowed_domains text NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS org_invitations (
+    id uuid DEFAULT uuid_generate_v4(),
+    org_id uuid NOT NULL,
+    email text NOT NULL,
+    role text NOT NULL,
+    token_hash text NOT NULL,
+    invited_by uuid NOT NULL,
+    expires_at timestamptz NOT NULL,
+    sent_at timestamptz NOT NULL,
+    accepted_at timestamptz,
+    accepted_by uuid,
+    revoked_at timestamptz,
+    created_at timestamptz,
+    PRIMARY KEY (id),
+    CONSTRAINT uni_org_invitations_token_hash UNIQUE (token_hash),
+    CONSTRAINT fk_org_invitations_organization FOREIGN KEY (org_id) REFERENCES organizations(id),
+    CONSTRAINT fk_org_invitations_inviter FOREIGN KEY (invited_by) REFERENCES users(id)
+);
+CREATE INDEX IF NOT EXISTS idx_org_invitations_org_id ON org_invitations (org_id);
@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS audit_events (
+    id uuid DEFAULT uuid_generate_v4(),
+    org_id uuid NOT NULL,
+    actor_id uuid NOT NULL,
+    actor_email text NOT NULL,
+    api_token_id uuid,
+    action text NOT NULL,
+    target_type text NOT NULL,
+    target_id uuid NOT NULL,
+    before jsonb,
+    after jsonb,
+    ip text NOT NULL DEFAULT '',
+    user_agent text NOT NULL DEFAULT '',
+    created_at timestamptz NOT NULL,
+    PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS idx_audit_events_org_id_created_at ON audit_events (org_id, created_at);
+
+-- The log is append-only: rows can be added but never changed or removed.
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_events_append_only ON audit_events;
+CREATE TRIGGER audit_events_append_only
+    BEFORE UPDATE OR DELETE OR TRUNCATE ON audit_events
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_events_append_only();
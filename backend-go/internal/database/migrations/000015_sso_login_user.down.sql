@@ -0,0 +1,2 @@
+ALTER TABLE sso_logins DROP CONSTRAINT IF EXISTS fk_sso_logins_user;
+ALTER TABLE sso_logins DROP COLUMN IF EXISTS user_id;
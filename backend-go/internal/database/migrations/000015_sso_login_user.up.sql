@@ -0,0 +1,2 @@
+ALTER TABLE sso_logins ADD COLUMN IF NOT EXISTS user_id uuid;
+ALTER TABLE sso_logins ADD CONSTRAINT fk_sso_logins_user FOREIGN KEY (user_id) REFERENCES users(id);
@@ -0,0 +1,4 @@
+-- Emails are stored lower-cased and looked up with lower(email), so accounts
+-- must also be unique regardless of case. Accounts that differ only in case
+-- have to be merged before this migration can run.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_lower ON users (lower(email));
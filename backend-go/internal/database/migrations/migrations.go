@@ -0,0 +1,8 @@
+// Package migrations embeds the numbered SQL migrations into every binary.
+// Files are named NNNNNN_description.up.sql and NNNNNN_description.down.sql.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,170 @@
+// Package extract pulls plain text out of uploaded course documents, keeping
+// page and slide boundaries so study packs and tutor citations can point
+// back to them.
+package extract
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+const (
+	KindPDF      = "PDF"
+	KindDOCX     = "DOCX"
+	KindPPTX     = "PPTX"
+	KindMarkdown = "MARKDOWN"
+	KindHTML     = "HTML"
+	KindText     = "TEXT"
+
+	kindCompound = "COMPOUND"
+)
+
+var (
+	ErrUnsupported = errors.New("unsupported document type")
+	ErrEncrypted   = errors.New("document is password protected")
+	// ErrNoText means the document parsed but holds no extractable text,
+	// typically a scanned PDF without a text layer.
+	ErrNoText = errors.New("document has no extractable text")
+)
+
+// Section is one page, slide or, for unpaged formats, the whole document.
+type Section struct {
+	// Label is "Page" or "Slide"; empty when the format has no pages.
+	Label  string
+	Number int
+	Text   string
+}
+
+type Document struct {
+	Kind     string
+	MimeType string
+	Sections []Section
+}
+
+// Text joins the sections, starting each page or slide with a marker line
+// such as "[Page 3]" that retrieval uses to cite the location.
+func (d *Document) Text() string {
+	var sb strings.Builder
+	for _, section := range d.Sections {
+		text := strings.TrimSpace(section.Text)
+		if text == "" {
+			continue
+		}
+		if sb.Len() > 0 {
+			sb.WriteString("\n\n")
+		}
+		if section.Label != "" {
+			sb.WriteString(SectionMarker(section.Label, section.Number) + "\n")
+		}
+		sb.WriteString(text)
+	}
+	return sb.String()
+}
+
+// SectionMarker formats the marker line that starts a page or slide.
+func SectionMarker(label string, number int) string {
+	return "[" + label + " " + strconv.Itoa(number) + "]"
+}
+
+// Detect sniffs the document kind from its content, falling back to the file
+// name and declared content type for text formats that have no signature.
+func Detect(data []byte, name, contentType string) (kind, mimeType string) {
+	detected := mimetype.Detect(data)
+	mimeType = detected.String()
+
+	switch {
+	case detected.Is("application/pdf"):
+		return KindPDF, mimeType
+	case detected.Is("application/vnd.openxmlformats-officedocument.wordprocessingml.document"):
+		return KindDOCX, mimeType
+	case detected.Is("application/vnd.openxmlformats-officedocument.presentationml.presentation"):
+		return KindPPTX, mimeType
+	case detected.Is("text/html"):
+		return KindHTML, mimeType
+	}
+
+	for m := detected; m != nil; m = m.Parent() {
+		if m.Is("application/x-ole-storage") {
+			// Legacy .doc/.ppt files and password-protected OOXML files
+			// are both compound files.
+			return kindCompound, mimeType
+		}
+	}
+	if !strings.HasPrefix(mimeType, "text/") {
+		return "", mimeType
+	}
+	ext := strings.ToLower(path.Ext(name))
+	declared := strings.ToLower(contentType)
+	switch {
+	case ext == ".md" || ext == ".markdown" || strings.HasPrefix(declared, "text/markdown"):
+		return KindMarkdown, "text/markdown"
+	case ext == ".html" || ext == ".htm" || strings.HasPrefix(declared, "text/html"):
+		return KindHTML, "text/html"
+	}
+	return KindText, mimeType
+}
+
+// Extract detects the type of data and extracts its text. name and
+// contentType are hints used only for formats without a file signature.
+func Extract(data []byte, name, contentType string) (*Document, error) {
+	kind, mimeType := Detect(data, name, contentType)
+
+	var sections []Section
+	var err error
+	switch kind {
+	case KindPDF:
+		sections, err = extractPDF(data)
+	case KindDOCX:
+		sections, err = extractDOCX(data)
+	case KindPPTX:
+		sections, err = extractPPTX(data)
+	case KindMarkdown:
+		sections = []Section{{Text: markdownText(string(data))}}
+	case KindHTML:
+		sections, err = extractHTML(data)
+	case KindText:
+		sections = []Section{{Text: normalizeText(string(data))}}
+	case kindCompound:
+		return nil, fmt.Errorf("%w: legacy .doc/.ppt files and password-protected Office files cannot be read; save it as PDF, DOCX or PPTX", ErrUnsupported)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupported, mimeType)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &Document{Kind: kind, MimeType: mimeType, Sections: sections}
+	if strings.TrimSpace(doc.Text()) == "" {
+		if kind == KindPDF {
+			return nil, fmt.Errorf("%w: the PDF has no text layer, so it is probably scanned and needs OCR first", ErrNoText)
+		}
+		return nil, ErrNoText
+	}
+	return doc, nil
+}
+
+// normalizeText trims every line and collapses runs of blank lines and
+// spaces.
+func normalizeText(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	var lines []string
+	blank := false
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.Join(strings.Fields(line), " ")
+		if line == "" {
+			blank = len(lines) > 0
+			continue
+		}
+		if blank {
+			lines = append(lines, "")
+			blank = false
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,99 @@
+package extract
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func readFixture(t testing.TB, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// TestExtractFixtures checks the sections of small documents. pages.pdf
+// numbers its page objects against reading order, nests its page tree and
+// splits a page over two content streams; slides.pptx lists its slides out
+// of file order.
+func TestExtractFixtures(t *testing.T) {
+	for _, tc := range []struct {
+		file     string
+		kind     string
+		sections []Section
+	}{
+		{"pages.pdf", KindPDF, []Section{
+			{Label: "Page", Number: 1, Text: "Binary search\nhalves a sorted range on every comparison."},
+			{Label: "Page", Number: 2, Text: "It needs about log n\ncomparisons (for n items)."},
+			{Label: "Page", Number: 3, Text: "Page three starts here\nand continues in a second stream."},
+		}},
+		{"paragraphs.docx", KindDOCX, []Section{
+			{Label: "Page", Number: 1, Text: "Sorting algorithms\nMerge sort splits the list, sorts both halves and merges them.\nQuick sort & heap sort"},
+			{Label: "Page", Number: 2, Text: "Searching\nBinary search needs sorted input."},
+		}},
+		{"slides.pptx", KindPPTX, []Section{
+			{Label: "Slide", Number: 1, Text: "Searching\nFinding an item in a list\n\nNotes: Ask who has seen a phone book."},
+			{Label: "Slide", Number: 2, Text: "Binary search\nHalve the range\nCompare with the middle"},
+			{Label: "Slide", Number: 3, Text: "Summary\nO(log n) comparisons"},
+		}},
+	} {
+		t.Run(tc.file, func(t *testing.T) {
+			// The name is only a hint: kinds are sniffed from the content
+			doc, err := Extract(readFixture(t, tc.file), "upload", "application/octet-stream")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if doc.Kind != tc.kind {
+				t.Errorf("kind = %s, want %s", doc.Kind, tc.kind)
+			}
+			if !reflect.DeepEqual(doc.Sections, tc.sections) {
+				t.Errorf("sections =\n%#v\nwant\n%#v", doc.Sections, tc.sections)
+			}
+		})
+	}
+}
+
+func TestExtractScannedPDF(t *testing.T) {
+	_, err := Extract(readFixture(t, "scanned.pdf"), "scan.pdf", "application/pdf")
+	if !errors.Is(err, ErrNoText) {
+		t.Fatalf("err = %v, want ErrNoText", err)
+	}
+}
+
+func TestDocumentText(t *testing.T) {
+	doc := &Document{Sections: []Section{
+		{Label: "Page", Number: 1, Text: " First page "},
+		{Label: "Page", Number: 2, Text: "\n"},
+		{Label: "Page", Number: 3, Text: "Third page"},
+	}}
+	if got, want := doc.Text(), "[Page 1]\nFirst page\n\n[Page 3]\nThird page"; got != want {
+		t.Fatalf("Text() = %q, want %q", got, want)
+	}
+}
+
+// FuzzExtractPDF feeds arbitrary bytes to the PDF parser, which reads
+// untrusted uploads. It must not panic or hang, and pages it returns are
+// numbered in order.
+func FuzzExtractPDF(f *testing.F) {
+	f.Add(readFixture(f, "pages.pdf"))
+	f.Add(readFixture(f, "scanned.pdf"))
+	f.Add([]byte("%PDF-1.4\n1 0 obj << /Type /Page /Contents 2 0 R >> endobj\n2 0 obj << /Length 99 >> stream\nBT (a) Tj ET\nendstream endobj"))
+	f.Add([]byte("%PDF-1.7\n1 0 obj << /Type /ObjStm /N 1 /First 4 /Length 20 >>\nstream\n2 0 << /Kids [2 0 R] >>\nendstream\nendobj\ntrailer << /Root 2 0 R >>"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		sections, err := extractPDF(data)
+		if err != nil {
+			return
+		}
+		for i, section := range sections {
+			if section.Label != "Page" || section.Number != i+1 {
+				t.Fatalf("section %d is %s %d", i, section.Label, section.Number)
+			}
+		}
+	})
+}
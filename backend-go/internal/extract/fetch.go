@@ -0,0 +1,86 @@
+package extract
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"myway-backend/internal/safehttp"
+	"net/http"
+	"net/url"
+	"path"
+	"time"
+)
+
+// MaxFileSize caps downloaded documents.
+const MaxFileSize = 50 << 20
+
+// File is a downloaded document.
+type File struct {
+	Data        []byte
+	Name        string
+	ContentType string
+}
+
+// HTTPError is returned for non-2xx responses. 4xx responses will not
+// succeed on retry.
+type HTTPError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("download %s: HTTP %d", e.URL, e.StatusCode)
+}
+
+func (e *HTTPError) Permanent() bool {
+	return e.StatusCode >= 400 && e.StatusCode < 500
+}
+
+// httpClient fetches URLs given by course staff, so it only connects to
+// public addresses and re-checks every redirect.
+var httpClient = safehttp.NewClient(safehttp.Options{Timeout: 2 * time.Minute, MaxRedirects: 5})
+
+// Fetch downloads a document over HTTP(S) from a public address. URLs that
+// lead to this host or its internal network fail with ErrUnsupported.
+func Fetch(ctx context.Context, rawURL string) (*File, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return nil, fmt.Errorf("%w: only http and https file URLs can be downloaded", ErrUnsupported)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		if errors.Is(err, safehttp.ErrDisallowed) {
+			return nil, fmt.Errorf("%w: %w", ErrUnsupported, err)
+		}
+		return nil, fmt.Errorf("download %s: %w", rawURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &HTTPError{URL: rawURL, StatusCode: resp.StatusCode}
+	}
+	if resp.ContentLength > MaxFileSize {
+		return nil, fmt.Errorf("%w: file is larger than %d MB", ErrUnsupported, MaxFileSize>>20)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, MaxFileSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("download %s: %w", rawURL, err)
+	}
+	if len(data) > MaxFileSize {
+		return nil, fmt.Errorf("%w: file is larger than %d MB", ErrUnsupported, MaxFileSize>>20)
+	}
+
+	name := path.Base(parsed.Path)
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+		name = params["filename"]
+	}
+	return &File{Data: data, Name: name, ContentType: resp.Header.Get("Content-Type")}, nil
+}
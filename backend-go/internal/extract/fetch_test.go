@@ -0,0 +1,22 @@
+package extract
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFetchRefusesInternalAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request reached the server")
+	}))
+	defer server.Close()
+
+	for _, target := range []string{server.URL, "http://169.254.169.254/latest/meta-data/", "file:///etc/passwd"} {
+		if _, err := Fetch(context.Background(), target); !errors.Is(err, ErrUnsupported) {
+			t.Errorf("Fetch(%s) error = %v, want ErrUnsupported", target, err)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package extract
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+var (
+	markdownImage    = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	markdownLink     = regexp.MustCompile(`\[([^\]]+)\]\([^)]*\)`)
+	markdownHeading  = regexp.MustCompile(`^\s{0,3}#{1,6}\s+`)
+	markdownEmphasis = regexp.MustCompile("\\*\\*|__|`")
+	markdownFence    = regexp.MustCompile("^\\s*(```|~~~)")
+	markdownRule     = regexp.MustCompile(`^\s*([-*_]\s*){3,}$`)
+)
+
+// markdownText strips the markup that would read as noise, keeping headings
+// as their own lines and link and image text in place.
+func markdownText(source string) string {
+	var lines []string
+	for _, line := range strings.Split(strings.ReplaceAll(source, "\r\n", "\n"), "\n") {
+		if markdownFence.MatchString(line) || markdownRule.MatchString(line) {
+			continue
+		}
+		line = markdownHeading.ReplaceAllString(line, "")
+		line = markdownImage.ReplaceAllString(line, "$1")
+		line = markdownLink.ReplaceAllString(line, "$1")
+		line = markdownEmphasis.ReplaceAllString(line, "")
+		lines = append(lines, line)
+	}
+	return normalizeText(strings.Join(lines, "\n"))
+}
+
+// htmlSkipped elements hold no readable text.
+var htmlSkipped = map[string]bool{
+	"head": true, "script": true, "style": true, "noscript": true,
+	"template": true, "svg": true, "iframe": true, "nav": true,
+}
+
+// htmlBlocks end a line of text.
+var htmlBlocks = map[string]bool{
+	"p": true, "div": true, "br": true, "li": true, "tr": true, "hr": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"section": true, "article": true, "header": true, "footer": true, "main": true,
+	"blockquote": true, "pre": true, "table": true, "ul": true, "ol": true,
+	"dt": true, "dd": true, "figcaption": true,
+}
+
+func extractHTML(data []byte) ([]Section, error) {
+	var sb strings.Builder
+	skipDepth := 0
+	tokenizer := html.NewTokenizer(bytes.NewReader(data))
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			if err := tokenizer.Err(); err != io.EOF {
+				return nil, err
+			}
+			return []Section{{Text: normalizeText(sb.String())}}, nil
+		}
+
+		token := tokenizer.Token()
+		switch tokenType {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			if htmlSkipped[token.Data] && tokenType == html.StartTagToken {
+				skipDepth++
+			}
+			if htmlBlocks[token.Data] {
+				sb.WriteString("\n")
+			}
+			if token.Data == "td" || token.Data == "th" {
+				sb.WriteString(" ")
+			}
+		case html.EndTagToken:
+			if htmlSkipped[token.Data] && skipDepth > 0 {
+				skipDepth--
+			}
+			if htmlBlocks[token.Data] {
+				sb.WriteString("\n")
+			}
+		case html.TextToken:
+			if skipDepth == 0 {
+				sb.WriteString(token.Data)
+			}
+		}
+	}
+}
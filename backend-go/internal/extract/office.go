@@ -0,0 +1,283 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxPartSize caps each decompressed XML part so a zip bomb cannot exhaust
+// memory.
+const maxPartSize = 64 << 20
+
+func openZip(data []byte) (*zip.Reader, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("read office document: %w", err)
+	}
+	return archive, nil
+}
+
+func readPart(archive *zip.Reader, name string) ([]byte, error) {
+	for _, file := range archive.File {
+		if file.Name != name {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return io.ReadAll(io.LimitReader(rc, maxPartSize))
+	}
+	return nil, fmt.Errorf("office document has no %s", name)
+}
+
+// extractDOCX reads word/document.xml. Word records where it last broke
+// pages, so those breaks and explicit page breaks start a new section.
+func extractDOCX(data []byte) ([]Section, error) {
+	archive, err := openZip(data)
+	if err != nil {
+		return nil, err
+	}
+	part, err := readPart(archive, "word/document.xml")
+	if err != nil {
+		return nil, err
+	}
+
+	var pages []string
+	var sb strings.Builder
+	inText := false
+	decoder := xml.NewDecoder(bytes.NewReader(part))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse document.xml: %w", err)
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				sb.WriteString("\t")
+			case "br":
+				if attr(t, "type") == "page" {
+					pages = append(pages, sb.String())
+					sb.Reset()
+				} else {
+					sb.WriteString("\n")
+				}
+			case "lastRenderedPageBreak":
+				pages = append(pages, sb.String())
+				sb.Reset()
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p", "tr":
+				sb.WriteString("\n")
+			case "tc":
+				sb.WriteString("\t")
+			}
+		case xml.CharData:
+			if inText {
+				sb.Write(t)
+			}
+		}
+	}
+	pages = append(pages, sb.String())
+
+	var sections []Section
+	for _, page := range pages {
+		if text := normalizeText(page); text != "" {
+			sections = append(sections, Section{Label: "Page", Number: len(sections) + 1, Text: text})
+		}
+	}
+	if len(sections) == 1 {
+		sections[0].Label = ""
+	}
+	return sections, nil
+}
+
+var slidePart = regexp.MustCompile(`^ppt/slides/slide(\d+)\.xml$`)
+
+// extractPPTX reads the slides in presentation order, appending each
+// slide's speaker notes.
+func extractPPTX(data []byte) ([]Section, error) {
+	archive, err := openZip(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var sections []Section
+	for i, slide := range slideOrder(archive) {
+		part, err := readPart(archive, slide)
+		if err != nil {
+			return nil, err
+		}
+		text, err := drawingText(part)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s: %w", slide, err)
+		}
+		if notes := slideNotes(archive, slide); notes != "" {
+			text += "\n\nNotes: " + notes
+		}
+		sections = append(sections, Section{Label: "Slide", Number: i + 1, Text: normalizeText(text)})
+	}
+	return sections, nil
+}
+
+type relationships struct {
+	Items []struct {
+		ID     string `xml:"Id,attr"`
+		Type   string `xml:"Type,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+func readRels(archive *zip.Reader, name string) map[string]struct{ Type, Target string } {
+	part, err := readPart(archive, name)
+	if err != nil {
+		return nil
+	}
+	var rels relationships
+	if xml.Unmarshal(part, &rels) != nil {
+		return nil
+	}
+	result := make(map[string]struct{ Type, Target string }, len(rels.Items))
+	for _, rel := range rels.Items {
+		result[rel.ID] = struct{ Type, Target string }{rel.Type, rel.Target}
+	}
+	return result
+}
+
+// slideOrder lists slide parts in the order of presentation.xml, falling back
+// to their file numbers.
+func slideOrder(archive *zip.Reader) []string {
+	var presentation struct {
+		Slides []struct {
+			RelID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sldIdLst>sldId"`
+	}
+	rels := readRels(archive, "ppt/_rels/presentation.xml.rels")
+	if part, err := readPart(archive, "ppt/presentation.xml"); err == nil && rels != nil &&
+		xml.Unmarshal(part, &presentation) == nil && len(presentation.Slides) > 0 {
+		var slides []string
+		for _, slide := range presentation.Slides {
+			if rel, ok := rels[slide.RelID]; ok {
+				slides = append(slides, partPath("ppt", rel.Target))
+			}
+		}
+		if len(slides) > 0 {
+			return slides
+		}
+	}
+
+	type numbered struct {
+		name   string
+		number int
+	}
+	var found []numbered
+	for _, file := range archive.File {
+		if m := slidePart.FindStringSubmatch(file.Name); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			found = append(found, numbered{file.Name, n})
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].number < found[j].number })
+	slides := make([]string, len(found))
+	for i, f := range found {
+		slides[i] = f.name
+	}
+	return slides
+}
+
+func slideNotes(archive *zip.Reader, slide string) string {
+	dir, file := path.Split(slide)
+	for _, rel := range readRels(archive, dir+"_rels/"+file+".rels") {
+		if !strings.HasSuffix(rel.Type, "/notesSlide") {
+			continue
+		}
+		part, err := readPart(archive, partPath(dir, rel.Target))
+		if err != nil {
+			return ""
+		}
+		text, err := drawingText(part)
+		if err != nil {
+			return ""
+		}
+		return normalizeText(text)
+	}
+	return ""
+}
+
+// drawingText collects the a:t runs of DrawingML text, one line per
+// paragraph. Fields such as the slide number are skipped.
+func drawingText(part []byte) (string, error) {
+	var sb strings.Builder
+	inText, inField := false, false
+	decoder := xml.NewDecoder(bytes.NewReader(part))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return sb.String(), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "fld":
+				inField = true
+			case "br":
+				sb.WriteString("\n")
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "fld":
+				inField = false
+			case "p":
+				sb.WriteString("\n")
+			}
+		case xml.CharData:
+			if inText && !inField {
+				sb.Write(t)
+			}
+		}
+	}
+}
+
+// partPath resolves a relationship target, which is relative to the source
+// part's folder unless it starts at the package root.
+func partPath(dir, target string) string {
+	if strings.HasPrefix(target, "/") {
+		return strings.TrimPrefix(target, "/")
+	}
+	return path.Join(dir, target)
+}
+
+func attr(element xml.StartElement, local string) string {
+	for _, a := range element.Attr {
+		if a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,618 @@
+package extract
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/ascii85"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// The PDF reader below understands just enough of the format to pull text
+// out of the page content streams: indirect objects (including those packed
+// in object streams), the page tree, Flate/ASCII filters and the font
+// encodings handled in pdffont.go. It scans the file for objects instead of
+// trusting the cross-reference table, which also copes with damaged files.
+
+const (
+	maxPDFPages       = 2000
+	maxStreamSize     = 64 << 20
+	maxResolveDepth   = 32
+	maxXObjectNesting = 8
+)
+
+type pdfName string
+
+type pdfRef struct {
+	num, gen int
+}
+
+type pdfDict map[pdfName]interface{}
+
+type pdfArray []interface{}
+
+type pdfString []byte
+
+// pdfKeyword is a bare word: an operator inside content streams or a
+// structural keyword such as "R" or "stream" in the file body.
+type pdfKeyword string
+
+type pdfStream struct {
+	dict pdfDict
+	raw  []byte
+}
+
+// pdfDelim marks the end of an array or dictionary while parsing.
+type pdfDelim string
+
+type pdfLexer struct {
+	data []byte
+	pos  int
+}
+
+var errPDFEnd = errors.New("unexpected end of PDF data")
+
+func isPDFSpace(c byte) bool {
+	return c == 0 || c == '\t' || c == '\n' || c == '\f' || c == '\r' || c == ' '
+}
+
+func isPDFDelimiter(c byte) bool {
+	switch c {
+	case '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return true
+	}
+	return false
+}
+
+func (lx *pdfLexer) skipSpace() {
+	for lx.pos < len(lx.data) {
+		c := lx.data[lx.pos]
+		if c == '%' {
+			for lx.pos < len(lx.data) && lx.data[lx.pos] != '\n' && lx.data[lx.pos] != '\r' {
+				lx.pos++
+			}
+			continue
+		}
+		if !isPDFSpace(c) {
+			return
+		}
+		lx.pos++
+	}
+}
+
+// token reads one primitive value, keyword or delimiter.
+func (lx *pdfLexer) token() (interface{}, error) {
+	lx.skipSpace()
+	if lx.pos >= len(lx.data) {
+		return nil, errPDFEnd
+	}
+
+	c := lx.data[lx.pos]
+	switch {
+	case c == '/':
+		lx.pos++
+		return pdfName(lx.word()), nil
+	case c == '(':
+		lx.pos++
+		return lx.literalString(), nil
+	case c == '<':
+		if lx.pos+1 < len(lx.data) && lx.data[lx.pos+1] == '<' {
+			lx.pos += 2
+			return pdfDelim("<<"), nil
+		}
+		lx.pos++
+		return lx.hexString(), nil
+	case c == '>':
+		if lx.pos+1 < len(lx.data) && lx.data[lx.pos+1] == '>' {
+			lx.pos += 2
+			return pdfDelim(">>"), nil
+		}
+		lx.pos++
+		return lx.token()
+	case c == '[' || c == ']' || c == '{' || c == '}':
+		lx.pos++
+		return pdfDelim(string(c)), nil
+	case c == ')':
+		lx.pos++
+		return lx.token()
+	}
+
+	word := lx.word()
+	if word == "" {
+		lx.pos++
+		return lx.token()
+	}
+	if n, err := strconv.ParseFloat(word, 64); err == nil {
+		return n, nil
+	}
+	switch word {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	return pdfKeyword(word), nil
+}
+
+func (lx *pdfLexer) word() string {
+	start := lx.pos
+	for lx.pos < len(lx.data) && !isPDFSpace(lx.data[lx.pos]) && !isPDFDelimiter(lx.data[lx.pos]) {
+		lx.pos++
+	}
+	word := lx.data[start:lx.pos]
+	if bytes.IndexByte(word, '#') < 0 {
+		return string(word)
+	}
+	// Names may escape bytes as #xx.
+	var out []byte
+	for i := 0; i < len(word); i++ {
+		if word[i] == '#' && i+2 < len(word) {
+			if b, err := hex.DecodeString(string(word[i+1 : i+3])); err == nil {
+				out = append(out, b[0])
+				i += 2
+				continue
+			}
+		}
+		out = append(out, word[i])
+	}
+	return string(out)
+}
+
+func (lx *pdfLexer) literalString() pdfString {
+	var out []byte
+	depth := 1
+	for lx.pos < len(lx.data) {
+		c := lx.data[lx.pos]
+		lx.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return out
+			}
+		case '\\':
+			if lx.pos >= len(lx.data) {
+				return out
+			}
+			e := lx.data[lx.pos]
+			lx.pos++
+			switch e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				if lx.pos < len(lx.data) && lx.data[lx.pos] == '\n' {
+					lx.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if e >= '0' && e <= '7' {
+					value := int(e - '0')
+					for i := 0; i < 2 && lx.pos < len(lx.data) && lx.data[lx.pos] >= '0' && lx.data[lx.pos] <= '7'; i++ {
+						value = value*8 + int(lx.data[lx.pos]-'0')
+						lx.pos++
+					}
+					c = byte(value)
+				} else {
+					c = e
+				}
+			}
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+func (lx *pdfLexer) hexString() pdfString {
+	var digits []byte
+	for lx.pos < len(lx.data) && lx.data[lx.pos] != '>' {
+		if c := lx.data[lx.pos]; !isPDFSpace(c) {
+			digits = append(digits, c)
+		}
+		lx.pos++
+	}
+	lx.pos++
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	out := make([]byte, len(digits)/2)
+	n, _ := hex.Decode(out, digits)
+	return out[:n]
+}
+
+// object reads a complete value, assembling arrays, dictionaries and, when
+// refs is set, "num gen R" references.
+func (lx *pdfLexer) object(refs bool) (interface{}, error) {
+	tok, err := lx.token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := tok.(type) {
+	case pdfDelim:
+		switch t {
+		case "[":
+			var array pdfArray
+			for {
+				item, err := lx.object(refs)
+				if err != nil {
+					return array, err
+				}
+				if d, ok := item.(pdfDelim); ok {
+					if d == "]" {
+						return array, nil
+					}
+					continue
+				}
+				array = append(array, item)
+			}
+		case "<<":
+			dict := make(pdfDict)
+			for {
+				key, err := lx.object(refs)
+				if err != nil {
+					return dict, err
+				}
+				if d, ok := key.(pdfDelim); ok && d == ">>" {
+					return dict, nil
+				}
+				name, ok := key.(pdfName)
+				if !ok {
+					continue
+				}
+				value, err := lx.object(refs)
+				if err != nil {
+					return dict, err
+				}
+				if d, ok := value.(pdfDelim); ok && d == ">>" {
+					return dict, nil
+				}
+				dict[name] = value
+			}
+		}
+		return t, nil
+	case float64:
+		if !refs || t != float64(int(t)) {
+			return t, nil
+		}
+		// Look ahead for "gen R".
+		save := lx.pos
+		gen, err := lx.token()
+		if g, ok := gen.(float64); err == nil && ok {
+			if r, err := lx.token(); err == nil && r == pdfKeyword("R") {
+				return pdfRef{num: int(t), gen: int(g)}, nil
+			}
+		}
+		lx.pos = save
+		return t, nil
+	}
+	return tok, nil
+}
+
+type pdfFile struct {
+	objects map[int]interface{}
+	root    pdfDict
+}
+
+var (
+	pdfObjectHeader = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+	pdfTrailer      = regexp.MustCompile(`trailer\s*<<`)
+	pdfEncrypt      = regexp.MustCompile(`/Encrypt\s*(\d+\s+\d+\s+R|<<)`)
+)
+
+func parsePDF(data []byte) (*pdfFile, error) {
+	header := data
+	if len(header) > 1024 {
+		header = header[:1024]
+	}
+	if !bytes.Contains(header, []byte("%PDF-")) {
+		return nil, errors.New("not a PDF file")
+	}
+	if pdfEncrypt.Match(data) {
+		return nil, ErrEncrypted
+	}
+
+	file := &pdfFile{objects: make(map[int]interface{})}
+	var objectStreams []*pdfStream
+	for _, match := range pdfObjectHeader.FindAllSubmatchIndex(data, -1) {
+		num, _ := strconv.Atoi(string(data[match[2]:match[3]]))
+		lx := &pdfLexer{data: data, pos: match[1]}
+		obj, err := lx.object(true)
+		if err != nil {
+			continue
+		}
+		if dict, ok := obj.(pdfDict); ok {
+			if stream := readStreamBody(lx, dict); stream != nil {
+				obj = stream
+				if dict["Type"] == pdfName("ObjStm") {
+					objectStreams = append(objectStreams, stream)
+				}
+				if dict["Type"] == pdfName("XRef") {
+					if root, ok := dict["Root"]; ok {
+						file.root = pdfDict{"Root": root}
+					}
+				}
+			}
+		}
+		// Later definitions win, as with incremental updates.
+		file.objects[num] = obj
+	}
+
+	for _, stream := range objectStreams {
+		file.unpackObjectStream(stream)
+	}
+
+	for _, loc := range pdfTrailer.FindAllIndex(data, -1) {
+		lx := &pdfLexer{data: data, pos: loc[1] - 2}
+		if trailer, err := lx.object(true); err == nil {
+			if dict, ok := trailer.(pdfDict); ok && dict["Root"] != nil {
+				file.root = dict
+			}
+		}
+	}
+	return file, nil
+}
+
+// readStreamBody reads the stream that follows a dictionary, if any. It uses
+// /Length when it is direct and plausible, and otherwise searches for
+// "endstream".
+func readStreamBody(lx *pdfLexer, dict pdfDict) *pdfStream {
+	lx.skipSpace()
+	if !bytes.HasPrefix(lx.data[lx.pos:], []byte("stream")) {
+		return nil
+	}
+	start := lx.pos + len("stream")
+	if start < len(lx.data) && lx.data[start] == '\r' {
+		start++
+	}
+	if start < len(lx.data) && lx.data[start] == '\n' {
+		start++
+	}
+
+	if length, ok := dict["Length"].(float64); ok {
+		end := start + int(length)
+		if end <= len(lx.data) && end >= start {
+			rest := bytes.TrimLeft(lx.data[end:min(end+16, len(lx.data))], "\r\n \t")
+			if bytes.HasPrefix(rest, []byte("endstream")) {
+				return &pdfStream{dict: dict, raw: lx.data[start:end]}
+			}
+		}
+	}
+
+	end := bytes.Index(lx.data[start:], []byte("endstream"))
+	if end < 0 {
+		return &pdfStream{dict: dict, raw: lx.data[start:]}
+	}
+	raw := lx.data[start : start+end]
+	raw = bytes.TrimSuffix(raw, []byte("\n"))
+	raw = bytes.TrimSuffix(raw, []byte("\r"))
+	return &pdfStream{dict: dict, raw: raw}
+}
+
+func (f *pdfFile) unpackObjectStream(stream *pdfStream) {
+	data, err := f.decode(stream)
+	if err != nil {
+		return
+	}
+	count, _ := f.resolve(stream.dict["N"]).(float64)
+	first, _ := f.resolve(stream.dict["First"]).(float64)
+	if int(first) > len(data) {
+		return
+	}
+
+	lx := &pdfLexer{data: data[:int(first)]}
+	for i := 0; i < int(count); i++ {
+		num, err1 := lx.token()
+		offset, err2 := lx.token()
+		n, ok1 := num.(float64)
+		off, ok2 := offset.(float64)
+		if err1 != nil || err2 != nil || !ok1 || !ok2 {
+			return
+		}
+		if _, exists := f.objects[int(n)]; exists {
+			continue
+		}
+		body := &pdfLexer{data: data, pos: int(first) + int(off)}
+		if body.pos >= len(data) {
+			continue
+		}
+		if obj, err := body.object(true); err == nil {
+			f.objects[int(n)] = obj
+		}
+	}
+}
+
+// resolve follows references to the object they point at.
+func (f *pdfFile) resolve(obj interface{}) interface{} {
+	for i := 0; i < maxResolveDepth; i++ {
+		ref, ok := obj.(pdfRef)
+		if !ok {
+			return obj
+		}
+		obj = f.objects[ref.num]
+	}
+	return nil
+}
+
+func (f *pdfFile) dict(obj interface{}) pdfDict {
+	switch v := f.resolve(obj).(type) {
+	case pdfDict:
+		return v
+	case *pdfStream:
+		return v.dict
+	}
+	return nil
+}
+
+// decode applies the stream's filters. Image filters are not supported and
+// return an error.
+func (f *pdfFile) decode(stream *pdfStream) ([]byte, error) {
+	var filters []interface{}
+	switch v := f.resolve(stream.dict["Filter"]).(type) {
+	case pdfName:
+		filters = []interface{}{v}
+	case pdfArray:
+		filters = v
+	}
+
+	data := stream.raw
+	for _, filter := range filters {
+		name, _ := f.resolve(filter).(pdfName)
+		switch name {
+		case "FlateDecode", "Fl":
+			r, err := zlib.NewReader(bytes.NewReader(data))
+			if err != nil {
+				return nil, err
+			}
+			// Truncated streams still yield most of their text.
+			decoded, err := io.ReadAll(io.LimitReader(r, maxStreamSize))
+			if err != nil && len(decoded) == 0 {
+				return nil, err
+			}
+			data = decoded
+		case "ASCIIHexDecode", "AHx":
+			data = (&pdfLexer{data: append(bytes.TrimSpace(data), '>')}).hexString()
+		case "ASCII85Decode", "A85":
+			src := bytes.TrimPrefix(bytes.TrimSpace(data), []byte("<~"))
+			src = bytes.TrimSuffix(src, []byte("~>"))
+			decoded := make([]byte, len(src)*4/5+4)
+			n, _, err := ascii85.Decode(decoded, src, true)
+			if err != nil {
+				return nil, err
+			}
+			data = decoded[:n]
+		default:
+			return nil, fmt.Errorf("unsupported PDF filter %s", name)
+		}
+	}
+	return data, nil
+}
+
+type pdfPage struct {
+	dict      pdfDict
+	resources pdfDict
+}
+
+// pages walks the page tree from the catalog, falling back to every page
+// object in object order when the tree cannot be followed.
+func (f *pdfFile) pages() []pdfPage {
+	var pages []pdfPage
+	visited := make(map[interface{}]bool)
+
+	var walk func(node interface{}, resources pdfDict)
+	walk = func(node interface{}, resources pdfDict) {
+		if len(pages) >= maxPDFPages {
+			return
+		}
+		if ref, ok := node.(pdfRef); ok {
+			if visited[ref] {
+				return
+			}
+			visited[ref] = true
+		}
+		dict := f.dict(node)
+		if dict == nil {
+			return
+		}
+		if r := f.dict(dict["Resources"]); r != nil {
+			resources = r
+		}
+		if kids, ok := f.resolve(dict["Kids"]).(pdfArray); ok {
+			for _, kid := range kids {
+				walk(kid, resources)
+			}
+			return
+		}
+		if dict["Type"] == pdfName("Page") || dict["Contents"] != nil {
+			pages = append(pages, pdfPage{dict: dict, resources: resources})
+		}
+	}
+
+	if catalog := f.dict(f.root["Root"]); catalog != nil {
+		walk(catalog["Pages"], nil)
+	}
+	if len(pages) > 0 {
+		return pages
+	}
+
+	var nums []int
+	for num, obj := range f.objects {
+		if dict, ok := obj.(pdfDict); ok && dict["Type"] == pdfName("Page") {
+			nums = append(nums, num)
+		}
+	}
+	sort.Ints(nums)
+	for _, num := range nums {
+		if len(pages) >= maxPDFPages {
+			break
+		}
+		dict := f.objects[num].(pdfDict)
+		pages = append(pages, pdfPage{dict: dict, resources: f.dict(dict["Resources"])})
+	}
+	return pages
+}
+
+// contents concatenates the page's content streams.
+func (f *pdfFile) contents(page pdfPage) []byte {
+	var parts []interface{}
+	switch v := f.resolve(page.dict["Contents"]).(type) {
+	case pdfArray:
+		parts = v
+	case *pdfStream:
+		parts = []interface{}{v}
+	}
+
+	var out []byte
+	for _, part := range parts {
+		stream, ok := f.resolve(part).(*pdfStream)
+		if !ok {
+			continue
+		}
+		data, err := f.decode(stream)
+		if err != nil {
+			continue
+		}
+		out = append(out, data...)
+		out = append(out, '\n')
+	}
+	return out
+}
+
+func extractPDF(data []byte) ([]Section, error) {
+	file, err := parsePDF(data)
+	if err != nil {
+		return nil, err
+	}
+
+	pages := file.pages()
+	if len(pages) == 0 {
+		return nil, errors.New("PDF has no pages")
+	}
+
+	fonts := newFontCache(file)
+	sections := make([]Section, 0, len(pages))
+	for i, page := range pages {
+		text := newTextWriter()
+		interpret(file, fonts, file.contents(page), page.resources, text, 0)
+		sections = append(sections, Section{Label: "Page", Number: i + 1, Text: normalizeText(text.String())})
+	}
+	return sections, nil
+}
@@ -0,0 +1,440 @@
+package extract
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/unicode/norm"
+)
+
+// pdfFont maps the character codes of shown strings to text. ToUnicode
+// CMaps take precedence; simple fonts fall back to their byte encoding.
+// Composite fonts without a ToUnicode map use glyph IDs that cannot be
+// turned back into text, so they yield nothing.
+type pdfFont struct {
+	codeLen   int
+	toUnicode *cmap
+	encoding  *[256]string
+	utf16     bool
+
+	// Glyph widths in thousandths of the font size, used to tell word gaps
+	// from glyphs that are positioned one by one.
+	widths       map[uint32]float64
+	defaultWidth float64
+}
+
+type fontCache struct {
+	file  *pdfFile
+	fonts map[pdfRef]*pdfFont
+}
+
+func newFontCache(file *pdfFile) *fontCache {
+	return &fontCache{file: file, fonts: make(map[pdfRef]*pdfFont)}
+}
+
+// get returns the font named in resources, or a Latin-1 font when it is
+// missing so text still comes through.
+func (c *fontCache) get(resources pdfDict, name pdfName) *pdfFont {
+	obj := c.file.dict(resources["Font"])[name]
+	if ref, ok := obj.(pdfRef); ok {
+		if font, ok := c.fonts[ref]; ok {
+			return font
+		}
+		font := c.load(c.file.dict(ref))
+		c.fonts[ref] = font
+		return font
+	}
+	return c.load(c.file.dict(obj))
+}
+
+func (c *fontCache) load(dict pdfDict) *pdfFont {
+	font := &pdfFont{codeLen: 1, encoding: &winAnsi, widths: make(map[uint32]float64), defaultWidth: 500}
+	if dict == nil {
+		return font
+	}
+
+	if stream, ok := c.file.resolve(dict["ToUnicode"]).(*pdfStream); ok {
+		if data, err := c.file.decode(stream); err == nil {
+			font.toUnicode = parseCMap(data)
+		}
+	}
+
+	if dict["Subtype"] == pdfName("Type0") {
+		font.codeLen = 2
+		font.encoding = nil
+		if descendants, ok := c.file.resolve(dict["DescendantFonts"]).(pdfArray); ok && len(descendants) > 0 {
+			c.loadCIDWidths(font, c.file.dict(descendants[0]))
+		}
+		switch enc := c.file.resolve(dict["Encoding"]).(type) {
+		case pdfName:
+			// Predefined Unicode CMaps code text as UTF-16.
+			name := string(enc)
+			font.utf16 = strings.HasPrefix(name, "Uni") && (strings.Contains(name, "UCS2") || strings.Contains(name, "UTF16"))
+		case *pdfStream:
+			if font.toUnicode == nil {
+				break
+			}
+			if data, err := c.file.decode(enc); err == nil {
+				font.toUnicode.space = parseCMap(data).space
+			}
+		}
+		return font
+	}
+
+	first, _ := c.file.resolve(dict["FirstChar"]).(float64)
+	if widths, ok := c.file.resolve(dict["Widths"]).(pdfArray); ok {
+		for i, w := range widths {
+			if width, ok := c.file.resolve(w).(float64); ok {
+				font.widths[uint32(int(first)+i)] = width
+			}
+		}
+	}
+
+	switch enc := c.file.resolve(dict["Encoding"]).(type) {
+	case pdfName:
+		font.encoding = baseEncoding(enc)
+	case pdfDict:
+		table := *baseEncoding(c.file.resolve(enc["BaseEncoding"]))
+		if diffs, ok := c.file.resolve(enc["Differences"]).(pdfArray); ok {
+			code := 0
+			for _, item := range diffs {
+				switch v := c.file.resolve(item).(type) {
+				case float64:
+					code = int(v)
+				case pdfName:
+					if code >= 0 && code < 256 {
+						if text, ok := glyphText(string(v)); ok {
+							table[code] = text
+						}
+					}
+					code++
+				}
+			}
+		}
+		font.encoding = &table
+	}
+	return font
+}
+
+// loadCIDWidths reads a CID font's /W array, whose entries are either
+// "c [w1 w2 ...]" or "cFirst cLast w".
+func (c *fontCache) loadCIDWidths(font *pdfFont, dict pdfDict) {
+	font.defaultWidth = 1000
+	if dw, ok := c.file.resolve(dict["DW"]).(float64); ok {
+		font.defaultWidth = dw
+	}
+	w, _ := c.file.resolve(dict["W"]).(pdfArray)
+	for i := 0; i+1 < len(w); {
+		first, ok := c.file.resolve(w[i]).(float64)
+		if !ok {
+			return
+		}
+		if list, ok := c.file.resolve(w[i+1]).(pdfArray); ok {
+			for j, item := range list {
+				if width, ok := c.file.resolve(item).(float64); ok {
+					font.widths[uint32(int(first)+j)] = width
+				}
+			}
+			i += 2
+			continue
+		}
+		if i+2 >= len(w) {
+			return
+		}
+		last, _ := c.file.resolve(w[i+1]).(float64)
+		width, _ := c.file.resolve(w[i+2]).(float64)
+		for code := int(first); code <= int(last) && code-int(first) < 0x10000; code++ {
+			font.widths[uint32(code)] = width
+		}
+		i += 3
+	}
+}
+
+// decode returns the text of s and its advance width in thousandths of the
+// font size.
+func (f *pdfFont) decode(s []byte) (string, float64) {
+	if f.utf16 && f.toUnicode == nil {
+		return decodeUTF16(s), float64(len(s)/2) * f.defaultWidth
+	}
+
+	var sb strings.Builder
+	var advance float64
+	for i := 0; i < len(s); {
+		n := f.codeLen
+		if f.toUnicode != nil {
+			n = f.toUnicode.codeLength(s[i:], f.codeLen)
+		}
+		if i+n > len(s) {
+			n = len(s) - i
+		}
+		code := codeValue(s[i : i+n])
+		i += n
+		if width, ok := f.widths[code]; ok {
+			advance += width
+		} else {
+			advance += f.defaultWidth
+		}
+
+		if f.toUnicode != nil {
+			if text, ok := f.toUnicode.lookup(n, code); ok {
+				sb.WriteString(text)
+				continue
+			}
+		}
+		if f.encoding != nil && n == 1 {
+			sb.WriteString(f.encoding[code])
+		}
+	}
+	return sb.String(), advance
+}
+
+func codeValue(b []byte) uint32 {
+	var code uint32
+	for _, c := range b {
+		code = code<<8 | uint32(c)
+	}
+	return code
+}
+
+func decodeUTF16(b []byte) string {
+	units := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		units = append(units, uint16(b[i])<<8|uint16(b[i+1]))
+	}
+	return string(utf16.Decode(units))
+}
+
+type codeRange struct {
+	n      int
+	lo, hi uint32
+}
+
+type cmapRange struct {
+	codeRange
+	// Either dst is the text of lo, with later codes incrementing its last
+	// character, or dsts lists the text of each code.
+	dst  []byte
+	dsts []string
+}
+
+type cmap struct {
+	space  []codeRange
+	chars  map[codeRange]string
+	ranges []cmapRange
+}
+
+// parseCMap reads the codespace, bfchar and bfrange sections of a CMap.
+// Anything else in the program is ignored.
+func parseCMap(data []byte) *cmap {
+	m := &cmap{chars: make(map[codeRange]string)}
+	lx := &pdfLexer{data: data}
+	var operands []interface{}
+	for {
+		obj, err := lx.object(false)
+		if err != nil {
+			return m
+		}
+		keyword, ok := obj.(pdfKeyword)
+		if !ok {
+			operands = append(operands, obj)
+			continue
+		}
+		switch keyword {
+		case "endcodespacerange":
+			for i := 0; i+1 < len(operands); i += 2 {
+				lo, ok1 := operands[i].(pdfString)
+				hi, ok2 := operands[i+1].(pdfString)
+				if ok1 && ok2 && len(lo) == len(hi) && len(lo) > 0 && len(lo) <= 4 {
+					m.space = append(m.space, codeRange{n: len(lo), lo: codeValue(lo), hi: codeValue(hi)})
+				}
+			}
+		case "endbfchar":
+			for i := 0; i+1 < len(operands); i += 2 {
+				src, ok := operands[i].(pdfString)
+				if !ok || len(src) == 0 || len(src) > 4 {
+					continue
+				}
+				key := codeRange{n: len(src), lo: codeValue(src)}
+				switch dst := operands[i+1].(type) {
+				case pdfString:
+					m.chars[key] = decodeUTF16(dst)
+				case pdfName:
+					if text, ok := glyphText(string(dst)); ok {
+						m.chars[key] = text
+					}
+				}
+			}
+		case "endbfrange":
+			for i := 0; i+2 < len(operands); i += 3 {
+				lo, ok1 := operands[i].(pdfString)
+				hi, ok2 := operands[i+1].(pdfString)
+				if !ok1 || !ok2 || len(lo) != len(hi) || len(lo) == 0 || len(lo) > 4 {
+					continue
+				}
+				r := cmapRange{codeRange: codeRange{n: len(lo), lo: codeValue(lo), hi: codeValue(hi)}}
+				switch dst := operands[i+2].(type) {
+				case pdfString:
+					r.dst = dst
+				case pdfArray:
+					for _, item := range dst {
+						s, _ := item.(pdfString)
+						r.dsts = append(r.dsts, decodeUTF16(s))
+					}
+				default:
+					continue
+				}
+				m.ranges = append(m.ranges, r)
+			}
+		}
+		operands = operands[:0]
+	}
+}
+
+// codeLength returns how many bytes the next code in s takes, per the
+// codespace ranges.
+func (m *cmap) codeLength(s []byte, fallback int) int {
+	for n := 1; n <= 4 && n <= len(s); n++ {
+		code := codeValue(s[:n])
+		for _, r := range m.space {
+			if r.n == n && code >= r.lo && code <= r.hi {
+				return n
+			}
+		}
+	}
+	return fallback
+}
+
+func (m *cmap) lookup(n int, code uint32) (string, bool) {
+	if text, ok := m.chars[codeRange{n: n, lo: code}]; ok {
+		return text, true
+	}
+	for _, r := range m.ranges {
+		if r.n != n || code < r.lo || code > r.hi {
+			continue
+		}
+		offset := code - r.lo
+		if r.dsts != nil {
+			if int(offset) < len(r.dsts) {
+				return r.dsts[offset], true
+			}
+			return "", false
+		}
+		if len(r.dst) < 2 {
+			return "", false
+		}
+		dst := append([]byte(nil), r.dst...)
+		last := uint32(dst[len(dst)-2])<<8 | uint32(dst[len(dst)-1])
+		last += offset
+		dst[len(dst)-2], dst[len(dst)-1] = byte(last>>8), byte(last)
+		return decodeUTF16(dst), true
+	}
+	return "", false
+}
+
+var winAnsi, macRoman = byteTable(charmap.Windows1252), byteTable(charmap.Macintosh)
+
+func byteTable(cm *charmap.Charmap) [256]string {
+	var table [256]string
+	for i := range table {
+		if r := cm.DecodeByte(byte(i)); r >= ' ' && r != 0x7f && r != 0xfffd {
+			table[i] = string(r)
+		}
+	}
+	return table
+}
+
+// baseEncoding returns the table for a named encoding. StandardEncoding and
+// font built-in encodings are close enough to WinAnsi for Latin text.
+func baseEncoding(name interface{}) *[256]string {
+	if name == pdfName("MacRomanEncoding") {
+		table := macRoman
+		return &table
+	}
+	table := winAnsi
+	return &table
+}
+
+var glyphNames = map[string]string{
+	"space": " ", "exclam": "!", "quotedbl": "\"", "numbersign": "#", "dollar": "$",
+	"percent": "%", "ampersand": "&", "quotesingle": "'", "parenleft": "(", "parenright": ")",
+	"asterisk": "*", "plus": "+", "comma": ",", "hyphen": "-", "period": ".", "slash": "/",
+	"zero": "0", "one": "1", "two": "2", "three": "3", "four": "4", "five": "5", "six": "6",
+	"seven": "7", "eight": "8", "nine": "9", "colon": ":", "semicolon": ";", "less": "<",
+	"equal": "=", "greater": ">", "question": "?", "at": "@", "bracketleft": "[",
+	"backslash": "\\", "bracketright": "]", "asciicircum": "^", "underscore": "_",
+	"grave": "`", "braceleft": "{", "bar": "|", "braceright": "}", "asciitilde": "~",
+	"quoteleft": "‘", "quoteright": "’", "quotedblleft": "“", "quotedblright": "”",
+	"quotesinglbase": "‚", "quotedblbase": "„", "guillemotleft": "«", "guillemotright": "»",
+	"bullet": "•", "endash": "–", "emdash": "—", "ellipsis": "…", "minus": "−",
+	"fi": "fi", "fl": "fl", "ff": "ff", "ffi": "ffi", "ffl": "ffl",
+	"trademark": "™", "copyright": "©", "registered": "®", "degree": "°", "section": "§",
+	"paragraph": "¶", "dagger": "†", "daggerdbl": "‡", "multiply": "×", "divide": "÷",
+	"plusminus": "±", "periodcentered": "·", "nbspace": "\u00a0", "nonbreakingspace": "\u00a0",
+	"germandbls": "ß", "ae": "æ", "AE": "Æ", "oe": "œ", "OE": "Œ", "oslash": "ø",
+	"Oslash": "Ø", "dotlessi": "ı", "Euro": "€", "sterling": "£", "yen": "¥", "cent": "¢",
+	"mu": "µ", "onehalf": "½", "onequarter": "¼", "threequarters": "¾",
+}
+
+var accentMarks = map[string]string{
+	"acute": "\u0301", "grave": "\u0300", "circumflex": "\u0302", "dieresis": "\u0308",
+	"tilde": "\u0303", "ring": "\u030a", "cedilla": "\u0327", "caron": "\u030c",
+}
+
+// glyphText maps an Adobe glyph name to its text: single letters, common
+// names, accented letters such as "eacute" and "uniXXXX" forms. Suffixes
+// like ".sc" are dropped and "_" joins ligature parts.
+func glyphText(name string) (string, bool) {
+	if i := strings.IndexByte(name, '.'); i > 0 {
+		name = name[:i]
+	}
+	if strings.Contains(name, "_") {
+		var sb strings.Builder
+		for _, part := range strings.Split(name, "_") {
+			text, ok := glyphText(part)
+			if !ok {
+				return "", false
+			}
+			sb.WriteString(text)
+		}
+		return sb.String(), true
+	}
+
+	if len(name) == 1 {
+		return name, true
+	}
+	if text, ok := glyphNames[name]; ok {
+		return text, true
+	}
+	if strings.HasPrefix(name, "uni") && len(name) >= 7 && (len(name)-3)%4 == 0 {
+		var runes []rune
+		for i := 3; i < len(name); i += 4 {
+			v, err := strconv.ParseUint(name[i:i+4], 16, 32)
+			if err != nil {
+				return "", false
+			}
+			runes = append(runes, rune(v))
+		}
+		return string(utf16.Decode(runesToUnits(runes))), true
+	}
+	if strings.HasPrefix(name, "u") && len(name) >= 5 && len(name) <= 7 {
+		if v, err := strconv.ParseUint(name[1:], 16, 32); err == nil {
+			return string(rune(v)), true
+		}
+	}
+	if len(name) > 1 {
+		if mark, ok := accentMarks[name[1:]]; ok {
+			return norm.NFC.String(name[:1] + mark), true
+		}
+	}
+	return "", false
+}
+
+func runesToUnits(runes []rune) []uint16 {
+	units := make([]uint16, len(runes))
+	for i, r := range runes {
+		units[i] = uint16(r)
+	}
+	return units
+}
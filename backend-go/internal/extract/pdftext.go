@@ -0,0 +1,231 @@
+package extract
+
+import (
+	"bytes"
+	"math"
+	"strings"
+)
+
+// textWriter accumulates shown text, inserting at most one separator between
+// runs so the heuristics below cannot pile up blank lines.
+type textWriter struct {
+	sb      strings.Builder
+	pending string
+}
+
+func newTextWriter() *textWriter {
+	return &textWriter{}
+}
+
+func (w *textWriter) write(text string) {
+	if text == "" {
+		return
+	}
+	if w.pending != "" && w.sb.Len() > 0 {
+		last := w.sb.String()[w.sb.Len()-1]
+		if last != ' ' && last != '\n' || w.pending == "\n" && last != '\n' {
+			w.sb.WriteString(w.pending)
+		}
+	}
+	w.pending = ""
+	w.sb.WriteString(text)
+}
+
+func (w *textWriter) space() {
+	if w.pending == "" {
+		w.pending = " "
+	}
+}
+
+func (w *textWriter) newline() {
+	w.pending = "\n"
+}
+
+func (w *textWriter) String() string {
+	return w.sb.String()
+}
+
+// TJ adjustments are in thousandths of a text space unit; a gap wider than
+// this is treated as a word break.
+const wordGap = -180
+
+// textState tracks where the pen is, in units of the text matrix at the start
+// of the line, so positioning operators can be told apart as line breaks,
+// word gaps or glyph-by-glyph placement.
+type textState struct {
+	font       *pdfFont
+	fontSize   float64
+	scale      float64
+	lineX      float64
+	lineY      float64
+	x          float64
+	positioned bool
+}
+
+// em is the current font size in the units of x.
+func (s *textState) em() float64 {
+	if em := math.Abs(s.fontSize * s.scale); em > 0 {
+		return em
+	}
+	return 1
+}
+
+// moveTo starts the next run at x on the current line, marking a word break
+// when it does not continue where the previous run ended.
+func (s *textState) moveTo(w *textWriter, x float64) {
+	if gap := x - s.x; gap > 0.15*s.em() || gap < -s.em() {
+		w.space()
+	}
+	s.x = x
+}
+
+func (s *textState) show(w *textWriter, operand interface{}) {
+	str, ok := operand.(pdfString)
+	if !ok {
+		return
+	}
+	text, advance := s.font.decode(str)
+	w.write(text)
+	s.x += advance / 1000 * s.fontSize * s.scale
+}
+
+// interpret runs a content stream, writing the text shown by its operators.
+// Positioning operators only decide where line and word breaks go.
+func interpret(file *pdfFile, fonts *fontCache, content []byte, resources pdfDict, w *textWriter, depth int) {
+	lx := &pdfLexer{data: content}
+	var operands []interface{}
+	state := &textState{font: fonts.get(nil, ""), fontSize: 1, scale: 1}
+	number := func(i int) float64 {
+		n, _ := operands[len(operands)-i].(float64)
+		return n
+	}
+
+	for {
+		obj, err := lx.object(false)
+		if err != nil {
+			return
+		}
+		op, ok := obj.(pdfKeyword)
+		if !ok {
+			operands = append(operands, obj)
+			continue
+		}
+
+		switch op {
+		case "BT":
+			state.lineX, state.lineY, state.x, state.scale = 0, 0, 0, 1
+			state.positioned = false
+		case "ET":
+			w.space()
+		case "Tf":
+			if len(operands) >= 2 {
+				if name, ok := operands[len(operands)-2].(pdfName); ok {
+					state.font = fonts.get(resources, name)
+				}
+				state.fontSize = number(1)
+			}
+		case "Td", "TD":
+			if len(operands) >= 2 {
+				tx, ty := number(2)*state.scale, number(1)*state.scale
+				state.lineX += tx
+				state.lineY += ty
+				if math.Abs(ty) > 0.01 {
+					w.newline()
+					state.x = state.lineX
+				} else {
+					state.moveTo(w, state.lineX)
+				}
+			}
+		case "Tm":
+			if len(operands) >= 6 {
+				scale := math.Hypot(number(6), number(5))
+				if scale == 0 {
+					scale = 1
+				}
+				e, f := number(2), number(1)
+				if state.positioned && math.Abs(f-state.lineY) > 0.1*state.em() {
+					w.newline()
+					state.x = e
+				} else {
+					state.moveTo(w, e)
+				}
+				state.lineX, state.lineY, state.scale = e, f, scale
+				state.positioned = true
+			}
+		case "T*":
+			w.newline()
+			state.x = state.lineX
+		case "Tj":
+			if len(operands) >= 1 {
+				state.show(w, operands[len(operands)-1])
+			}
+		case "'", "\"":
+			w.newline()
+			state.x = state.lineX
+			if len(operands) >= 1 {
+				state.show(w, operands[len(operands)-1])
+			}
+		case "TJ":
+			if len(operands) >= 1 {
+				array, _ := operands[len(operands)-1].(pdfArray)
+				for _, item := range array {
+					if n, ok := item.(float64); ok {
+						if n < wordGap {
+							w.space()
+						}
+						state.x -= n / 1000 * state.fontSize * state.scale
+						continue
+					}
+					state.show(w, item)
+				}
+			}
+		case "Do":
+			if len(operands) >= 1 && depth < maxXObjectNesting {
+				name, _ := operands[len(operands)-1].(pdfName)
+				showForm(file, fonts, resources, name, w, depth)
+			}
+		case "ID":
+			skipInlineImage(lx)
+		}
+		operands = operands[:0]
+	}
+}
+
+// showForm interprets a form XObject, which can hold text of its own. Image
+// XObjects are skipped.
+func showForm(file *pdfFile, fonts *fontCache, resources pdfDict, name pdfName, w *textWriter, depth int) {
+	stream, ok := file.resolve(file.dict(resources["XObject"])[name]).(*pdfStream)
+	if !ok || stream.dict["Subtype"] != pdfName("Form") {
+		return
+	}
+	data, err := file.decode(stream)
+	if err != nil {
+		return
+	}
+	if own := file.dict(stream.dict["Resources"]); own != nil {
+		resources = own
+	}
+	w.newline()
+	interpret(file, fonts, data, resources, w, depth+1)
+	w.newline()
+}
+
+// skipInlineImage moves past the binary data of a BI ... ID ... EI inline
+// image, which ends at the first "EI" surrounded by whitespace.
+func skipInlineImage(lx *pdfLexer) {
+	lx.pos++
+	for lx.pos < len(lx.data) {
+		i := bytes.Index(lx.data[lx.pos:], []byte("EI"))
+		if i < 0 {
+			lx.pos = len(lx.data)
+			return
+		}
+		start := lx.pos + i
+		end := start + 2
+		if (start == 0 || isPDFSpace(lx.data[start-1])) && (end == len(lx.data) || isPDFSpace(lx.data[end])) {
+			lx.pos = end
+			return
+		}
+		lx.pos = end
+	}
+}
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"errors"
+	"myway-backend/internal/middleware"
+	"myway-backend/internal/models"
+	"myway-backend/internal/repository"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// requireOrgMember returns the caller's active membership in the
+// organization, responding with 403 when there is none, when the request
+// comes with another organization's API key, or when the organization
+// requires MFA of the member and this session did not pass it.
+func requireOrgMember(c *gin.Context, orgs repository.OrgRepository, userID, orgID uuid.UUID) (*models.OrgMembership, bool) {
+	if !apiKeyAllows(c, orgID) {
+		return nil, false
+	}
+	membership, err := orgs.GetActiveMembership(userID, orgID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) && repository.Suspended(orgs, userID, orgID) {
+			c.JSON(http.StatusForbidden, middleware.SuspendedResponse)
+		} else if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You do not have access to this organization"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check organization membership"})
+		}
+		return nil, false
+	}
+	if repository.MFARequired(membership) && !c.GetBool("mfa") {
+		c.JSON(http.StatusForbidden, middleware.MFARequiredResponse)
+		return nil, false
+	}
+	return membership, true
+}
+
+// requireOrgRole returns the caller's active membership of the :id
+// organization, with the organization, when it holds one of the roles. It
+// responds with 400 or 403 otherwise; forbidden is the message of the 403 for
+// members in another role.
+func requireOrgRole(c *gin.Context, orgs repository.OrgRepository, forbidden string, roles ...string) (*models.OrgMembership, bool) {
+	orgID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+		return nil, false
+	}
+	membership, ok := requireOrgMember(c, orgs, c.MustGet("userID").(uuid.UUID), orgID)
+	if !ok {
+		return nil, false
+	}
+	for _, role := range roles {
+		if membership.Role == role {
+			return membership, true
+		}
+	}
+	c.JSON(http.StatusForbidden, gin.H{"error": forbidden})
+	return nil, false
+}
+
+// apiKeyAllows responds with 403 when the request is authenticated with an
+// organization API key of another organization.
+func apiKeyAllows(c *gin.Context, orgID uuid.UUID) bool {
+	if keyOrgID, ok := c.Get("apiKeyOrgID"); ok && keyOrgID.(uuid.UUID) != orgID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "This API key belongs to another organization"})
+		return false
+	}
+	return true
+}
+
+// courseAccess is the caller's standing in a course. Organizers manage every
+// course in their organization without an enrollment, so CourseRole is empty
+// for them unless they enrolled.
+type courseAccess struct {
+	OrgRole    string
+	CourseRole string
+}
+
+func (a *courseAccess) isOrganizer() bool {
+	return a.OrgRole == "ORGANIZER"
+}
+
+// canTeach reports whether the caller may manage the course's content and roster.
+func (a *courseAccess) canTeach() bool {
+	return a.isOrganizer() || a.CourseRole == repository.EnrollmentTeacher
+}
+
+// isStaff also admits teaching assistants, who see the roster and grade.
+func (a *courseAccess) isStaff() bool {
+	return a.canTeach() || a.CourseRole == repository.EnrollmentTA
+}
+
+// requireCourseAccess checks the caller is an active member of the course's
+// organization and enrolled in the course, responding with 403 otherwise.
+func requireCourseAccess(c *gin.Context, orgs repository.OrgRepository, courses repository.CourseRepository, userID uuid.UUID, course *models.Course) (*courseAccess, bool) {
+	membership, ok := requireOrgMember(c, orgs, userID, course.OrgID)
+	if !ok {
+		return nil, false
+	}
+	access := &courseAccess{OrgRole: membership.Role}
+
+	enrollment, err := courses.GetEnrollment(course.ID, userID)
+	switch {
+	case err == nil:
+		access.CourseRole = enrollment.Role
+	case !errors.Is(err, repository.ErrNotFound):
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check course enrollment"})
+		return nil, false
+	case !access.isOrganizer():
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not enrolled in this course"})
+		return nil, false
+	}
+	return access, true
+}
+
+// requireMaterialAccess loads a material and checks the caller has access
+// to the course it belongs to, responding with 404 or 403 otherwise. The
+// material comes with its module and course.
+func requireMaterialAccess(c *gin.Context, orgs repository.OrgRepository, courses repository.CourseRepository, userID, materialID uuid.UUID) (*models.Material, *courseAccess, bool) {
+	material, err := courses.GetMaterial(materialID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Material not found"})
+			return nil, nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch material"})
+		return nil, nil, false
+	}
+	module, err := courses.GetModule(material.ModuleID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch module"})
+		return nil, nil, false
+	}
+	course, err := courses.GetByID(module.CourseID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch course"})
+		return nil, nil, false
+	}
+	access, ok := requireCourseAccess(c, orgs, courses, userID, course)
+	if !ok {
+		return nil, nil, false
+	}
+	material.Module = *module
+	material.Module.Course = *course
+	return material, access, true
+}
+
+// requireMaterialTeacher is requireMaterialAccess for callers who can teach
+// the course. forbidden is the message of the 403 for other members.
+func requireMaterialTeacher(c *gin.Context, orgs repository.OrgRepository, courses repository.CourseRepository, userID, materialID uuid.UUID, forbidden string) (*models.Material, bool) {
+	material, access, ok := requireMaterialAccess(c, orgs, courses, userID, materialID)
+	if !ok {
+		return nil, false
+	}
+	if !access.canTeach() {
+		c.JSON(http.StatusForbidden, gin.H{"error": forbidden})
+		return nil, false
+	}
+	return material, true
+}
+
+// requireStudyPackAccess loads a study pack and checks the caller has access
+// to its course, responding with 404 or 403 otherwise. Only course staff see
+// study packs that are not READY yet.
+func requireStudyPackAccess(c *gin.Context, orgs repository.OrgRepository, courses repository.CourseRepository, studyPacks repository.StudyPackRepository, userID, studyPackID uuid.UUID) (*models.StudyPack, bool) {
+	studyPack, err := studyPacks.GetByID(studyPackID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Study pack not found"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch study pack"})
+		return nil, false
+	}
+	_, access, ok := requireMaterialAccess(c, orgs, courses, userID, studyPack.MaterialID)
+	if !ok {
+		return nil, false
+	}
+	if studyPack.Status != "READY" && !access.isStaff() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Study pack not found or not ready"})
+		return nil, false
+	}
+	return studyPack, true
+}
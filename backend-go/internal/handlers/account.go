@@ -0,0 +1,288 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"myway-backend/internal/mail"
+	"myway-backend/internal/models"
+	"myway-backend/internal/repository"
+	jwtutil "myway-backend/pkg/jwt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Purposes of emailed user tokens.
+const (
+	TokenEmailVerification = "EMAIL_VERIFICATION"
+	TokenPasswordReset     = "PASSWORD_RESET"
+	// TokenMFAChallenge is not emailed: SignIn returns it to be presented
+	// with an authenticator code.
+	TokenMFAChallenge = "MFA_CHALLENGE"
+)
+
+const (
+	emailVerificationTTL = 24 * time.Hour
+	passwordResetTTL     = time.Hour
+	// tokensPerHour caps the emails of one purpose sent to an address.
+	tokensPerHour = 3
+)
+
+type EmailRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=6"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword" binding:"required"`
+	NewPassword     string `json:"newPassword" binding:"required,min=6"`
+}
+
+// emailAccepted is the answer to every request keyed by an email address, so
+// the endpoints do not reveal which addresses have accounts.
+var emailAccepted = gin.H{"message": "If the address belongs to an account, an email is on its way"}
+
+// RequestEmailVerification sends a new verification link.
+func (h *AuthHandler) RequestEmailVerification(c *gin.Context) {
+	var req EmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.Users.GetByEmail(repository.NormalizeEmail(req.Email))
+	if err == nil && user.EmailVerifiedAt == nil {
+		h.sendEmailVerification(user)
+	} else if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, emailAccepted)
+}
+
+// VerifyEmail confirms the address the token was sent to.
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	var req VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := h.consumeUserToken(c, req.Token, TokenEmailVerification)
+	if !ok {
+		return
+	}
+	if user.EmailVerifiedAt == nil {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+		if err := h.Users.Update(user); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified"})
+}
+
+// ForgotPassword emails a password reset link.
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	var req EmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.Users.GetByEmail(repository.NormalizeEmail(req.Email))
+	if err == nil {
+		h.sendPasswordReset(user)
+	} else if !errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, emailAccepted)
+}
+
+// ResetPassword sets a new password from an emailed token and signs the user
+// out everywhere. Following the link also proves the address, so the email
+// counts as verified.
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := h.consumeUserToken(c, req.Token, TokenPasswordReset)
+	if !ok {
+		return
+	}
+	if err := h.setPassword(user, req.Password); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset; sign in with the new password"})
+}
+
+// ChangePassword replaces the password of the signed-in user. Every refresh
+// token is revoked and this device gets a new session.
+func (h *AuthHandler) ChangePassword(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.Users.GetByID(userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.CurrentPassword)); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Current password is incorrect"})
+		return
+	}
+	if err := h.setPassword(user, req.NewPassword); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password"})
+		return
+	}
+
+	// The new session keeps whether this one passed MFA
+	accessToken, refreshToken, err := h.newSession(user, c.GetBool("mfa"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"accessToken":  accessToken,
+		"refreshToken": refreshToken,
+	})
+}
+
+// setPassword stores a new password hash and revokes every refresh token of
+// the user.
+func (h *AuthHandler) setPassword(user *models.User, password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	user.PasswordHash = string(hashedPassword)
+	if user.EmailVerifiedAt == nil {
+		user.EmailVerifiedAt = &now
+	}
+	if err := h.Users.Update(user); err != nil {
+		return err
+	}
+	return h.Users.RevokeUserRefreshTokens(user.ID, now)
+}
+
+func (h *AuthHandler) consumeUserToken(c *gin.Context, token, purpose string) (*models.User, bool) {
+	userToken, err := h.Users.ConsumeUserToken(jwtutil.HashToken(token), purpose, time.Now())
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired token"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check token"})
+		return nil, false
+	}
+	user, err := h.Users.GetByID(userToken.UserID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return nil, false
+	}
+	return user, true
+}
+
+func (h *AuthHandler) sendEmailVerification(user *models.User) {
+	token, ok := h.issueUserToken(user, TokenEmailVerification, emailVerificationTTL)
+	if !ok {
+		return
+	}
+	h.deliver(mail.Message{
+		To:      user.Email,
+		Subject: "Verify your MyWay email address",
+		Text: fmt.Sprintf("Hi %s,\n\nConfirm your email address by opening this link:\n\n%s\n\nThe link expires in 24 hours. If you did not create a MyWay account, ignore this email.\n",
+			user.Name, h.link("/verify-email", token)),
+	})
+}
+
+func (h *AuthHandler) sendPasswordReset(user *models.User) {
+	token, ok := h.issueUserToken(user, TokenPasswordReset, passwordResetTTL)
+	if !ok {
+		return
+	}
+	h.deliver(mail.Message{
+		To:      user.Email,
+		Subject: "Reset your MyWay password",
+		Text: fmt.Sprintf("Hi %s,\n\nChoose a new password by opening this link:\n\n%s\n\nThe link expires in one hour. If you did not ask to reset your password, ignore this email.\n",
+			user.Name, h.link("/reset-password", token)),
+	})
+}
+
+// issueUserToken stores a new token unless the rate limit for the purpose
+// is reached. Failures are logged rather than returned so callers answer
+// the same way whether or not an email goes out.
+func (h *AuthHandler) issueUserToken(user *models.User, purpose string, ttl time.Duration) (string, bool) {
+	now := time.Now()
+	count, err := h.Users.CountUserTokens(user.ID, purpose, now.Add(-time.Hour))
+	if err != nil {
+		log.Printf("Failed to count %s tokens for user %s: %v", purpose, user.ID, err)
+		return "", false
+	}
+	if count >= tokensPerHour {
+		log.Printf("Rate limited %s email to user %s", purpose, user.ID)
+		return "", false
+	}
+
+	token, hash, err := jwtutil.NewOpaqueToken()
+	if err != nil {
+		log.Printf("Failed to generate %s token: %v", purpose, err)
+		return "", false
+	}
+	if err := h.Users.CreateUserToken(&models.UserToken{
+		UserID:    user.ID,
+		Purpose:   purpose,
+		TokenHash: hash,
+		ExpiresAt: now.Add(ttl),
+	}); err != nil {
+		log.Printf("Failed to store %s token for user %s: %v", purpose, user.ID, err)
+		return "", false
+	}
+	return token, true
+}
+
+func (h *AuthHandler) link(path, token string) string {
+	return h.AppURL + path + "?token=" + url.QueryEscape(token)
+}
+
+// deliver sends in the background so a slow mail server neither delays the
+// response nor reveals through timing whether an account exists.
+func (h *AuthHandler) deliver(msg mail.Message) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := h.Mail.Send(ctx, msg); err != nil {
+			log.Printf("Failed to send %q to %s: %v", msg.Subject, msg.To, err)
+		}
+	}()
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"myway-backend/internal/repository"
+	"net/http"
+	"testing"
+)
+
+func TestEmailVerificationTokenWorksOnce(t *testing.T) {
+	s := newTestServer(t)
+	s.auth.RequireEmailVerification = true
+	credentials := map[string]string{"email": "ada@example.com", "password": testPassword}
+
+	signup := s.expect(s.do(http.MethodPost, "/auth/signup", "", map[string]string{
+		"email": "ada@example.com", "password": testPassword, "name": "Ada",
+	}), http.StatusCreated)
+	if signup["accessToken"] != nil || signup["verificationRequired"] != true {
+		t.Fatalf("signup response = %v, want verification before any session", signup)
+	}
+	token := s.mailToken()
+	s.expect(s.do(http.MethodPost, "/auth/signin", "", credentials), http.StatusForbidden)
+
+	// A verification token does not reset passwords
+	s.expect(s.do(http.MethodPost, "/auth/password/reset", "", map[string]string{"token": token, "password": "new password"}), http.StatusBadRequest)
+
+	s.expect(s.do(http.MethodPost, "/auth/verify-email", "", map[string]string{"token": token}), http.StatusOK)
+	s.expect(s.do(http.MethodPost, "/auth/verify-email", "", map[string]string{"token": token}), http.StatusBadRequest)
+	s.expect(s.do(http.MethodPost, "/auth/signin", "", credentials), http.StatusOK)
+}
+
+func TestPasswordResetTokenWorksOnce(t *testing.T) {
+	s := newTestServer(t)
+	s.user("ada@example.com", repository.RoleStudent)
+	signin := s.expect(s.do(http.MethodPost, "/auth/signin", "", map[string]string{
+		"email": "ada@example.com", "password": testPassword,
+	}), http.StatusOK)
+
+	// Unknown addresses get the same answer and no email
+	s.expect(s.do(http.MethodPost, "/auth/password/forgot", "", map[string]string{"email": "nobody@example.com"}), http.StatusAccepted)
+	s.expect(s.do(http.MethodPost, "/auth/password/forgot", "", map[string]string{"email": "ada@example.com"}), http.StatusAccepted)
+	first := s.mailToken()
+	s.expect(s.do(http.MethodPost, "/auth/password/forgot", "", map[string]string{"email": "ada@example.com"}), http.StatusAccepted)
+	second := s.mailToken()
+
+	reset := map[string]string{"token": second, "password": "new password"}
+	s.expect(s.do(http.MethodPost, "/auth/password/reset", "", reset), http.StatusOK)
+	s.expect(s.do(http.MethodPost, "/auth/password/reset", "", reset), http.StatusBadRequest)
+
+	// Using one link spends the other links sent for the same purpose
+	s.expect(s.do(http.MethodPost, "/auth/password/reset", "", map[string]string{"token": first, "password": "other password"}), http.StatusBadRequest)
+
+	// The reset signs the user out everywhere
+	s.expect(s.do(http.MethodPost, "/auth/refresh", "", map[string]string{"refreshToken": signin["refreshToken"].(string)}), http.StatusUnauthorized)
+	s.expect(s.do(http.MethodPost, "/auth/signin", "", map[string]string{"email": "ada@example.com", "password": testPassword}), http.StatusUnauthorized)
+	s.expect(s.do(http.MethodPost, "/auth/signin", "", map[string]string{"email": "ada@example.com", "password": "new password"}), http.StatusOK)
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"errors"
+	"myway-backend/internal/repository"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// AdminHandler serves platform administration. Its routes are limited to
+// users with the ADMIN platform role.
+type AdminHandler struct {
+	Users repository.UserRepository
+}
+
+func NewAdminHandler(repos *repository.Repositories) *AdminHandler {
+	return &AdminHandler{Users: repos.Users}
+}
+
+type SetUserRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+// ParsePlatformRole normalises a platform role name, reporting whether it
+// is one of the known roles.
+func ParsePlatformRole(raw string) (string, bool) {
+	role := strings.ToUpper(strings.TrimSpace(raw))
+	switch role {
+	case repository.RoleStudent, repository.RoleTeacher, repository.RoleOrganizer, repository.RoleAdmin:
+		return role, true
+	}
+	return "", false
+}
+
+// GetUser looks a user up by email.
+func (h *AdminHandler) GetUser(c *gin.Context) {
+	email := repository.NormalizeEmail(c.Query("email"))
+	if email == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing 'email' query parameter"})
+		return
+	}
+
+	user, err := h.Users.GetByEmail(email)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":            user.ID,
+		"email":         user.Email,
+		"name":          user.Name,
+		"role":          user.Role,
+		"emailVerified": user.EmailVerifiedAt != nil,
+		"createdAt":     user.CreatedAt,
+		"lastLogin":     user.LastLogin,
+	})
+}
+
+// SetUserRole grants or withdraws a platform role. Admins cannot change
+// their own role, so the last admin cannot lock everyone out.
+func (h *AdminHandler) SetUserRole(c *gin.Context) {
+	adminID := c.MustGet("userID").(uuid.UUID)
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	if userID == adminID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot change your own role"})
+		return
+	}
+
+	var req SetUserRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	role, ok := ParsePlatformRole(req.Role)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Role must be STUDENT, TEACHER, ORGANIZER, or ADMIN"})
+		return
+	}
+
+	user, err := h.Users.GetByID(userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+
+	user.Role = role
+	if err := h.Users.Update(user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":    user.ID,
+		"email": user.Email,
+		"name":  user.Name,
+		"role":  user.Role,
+	})
+}
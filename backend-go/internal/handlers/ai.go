@@ -1,12 +1,15 @@
 package handlers
 
 import (
-	"bytes"
 	"encoding/json"
 	"errors"
-	"io"
-	"myway-backend/internal/database"
+	"fmt"
+	"log"
+	"myway-backend/internal/llm"
 	"myway-backend/internal/models"
+	"myway-backend/internal/repository"
+	"myway-backend/internal/retrieval"
+	"myway-backend/internal/studypack"
 	"net/http"
 	"regexp"
 	"strings"
@@ -14,15 +17,30 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"gorm.io/gorm"
 )
 
 type AIHandler struct {
-	GeminiAPIKey string
+	Provider      llm.Provider
+	Generator     *studypack.Generator
+	Index         retrieval.Index
+	Orgs          repository.OrgRepository
+	Courses       repository.CourseRepository
+	StudyPacks    repository.StudyPackRepository
+	Conversations repository.ConversationRepository
+	Audit         repository.AuditRepository
 }
 
-func NewAIHandler(geminiAPIKey string) *AIHandler {
-	return &AIHandler{GeminiAPIKey: geminiAPIKey}
+func NewAIHandler(provider llm.Provider, generator *studypack.Generator, index retrieval.Index, repos *repository.Repositories) *AIHandler {
+	return &AIHandler{
+		Provider:      provider,
+		Generator:     generator,
+		Index:         index,
+		Orgs:          repos.Orgs,
+		Courses:       repos.Courses,
+		StudyPacks:    repos.StudyPacks,
+		Conversations: repos.Conversations,
+		Audit:         repos.Audit,
+	}
 }
 
 func (h *AIHandler) GetStudyPack(c *gin.Context) {
@@ -31,16 +49,14 @@ func (h *AIHandler) GetStudyPack(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid material ID"})
 		return
 	}
+	_, access, ok := requireMaterialAccess(c, h.Orgs, h.Courses, c.MustGet("userID").(uuid.UUID), materialID)
+	if !ok {
+		return
+	}
 
-	var studyPack models.StudyPack
-	if err := database.GetDB().
-		Preload("Summary").
-		Preload("Quizzes.Questions").
-		Preload("Flashcards").
-		Preload("Material").
-		Where("material_id = ?", materialID).
-		Order("created_at DESC").
-		First(&studyPack).Error; err != nil {
+	// Students only see study packs once a teacher approved them
+	studyPack, err := h.StudyPacks.GetLatestByMaterial(materialID)
+	if err != nil || (studyPack.Status != "READY" && !access.isStaff()) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Study pack not found or not ready"})
 		return
 	}
@@ -69,22 +85,18 @@ type ApproveStudyPackRequest struct {
 }
 
 type RegenerateStudyPackRequest struct {
-	Notes string `json:"notes"`
+	Notes          string `json:"notes"`
+	QuestionCount  int    `json:"questionCount"`
+	FlashcardCount int    `json:"flashcardCount"`
 }
 
 func (h *AIHandler) GetReviewDraft(c *gin.Context) {
-	_, ok := h.requireInstructor(c)
+	_, material, ok := h.requireReviewer(c)
 	if !ok {
 		return
 	}
 
-	materialID, err := uuid.Parse(c.Param("materialId"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid material ID"})
-		return
-	}
-
-	studyPack, err := h.getLatestStudyPackByMaterial(materialID)
+	studyPack, err := h.StudyPacks.GetLatestByMaterial(material.ID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Study pack draft not found"})
 		return
@@ -105,17 +117,11 @@ func (h *AIHandler) GetReviewDraft(c *gin.Context) {
 }
 
 func (h *AIHandler) ApproveStudyPack(c *gin.Context) {
-	userID, ok := h.requireInstructor(c)
+	userID, material, ok := h.requireReviewer(c)
 	if !ok {
 		return
 	}
 
-	materialID, err := uuid.Parse(c.Param("materialId"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid material ID"})
-		return
-	}
-
 	var req ApproveStudyPackRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -136,7 +142,7 @@ func (h *AIHandler) ApproveStudyPack(c *gin.Context) {
 		return
 	}
 
-	studyPack, err := h.getLatestStudyPackByMaterial(materialID)
+	studyPack, err := h.StudyPacks.GetLatestByMaterial(material.ID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Study pack draft not found"})
 		return
@@ -147,35 +153,24 @@ func (h *AIHandler) ApproveStudyPack(c *gin.Context) {
 		"bullets": keyPoints,
 	})
 
-	db := database.GetDB()
-	if studyPack.Summary == nil {
-		summary := models.Summary{
-			StudyPackID: studyPack.ID,
-			Content:     string(contentJSON),
-		}
-		if err := db.Create(&summary).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save summary"})
-			return
-		}
-	} else {
-		if err := db.Model(&models.Summary{}).Where("id = ?", studyPack.Summary.ID).Update("content", string(contentJSON)).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update summary"})
-			return
+	previousSummary, previousKeyPoints := extractSummaryAndKeyPoints(studyPack.Summary)
+	event := newAuditEvent(c, material.Module.Course.OrgID, auditStudyPackApprove, "study_pack", studyPack.ID,
+		gin.H{"status": studyPack.Status, "summary": previousSummary, "keyPoints": previousKeyPoints},
+		gin.H{"status": "READY", "summary": req.Summary, "keyPoints": keyPoints},
+	)
+	err = h.Audit.Record(event, func(tx *repository.Repositories) error {
+		if err := tx.StudyPacks.SaveSummary(studyPack.ID, string(contentJSON)); err != nil {
+			return err
 		}
-	}
-
-	now := time.Now()
-	approvedBy := userID.String()
-	if err := db.Model(&models.StudyPack{}).Where("id = ?", studyPack.ID).Updates(map[string]interface{}{
-		"status":            "READY",
-		"published_at":      &now,
-		"requires_approval": false,
-		"approved_by":       &approvedBy,
-	}).Error; err != nil {
+		return tx.StudyPacks.Publish(studyPack.ID, userID.String(), time.Now())
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to approve study pack"})
 		return
 	}
 
+	reindexMaterial(h.Index, studyPack.MaterialID)
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Study pack approved and published",
 		"draft": gin.H{
@@ -189,31 +184,23 @@ func (h *AIHandler) ApproveStudyPack(c *gin.Context) {
 }
 
 func (h *AIHandler) RegenerateStudyPack(c *gin.Context) {
-	userID, ok := h.requireInstructor(c)
-	if !ok {
-		return
-	}
-
+	userID := c.MustGet("userID").(uuid.UUID)
 	materialID, err := uuid.Parse(c.Param("materialId"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid material ID"})
 		return
 	}
+	material, ok := requireMaterialTeacher(c, h.Orgs, h.Courses, userID, materialID, "Only course teachers can review study packs")
+	if !ok {
+		return
+	}
 
 	var req RegenerateStudyPackRequest
 	_ = c.ShouldBindJSON(&req)
 
-	db := database.GetDB()
-
-	var material models.Material
-	if err := db.First(&material, materialID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Material not found"})
-		return
-	}
-
-	studyPack, err := h.getLatestStudyPackByMaterial(materialID)
+	studyPack, err := h.StudyPacks.GetLatestByMaterial(materialID)
 	if err != nil {
-		if !errors.Is(err, gorm.ErrRecordNotFound) {
+		if !errors.Is(err, repository.ErrNotFound) {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load study pack"})
 			return
 		}
@@ -224,7 +211,7 @@ func (h *AIHandler) RegenerateStudyPack(c *gin.Context) {
 			Status:           "PROCESSING",
 			RequiresApproval: true,
 		}
-		if err := db.Create(&newPack).Error; err != nil {
+		if err := h.StudyPacks.Create(&newPack); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create study pack"})
 			return
 		}
@@ -232,56 +219,31 @@ func (h *AIHandler) RegenerateStudyPack(c *gin.Context) {
 		studyPack = &newPack
 	}
 
-	noteSuffix := ""
-	if strings.TrimSpace(req.Notes) != "" {
-		noteSuffix = " Instructor note: " + strings.TrimSpace(req.Notes)
-	}
-
-	generatedSummary := "This regenerated draft condenses the lecture into actionable concepts, emphasizing practical understanding and review checkpoints." + noteSuffix
-	generatedKeyPoints := []string{
-		"00:45 - Problem framing and learning objective",
-		"03:10 - Core concept explained with real-world example",
-		"06:25 - Common mistakes and how to avoid them",
-		"09:40 - Practical takeaway and next step",
-	}
-
-	contentJSON, _ := json.Marshal(map[string]interface{}{
-		"summary": generatedSummary,
-		"bullets": generatedKeyPoints,
+	content, err := h.Generator.Generate(c.Request.Context(), *material, studypack.Options{
+		QuestionCount:   req.QuestionCount,
+		FlashcardCount:  req.FlashcardCount,
+		InstructorNotes: req.Notes,
 	})
-
-	var existingSummary models.Summary
-	if err := db.Where("study_pack_id = ?", studyPack.ID).First(&existingSummary).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			summary := models.Summary{
-				StudyPackID: studyPack.ID,
-				Content:     string(contentJSON),
-			}
-			if err := db.Create(&summary).Error; err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create regenerated summary"})
-				return
-			}
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load summary"})
-			return
-		}
-	} else {
-		if err := db.Model(&existingSummary).Update("content", string(contentJSON)).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update summary"})
-			return
+	if err != nil {
+		switch {
+		case errors.Is(err, studypack.ErrNoSourceText):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Material has no transcript to generate from"})
+		case errors.Is(err, llm.ErrNotConfigured):
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "AI provider is not configured"})
+		default:
+			log.Printf("Study pack regeneration for material %s failed: %v", materialID, err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to generate study pack"})
 		}
+		return
 	}
 
-	if err := db.Model(&models.StudyPack{}).Where("id = ?", studyPack.ID).Updates(map[string]interface{}{
-		"status":            "GENERATED",
-		"requires_approval": true,
-		"approved_by":       nil,
-		"published_at":      nil,
-	}).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update study pack status"})
+	if err := h.StudyPacks.SaveDraft(studyPack.ID, content); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save regenerated study pack"})
 		return
 	}
 
+	reindexMaterial(h.Index, materialID)
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "AI draft regenerated",
 		"draft": gin.H{
@@ -289,42 +251,35 @@ func (h *AIHandler) RegenerateStudyPack(c *gin.Context) {
 			"studyPackId": studyPack.ID,
 			"status":      "GENERATED",
 			"videoUrl":    material.SourceURL,
-			"summary":     generatedSummary,
-			"keyPoints":   generatedKeyPoints,
+			"summary":     content.Summary,
+			"keyPoints":   content.KeyPoints,
 		},
+		"questionCount":  len(content.Quiz),
+		"flashcardCount": len(content.Flashcards),
 	})
 }
 
-func (h *AIHandler) requireInstructor(c *gin.Context) (uuid.UUID, bool) {
+// requireReviewer checks the caller teaches the course of the material named
+// in the route; study pack review is a course role, not a platform role.
+func (h *AIHandler) requireReviewer(c *gin.Context) (uuid.UUID, *models.Material, bool) {
 	userID := c.MustGet("userID").(uuid.UUID)
-
-	var user models.User
-	if err := database.GetDB().Select("id", "role").First(&user, userID).Error; err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
-		return uuid.Nil, false
+	materialID, err := uuid.Parse(c.Param("materialId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid material ID"})
+		return uuid.Nil, nil, false
 	}
-
-	role := strings.ToUpper(strings.TrimSpace(user.Role))
-	if role != "TEACHER" && role != "ORGANIZER" {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Only instructors can perform this action"})
-		return uuid.Nil, false
+	material, ok := requireMaterialTeacher(c, h.Orgs, h.Courses, userID, materialID, "Only course teachers can review study packs")
+	if !ok {
+		return uuid.Nil, nil, false
 	}
-
-	return userID, true
+	return userID, material, true
 }
 
-func (h *AIHandler) getLatestStudyPackByMaterial(materialID uuid.UUID) (*models.StudyPack, error) {
-	var studyPack models.StudyPack
-	err := database.GetDB().
-		Preload("Summary").
-		Preload("Material").
-		Where("material_id = ?", materialID).
-		Order("created_at DESC").
-		First(&studyPack).Error
-	if err != nil {
-		return nil, err
+// reindexMaterial refreshes the tutor's search chunks after the summary changed.
+func reindexMaterial(index retrieval.Index, materialID uuid.UUID) {
+	if _, err := index.IndexMaterial(materialID); err != nil {
+		log.Printf("Failed to reindex material %s: %v", materialID, err)
 	}
-	return &studyPack, nil
 }
 
 func extractSummaryAndKeyPoints(summary *models.Summary) (string, []string) {
@@ -387,140 +342,290 @@ func parseKeyPointsText(input string) []string {
 }
 
 type TutorChatRequest struct {
-	CourseID string `json:"courseId" binding:"required"`
-	Query    string `json:"query" binding:"required"`
+	CourseID       string `json:"courseId" binding:"required"`
+	Query          string `json:"query" binding:"required"`
+	ConversationID string `json:"conversationId"`
 }
 
 func (h *AIHandler) TutorChat(c *gin.Context) {
-	var req TutorChatRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	turn, ok := h.prepareTutorTurn(c)
+	if !ok {
 		return
 	}
 
-	query := strings.TrimSpace(req.Query)
-	if query == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "query is required"})
+	resp, err := h.Provider.Generate(c.Request.Context(), turn.request)
+	if err != nil {
+		if errors.Is(err, llm.ErrNotConfigured) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "AI provider is not configured"})
+			return
+		}
+		log.Printf("Tutor request to %s failed: %v", h.Provider.Name(), err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "AI provider request failed"})
 		return
 	}
 
-	if strings.TrimSpace(h.GeminiAPIKey) == "" {
-		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Gemini API key is not configured"})
+	result, err := h.finishTutorTurn(turn, resp)
+	if err != nil {
+		log.Printf("Failed to save tutor conversation: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save conversation"})
 		return
 	}
 
-	answer, err := h.generateTutorAnswerWithGemini(req.CourseID, query)
-	if err != nil {
-		c.JSON(http.StatusBadGateway, gin.H{"error": "Gemini request failed"})
+	c.JSON(http.StatusOK, result)
+}
+
+// TutorChatStream answers like TutorChat but relays the provider's token
+// deltas as server-sent events: "delta" events carry text fragments and a
+// final "done" event carries the cleaned answer, source references and usage.
+func (h *AIHandler) TutorChatStream(c *gin.Context) {
+	turn, ok := h.prepareTutorTurn(c)
+	if !ok {
 		return
 	}
-	answer = sanitizeTutorAnswer(answer)
 
-	c.JSON(http.StatusOK, gin.H{
-		"answer":                 answer,
-		"sourceReferences":       []string{},
-		"analyzedMaterialsCount": 1,
-		"provider":               "gemini",
-		"model":                  "gemini-3-flash-preview",
+	ctx := c.Request.Context()
+
+	c.Writer.Header().Set("Content-Type", "text/event-stream")
+	c.Writer.Header().Set("Cache-Control", "no-cache")
+	c.Writer.Header().Set("Connection", "keep-alive")
+	c.Writer.Header().Set("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	resp, err := h.Provider.Stream(ctx, turn.request, func(delta string) error {
+		// Stop pulling from the provider as soon as the client goes away.
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		c.SSEvent("delta", gin.H{"text": delta})
+		c.Writer.Flush()
+		return nil
 	})
+	if err != nil {
+		if ctx.Err() != nil {
+			log.Printf("Tutor stream cancelled by client: %v", ctx.Err())
+			return
+		}
+		message := "AI provider request failed"
+		if errors.Is(err, llm.ErrNotConfigured) {
+			message = "AI provider is not configured"
+		} else {
+			log.Printf("Tutor stream from %s failed: %v", h.Provider.Name(), err)
+		}
+		c.SSEvent("error", gin.H{"error": message})
+		c.Writer.Flush()
+		return
+	}
+
+	result, err := h.finishTutorTurn(turn, resp)
+	if err != nil {
+		log.Printf("Failed to save tutor conversation: %v", err)
+		c.SSEvent("error", gin.H{"error": "Failed to save conversation"})
+		c.Writer.Flush()
+		return
+	}
+
+	c.SSEvent("done", result)
+	c.Writer.Flush()
 }
 
-func (h *AIHandler) generateTutorAnswerWithGemini(courseID, query string) (string, error) {
-	modelName := "gemini-3-flash-preview"
+type tutorTurn struct {
+	conversation *models.Conversation
+	query        string
+	request      llm.Request
+	results      []retrieval.Result
+}
 
-	prompt := strings.TrimSpace(`You are MyWay AI Tutor.
+// finishTutorTurn stores the question and answer in the conversation and
+// builds the response body.
+func (h *AIHandler) finishTutorTurn(turn *tutorTurn, resp *llm.Response) (gin.H, error) {
+	answer := sanitizeTutorAnswer(resp.Text)
+	references := buildSourceReferences(turn.results)
 
-Rules:
-- Explain clearly and practically.
-- Use short paragraphs and bullet points when useful.
-- If user asks for differences/comparisons, provide a side-by-side style explanation.
-- If user asks for steps, give numbered steps.
-- Do NOT start responses with greetings (no "Hi", "Hello", "Hey", "Great question", or similar openers).
-- Start directly with the answer.
-- Keep tone professional, concise, and natural.
-- End with one concise check-for-understanding question.
+	if err := h.saveTutorTurn(turn.conversation, turn.query, answer, references, resp.Usage); err != nil {
+		return nil, err
+	}
 
-Course context: ` + courseID + `
-User question: ` + query)
+	return gin.H{
+		"conversationId":         turn.conversation.ID,
+		"answer":                 answer,
+		"sourceReferences":       references,
+		"analyzedMaterialsCount": countMaterials(turn.results),
+		"provider":               resp.Provider,
+		"model":                  resp.Model,
+		"usage":                  resp.Usage,
+	}, nil
+}
 
-	type geminiPart struct {
-		Text string `json:"text"`
+// prepareTutorTurn validates the request, checks course access, loads the
+// conversation history and retrieves the course material excerpts for the
+// question.
+func (h *AIHandler) prepareTutorTurn(c *gin.Context) (*tutorTurn, bool) {
+	var req TutorChatRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
 	}
-	type geminiContent struct {
-		Role  string       `json:"role,omitempty"`
-		Parts []geminiPart `json:"parts"`
+
+	query := strings.TrimSpace(req.Query)
+	if query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "query is required"})
+		return nil, false
+	}
+
+	course, ok := h.loadTutorCourse(c, req.CourseID)
+	if !ok {
+		return nil, false
 	}
-	type geminiGenerationConfig struct {
-		Temperature     float64 `json:"temperature,omitempty"`
-		MaxOutputTokens int     `json:"maxOutputTokens,omitempty"`
+
+	conversation, ok := h.loadTutorConversation(c, course, req.ConversationID)
+	if !ok {
+		return nil, false
 	}
-	type geminiRequest struct {
-		Contents         []geminiContent         `json:"contents"`
-		GenerationConfig geminiGenerationConfig `json:"generationConfig,omitempty"`
+
+	history, err := h.conversationHistory(c.Request.Context(), conversation)
+	if err != nil {
+		log.Printf("Failed to load tutor conversation %s: %v", conversation.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load conversation"})
+		return nil, false
 	}
 
-	requestBody := geminiRequest{
-		Contents: []geminiContent{
-			{
-				Role:  "user",
-				Parts: []geminiPart{{Text: prompt}},
-			},
-		},
-		GenerationConfig: geminiGenerationConfig{
-			Temperature:     0.4,
-			MaxOutputTokens: 900,
-		},
+	// Follow-ups such as "explain the second point again" carry few terms of
+	// their own, so search with the previous question as well.
+	searchQuery := query
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Role == llm.RoleUser {
+			searchQuery = history[i].Content + " " + query
+			break
+		}
 	}
 
-	payload, err := json.Marshal(requestBody)
+	results, err := h.Index.Search(course.ID, searchQuery, retrieval.DefaultTopK)
 	if err != nil {
-		return "", err
+		log.Printf("Tutor retrieval for course %s failed: %v", course.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search course materials"})
+		return nil, false
 	}
 
-	url := "https://generativelanguage.googleapis.com/v1beta/models/" + modelName + ":generateContent?key=" + h.GeminiAPIKey
-	httpReq, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(payload))
+	return &tutorTurn{
+		conversation: conversation,
+		query:        query,
+		request:      buildTutorRequest(course, conversation.Summary, history, query, results),
+		results:      results,
+	}, true
+}
+
+// loadTutorCourse resolves the course and checks the caller is enrolled in it.
+func (h *AIHandler) loadTutorCourse(c *gin.Context, courseIDParam string) (models.Course, bool) {
+	userID := c.MustGet("userID").(uuid.UUID)
+
+	courseID, err := uuid.Parse(courseIDParam)
 	if err != nil {
-		return "", err
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid course ID"})
+		return models.Course{}, false
 	}
-	httpReq.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(httpReq)
+	course, err := h.Courses.GetByID(courseID)
 	if err != nil {
-		return "", err
+		c.JSON(http.StatusNotFound, gin.H{"error": "Course not found"})
+		return models.Course{}, false
 	}
-	defer resp.Body.Close()
 
-	body, _ := io.ReadAll(resp.Body)
-	if resp.StatusCode >= 400 {
-		return "", errors.New("gemini returned non-2xx response")
+	if _, ok := requireCourseAccess(c, h.Orgs, h.Courses, userID, course); !ok {
+		return *course, false
 	}
 
-	type geminiResponse struct {
-		Candidates []struct {
-			Content struct {
-				Parts []struct {
-					Text string `json:"text"`
-				} `json:"parts"`
-			} `json:"content"`
-		} `json:"candidates"`
+	return *course, true
+}
+
+const tutorSystemPrompt = `You are MyWay AI Tutor.
+
+Rules:
+- Explain clearly and practically.
+- Use short paragraphs and bullet points when useful.
+- If user asks for differences/comparisons, provide a side-by-side style explanation.
+- If user asks for steps, give numbered steps.
+- Do NOT start responses with greetings (no "Hi", "Hello", "Hey", "Great question", or similar openers).
+- Start directly with the answer.
+- Keep tone professional, concise, and natural.
+- End with one concise check-for-understanding question.
+- Ground the answer in the numbered course material excerpts and cite them inline like [1] or [2][3].
+- The excerpts are what the instructor actually taught; when they differ from general knowledge, follow the excerpts.
+- If the excerpts do not cover the question, say so briefly before answering from general knowledge.`
+
+func buildTutorRequest(course models.Course, summary *string, history []llm.Message, query string, results []retrieval.Result) llm.Request {
+	var sb strings.Builder
+	sb.WriteString("Course: " + course.Code + " - " + course.Title + "\n\n")
+	if len(results) == 0 {
+		sb.WriteString("Course material excerpts: none matched this question.\n")
+	} else {
+		sb.WriteString("Course material excerpts:\n")
+		for i, result := range results {
+			sb.WriteString(fmt.Sprintf("[%d] %s", i+1, result.MaterialTitle))
+			if result.StartSec != nil {
+				sb.WriteString(" (at " + retrieval.FormatTimestamp(*result.StartSec) + ")")
+			} else if result.Page != nil {
+				sb.WriteString(fmt.Sprintf(" (page %d)", *result.Page))
+			}
+			sb.WriteString(":\n" + result.Content + "\n\n")
+		}
 	}
+	sb.WriteString("User question: " + query)
 
-	var parsed geminiResponse
-	if err := json.Unmarshal(body, &parsed); err != nil {
-		return "", err
+	system := tutorSystemPrompt
+	if summary != nil && strings.TrimSpace(*summary) != "" {
+		system += "\n\nSummary of the earlier conversation:\n" + strings.TrimSpace(*summary)
 	}
 
-	for _, candidate := range parsed.Candidates {
-		for _, part := range candidate.Content.Parts {
-			text := strings.TrimSpace(part.Text)
-			if text != "" {
-				return text, nil
-			}
+	messages := make([]llm.Message, 0, len(history)+1)
+	messages = append(messages, history...)
+	messages = append(messages, llm.Message{Role: llm.RoleUser, Content: sb.String()})
+
+	return llm.Request{
+		System:      system,
+		Messages:    messages,
+		Temperature: 0.4,
+		MaxTokens:   900,
+	}
+}
+
+func buildSourceReferences(results []retrieval.Result) []gin.H {
+	references := make([]gin.H, 0, len(results))
+	for i, result := range results {
+		reference := gin.H{
+			"index":         i + 1,
+			"materialId":    result.MaterialID,
+			"materialTitle": result.MaterialTitle,
+			"moduleId":      result.ModuleID,
+			"source":        result.Source,
+			"snippet":       snippet(result.Content, 240),
+			"startSec":      result.StartSec,
+			"endSec":        result.EndSec,
+			"page":          result.Page,
+			"timestamp":     nil,
 		}
+		if result.StartSec != nil {
+			reference["timestamp"] = retrieval.FormatTimestamp(*result.StartSec)
+		}
+		references = append(references, reference)
 	}
+	return references
+}
 
-	return "", errors.New("empty response from gemini")
+func countMaterials(results []retrieval.Result) int {
+	seen := make(map[uuid.UUID]bool)
+	for _, result := range results {
+		seen[result.MaterialID] = true
+	}
+	return len(seen)
+}
+
+func snippet(text string, maxLen int) string {
+	runes := []rune(strings.TrimSpace(text))
+	if len(runes) <= maxLen {
+		return string(runes)
+	}
+	return strings.TrimSpace(string(runes[:maxLen])) + "…"
 }
 
 func sanitizeTutorAnswer(input string) string {
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"myway-backend/internal/llm"
+	"myway-backend/internal/models"
+	"myway-backend/internal/repository"
+	"myway-backend/internal/retrieval"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestStudyPackReview(t *testing.T) {
+	s := newTestServer(t)
+	teacher := s.user("teacher@example.com", repository.RoleTeacher)
+	student := s.user("student@example.com", repository.RoleStudent)
+	org := s.org(teacher)
+	s.join(org, student, repository.RoleStudent)
+	course := s.course(org, teacher)
+	s.enroll(course, student, repository.EnrollmentStudent)
+	material := s.material(course)
+	teacherToken := s.token(teacher, false)
+	studentToken := s.token(student, false)
+	path := "/ai/review/" + material.ID.String()
+
+	s.expect(s.do(http.MethodPost, path+"/regenerate", studentToken, nil), http.StatusForbidden)
+	draft := s.expect(s.do(http.MethodPost, path+"/regenerate", teacherToken, nil), http.StatusOK)
+	if draft["flashcardCount"].(float64) == 0 {
+		t.Fatalf("regenerated draft has no flashcards: %v", draft)
+	}
+
+	approval := map[string]interface{}{"summary": "Binary search in brief.", "keyPoints": []string{"Halve the range"}}
+	s.expect(s.do(http.MethodPost, path+"/approve", studentToken, approval), http.StatusForbidden)
+	s.expect(s.do(http.MethodPost, path+"/approve", teacherToken, approval), http.StatusOK)
+
+	pack := s.expect(s.do(http.MethodGet, "/ai/studypack/"+material.ID.String(), studentToken, nil), http.StatusOK)
+	if pack["status"] != "READY" {
+		t.Fatalf("study pack status = %v, want READY", pack["status"])
+	}
+	if len(s.index.indexed) != 2 {
+		t.Fatalf("material indexed %d times, want after regeneration and approval", len(s.index.indexed))
+	}
+
+	events, _, err := s.repos.Audit.List(org.ID, repository.AuditFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Action != auditStudyPackApprove {
+		t.Fatalf("audit events = %+v, want one approval", events)
+	}
+}
+
+func TestTutorConversation(t *testing.T) {
+	s := newTestServer(t)
+	teacher := s.user("teacher@example.com", repository.RoleTeacher)
+	student := s.user("student@example.com", repository.RoleStudent)
+	other := s.user("other@example.com", repository.RoleStudent)
+	org := s.org(teacher)
+	s.join(org, student, repository.RoleStudent)
+	s.join(org, other, repository.RoleStudent)
+	course := s.course(org, teacher)
+	s.enroll(course, student, repository.EnrollmentStudent)
+	material := s.material(course)
+	s.index.Results = []retrieval.Result{{MaterialID: material.ID, MaterialTitle: material.Title, Source: "transcript", Content: *material.TranscriptText}}
+	studentToken := s.token(student, false)
+	otherToken := s.token(other, false)
+
+	ask := map[string]string{"courseId": course.ID.String(), "query": "How does binary search work?"}
+	s.expect(s.do(http.MethodPost, "/ai/tutor", otherToken, ask), http.StatusForbidden)
+	first := s.expect(s.do(http.MethodPost, "/ai/tutor", studentToken, ask), http.StatusOK)
+	conversationID := first["conversationId"].(string)
+	if refs := first["sourceReferences"].([]interface{}); len(refs) != 1 {
+		t.Fatalf("source references = %v, want the indexed material", refs)
+	}
+
+	followUp := map[string]string{"courseId": course.ID.String(), "query": "Why must it be sorted?", "conversationId": conversationID}
+	s.expect(s.do(http.MethodPost, "/ai/tutor", studentToken, followUp), http.StatusOK)
+
+	// The follow-up carries the first turn and is searched with it
+	requests := s.llm.Requests()
+	last := requests[len(requests)-1]
+	if len(last.Messages) != 3 || last.Messages[1].Role != llm.RoleAssistant {
+		t.Fatalf("follow-up messages = %+v, want the previous turn before the question", last.Messages)
+	}
+	if search := s.index.searches[len(s.index.searches)-1]; !strings.Contains(search, "binary search") {
+		t.Fatalf("follow-up search %q does not include the previous question", search)
+	}
+
+	conversation := s.expect(s.do(http.MethodGet, "/ai/tutor/conversations/"+conversationID, studentToken, nil), http.StatusOK)
+	if messages := conversation["messages"].([]interface{}); len(messages) != 4 {
+		t.Fatalf("conversation has %d messages, want 4", len(messages))
+	}
+	if conversation["title"] != "How does binary search work?" {
+		t.Fatalf("conversation title = %v", conversation["title"])
+	}
+
+	s.expect(s.do(http.MethodGet, "/ai/tutor/conversations/"+conversationID, otherToken, nil), http.StatusNotFound)
+	s.expect(s.do(http.MethodDelete, "/ai/tutor/conversations/"+conversationID, otherToken, nil), http.StatusNotFound)
+	s.expect(s.do(http.MethodDelete, "/ai/tutor/conversations/"+conversationID, studentToken, nil), http.StatusOK)
+
+	w := s.do(http.MethodGet, "/ai/tutor/conversations", studentToken, nil)
+	s.expect(w, http.StatusOK)
+	if list := decodeList(t, w); len(list) != 0 {
+		t.Fatalf("conversations after delete = %v", list)
+	}
+}
+
+func TestImportYouTubeQueuesStudyPack(t *testing.T) {
+	s := newTestServer(t)
+	teacher := s.user("teacher@example.com", repository.RoleTeacher)
+	org := s.org(teacher)
+	course := s.course(org, teacher)
+	token := s.token(teacher, false)
+
+	imported := s.expect(s.do(http.MethodPost, "/imports/youtube", token, map[string]string{
+		"courseId":   course.ID.String(),
+		"youtubeUrl": "https://www.youtube.com/watch?v=dQw4w9WgXcQ",
+	}), http.StatusCreated)
+	materialID := imported["material"].(map[string]interface{})["ID"].(string)
+
+	status := s.expect(s.do(http.MethodGet, "/imports/status/"+materialID, token, nil), http.StatusOK)
+	if status["status"] != "QUEUED" || status["job"] == nil {
+		t.Fatalf("import status = %v, want QUEUED with a job", status)
+	}
+
+	// The material went into the course's Resources module, created once
+	s.expect(s.do(http.MethodPost, "/imports/youtube", token, map[string]string{
+		"courseId":   course.ID.String(),
+		"youtubeUrl": "https://youtu.be/dQw4w9WgXcQ",
+	}), http.StatusCreated)
+	modules, err := s.repos.Courses.ListModules(course.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(modules) != 1 || modules[0].Title != "Resources" {
+		t.Fatalf("modules = %+v, want one Resources module", modules)
+	}
+}
+
+func TestImportsRequireCourseTeacher(t *testing.T) {
+	s := newTestServer(t)
+	teacher := s.user("teacher@example.com", repository.RoleTeacher)
+	student := s.user("student@example.com", repository.RoleStudent)
+	outsider := s.user("outsider@example.com", repository.RoleTeacher)
+	org := s.org(teacher)
+	s.join(org, student, repository.RoleStudent)
+	course := s.course(org, teacher)
+	s.enroll(course, student, repository.EnrollmentStudent)
+	otherCourse := s.course(s.org(outsider), outsider)
+	material := s.material(course)
+	video := map[string]string{"courseId": course.ID.String(), "youtubeUrl": "https://youtu.be/dQw4w9WgXcQ"}
+	document := map[string]string{"courseId": course.ID.String(), "fileUrl": "https://example.com/notes.pdf", "title": "Notes"}
+
+	for _, token := range []string{s.token(student, false), s.token(outsider, false)} {
+		s.expect(s.do(http.MethodPost, "/imports/youtube", token, video), http.StatusForbidden)
+		s.expect(s.do(http.MethodPost, "/imports/document", token, document), http.StatusForbidden)
+	}
+	s.expect(s.do(http.MethodGet, "/imports/status/"+material.ID.String(), s.token(outsider, false), nil), http.StatusForbidden)
+
+	// A module of another course is refused
+	foreign := &models.Module{CourseID: otherCourse.ID, Title: "Week 1"}
+	if err := s.repos.Courses.CreateModule(foreign); err != nil {
+		t.Fatal(err)
+	}
+	document["moduleId"] = foreign.ID.String()
+	s.expect(s.do(http.MethodPost, "/imports/document", s.token(teacher, false), document), http.StatusBadRequest)
+	delete(document, "moduleId")
+	s.expect(s.do(http.MethodPost, "/imports/document", s.token(teacher, false), document), http.StatusCreated)
+}
+
+func TestStudyPackReadsRequireEnrollment(t *testing.T) {
+	s := newTestServer(t)
+	teacher := s.user("teacher@example.com", repository.RoleTeacher)
+	student := s.user("student@example.com", repository.RoleStudent)
+	outsider := s.user("outsider@example.com", repository.RoleStudent)
+	org := s.org(teacher)
+	s.join(org, student, repository.RoleStudent)
+	s.join(org, outsider, repository.RoleStudent)
+	course := s.course(org, teacher)
+	s.enroll(course, student, repository.EnrollmentStudent)
+	material := s.material(course)
+	studentToken := s.token(student, false)
+	path := "/ai/studypack/" + material.ID.String()
+
+	// A draft is for the teacher only
+	draft := s.expect(s.do(http.MethodPost, "/ai/review/"+material.ID.String()+"/regenerate", s.token(teacher, false), nil), http.StatusOK)
+	cards := "/flashcards/studypack/" + draft["draft"].(map[string]interface{})["studyPackId"].(string)
+	s.expect(s.do(http.MethodGet, path, s.token(teacher, false), nil), http.StatusOK)
+	s.expect(s.do(http.MethodGet, path, studentToken, nil), http.StatusNotFound)
+	s.expect(s.do(http.MethodGet, cards, studentToken, nil), http.StatusNotFound)
+
+	pack := s.readyStudyPack(material)
+	cards = "/flashcards/studypack/" + pack.ID.String()
+	s.expect(s.do(http.MethodGet, path, studentToken, nil), http.StatusOK)
+	s.expect(s.do(http.MethodGet, cards, studentToken, nil), http.StatusOK)
+	s.expect(s.do(http.MethodGet, path, s.token(outsider, false), nil), http.StatusForbidden)
+	s.expect(s.do(http.MethodGet, cards, s.token(outsider, false), nil), http.StatusForbidden)
+}
@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"myway-backend/internal/models"
+	"myway-backend/internal/repository"
+	jwtutil "myway-backend/pkg/jwt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultAPITokenDays = 90
+	maxAPITokenDays     = 365
+)
+
+// APITokenHandler manages personal access tokens and organization API keys.
+// Both are created and revoked from a signed-in session only.
+type APITokenHandler struct {
+	Tokens repository.APITokenRepository
+	Orgs   repository.OrgRepository
+}
+
+func NewAPITokenHandler(repos *repository.Repositories) *APITokenHandler {
+	return &APITokenHandler{Tokens: repos.APITokens, Orgs: repos.Orgs}
+}
+
+type CreateAPITokenRequest struct {
+	Name   string   `json:"name" binding:"required"`
+	Scopes []string `json:"scopes" binding:"required,min=1"`
+	// ExpiresInDays defaults to 90 and may be at most 365.
+	ExpiresInDays *int `json:"expiresInDays"`
+}
+
+// CreatePersonalToken issues a token that acts for the caller. The token is
+// in the response only.
+func (h *APITokenHandler) CreatePersonalToken(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+	h.create(c, userID, nil)
+}
+
+func (h *APITokenHandler) ListPersonalTokens(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+	tokens, err := h.Tokens.ListPersonal(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tokens"})
+		return
+	}
+	c.JSON(http.StatusOK, apiTokenList(tokens))
+}
+
+func (h *APITokenHandler) RevokePersonalToken(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+	token, ok := h.loadToken(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	if token.UserID != userID || token.OrgID != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Token not found"})
+		return
+	}
+	h.revoke(c, token)
+}
+
+// CreateOrgKey issues an API key for the organization. The key acts for the
+// organizer creating it, within the organization only, and stops working if
+// they leave it.
+func (h *APITokenHandler) CreateOrgKey(c *gin.Context) {
+	membership, ok := requireOrgRole(c, h.Orgs, "Only organizers can manage API keys", "ORGANIZER")
+	if !ok {
+		return
+	}
+	h.create(c, membership.UserID, &membership.OrgID)
+}
+
+func (h *APITokenHandler) ListOrgKeys(c *gin.Context) {
+	membership, ok := requireOrgRole(c, h.Orgs, "Only organizers can manage API keys", "ORGANIZER")
+	if !ok {
+		return
+	}
+	tokens, err := h.Tokens.ListByOrg(membership.OrgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch API keys"})
+		return
+	}
+	c.JSON(http.StatusOK, apiTokenList(tokens))
+}
+
+func (h *APITokenHandler) RevokeOrgKey(c *gin.Context) {
+	membership, ok := requireOrgRole(c, h.Orgs, "Only organizers can manage API keys", "ORGANIZER")
+	if !ok {
+		return
+	}
+	token, ok := h.loadToken(c, c.Param("keyId"))
+	if !ok {
+		return
+	}
+	if token.OrgID == nil || *token.OrgID != membership.OrgID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Token not found"})
+		return
+	}
+	h.revoke(c, token)
+}
+
+func (h *APITokenHandler) create(c *gin.Context, userID uuid.UUID, orgID *uuid.UUID) {
+	var req CreateAPITokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	scopes, err := parseScopes(req.Scopes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	days := defaultAPITokenDays
+	if req.ExpiresInDays != nil {
+		days = *req.ExpiresInDays
+	}
+	if days < 1 || days > maxAPITokenDays {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("expiresInDays must be between 1 and %d", maxAPITokenDays)})
+		return
+	}
+
+	secret, _, err := jwtutil.NewOpaqueToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+	raw := jwtutil.APITokenPrefix + secret
+	expiresAt := time.Now().AddDate(0, 0, days)
+	token := models.APIToken{
+		UserID:    userID,
+		OrgID:     orgID,
+		Name:      strings.TrimSpace(req.Name),
+		Prefix:    raw[:len(jwtutil.APITokenPrefix)+6],
+		TokenHash: jwtutil.HashToken(raw),
+		Scopes:    strings.Join(scopes, " "),
+		MFA:       c.GetBool("mfa"),
+		ExpiresAt: &expiresAt,
+	}
+	if err := h.Tokens.Create(&token); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store token"})
+		return
+	}
+
+	response := apiTokenResponse(&token)
+	response["token"] = raw
+	c.JSON(http.StatusCreated, response)
+}
+
+func (h *APITokenHandler) loadToken(c *gin.Context, idParam string) (*models.APIToken, bool) {
+	id, err := uuid.Parse(idParam)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid token ID"})
+		return nil, false
+	}
+	token, err := h.Tokens.GetByID(id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Token not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch token"})
+		}
+		return nil, false
+	}
+	return token, true
+}
+
+func (h *APITokenHandler) revoke(c *gin.Context, token *models.APIToken) {
+	if err := h.Tokens.Revoke(token.ID, time.Now()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke token"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Token revoked"})
+}
+
+// parseScopes checks the requested scopes against repository.APIScopes and
+// drops duplicates.
+func parseScopes(requested []string) ([]string, error) {
+	known := make(map[string]bool, len(repository.APIScopes))
+	for _, scope := range repository.APIScopes {
+		known[scope] = true
+	}
+	seen := make(map[string]bool, len(requested))
+	var scopes []string
+	for _, scope := range requested {
+		scope = strings.ToLower(strings.TrimSpace(scope))
+		if !known[scope] {
+			return nil, fmt.Errorf("unknown scope %q; valid scopes are %s", scope, strings.Join(repository.APIScopes, ", "))
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes, nil
+}
+
+func apiTokenList(tokens []models.APIToken) []gin.H {
+	list := make([]gin.H, len(tokens))
+	for i := range tokens {
+		list[i] = apiTokenResponse(&tokens[i])
+	}
+	return list
+}
+
+func apiTokenResponse(token *models.APIToken) gin.H {
+	return gin.H{
+		"id":             token.ID,
+		"name":           token.Name,
+		"prefix":         token.Prefix,
+		"scopes":         strings.Fields(token.Scopes),
+		"organizationId": token.OrgID,
+		"createdBy":      token.UserID,
+		"expiresAt":      token.ExpiresAt,
+		"lastUsedAt":     token.LastUsedAt,
+		"revokedAt":      token.RevokedAt,
+		"createdAt":      token.CreatedAt,
+	}
+}
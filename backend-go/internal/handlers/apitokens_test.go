@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"myway-backend/internal/repository"
+	"net/http"
+	"testing"
+)
+
+func TestPersonalTokenScopes(t *testing.T) {
+	s := newTestServer(t)
+	teacher := s.user("teacher@example.com", repository.RoleTeacher)
+	org := s.org(teacher)
+	course := s.course(org, teacher)
+	session := s.token(teacher, false)
+	coursePath := "/courses/" + course.ID.String()
+	module := map[string]interface{}{"courseId": course.ID, "title": "Week 1"}
+
+	s.expect(s.do(http.MethodPost, "/api-tokens", session, map[string]interface{}{"name": "CI", "scopes": []string{"everything"}}), http.StatusBadRequest)
+	reader := s.expect(s.do(http.MethodPost, "/api-tokens", session, map[string]interface{}{"name": "CI", "scopes": []string{"courses:read"}}), http.StatusCreated)
+	readToken := reader["token"].(string)
+
+	s.expect(s.do(http.MethodGet, coursePath, readToken, nil), http.StatusOK)
+	s.expect(s.do(http.MethodPost, "/modules", readToken, module), http.StatusForbidden)
+	s.expect(s.do(http.MethodGet, "/assignments/course/"+course.ID.String(), readToken, nil), http.StatusOK)
+	s.expect(s.do(http.MethodGet, "/organizations/"+org.ID.String()+"/members", readToken, nil), http.StatusForbidden)
+
+	// Tokens cannot manage tokens or reach routes missing from the scope map
+	s.expect(s.do(http.MethodPost, "/api-tokens", readToken, map[string]interface{}{"name": "More", "scopes": []string{"courses:write"}}), http.StatusForbidden)
+	s.expect(s.do(http.MethodPost, "/ai/tutor", readToken, map[string]string{"courseId": course.ID.String(), "query": "Hi"}), http.StatusForbidden)
+
+	// A write scope includes reading
+	writer := s.expect(s.do(http.MethodPost, "/api-tokens", session, map[string]interface{}{"name": "Sync", "scopes": []string{"courses:write"}}), http.StatusCreated)
+	s.expect(s.do(http.MethodGet, coursePath, writer["token"].(string), nil), http.StatusOK)
+	s.expect(s.do(http.MethodPost, "/modules", writer["token"].(string), module), http.StatusCreated)
+
+	s.expect(s.do(http.MethodDelete, "/api-tokens/"+reader["id"].(string), session, nil), http.StatusOK)
+	s.expect(s.do(http.MethodGet, coursePath, readToken, nil), http.StatusUnauthorized)
+}
+
+func TestOrganizationKeyStaysInItsOrganization(t *testing.T) {
+	s := newTestServer(t)
+	organizer := s.user("organizer@example.com", repository.RoleOrganizer)
+	teacher := s.user("teacher@example.com", repository.RoleTeacher)
+	org := s.org(organizer)
+	s.join(org, teacher, repository.RoleTeacher)
+	other := s.org(organizer)
+	session := s.token(organizer, false)
+	orgPath := "/organizations/" + org.ID.String()
+
+	s.expect(s.do(http.MethodPost, orgPath+"/api-keys", s.token(teacher, false), map[string]interface{}{"name": "HR sync", "scopes": []string{"orgs:read"}}), http.StatusForbidden)
+	key := s.expect(s.do(http.MethodPost, orgPath+"/api-keys", session, map[string]interface{}{"name": "HR sync", "scopes": []string{"orgs:read"}}), http.StatusCreated)
+	raw := key["token"].(string)
+
+	s.expect(s.do(http.MethodGet, orgPath+"/members", raw, nil), http.StatusOK)
+	s.expect(s.do(http.MethodGet, "/organizations/"+other.ID.String()+"/members", raw, nil), http.StatusForbidden)
+
+	// Routes that check no organization are for personal tokens only
+	s.expect(s.do(http.MethodGet, "/organizations", raw, nil), http.StatusForbidden)
+	s.expect(s.do(http.MethodGet, "/auth/me", raw, nil), http.StatusForbidden)
+}
@@ -1,8 +1,8 @@
 package handlers
 
 import (
-	"myway-backend/internal/database"
 	"myway-backend/internal/models"
+	"myway-backend/internal/repository"
 	"net/http"
 	"strconv"
 	"strings"
@@ -12,10 +12,24 @@ import (
 	"github.com/google/uuid"
 )
 
-type AssignmentHandler struct{}
+type AssignmentHandler struct {
+	Users       repository.UserRepository
+	Orgs        repository.OrgRepository
+	Courses     repository.CourseRepository
+	Assessments repository.AssessmentRepository
+	Files       repository.FileRepository
+	Audit       repository.AuditRepository
+}
 
-func NewAssignmentHandler() *AssignmentHandler {
-	return &AssignmentHandler{}
+func NewAssignmentHandler(repos *repository.Repositories) *AssignmentHandler {
+	return &AssignmentHandler{
+		Users:       repos.Users,
+		Orgs:        repos.Orgs,
+		Courses:     repos.Courses,
+		Assessments: repos.Assessments,
+		Files:       repos.Files,
+		Audit:       repos.Audit,
+	}
 }
 
 type CreateAssignmentRequest struct {
@@ -51,20 +65,19 @@ func (h *AssignmentHandler) CreateAssignment(c *gin.Context) {
 		return
 	}
 
-	var course models.Course
-	if err := database.GetDB().First(&course, courseID).Error; err != nil {
+	course, err := h.Courses.GetByID(courseID)
+	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Course not found"})
 		return
 	}
 
-	var membership models.OrgMembership
-	if err := database.GetDB().Where("user_id = ? AND org_id = ? AND status = ?", userID, course.OrgID, "Active").First(&membership).Error; err != nil {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have access to this organization"})
+	access, ok := requireCourseAccess(c, h.Orgs, h.Courses, userID, course)
+	if !ok {
 		return
 	}
 
-	if membership.Role != "TEACHER" && membership.Role != "ORGANIZER" {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Only teachers or organizers can create assignments"})
+	if !access.canTeach() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only course teachers or organizers can create assignments"})
 		return
 	}
 
@@ -77,7 +90,7 @@ func (h *AssignmentHandler) CreateAssignment(c *gin.Context) {
 		Status:       "ACTIVE",
 	}
 
-	if err := database.GetDB().Create(&assignment).Error; err != nil {
+	if err := h.Assessments.CreateAssignment(&assignment); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create assignment"})
 		return
 	}
@@ -94,36 +107,35 @@ func (h *AssignmentHandler) GetAssignmentsByCourse(c *gin.Context) {
 
 	userID := c.MustGet("userID").(uuid.UUID)
 
-	var course models.Course
-	if err := database.GetDB().First(&course, courseID).Error; err != nil {
+	course, err := h.Courses.GetByID(courseID)
+	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Course not found"})
 		return
 	}
 
-	var membership models.OrgMembership
-	if err := database.GetDB().Where("user_id = ? AND org_id = ? AND status = ?", userID, course.OrgID, "Active").First(&membership).Error; err != nil {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have access to this organization"})
+	access, ok := requireCourseAccess(c, h.Orgs, h.Courses, userID, course)
+	if !ok {
 		return
 	}
 
-	var assignments []models.Assignment
-	if err := database.GetDB().
-		Where("course_id = ? AND status = ?", courseID, "ACTIVE").
-		Order("due_at ASC").
-		Find(&assignments).Error; err != nil {
+	assignments, err := h.Assessments.ListActiveAssignments(courseID)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch assignments"})
 		return
 	}
 
 	// Get user's submissions to determine status
-	var submissions []models.Submission
-	database.GetDB().Where("user_id = ?", userID).Find(&submissions)
+	submissions, err := h.Assessments.ListSubmissionsByUser(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch submissions"})
+		return
+	}
 	submissionMap := make(map[uuid.UUID]models.Submission)
 	for _, sub := range submissions {
 		submissionMap[sub.AssignmentID] = sub
 	}
 
-	isTeacherView := membership.Role == "TEACHER" || membership.Role == "ORGANIZER"
+	isTeacherView := access.isStaff()
 
 	// Build response with status
 	result := make([]gin.H, len(assignments))
@@ -157,8 +169,7 @@ func (h *AssignmentHandler) GetAssignmentsByCourse(c *gin.Context) {
 				result[i]["submission"] = sub
 			}
 		} else {
-			var submissionCount int64
-			database.GetDB().Model(&models.Submission{}).Where("assignment_id = ?", assignment.ID).Count(&submissionCount)
+			submissionCount, _ := h.Assessments.CountSubmissions(assignment.ID)
 			result[i]["submissionCount"] = submissionCount
 		}
 
@@ -179,22 +190,18 @@ func (h *AssignmentHandler) GetAssignment(c *gin.Context) {
 
 	userID := c.MustGet("userID").(uuid.UUID)
 
-	var assignment models.Assignment
-	if err := database.GetDB().
-		Preload("Submissions").
-		Preload("Course").
-		First(&assignment, assignmentID).Error; err != nil {
+	assignment, err := h.Assessments.GetAssignment(assignmentID)
+	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Assignment not found"})
 		return
 	}
 
-	var membership models.OrgMembership
-	if err := database.GetDB().Where("user_id = ? AND org_id = ? AND status = ?", userID, assignment.Course.OrgID, "Active").First(&membership).Error; err != nil {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have access to this organization"})
+	access, ok := requireCourseAccess(c, h.Orgs, h.Courses, userID, &assignment.Course)
+	if !ok {
 		return
 	}
 
-	isTeacherView := membership.Role == "TEACHER" || membership.Role == "ORGANIZER"
+	isTeacherView := access.isStaff()
 
 	response := gin.H{
 		"id":           assignment.ID,
@@ -215,12 +222,9 @@ func (h *AssignmentHandler) GetAssignment(c *gin.Context) {
 		}
 
 		userNameMap := make(map[uuid.UUID]string)
-		if len(userIDs) > 0 {
-			var users []models.User
-			database.GetDB().Where("id IN ?", userIDs).Find(&users)
-			for _, u := range users {
-				userNameMap[u.ID] = u.Name
-			}
+		users, _ := h.Users.ListByIDs(userIDs)
+		for _, u := range users {
+			userNameMap[u.ID] = u.Name
 		}
 
 		safeSubmissions := make([]gin.H, 0, len(assignment.Submissions))
@@ -259,8 +263,10 @@ func (h *AssignmentHandler) GetAssignment(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// SubmitAssignmentRequest names a file the student uploaded for the
+// assignment's course; links to files stored elsewhere are not accepted.
 type SubmitAssignmentRequest struct {
-	FileURL *string `json:"fileUrl"`
+	FileID string `json:"fileId" binding:"required"`
 }
 
 func (h *AssignmentHandler) SubmitAssignment(c *gin.Context) {
@@ -279,22 +285,34 @@ func (h *AssignmentHandler) SubmitAssignment(c *gin.Context) {
 	}
 
 	// Check if assignment exists
-	var assignment models.Assignment
-	if err := database.GetDB().First(&assignment, assignmentID).Error; err != nil {
+	assignment, err := h.Assessments.GetAssignment(assignmentID)
+	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Assignment not found"})
 		return
 	}
 
+	if _, ok := requireCourseAccess(c, h.Orgs, h.Courses, userID, &assignment.Course); !ok {
+		return
+	}
+
+	// The uploaded file must belong to this course and to the submitter
+	file, ok := attachedFile(c, h.Files, req.FileID, assignment.CourseID, FilePurposeSubmission)
+	if !ok {
+		return
+	}
+	if file.UploadedBy != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only submit files you uploaded"})
+		return
+	}
+
 	// Check if submission already exists
-	var existingSubmission models.Submission
-	if err := database.GetDB().Where("assignment_id = ? AND user_id = ?", assignmentID, userID).First(&existingSubmission).Error; err == nil {
+	if existingSubmission, err := h.Assessments.GetSubmission(assignmentID, userID); err == nil {
 		// Update existing submission
 		existingSubmission.Status = "SUBMITTED"
 		existingSubmission.SubmittedAt = time.Now()
-		if req.FileURL != nil {
-			existingSubmission.FileURL = req.FileURL
-		}
-		if err := database.GetDB().Save(&existingSubmission).Error; err != nil {
+		existingSubmission.FileID = &file.ID
+		existingSubmission.FileURL = nil
+		if err := h.Assessments.SaveSubmission(existingSubmission); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update submission"})
 			return
 		}
@@ -308,10 +326,10 @@ func (h *AssignmentHandler) SubmitAssignment(c *gin.Context) {
 		UserID:       userID,
 		Status:       "SUBMITTED",
 		SubmittedAt:  time.Now(),
-		FileURL:      req.FileURL,
+		FileID:       &file.ID,
 	}
 
-	if err := database.GetDB().Create(&submission).Error; err != nil {
+	if err := h.Assessments.SaveSubmission(&submission); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create submission"})
 		return
 	}
@@ -343,8 +361,8 @@ func (h *AssignmentHandler) GradeSubmission(c *gin.Context) {
 		return
 	}
 
-	var submission models.Submission
-	if err := database.GetDB().Preload("Assignment.Course").First(&submission, submissionID).Error; err != nil {
+	submission, err := h.Assessments.GetSubmissionWithAssignment(submissionID)
+	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Submission not found"})
 		return
 	}
@@ -355,14 +373,13 @@ func (h *AssignmentHandler) GradeSubmission(c *gin.Context) {
 		return
 	}
 
-	// RBAC: only TEACHER or ORGANIZER in course organization can grade
-	var membership models.OrgMembership
-	if err := database.GetDB().Where("user_id = ? AND org_id = ? AND status = ?", graderID, course.OrgID, "Active").First(&membership).Error; err != nil {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have access to this organization"})
+	// RBAC: only course staff or organizers can grade
+	access, ok := requireCourseAccess(c, h.Orgs, h.Courses, graderID, &course)
+	if !ok {
 		return
 	}
-	if membership.Role != "TEACHER" && membership.Role != "ORGANIZER" {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Only teachers or organizers can grade submissions"})
+	if !access.isStaff() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only course staff or organizers can grade submissions"})
 		return
 	}
 
@@ -371,6 +388,7 @@ func (h *AssignmentHandler) GradeSubmission(c *gin.Context) {
 		return
 	}
 
+	before := gradeAudit(submission)
 	gradeValue := strconv.Itoa(req.Score)
 	submission.Status = "GRADED"
 	submission.Grade = &gradeValue
@@ -381,7 +399,11 @@ func (h *AssignmentHandler) GradeSubmission(c *gin.Context) {
 		submission.Feedback = &feedback
 	}
 
-	if err := database.GetDB().Save(&submission).Error; err != nil {
+	event := newAuditEvent(c, course.OrgID, auditSubmissionGrade, "submission", submission.ID, before, gradeAudit(submission))
+	err = h.Audit.Record(event, func(tx *repository.Repositories) error {
+		return tx.Assessments.SaveSubmission(submission)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to grade submission"})
 		return
 	}
@@ -396,3 +418,13 @@ func (h *AssignmentHandler) GradeSubmission(c *gin.Context) {
 		"feedback":     submission.Feedback,
 	})
 }
+
+// gradeAudit is what the audit log records of a submission's grading.
+func gradeAudit(submission *models.Submission) gin.H {
+	return gin.H{
+		"userId":   submission.UserID,
+		"status":   submission.Status,
+		"grade":    submission.Grade,
+		"feedback": submission.Feedback,
+	}
+}
@@ -0,0 +1,333 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"mime"
+	"myway-backend/internal/models"
+	"myway-backend/internal/repository"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Actions recorded in the audit log.
+const (
+	auditOrganizationDelete = "organization.delete"
+	auditCourseDelete       = "course.delete"
+	auditSubmissionGrade    = "submission.grade"
+	auditStudyPackApprove   = "study_pack.approve"
+	auditMemberJoin         = "member.join"
+	auditMemberRoleChange   = "member.role_change"
+	auditMemberSuspend      = "member.suspend"
+	auditMemberReactivate   = "member.reactivate"
+	auditMemberRemove       = "member.remove"
+	auditOwnershipTransfer  = "member.ownership_transfer"
+)
+
+const (
+	defaultAuditPageSize = 50
+	// auditExportBatch is how many events an export reads at a time.
+	auditExportBatch = 500
+)
+
+type AuditHandler struct {
+	Users repository.UserRepository
+	Orgs  repository.OrgRepository
+	Audit repository.AuditRepository
+}
+
+func NewAuditHandler(repos *repository.Repositories) *AuditHandler {
+	return &AuditHandler{Users: repos.Users, Orgs: repos.Orgs, Audit: repos.Audit}
+}
+
+// newAuditEvent starts the record of the caller's action on a target in the
+// organization. before and after are the target's fields around the action,
+// nil when it did not exist before or no longer exists after; only the
+// fields that changed are kept.
+func newAuditEvent(c *gin.Context, orgID uuid.UUID, action, targetType string, targetID uuid.UUID, before, after gin.H) *models.AuditEvent {
+	event := &models.AuditEvent{
+		OrgID:      orgID,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		IP:         c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+	}
+	if userID, ok := c.Get("userID"); ok {
+		event.ActorID = userID.(uuid.UUID)
+	}
+	if token, ok := c.Get("apiToken"); ok {
+		tokenID := token.(*models.APIToken).ID
+		event.APITokenID = &tokenID
+	}
+	event.Before, event.After = auditDiff(before, after)
+	return event
+}
+
+// auditDiff encodes the fields of before and after whose values differ.
+func auditDiff(before, after gin.H) (*string, *string) {
+	changedBefore, changedAfter := gin.H{}, gin.H{}
+	for key, value := range before {
+		if other, ok := after[key]; !ok || !sameJSON(value, other) {
+			changedBefore[key] = value
+		}
+	}
+	for key, value := range after {
+		if other, ok := before[key]; !ok || !sameJSON(value, other) {
+			changedAfter[key] = value
+		}
+	}
+	return auditJSON(before, changedBefore), auditJSON(after, changedAfter)
+}
+
+func sameJSON(a, b interface{}) bool {
+	encodedA, errA := json.Marshal(a)
+	encodedB, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(encodedA, encodedB)
+}
+
+func auditJSON(side, changed gin.H) *string {
+	if side == nil {
+		return nil
+	}
+	encoded, err := json.Marshal(changed)
+	if err != nil {
+		return nil
+	}
+	value := string(encoded)
+	return &value
+}
+
+// ListAuditEvents returns a page of the organization's audit log, newest
+// first, to its organizers.
+func (h *AuditHandler) ListAuditEvents(c *gin.Context) {
+	orgID, ok := h.requireAuditor(c)
+	if !ok {
+		return
+	}
+	filter, ok := auditFilter(c)
+	if !ok {
+		return
+	}
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	limit := queryLimit(c, "limit", defaultAuditPageSize, 200)
+	if limit == 0 {
+		limit = defaultAuditPageSize
+	}
+	filter.Offset = (page - 1) * limit
+	filter.Limit = limit
+
+	events, total, err := h.Audit.List(orgID, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch audit events"})
+		return
+	}
+	list := make([]gin.H, len(events))
+	for i := range events {
+		list[i] = auditEventResponse(&events[i])
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"events": list,
+		"total":  total,
+		"page":   page,
+		"limit":  limit,
+	})
+}
+
+// ExportAuditEvents downloads every event matching the filters as CSV or,
+// with format=ndjson, as one JSON object per line.
+func (h *AuditHandler) ExportAuditEvents(c *gin.Context) {
+	orgID, ok := h.requireAuditor(c)
+	if !ok {
+		return
+	}
+	filter, ok := auditFilter(c)
+	if !ok {
+		return
+	}
+	format := strings.ToLower(c.DefaultQuery("format", "csv"))
+	if format != "csv" && format != "ndjson" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv or ndjson"})
+		return
+	}
+	// Events recorded during the export would shift the pages under it
+	if filter.Until == nil {
+		now := time.Now()
+		filter.Until = &now
+	}
+	filter.Limit = auditExportBatch
+
+	events, _, err := h.Audit.List(orgID, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch audit events"})
+		return
+	}
+
+	filename := fmt.Sprintf("audit-%s.%s", orgID, format)
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	var csvWriter *csv.Writer
+	if format == "csv" {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		csvWriter = csv.NewWriter(c.Writer)
+		csvWriter.Write([]string{"id", "createdAt", "actorId", "actorEmail", "apiTokenId", "action", "targetType", "targetId", "before", "after", "ip", "userAgent"})
+	} else {
+		c.Header("Content-Type", "application/x-ndjson")
+	}
+	c.Status(http.StatusOK)
+
+	encoder := json.NewEncoder(c.Writer)
+	for len(events) > 0 {
+		for i := range events {
+			if csvWriter != nil {
+				csvWriter.Write(auditEventRecord(&events[i]))
+			} else if err := encoder.Encode(auditEventResponse(&events[i])); err != nil {
+				return
+			}
+		}
+		if csvWriter != nil {
+			csvWriter.Flush()
+			if csvWriter.Error() != nil {
+				return
+			}
+		}
+		if len(events) < auditExportBatch {
+			break
+		}
+		filter.Offset += auditExportBatch
+		events, _, err = h.Audit.List(orgID, filter)
+		if err != nil {
+			// The status is sent; the download ends short
+			log.Printf("Failed to export audit events of organization %s: %v", orgID, err)
+			return
+		}
+	}
+}
+
+// requireAuditor checks the caller organizes the :id organization or is a
+// platform admin. Admins need no membership, so the log of a deleted
+// organization, whose memberships went with it, stays readable to them.
+func (h *AuditHandler) requireAuditor(c *gin.Context) (uuid.UUID, bool) {
+	user, err := h.Users.GetByID(c.MustGet("userID").(uuid.UUID))
+	if err == nil && user.Role == repository.RoleAdmin {
+		orgID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+			return uuid.Nil, false
+		}
+		return orgID, apiKeyAllows(c, orgID)
+	}
+	membership, ok := requireOrgRole(c, h.Orgs, "Only organizers can read the audit log", "ORGANIZER")
+	if !ok {
+		return uuid.Nil, false
+	}
+	return membership.OrgID, true
+}
+
+// auditFilter reads the action, actorId, targetType, targetId, since and
+// until query parameters. Times are RFC 3339 or dates.
+func auditFilter(c *gin.Context) (repository.AuditFilter, bool) {
+	filter := repository.AuditFilter{
+		Action:     strings.TrimSpace(c.Query("action")),
+		TargetType: strings.TrimSpace(c.Query("targetType")),
+	}
+	for name, target := range map[string]**uuid.UUID{"actorId": &filter.ActorID, "targetId": &filter.TargetID} {
+		value := c.Query(name)
+		if value == "" {
+			continue
+		}
+		id, err := uuid.Parse(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + name})
+			return filter, false
+		}
+		*target = &id
+	}
+	for name, target := range map[string]**time.Time{"since": &filter.Since, "until": &filter.Until} {
+		value := c.Query(name)
+		if value == "" {
+			continue
+		}
+		at, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			at, err = time.Parse(time.DateOnly, value)
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": name + " must be an RFC 3339 time or a date"})
+			return filter, false
+		}
+		*target = &at
+	}
+	return filter, true
+}
+
+func auditEventResponse(event *models.AuditEvent) gin.H {
+	return gin.H{
+		"id":         event.ID,
+		"createdAt":  event.CreatedAt,
+		"actorId":    event.ActorID,
+		"actorEmail": event.ActorEmail,
+		"apiTokenId": event.APITokenID,
+		"action":     event.Action,
+		"targetType": event.TargetType,
+		"targetId":   event.TargetID,
+		"before":     rawJSON(event.Before),
+		"after":      rawJSON(event.After),
+		"ip":         event.IP,
+		"userAgent":  event.UserAgent,
+	}
+}
+
+func auditEventRecord(event *models.AuditEvent) []string {
+	tokenID := ""
+	if event.APITokenID != nil {
+		tokenID = event.APITokenID.String()
+	}
+	record := []string{
+		event.ID.String(),
+		event.CreatedAt.UTC().Format(time.RFC3339),
+		event.ActorID.String(),
+		event.ActorEmail,
+		tokenID,
+		event.Action,
+		event.TargetType,
+		event.TargetID.String(),
+		stringValue(event.Before),
+		stringValue(event.After),
+		event.IP,
+		event.UserAgent,
+	}
+	// Spreadsheets run cells starting with these as formulas
+	for i, value := range record {
+		if value != "" && strings.ContainsRune("=+-@", rune(value[0])) {
+			record[i] = "'" + value
+		}
+	}
+	return record
+}
+
+// rawJSON embeds a stored JSON document in a response, or null.
+func rawJSON(value *string) interface{} {
+	if value == nil {
+		return nil
+	}
+	return json.RawMessage(*value)
+}
+
+func stringValue(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
@@ -0,0 +1,272 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"myway-backend/internal/models"
+	"myway-backend/internal/repository"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// auditEvents returns the organization's events with the action, newest
+// first.
+func (s *testServer) auditEvents(org *models.Organization, action string) []models.AuditEvent {
+	s.t.Helper()
+	events, _, err := s.repos.Audit.List(org.ID, repository.AuditFilter{Action: action})
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return events
+}
+
+// expectAudit fails unless the organization has exactly one event with the
+// action, by actor on target, and returns it.
+func (s *testServer) expectAudit(org *models.Organization, action string, actor *models.User, targetID uuid.UUID) models.AuditEvent {
+	s.t.Helper()
+	events := s.auditEvents(org, action)
+	if len(events) != 1 {
+		s.t.Fatalf("%d %s events, want 1: %+v", len(events), action, events)
+	}
+	event := events[0]
+	if event.ActorID != actor.ID || event.ActorEmail != actor.Email || event.TargetID != targetID {
+		s.t.Fatalf("%s event by %s (%s) on %s, want by %s on %s", action, event.ActorID, event.ActorEmail, event.TargetID, actor.ID, targetID)
+	}
+	return event
+}
+
+func decodeAudit(t *testing.T, value *string) map[string]interface{} {
+	t.Helper()
+	if value == nil {
+		return nil
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(*value), &fields); err != nil {
+		t.Fatal(err)
+	}
+	return fields
+}
+
+func TestAuditedActionsRecordEvents(t *testing.T) {
+	s := newTestServer(t)
+	organizer := s.user("organizer@example.com", repository.RoleOrganizer)
+	teacher := s.user("teacher@example.com", repository.RoleTeacher)
+	student := s.user("student@example.com", repository.RoleStudent)
+	admin := s.user("admin@example.com", repository.RoleAdmin)
+	org := s.org(organizer)
+	s.join(org, teacher, repository.RoleTeacher)
+	s.join(org, student, repository.RoleStudent)
+	course := s.course(org, teacher)
+	s.enroll(course, student, repository.EnrollmentStudent)
+	material := s.material(course)
+	organizerToken := s.token(organizer, false)
+	teacherToken := s.token(teacher, false)
+	studentToken := s.token(student, false)
+
+	// Grading
+	created := s.expect(s.do(http.MethodPost, "/assignments", teacherToken, map[string]interface{}{
+		"courseId":     course.ID,
+		"title":        "Problem set 1",
+		"dueAt":        time.Now().Add(24 * time.Hour),
+		"points":       10,
+		"instructions": "Solve every exercise.",
+	}), http.StatusCreated)
+	submission := s.expect(s.do(http.MethodPost, "/assignments/"+created["ID"].(string)+"/submit", studentToken,
+		map[string]string{"fileId": s.upload(course, student, FilePurposeSubmission).ID.String()}), http.StatusCreated)
+	submissionID := submission["ID"].(string)
+	s.expect(s.do(http.MethodPut, "/submissions/"+submissionID+"/grade", teacherToken,
+		map[string]interface{}{"score": 8, "feedback": "Check exercise 3."}), http.StatusOK)
+	graded := s.expectAudit(org, auditSubmissionGrade, teacher, mustParse(t, submissionID))
+	if after := decodeAudit(t, graded.After); after["status"] != "GRADED" || after["grade"] != "8" || after["feedback"] != "Check exercise 3." {
+		t.Fatalf("grade event after = %v", after)
+	}
+	if before := decodeAudit(t, graded.Before); before["grade"] != nil || before["status"] == "GRADED" {
+		t.Fatalf("grade event before = %v", before)
+	}
+
+	// Study pack approval
+	path := "/ai/review/" + material.ID.String()
+	s.expect(s.do(http.MethodPost, path+"/regenerate", teacherToken, nil), http.StatusOK)
+	s.expect(s.do(http.MethodPost, path+"/approve", teacherToken, map[string]interface{}{"summary": "Binary search in brief.", "keyPoints": []string{"Halve the range"}}), http.StatusOK)
+	pack, err := s.repos.StudyPacks.GetLatestByMaterial(material.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.expectAudit(org, auditStudyPackApprove, teacher, pack.ID)
+
+	// Membership change; unchanged fields are left out
+	s.expect(s.do(http.MethodPut, "/organizations/"+org.ID.String()+"/members/"+student.ID.String(), organizerToken,
+		map[string]string{"role": "TEACHER"}), http.StatusOK)
+	changed := s.expectAudit(org, auditMemberRoleChange, organizer, student.ID)
+	if before, after := decodeAudit(t, changed.Before), decodeAudit(t, changed.After); len(before) != 1 || before["role"] != "STUDENT" || len(after) != 1 || after["role"] != "TEACHER" {
+		t.Fatalf("role change event = %v -> %v", before, after)
+	}
+
+	// Course deletion keeps what was deleted
+	s.expect(s.do(http.MethodDelete, "/courses/"+course.ID.String(), organizerToken, nil), http.StatusOK)
+	deleted := s.expectAudit(org, auditCourseDelete, organizer, course.ID)
+	if before := decodeAudit(t, deleted.Before); before["title"] != course.Title || deleted.After != nil {
+		t.Fatalf("course delete event = %v -> %v", before, deleted.After)
+	}
+
+	// Organization deletion; its log stays readable to platform admins
+	s.expect(s.do(http.MethodDelete, "/organizations/"+org.ID.String(), organizerToken, nil), http.StatusOK)
+	s.expectAudit(org, auditOrganizationDelete, organizer, org.ID)
+	s.expect(s.do(http.MethodGet, "/organizations/"+org.ID.String()+"/audit-events", organizerToken, nil), http.StatusForbidden)
+	log := s.expect(s.do(http.MethodGet, "/organizations/"+org.ID.String()+"/audit-events", s.token(admin, false), nil), http.StatusOK)
+	if log["total"].(float64) != 5 {
+		t.Fatalf("admin reads %v events of the deleted organization, want 5", log["total"])
+	}
+}
+
+func TestListAuditEvents(t *testing.T) {
+	s := newTestServer(t)
+	organizer := s.user("organizer@example.com", repository.RoleOrganizer)
+	teacher := s.user("teacher@example.com", repository.RoleTeacher)
+	student := s.user("student@example.com", repository.RoleStudent)
+	otherOrganizer := s.user("other@example.com", repository.RoleOrganizer)
+	org := s.org(organizer)
+	other := s.org(otherOrganizer)
+	s.join(org, teacher, repository.RoleTeacher)
+	s.join(org, student, repository.RoleStudent)
+	s.join(other, student, repository.RoleStudent)
+	token := s.token(organizer, false)
+	base := "/organizations/" + org.ID.String()
+
+	s.expect(s.do(http.MethodPut, base+"/members/"+student.ID.String(), token, map[string]string{"role": "TEACHER"}), http.StatusOK)
+	s.expect(s.do(http.MethodPost, base+"/members/"+student.ID.String()+"/suspend", token, nil), http.StatusOK)
+	s.expect(s.do(http.MethodPut, base+"/members/"+teacher.ID.String(), token, map[string]string{"role": "STUDENT"}), http.StatusOK)
+	// The same member changes in the other organization
+	s.expect(s.do(http.MethodPut, "/organizations/"+other.ID.String()+"/members/"+student.ID.String(), s.token(otherOrganizer, false),
+		map[string]string{"role": "TEACHER"}), http.StatusOK)
+
+	list := func(query string) (ids []string, total float64) {
+		t.Helper()
+		body := s.expect(s.do(http.MethodGet, base+"/audit-events"+query, token, nil), http.StatusOK)
+		for _, item := range body["events"].([]interface{}) {
+			event := item.(map[string]interface{})
+			ids = append(ids, event["action"].(string)+" "+event["targetId"].(string))
+		}
+		return ids, body["total"].(float64)
+	}
+
+	// Newest first, and only this organization's events
+	ids, total := list("")
+	want := []string{
+		auditMemberRoleChange + " " + teacher.ID.String(),
+		auditMemberSuspend + " " + student.ID.String(),
+		auditMemberRoleChange + " " + student.ID.String(),
+	}
+	if total != 3 || strings.Join(ids, ",") != strings.Join(want, ",") {
+		t.Fatalf("events = %v (total %v), want %v", ids, total, want)
+	}
+
+	for query, want := range map[string][]string{
+		"?action=" + auditMemberSuspend:                                {want[1]},
+		"?targetId=" + student.ID.String():                             {want[1], want[2]},
+		"?targetType=member&action=" + auditMemberRoleChange:           {want[0], want[2]},
+		"?actorId=" + teacher.ID.String():                              nil,
+		"?limit=1&page=2":                                              {want[1]},
+		"?since=" + time.Now().Add(time.Hour).Format(time.RFC3339):     nil,
+		"?until=" + time.Now().Add(24*time.Hour).Format(time.DateOnly): want,
+	} {
+		if ids, _ := list(query); strings.Join(ids, ",") != strings.Join(want, ",") {
+			t.Errorf("%s: events = %v, want %v", query, ids, want)
+		}
+	}
+
+	s.expect(s.do(http.MethodGet, base+"/audit-events?actorId=someone", token, nil), http.StatusBadRequest)
+	s.expect(s.do(http.MethodGet, base+"/audit-events?since=yesterday", token, nil), http.StatusBadRequest)
+
+	// Only the organization's own organizers read its log
+	s.expect(s.do(http.MethodGet, base+"/audit-events", s.token(teacher, false), nil), http.StatusForbidden)
+	s.expect(s.do(http.MethodGet, base+"/audit-events/export", s.token(teacher, false), nil), http.StatusForbidden)
+	s.expect(s.do(http.MethodGet, base+"/audit-events", s.token(otherOrganizer, false), nil), http.StatusForbidden)
+}
+
+func TestExportAuditEvents(t *testing.T) {
+	s := newTestServer(t)
+	// Spreadsheets would run a cell starting with "=" as a formula
+	organizer := s.user("=organizer@example.com", repository.RoleOrganizer)
+	student := s.user("student@example.com", repository.RoleStudent)
+	org := s.org(organizer)
+	s.join(org, student, repository.RoleStudent)
+	token := s.token(organizer, false)
+	base := "/organizations/" + org.ID.String()
+	s.expect(s.do(http.MethodPut, base+"/members/"+student.ID.String(), token, map[string]string{"role": "TEACHER"}), http.StatusOK)
+	s.expect(s.do(http.MethodPost, base+"/members/"+student.ID.String()+"/suspend", token, nil), http.StatusOK)
+
+	w := s.do(http.MethodGet, base+"/audit-events/export", token, nil)
+	s.expect(w, http.StatusOK)
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/csv") || !strings.Contains(w.Header().Get("Content-Disposition"), "audit-"+org.ID.String()+".csv") {
+		t.Fatalf("headers = %v", w.Header())
+	}
+	records, err := csv.NewReader(bytes.NewReader(w.Body.Bytes())).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 || records[0][0] != "id" || records[1][5] != auditMemberSuspend || records[2][5] != auditMemberRoleChange {
+		t.Fatalf("CSV = %v", records)
+	}
+	if records[1][3] != "'=organizer@example.com" {
+		t.Fatalf("actor email cell = %q, want it escaped", records[1][3])
+	}
+
+	w = s.do(http.MethodGet, base+"/audit-events/export?format=ndjson&action="+auditMemberRoleChange, token, nil)
+	s.expect(w, http.StatusOK)
+	var lines []map[string]interface{}
+	scanner := bufio.NewScanner(bytes.NewReader(w.Body.Bytes()))
+	for scanner.Scan() {
+		var event map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, event)
+	}
+	if len(lines) != 1 || lines[0]["action"] != auditMemberRoleChange || lines[0]["after"].(map[string]interface{})["role"] != "TEACHER" {
+		t.Fatalf("NDJSON = %v", lines)
+	}
+
+	s.expect(s.do(http.MethodGet, base+"/audit-events/export?format=xml", token, nil), http.StatusBadRequest)
+}
+
+// failingCourseDelete fails course deletion inside the audit transaction.
+type failingCourseDelete struct {
+	repository.CourseRepository
+}
+
+func (failingCourseDelete) Delete(id uuid.UUID) error {
+	return errors.New("database unavailable")
+}
+
+func TestFailedActionRecordsNoEvent(t *testing.T) {
+	s := newTestServer(t)
+	organizer := s.user("organizer@example.com", repository.RoleOrganizer)
+	teacher := s.user("teacher@example.com", repository.RoleTeacher)
+	org := s.org(organizer)
+	s.join(org, teacher, repository.RoleTeacher)
+	course := s.course(org, teacher)
+	path := "/courses/" + course.ID.String()
+
+	// A refused action records nothing
+	s.expect(s.do(http.MethodDelete, path, s.token(teacher, false), nil), http.StatusForbidden)
+
+	// Nor does one whose write fails. The handler keeps its own repositories;
+	// the transaction reads them from the store it runs against.
+	s.repos.Courses = failingCourseDelete{s.repos.Courses}
+	s.expect(s.do(http.MethodDelete, path, s.token(organizer, false), nil), http.StatusInternalServerError)
+
+	if events := s.auditEvents(org, ""); len(events) != 0 {
+		t.Fatalf("audit events = %+v, want none", events)
+	}
+	if _, err := s.repos.Courses.GetByID(course.ID); err != nil {
+		t.Fatalf("course: %v", err)
+	}
+}
@@ -1,8 +1,11 @@
 package handlers
 
 import (
-	"myway-backend/internal/database"
+	"errors"
+	"log"
+	"myway-backend/internal/mail"
 	"myway-backend/internal/models"
+	"myway-backend/internal/repository"
 	jwtutil "myway-backend/pkg/jwt"
 	"net/http"
 	"time"
@@ -13,14 +16,20 @@ import (
 )
 
 type AuthHandler struct {
-	JWTSecret string
+	JWTKeys *jwtutil.Keys
+	Users   repository.UserRepository
+	Orgs    repository.OrgRepository
+	Mail    mail.Sender
+	// AppURL is the frontend base URL that emailed links point to.
+	AppURL string
+	// RequireEmailVerification refuses sign-in until the email is verified.
+	RequireEmailVerification bool
 }
 
 type SignUpRequest struct {
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required,min=6"`
 	Name     string `json:"name" binding:"required"`
-	Role     string `json:"role"`
 }
 
 type SignInRequest struct {
@@ -28,8 +37,15 @@ type SignInRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
-func NewAuthHandler(jwtSecret string) *AuthHandler {
-	return &AuthHandler{JWTSecret: jwtSecret}
+func NewAuthHandler(jwtKeys *jwtutil.Keys, repos *repository.Repositories, mailer mail.Sender, appURL string, requireEmailVerification bool) *AuthHandler {
+	return &AuthHandler{
+		JWTKeys:                  jwtKeys,
+		Users:                    repos.Users,
+		Orgs:                     repos.Orgs,
+		Mail:                     mailer,
+		AppURL:                   appURL,
+		RequireEmailVerification: requireEmailVerification,
+	}
 }
 
 func (h *AuthHandler) SignUp(c *gin.Context) {
@@ -38,12 +54,15 @@ func (h *AuthHandler) SignUp(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	req.Email = repository.NormalizeEmail(req.Email)
 
 	// Check if user exists
-	var existingUser models.User
-	if err := database.GetDB().Where("email = ?", req.Email).First(&existingUser).Error; err == nil {
+	if _, err := h.Users.GetByEmail(req.Email); err == nil {
 		c.JSON(http.StatusConflict, gin.H{"error": "User already exists"})
 		return
+	} else if !errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check existing user"})
+		return
 	}
 
 	// Hash password
@@ -53,44 +72,39 @@ func (h *AuthHandler) SignUp(c *gin.Context) {
 		return
 	}
 
-	// Create user
+	// Create user. Everyone starts as a student; elevated roles are granted
+	// by an admin or through organization invitations.
 	user := models.User{
 		Email:        req.Email,
 		PasswordHash: string(hashedPassword),
 		Name:         req.Name,
-		Role:         req.Role,
+		Role:         repository.RoleStudent,
 	}
 
-	if user.Role == "" {
-		user.Role = "STUDENT"
-	}
-
-	if err := database.GetDB().Create(&user).Error; err != nil {
+	if err := h.Users.Create(&user); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
 		return
 	}
-
-	// Generate tokens
-	accessToken, err := jwtutil.GenerateToken(user.ID, user.Email, h.JWTSecret)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+	h.sendEmailVerification(&user)
+
+	if h.RequireEmailVerification {
+		c.JSON(http.StatusCreated, gin.H{
+			"verificationRequired": true,
+			"user": gin.H{
+				"id":            user.ID,
+				"email":         user.Email,
+				"name":          user.Name,
+				"role":          user.Role,
+				"emailVerified": false,
+			},
+		})
 		return
 	}
 
-	refreshToken, err := jwtutil.GenerateRefreshToken(user.ID, user.Email, h.JWTSecret)
+	// Generate tokens
+	accessToken, refreshToken, err := h.newSession(&user, false)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate refresh token"})
-		return
-	}
-
-	// Store refresh token
-	refreshTokenModel := models.RefreshToken{
-		UserID:    user.ID,
-		Token:     refreshToken,
-		ExpiresAt: time.Now().Add(7 * 24 * time.Hour),
-	}
-	if err := database.GetDB().Create(&refreshTokenModel).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store refresh token"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
@@ -98,10 +112,11 @@ func (h *AuthHandler) SignUp(c *gin.Context) {
 		"accessToken":  accessToken,
 		"refreshToken": refreshToken,
 		"user": gin.H{
-			"id":    user.ID,
-			"email": user.Email,
-			"name":  user.Name,
-			"role":  user.Role,
+			"id":            user.ID,
+			"email":         user.Email,
+			"name":          user.Name,
+			"role":          user.Role,
+			"emailVerified": user.EmailVerifiedAt != nil,
 		},
 	})
 }
@@ -114,8 +129,8 @@ func (h *AuthHandler) SignIn(c *gin.Context) {
 	}
 
 	// Find user
-	var user models.User
-	if err := database.GetDB().Where("email = ?", req.Email).First(&user).Error; err != nil {
+	user, err := h.Users.GetByEmail(repository.NormalizeEmail(req.Email))
+	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
@@ -126,32 +141,34 @@ func (h *AuthHandler) SignIn(c *gin.Context) {
 		return
 	}
 
-	// Update last login
-	now := time.Now()
-	user.LastLogin = &now
-	database.GetDB().Save(&user)
-
-	// Generate tokens
-	accessToken, err := jwtutil.GenerateToken(user.ID, user.Email, h.JWTSecret)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+	if h.RequireEmailVerification && user.EmailVerifiedAt == nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Verify your email address before signing in"})
 		return
 	}
 
-	refreshToken, err := jwtutil.GenerateRefreshToken(user.ID, user.Email, h.JWTSecret)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate refresh token"})
+	// With MFA on, the password only earns a challenge for VerifyMFA
+	if user.MFAEnabledAt != nil {
+		h.startMFAChallenge(c, user)
 		return
 	}
 
-	// Store refresh token
-	refreshTokenModel := models.RefreshToken{
-		UserID:    user.ID,
-		Token:     refreshToken,
-		ExpiresAt: time.Now().Add(7 * 24 * time.Hour),
+	h.completeSignIn(c, user, false)
+}
+
+// completeSignIn starts a session for a user who passed every factor they
+// have; mfa records whether that included a second factor.
+func (h *AuthHandler) completeSignIn(c *gin.Context, user *models.User, mfa bool) {
+	// Update last login
+	now := time.Now()
+	user.LastLogin = &now
+	if err := h.Users.Update(user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
 	}
-	if err := database.GetDB().Create(&refreshTokenModel).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store refresh token"})
+
+	accessToken, refreshToken, err := h.newSession(user, mfa)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
@@ -159,29 +176,57 @@ func (h *AuthHandler) SignIn(c *gin.Context) {
 		"accessToken":  accessToken,
 		"refreshToken": refreshToken,
 		"user": gin.H{
-			"id":    user.ID,
-			"email": user.Email,
-			"name":  user.Name,
-			"role":  user.Role,
+			"id":            user.ID,
+			"email":         user.Email,
+			"name":          user.Name,
+			"role":          user.Role,
+			"emailVerified": user.EmailVerifiedAt != nil,
+			"mfaEnabled":    user.MFAEnabledAt != nil,
 		},
 	})
 }
 
+// newSession issues an access token and the first refresh token of a new
+// family.
+func (h *AuthHandler) newSession(user *models.User, mfa bool) (string, string, error) {
+	accessToken, err := jwtutil.GenerateToken(user.ID, user.Email, mfa, h.JWTKeys)
+	if err != nil {
+		return "", "", err
+	}
+	refreshToken, refreshTokenModel, err := newRefreshToken(user.ID, uuid.New())
+	if err != nil {
+		return "", "", err
+	}
+	refreshTokenModel.MFA = mfa
+	if err := h.Users.CreateRefreshToken(refreshTokenModel); err != nil {
+		return "", "", err
+	}
+	return accessToken, refreshToken, nil
+}
+
 func (h *AuthHandler) GetMe(c *gin.Context) {
 	userID := c.MustGet("userID").(uuid.UUID)
 
-	var user models.User
-	if err := database.GetDB().Preload("Memberships.Organization").First(&user, userID).Error; err != nil {
+	user, err := h.Users.GetByID(userID)
+	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
 
+	memberships, err := h.Orgs.ListMemberships(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch memberships"})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"id":          user.ID,
-		"email":       user.Email,
-		"name":        user.Name,
-		"role":        user.Role,
-		"memberships": user.Memberships,
+		"id":            user.ID,
+		"email":         user.Email,
+		"name":          user.Name,
+		"role":          user.Role,
+		"emailVerified": user.EmailVerifiedAt != nil,
+		"mfaEnabled":    user.MFAEnabledAt != nil,
+		"memberships":   memberships,
 	})
 }
 
@@ -189,6 +234,10 @@ type RefreshTokenRequest struct {
 	RefreshToken string `json:"refreshToken" binding:"required"`
 }
 
+// RefreshToken exchanges a refresh token for a new access token and a new
+// refresh token. Each refresh token works once: presenting one that was
+// already exchanged means it leaked, so its whole family is revoked and every
+// device holding a token from it has to sign in again.
 func (h *AuthHandler) RefreshToken(c *gin.Context) {
 	var req RefreshTokenRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -196,52 +245,119 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 		return
 	}
 
-	// Validate refresh token
-	claims, err := jwtutil.ValidateToken(req.RefreshToken, h.JWTSecret)
+	now := time.Now()
+	stored, err := h.Users.GetRefreshToken(jwtutil.HashToken(req.RefreshToken))
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check refresh token"})
 		return
 	}
-
-	// Check if token exists in database
-	var refreshToken models.RefreshToken
-	if err := database.GetDB().Where("token = ? AND user_id = ? AND expires_at > ?", req.RefreshToken, claims.UserID, time.Now()).First(&refreshToken).Error; err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token not found or expired"})
+	if stored.RevokedAt != nil || !stored.ExpiresAt.After(now) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token expired or revoked"})
+		return
+	}
+	if stored.UsedAt != nil {
+		h.revokeReusedFamily(c, stored, now)
 		return
 	}
 
 	// Get user
-	var user models.User
-	if err := database.GetDB().First(&user, claims.UserID).Error; err != nil {
+	user, err := h.Users.GetByID(stored.UserID)
+	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
 
+	// Rotate the refresh token within its family
+	refreshToken, next, err := newRefreshToken(user.ID, stored.FamilyID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate refresh token"})
+		return
+	}
+	next.MFA = stored.MFA
+	if err := h.Users.RotateRefreshToken(stored, next, now); err != nil {
+		if errors.Is(err, repository.ErrTokenUsed) {
+			h.revokeReusedFamily(c, stored, now)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store refresh token"})
+		return
+	}
+
 	// Generate new access token
-	accessToken, err := jwtutil.GenerateToken(user.ID, user.Email, h.JWTSecret)
+	accessToken, err := jwtutil.GenerateToken(user.ID, user.Email, stored.MFA, h.JWTKeys)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"accessToken": accessToken,
+		"accessToken":  accessToken,
+		"refreshToken": refreshToken,
 	})
 }
 
+func (h *AuthHandler) revokeReusedFamily(c *gin.Context, stored *models.RefreshToken, now time.Time) {
+	log.Printf("Refresh token reuse detected for user %s, revoking family %s", stored.UserID, stored.FamilyID)
+	if err := h.Users.RevokeRefreshTokenFamily(stored.FamilyID, now); err != nil {
+		log.Printf("Failed to revoke refresh token family %s: %v", stored.FamilyID, err)
+	}
+	c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token already used; sign in again"})
+}
+
+// newRefreshToken returns a refresh token and the row that stores its hash.
+func newRefreshToken(userID, familyID uuid.UUID) (string, *models.RefreshToken, error) {
+	token, hash, err := jwtutil.NewOpaqueToken()
+	if err != nil {
+		return "", nil, err
+	}
+	return token, &models.RefreshToken{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(jwtutil.RefreshTokenTTL),
+	}, nil
+}
+
 type LogoutRequest struct {
 	RefreshToken string `json:"refreshToken" binding:"required"`
 }
 
+// Logout revokes the refresh token family of this device.
 func (h *AuthHandler) Logout(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
 	var req LogoutRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Delete refresh token
-	database.GetDB().Where("token = ?", req.RefreshToken).Delete(&models.RefreshToken{})
+	stored, err := h.Users.GetRefreshToken(jwtutil.HashToken(req.RefreshToken))
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+	if stored != nil && stored.UserID == userID {
+		if err := h.Users.RevokeRefreshTokenFamily(stored.FamilyID, time.Now()); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+			return
+		}
+	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
 }
+
+// LogoutAll revokes every refresh token of the user. Access tokens already
+// issued stay valid until they expire.
+func (h *AuthHandler) LogoutAll(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+	if err := h.Users.RevokeUserRefreshTokens(userID, time.Now()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out on all devices"})
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"myway-backend/internal/repository"
+	"net/http"
+	"testing"
+)
+
+func TestSignUpAndSignIn(t *testing.T) {
+	s := newTestServer(t)
+
+	signup := s.expect(s.do(http.MethodPost, "/auth/signup", "", map[string]string{
+		"email": "ada@example.com", "password": testPassword, "name": "Ada",
+	}), http.StatusCreated)
+	if signup["accessToken"] == "" || signup["refreshToken"] == "" {
+		t.Fatalf("signup response = %v, want tokens", signup)
+	}
+	s.expect(s.do(http.MethodPost, "/auth/signup", "", map[string]string{
+		"email": "ada@example.com", "password": testPassword, "name": "Ada",
+	}), http.StatusConflict)
+
+	s.expect(s.do(http.MethodPost, "/auth/signin", "", map[string]string{
+		"email": "ada@example.com", "password": "wrong password",
+	}), http.StatusUnauthorized)
+	signin := s.expect(s.do(http.MethodPost, "/auth/signin", "", map[string]string{
+		"email": "ada@example.com", "password": testPassword,
+	}), http.StatusOK)
+
+	me := s.expect(s.do(http.MethodGet, "/auth/me", signin["accessToken"].(string), nil), http.StatusOK)
+	if me["email"] != "ada@example.com" || me["role"] != repository.RoleStudent {
+		t.Fatalf("me = %v", me)
+	}
+	s.expect(s.do(http.MethodGet, "/auth/me", "", nil), http.StatusUnauthorized)
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	s := newTestServer(t)
+	s.user("ada@example.com", repository.RoleStudent)
+	signin := s.expect(s.do(http.MethodPost, "/auth/signin", "", map[string]string{
+		"email": "ada@example.com", "password": testPassword,
+	}), http.StatusOK)
+	first := signin["refreshToken"].(string)
+
+	rotated := s.expect(s.do(http.MethodPost, "/auth/refresh", "", map[string]string{"refreshToken": first}), http.StatusOK)
+	second := rotated["refreshToken"].(string)
+	if second == first || rotated["accessToken"] == "" {
+		t.Fatalf("refresh response = %v, want a new refresh and access token", rotated)
+	}
+
+	// Replaying the exchanged token revokes the whole family, including the
+	// token it was exchanged for
+	s.expect(s.do(http.MethodPost, "/auth/refresh", "", map[string]string{"refreshToken": first}), http.StatusUnauthorized)
+	s.expect(s.do(http.MethodPost, "/auth/refresh", "", map[string]string{"refreshToken": second}), http.StatusUnauthorized)
+
+	// Other sessions keep working
+	other := s.expect(s.do(http.MethodPost, "/auth/signin", "", map[string]string{
+		"email": "ada@example.com", "password": testPassword,
+	}), http.StatusOK)
+	s.expect(s.do(http.MethodPost, "/auth/refresh", "", map[string]string{"refreshToken": other["refreshToken"].(string)}), http.StatusOK)
+
+	s.expect(s.do(http.MethodPost, "/auth/refresh", "", map[string]string{"refreshToken": "not-a-token"}), http.StatusUnauthorized)
+}
+
+func TestLogoutRevokesRefreshFamily(t *testing.T) {
+	s := newTestServer(t)
+	s.user("ada@example.com", repository.RoleStudent)
+	signin := s.expect(s.do(http.MethodPost, "/auth/signin", "", map[string]string{
+		"email": "ada@example.com", "password": testPassword,
+	}), http.StatusOK)
+	refreshToken := signin["refreshToken"].(string)
+	rotated := s.expect(s.do(http.MethodPost, "/auth/refresh", "", map[string]string{"refreshToken": refreshToken}), http.StatusOK)
+
+	s.expect(s.do(http.MethodPost, "/auth/logout", rotated["accessToken"].(string), map[string]string{"refreshToken": rotated["refreshToken"].(string)}), http.StatusOK)
+	s.expect(s.do(http.MethodPost, "/auth/refresh", "", map[string]string{"refreshToken": rotated["refreshToken"].(string)}), http.StatusUnauthorized)
+}
+
+func TestSignUpIgnoresRequestedRole(t *testing.T) {
+	s := newTestServer(t)
+
+	signup := s.expect(s.do(http.MethodPost, "/auth/signup", "", map[string]string{
+		"email": "mallory@example.com", "password": testPassword, "name": "Mallory", "role": repository.RoleAdmin,
+	}), http.StatusCreated)
+	user := signup["user"].(map[string]interface{})
+	if user["role"] != repository.RoleStudent {
+		t.Fatalf("signed up with role %v, want %s", user["role"], repository.RoleStudent)
+	}
+	token := signup["accessToken"].(string)
+
+	// The student can neither create organizations nor grant themselves a role
+	s.expect(s.do(http.MethodPost, "/organizations", token, map[string]string{"name": "Mallory Inc"}), http.StatusForbidden)
+	s.expect(s.do(http.MethodPut, "/admin/users/"+user["id"].(string)+"/role", token, map[string]string{"role": repository.RoleOrganizer}), http.StatusForbidden)
+
+	// An admin grants the organizer role
+	admin := s.user("admin@example.com", repository.RoleAdmin)
+	adminToken := s.token(admin, false)
+	s.expect(s.do(http.MethodPut, "/admin/users/"+admin.ID.String()+"/role", adminToken, map[string]string{"role": repository.RoleStudent}), http.StatusBadRequest)
+	s.expect(s.do(http.MethodPut, "/admin/users/"+user["id"].(string)+"/role", adminToken, map[string]string{"role": repository.RoleOrganizer}), http.StatusOK)
+	s.expect(s.do(http.MethodPost, "/organizations", token, map[string]string{"name": "Mallory Inc"}), http.StatusCreated)
+}
@@ -0,0 +1,288 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"myway-backend/internal/llm"
+	"myway-backend/internal/models"
+	"myway-backend/internal/repository"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	// historyCharBudget caps the prior turns sent with a question, roughly
+	// 3k tokens, leaving room for the course excerpts and the answer.
+	historyCharBudget = 12000
+
+	roleUser      = "USER"
+	roleAssistant = "ASSISTANT"
+)
+
+const conversationSummaryPrompt = `You maintain the running summary of a student's conversation with a course tutor.
+Merge the previous summary and the new turns into one concise summary of at most 150 words.
+Keep the topics covered, what the student found difficult and any numbered points or examples they may refer back to.
+Reply with the summary text only.`
+
+// loadTutorConversation returns the caller's conversation for the course, or
+// a new unsaved one when no conversation ID is given.
+func (h *AIHandler) loadTutorConversation(c *gin.Context, course models.Course, conversationIDParam string) (*models.Conversation, bool) {
+	userID := c.MustGet("userID").(uuid.UUID)
+
+	if strings.TrimSpace(conversationIDParam) == "" {
+		return &models.Conversation{UserID: userID, CourseID: course.ID}, true
+	}
+
+	conversationID, err := uuid.Parse(conversationIDParam)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid conversation ID"})
+		return nil, false
+	}
+
+	conversation, ok := h.getOwnConversation(c, conversationID, userID)
+	if !ok {
+		return nil, false
+	}
+
+	if conversation.CourseID != course.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Conversation belongs to a different course"})
+		return nil, false
+	}
+
+	return conversation, true
+}
+
+// conversationHistory returns the most recent turns that fit the history
+// budget. Older turns are folded into the conversation summary, which is
+// refreshed down to half the budget at a time so it is not rewritten on
+// every question.
+func (h *AIHandler) conversationHistory(ctx context.Context, conversation *models.Conversation) ([]llm.Message, error) {
+	if conversation.ID == uuid.Nil {
+		return nil, nil
+	}
+
+	messages, err := h.Conversations.ListMessages(conversation.ID, conversation.SummarizedCount)
+	if err != nil {
+		return nil, err
+	}
+
+	start := windowStart(messages, historyCharBudget)
+	if start > 0 {
+		start = windowStart(messages, historyCharBudget/2)
+		if err := h.summarizeConversation(ctx, conversation, messages[:start]); err != nil {
+			// The window alone still answers the question; the summary is
+			// retried on the next turn.
+			log.Printf("Failed to summarize conversation %s: %v", conversation.ID, err)
+		}
+	}
+
+	history := make([]llm.Message, 0, len(messages)-start)
+	for _, message := range messages[start:] {
+		role := llm.RoleUser
+		if message.Role == roleAssistant {
+			role = llm.RoleAssistant
+		}
+		history = append(history, llm.Message{Role: role, Content: message.Content})
+	}
+	return history, nil
+}
+
+// windowStart returns the index of the oldest message that fits the budget,
+// never opening the window on an assistant reply.
+func windowStart(messages []models.Message, budget int) int {
+	start := len(messages)
+	size := 0
+	for start > 0 && size+len(messages[start-1].Content) <= budget {
+		start--
+		size += len(messages[start].Content)
+	}
+	for start < len(messages) && messages[start].Role != roleUser {
+		start++
+	}
+	return start
+}
+
+func (h *AIHandler) summarizeConversation(ctx context.Context, conversation *models.Conversation, older []models.Message) error {
+	var sb strings.Builder
+	if conversation.Summary != nil && strings.TrimSpace(*conversation.Summary) != "" {
+		sb.WriteString("Previous summary:\n" + strings.TrimSpace(*conversation.Summary) + "\n\n")
+	}
+	sb.WriteString("New turns:\n")
+	for _, message := range older {
+		speaker := "Student"
+		if message.Role == roleAssistant {
+			speaker = "Tutor"
+		}
+		sb.WriteString(speaker + ": " + message.Content + "\n\n")
+	}
+
+	resp, err := h.Provider.Generate(ctx, llm.Request{
+		System:      conversationSummaryPrompt,
+		Messages:    []llm.Message{{Role: llm.RoleUser, Content: sb.String()}},
+		Temperature: 0.2,
+		MaxTokens:   400,
+	})
+	if err != nil {
+		return err
+	}
+
+	summary := strings.TrimSpace(resp.Text)
+	summarizedCount := conversation.SummarizedCount + len(older)
+	if err := h.Conversations.SaveSummary(conversation.ID, summary, summarizedCount); err != nil {
+		return err
+	}
+
+	conversation.Summary = &summary
+	conversation.SummarizedCount = summarizedCount
+	return nil
+}
+
+// saveTutorTurn appends a question and its answer to the conversation,
+// creating the conversation on its first turn.
+func (h *AIHandler) saveTutorTurn(conversation *models.Conversation, query, answer string, references []gin.H, usage llm.Usage) error {
+	referencesJSON, err := json.Marshal(references)
+	if err != nil {
+		return err
+	}
+	referencesStr := string(referencesJSON)
+	now := time.Now()
+
+	if conversation.ID == uuid.Nil {
+		conversation.Title = snippet(query, 80)
+	}
+	messages := []models.Message{
+		{
+			ID:           uuid.New(),
+			Role:         roleUser,
+			Content:      query,
+			PromptTokens: usage.PromptTokens,
+			CreatedAt:    now,
+		},
+		{
+			ID:               uuid.New(),
+			Role:             roleAssistant,
+			Content:          answer,
+			SourceReferences: &referencesStr,
+			CompletionTokens: usage.CompletionTokens,
+			// Keep the reply ordered after the question.
+			CreatedAt: now.Add(time.Millisecond),
+		},
+	}
+	return h.Conversations.AppendMessages(conversation, messages, now)
+}
+
+func (h *AIHandler) ListTutorConversations(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+
+	var courseID *uuid.UUID
+	if courseIDParam := c.Query("courseId"); courseIDParam != "" {
+		id, err := uuid.Parse(courseIDParam)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid course ID"})
+			return
+		}
+		courseID = &id
+	}
+
+	conversations, err := h.Conversations.ListByUser(userID, courseID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch conversations"})
+		return
+	}
+
+	response := make([]gin.H, 0, len(conversations))
+	for _, conversation := range conversations {
+		response = append(response, gin.H{
+			"id":            conversation.ID,
+			"courseId":      conversation.CourseID,
+			"courseTitle":   conversation.Course.Title,
+			"title":         conversation.Title,
+			"lastMessageAt": conversation.LastMessageAt,
+			"createdAt":     conversation.CreatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *AIHandler) GetTutorConversation(c *gin.Context) {
+	conversation, ok := h.findOwnConversation(c)
+	if !ok {
+		return
+	}
+
+	messages, err := h.Conversations.ListMessages(conversation.ID, 0)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch messages"})
+		return
+	}
+
+	messageList := make([]gin.H, 0, len(messages))
+	for _, message := range messages {
+		var references []map[string]interface{}
+		if message.SourceReferences != nil {
+			json.Unmarshal([]byte(*message.SourceReferences), &references)
+		}
+		messageList = append(messageList, gin.H{
+			"id":               message.ID,
+			"role":             message.Role,
+			"content":          message.Content,
+			"sourceReferences": references,
+			"createdAt":        message.CreatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":            conversation.ID,
+		"courseId":      conversation.CourseID,
+		"title":         conversation.Title,
+		"lastMessageAt": conversation.LastMessageAt,
+		"createdAt":     conversation.CreatedAt,
+		"messages":      messageList,
+	})
+}
+
+func (h *AIHandler) DeleteTutorConversation(c *gin.Context) {
+	conversation, ok := h.findOwnConversation(c)
+	if !ok {
+		return
+	}
+
+	if err := h.Conversations.Delete(conversation.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete conversation"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Conversation deleted"})
+}
+
+func (h *AIHandler) findOwnConversation(c *gin.Context) (*models.Conversation, bool) {
+	userID := c.MustGet("userID").(uuid.UUID)
+
+	conversationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid conversation ID"})
+		return nil, false
+	}
+
+	return h.getOwnConversation(c, conversationID, userID)
+}
+
+func (h *AIHandler) getOwnConversation(c *gin.Context, conversationID, userID uuid.UUID) (*models.Conversation, bool) {
+	conversation, err := h.Conversations.GetOwned(conversationID, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch conversation"})
+		return nil, false
+	}
+	return conversation, true
+}
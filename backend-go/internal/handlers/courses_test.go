@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"myway-backend/internal/repository"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestCourseLifecycle(t *testing.T) {
+	s := newTestServer(t)
+	organizer := s.user("organizer@example.com", repository.RoleOrganizer)
+	student := s.user("student@example.com", repository.RoleStudent)
+	organizerToken := s.token(organizer, false)
+	studentToken := s.token(student, false)
+
+	org := s.expect(s.do(http.MethodPost, "/organizations", organizerToken, map[string]string{"name": "Acme"}), http.StatusCreated)
+	orgID := org["ID"].(string)
+
+	course := s.expect(s.do(http.MethodPost, "/courses", organizerToken, map[string]string{
+		"orgId": orgID, "code": "CS101", "title": "Algorithms", "description": "Sorting",
+	}), http.StatusCreated)
+	courseID := course["ID"].(string)
+
+	// Outsiders see neither the organization's courses nor the course
+	s.expect(s.do(http.MethodGet, "/courses/org/"+orgID, studentToken, nil), http.StatusForbidden)
+	s.expect(s.do(http.MethodGet, "/courses/"+courseID, studentToken, nil), http.StatusForbidden)
+	s.expect(s.do(http.MethodPost, "/courses/"+courseID+"/enroll", studentToken, nil), http.StatusForbidden)
+
+	orgRecord, err := s.repos.Orgs.GetByID(mustParse(t, orgID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.join(orgRecord, student, repository.RoleStudent)
+	s.expect(s.do(http.MethodPost, "/courses/"+courseID+"/enroll", studentToken, nil), http.StatusCreated)
+	s.expect(s.do(http.MethodGet, "/courses/"+courseID, studentToken, nil), http.StatusOK)
+
+	// Only teachers manage modules; students read them
+	module := map[string]interface{}{"courseId": courseID, "title": "Week 1", "order": 1}
+	s.expect(s.do(http.MethodPost, "/modules", studentToken, module), http.StatusForbidden)
+	s.expect(s.do(http.MethodPost, "/modules", organizerToken, module), http.StatusCreated)
+	w := s.do(http.MethodGet, "/modules/course/"+courseID, studentToken, nil)
+	s.expect(w, http.StatusOK)
+	if modules := decodeList(t, w); len(modules) != 1 || modules[0]["Title"] != "Week 1" {
+		t.Fatalf("modules = %v, want Week 1", modules)
+	}
+}
+
+func TestAssignmentSubmitAndGrade(t *testing.T) {
+	s := newTestServer(t)
+	teacher := s.user("teacher@example.com", repository.RoleTeacher)
+	student := s.user("student@example.com", repository.RoleStudent)
+	org := s.org(teacher)
+	s.join(org, student, repository.RoleStudent)
+	course := s.course(org, teacher)
+	s.enroll(course, student, repository.EnrollmentStudent)
+	teacherToken := s.token(teacher, false)
+	studentToken := s.token(student, false)
+
+	assignment := map[string]interface{}{
+		"courseId":     course.ID,
+		"title":        "Problem set 1",
+		"dueAt":        time.Now().Add(7 * 24 * time.Hour),
+		"points":       10,
+		"instructions": "Solve every exercise.",
+	}
+	s.expect(s.do(http.MethodPost, "/assignments", studentToken, assignment), http.StatusForbidden)
+	created := s.expect(s.do(http.MethodPost, "/assignments", teacherToken, assignment), http.StatusCreated)
+	assignmentID := created["ID"].(string)
+
+	// Submissions point at the student's own uploads only
+	submit := "/assignments/" + assignmentID + "/submit"
+	s.expect(s.do(http.MethodPost, submit, studentToken, map[string]string{"fileUrl": "https://files.example.com/answers.pdf"}), http.StatusBadRequest)
+	s.expect(s.do(http.MethodPost, submit, studentToken, map[string]string{"fileId": s.upload(course, teacher, FilePurposeSubmission).ID.String()}), http.StatusForbidden)
+	s.expect(s.do(http.MethodPost, submit, studentToken, map[string]string{"fileId": s.upload(course, student, FilePurposeMaterial).ID.String()}), http.StatusBadRequest)
+	submission := s.expect(s.do(http.MethodPost, submit, studentToken,
+		map[string]string{"fileId": s.upload(course, student, FilePurposeSubmission).ID.String()}), http.StatusCreated)
+	submissionID := submission["ID"].(string)
+
+	s.expect(s.do(http.MethodPut, "/submissions/"+submissionID+"/grade", studentToken,
+		map[string]interface{}{"score": 10}), http.StatusForbidden)
+	s.expect(s.do(http.MethodPut, "/submissions/"+submissionID+"/grade", teacherToken,
+		map[string]interface{}{"score": 8, "feedback": "Check exercise 3."}), http.StatusOK)
+
+	view := s.expect(s.do(http.MethodGet, "/assignments/"+assignmentID, studentToken, nil), http.StatusOK)
+	own, ok := view["submission"].(map[string]interface{})
+	if !ok || own["grade"] != "8" {
+		t.Fatalf("student submission = %v, want grade 8", view["submission"])
+	}
+	if submissions := view["submissions"].([]interface{}); len(submissions) != 0 {
+		t.Fatalf("student sees %d submissions of others", len(submissions))
+	}
+}
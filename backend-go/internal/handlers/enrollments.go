@@ -0,0 +1,486 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/csv"
+	"errors"
+	"io"
+	"myway-backend/internal/models"
+	"myway-backend/internal/repository"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// maxRosterUpload caps the size of a bulk enrollment CSV.
+const maxRosterUpload = 1 << 20
+
+type EnrollmentHandler struct {
+	Users   repository.UserRepository
+	Orgs    repository.OrgRepository
+	Courses repository.CourseRepository
+}
+
+func NewEnrollmentHandler(repos *repository.Repositories) *EnrollmentHandler {
+	return &EnrollmentHandler{Users: repos.Users, Orgs: repos.Orgs, Courses: repos.Courses}
+}
+
+type EnrollRequest struct {
+	EnrollmentKey string `json:"enrollmentKey"`
+}
+
+type AddEnrollmentRequest struct {
+	UserID string `json:"userId"`
+	Email  string `json:"email"`
+	Role   string `json:"role"`
+}
+
+type UpdateEnrollmentRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+type EnrollmentKeyRequest struct {
+	EnrollmentKey string `json:"enrollmentKey"`
+}
+
+// Enroll adds the caller to the course as a student.
+func (h *EnrollmentHandler) Enroll(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+	course, ok := h.loadCourse(c)
+	if !ok {
+		return
+	}
+
+	// The body is optional for courses without an enrollment key.
+	var req EnrollRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, ok := requireOrgMember(c, h.Orgs, userID, course.OrgID); !ok {
+		return
+	}
+
+	if course.EnrollmentKey != nil && *course.EnrollmentKey != "" &&
+		subtle.ConstantTimeCompare([]byte(req.EnrollmentKey), []byte(*course.EnrollmentKey)) != 1 {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid enrollment key"})
+		return
+	}
+
+	enrollment, err := h.enroll(course.ID, userID, repository.EnrollmentStudent)
+	if err != nil {
+		respondEnrollError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, enrollmentResponse(*enrollment))
+}
+
+// Unenroll removes the caller from the course.
+func (h *EnrollmentHandler) Unenroll(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+	course, ok := h.loadCourse(c)
+	if !ok {
+		return
+	}
+
+	h.removeEnrollment(c, course.ID, userID)
+}
+
+func (h *EnrollmentHandler) GetRoster(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+	course, ok := h.loadCourse(c)
+	if !ok {
+		return
+	}
+
+	access, ok := requireCourseAccess(c, h.Orgs, h.Courses, userID, course)
+	if !ok {
+		return
+	}
+	if !access.isStaff() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only course staff can view the roster"})
+		return
+	}
+
+	enrollments, err := h.Courses.ListEnrollments(course.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch enrollments"})
+		return
+	}
+
+	roster := make([]gin.H, 0, len(enrollments))
+	for _, enrollment := range enrollments {
+		entry := enrollmentResponse(enrollment)
+		entry["name"] = enrollment.User.Name
+		entry["email"] = enrollment.User.Email
+		roster = append(roster, entry)
+	}
+
+	c.JSON(http.StatusOK, roster)
+}
+
+// AddEnrollment lets a teacher enroll a member of the organization by user
+// ID or email.
+func (h *EnrollmentHandler) AddEnrollment(c *gin.Context) {
+	course, ok := h.requireTeacher(c)
+	if !ok {
+		return
+	}
+
+	var req AddEnrollmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	role, ok := parseCourseRole(req.Role)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Role must be STUDENT, TA or TEACHER"})
+		return
+	}
+
+	var user *models.User
+	var err error
+	switch {
+	case req.UserID != "":
+		userID, parseErr := uuid.Parse(req.UserID)
+		if parseErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+		user, err = h.Users.GetByID(userID)
+	case req.Email != "":
+		user, err = h.Users.GetByEmail(repository.NormalizeEmail(req.Email))
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "userId or email is required"})
+		return
+	}
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+
+	if _, err := h.Orgs.GetActiveMembership(user.ID, course.OrgID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "User is not a member of this organization"})
+		return
+	}
+
+	enrollment, err := h.enroll(course.ID, user.ID, role)
+	if err != nil {
+		respondEnrollError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, enrollmentResponse(*enrollment))
+}
+
+// ImportRoster bulk-enrolls a cohort from a CSV of "email[,role]" rows,
+// uploaded as the "file" form field or sent as the request body. A header
+// row is skipped.
+func (h *EnrollmentHandler) ImportRoster(c *gin.Context) {
+	course, ok := h.requireTeacher(c)
+	if !ok {
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxRosterUpload)
+
+	var source io.Reader = c.Request.Body
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "CSV file is required"})
+			return
+		}
+		file, err := fileHeader.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read CSV file"})
+			return
+		}
+		defer file.Close()
+		source = file
+	}
+
+	reader := csv.NewReader(source)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	rows, err := reader.ReadAll()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid CSV: " + err.Error()})
+		return
+	}
+
+	enrolled := make([]gin.H, 0, len(rows))
+	skipped := make([]gin.H, 0)
+	for i, row := range rows {
+		line := i + 1
+		if len(row) == 0 || strings.TrimSpace(row[0]) == "" {
+			continue
+		}
+		email := strings.TrimSpace(row[0])
+		if i == 0 && strings.EqualFold(email, "email") {
+			continue
+		}
+
+		skip := func(reason string) {
+			skipped = append(skipped, gin.H{"row": line, "email": email, "reason": reason})
+		}
+
+		roleValue := ""
+		if len(row) > 1 {
+			roleValue = row[1]
+		}
+		role, ok := parseCourseRole(roleValue)
+		if !ok {
+			skip("Unknown role " + strings.TrimSpace(roleValue))
+			continue
+		}
+
+		user, err := h.Users.GetByEmail(email)
+		if err != nil {
+			skip("No user with this email")
+			continue
+		}
+		if _, err := h.Orgs.GetActiveMembership(user.ID, course.OrgID); err != nil {
+			skip("Not a member of this organization")
+			continue
+		}
+
+		enrollment, err := h.enroll(course.ID, user.ID, role)
+		if errors.Is(err, errAlreadyEnrolled) {
+			skip("Already enrolled")
+			continue
+		}
+		if err != nil {
+			skip("Failed to enroll")
+			continue
+		}
+		entry := enrollmentResponse(*enrollment)
+		entry["email"] = user.Email
+		enrolled = append(enrolled, entry)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"enrolled": enrolled,
+		"skipped":  skipped,
+	})
+}
+
+func (h *EnrollmentHandler) UpdateEnrollment(c *gin.Context) {
+	course, ok := h.requireTeacher(c)
+	if !ok {
+		return
+	}
+
+	targetID, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req UpdateEnrollmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	role, ok := parseCourseRole(req.Role)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Role must be STUDENT, TA or TEACHER"})
+		return
+	}
+
+	enrollment, err := h.Courses.GetEnrollment(course.ID, targetID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Enrollment not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch enrollment"})
+		return
+	}
+
+	if enrollment.Role == repository.EnrollmentTeacher && role != repository.EnrollmentTeacher && !h.hasOtherTeacher(c, course.ID) {
+		return
+	}
+
+	enrollment.Role = role
+	if err := h.Courses.SaveEnrollment(enrollment); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update enrollment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, enrollmentResponse(*enrollment))
+}
+
+func (h *EnrollmentHandler) RemoveEnrollment(c *gin.Context) {
+	course, ok := h.requireTeacher(c)
+	if !ok {
+		return
+	}
+
+	targetID, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	h.removeEnrollment(c, course.ID, targetID)
+}
+
+// SetEnrollmentKey sets the key students must give to self-enroll; an empty
+// key opens the course to every member of the organization.
+func (h *EnrollmentHandler) SetEnrollmentKey(c *gin.Context) {
+	course, ok := h.requireTeacher(c)
+	if !ok {
+		return
+	}
+
+	var req EnrollmentKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	key := strings.TrimSpace(req.EnrollmentKey)
+	course.EnrollmentKey = nil
+	if key != "" {
+		course.EnrollmentKey = &key
+	}
+	if err := h.Courses.Update(course); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update enrollment key"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"requiresEnrollmentKey": key != ""})
+}
+
+func (h *EnrollmentHandler) loadCourse(c *gin.Context) (*models.Course, bool) {
+	courseID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid course ID"})
+		return nil, false
+	}
+
+	course, err := h.Courses.GetByID(courseID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Course not found"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch course"})
+		return nil, false
+	}
+	return course, true
+}
+
+// requireTeacher loads the course and checks the caller is one of its
+// teachers or an organizer.
+func (h *EnrollmentHandler) requireTeacher(c *gin.Context) (*models.Course, bool) {
+	userID := c.MustGet("userID").(uuid.UUID)
+	course, ok := h.loadCourse(c)
+	if !ok {
+		return nil, false
+	}
+
+	access, ok := requireCourseAccess(c, h.Orgs, h.Courses, userID, course)
+	if !ok {
+		return nil, false
+	}
+	if !access.canTeach() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only course teachers can manage enrollments"})
+		return nil, false
+	}
+	return course, true
+}
+
+var errAlreadyEnrolled = errors.New("already enrolled")
+
+func (h *EnrollmentHandler) enroll(courseID, userID uuid.UUID, role string) (*models.Enrollment, error) {
+	if _, err := h.Courses.GetEnrollment(courseID, userID); err == nil {
+		return nil, errAlreadyEnrolled
+	} else if !errors.Is(err, repository.ErrNotFound) {
+		return nil, err
+	}
+
+	enrollment := models.Enrollment{CourseID: courseID, UserID: userID, Role: role}
+	if err := h.Courses.SaveEnrollment(&enrollment); err != nil {
+		return nil, err
+	}
+	return &enrollment, nil
+}
+
+func respondEnrollError(c *gin.Context, err error) {
+	if errors.Is(err, errAlreadyEnrolled) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Already enrolled in this course"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enroll"})
+}
+
+func (h *EnrollmentHandler) removeEnrollment(c *gin.Context, courseID, userID uuid.UUID) {
+	enrollment, err := h.Courses.GetEnrollment(courseID, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Enrollment not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch enrollment"})
+		return
+	}
+
+	if enrollment.Role == repository.EnrollmentTeacher && !h.hasOtherTeacher(c, courseID) {
+		return
+	}
+
+	if err := h.Courses.DeleteEnrollment(courseID, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove enrollment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Enrollment removed"})
+}
+
+// hasOtherTeacher keeps every course with at least one teacher, responding
+// with 409 when the teacher being removed or demoted is the last one.
+func (h *EnrollmentHandler) hasOtherTeacher(c *gin.Context, courseID uuid.UUID) bool {
+	teachers, err := h.Courses.CountEnrollments(courseID, repository.EnrollmentTeacher)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check course teachers"})
+		return false
+	}
+	if teachers <= 1 {
+		c.JSON(http.StatusConflict, gin.H{"error": "A course needs at least one teacher"})
+		return false
+	}
+	return true
+}
+
+// parseCourseRole normalizes a course role, defaulting to STUDENT.
+func parseCourseRole(role string) (string, bool) {
+	role = strings.ToUpper(strings.TrimSpace(role))
+	switch role {
+	case "":
+		return repository.EnrollmentStudent, true
+	case repository.EnrollmentStudent, repository.EnrollmentTA, repository.EnrollmentTeacher:
+		return role, true
+	}
+	return "", false
+}
+
+func enrollmentResponse(enrollment models.Enrollment) gin.H {
+	return gin.H{
+		"id":         enrollment.ID,
+		"courseId":   enrollment.CourseID,
+		"userId":     enrollment.UserID,
+		"role":       enrollment.Role,
+		"enrolledAt": enrollment.CreatedAt,
+	}
+}
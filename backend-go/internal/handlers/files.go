@@ -0,0 +1,378 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"myway-backend/internal/models"
+	"myway-backend/internal/repository"
+	"myway-backend/internal/storage"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	FilePurposeMaterial   = "MATERIAL"
+	FilePurposeSubmission = "SUBMISSION"
+
+	// downloadLinkTTL is how long the links handed to clients stay valid.
+	downloadLinkTTL = 15 * time.Minute
+	// presignTTL only has to cover the redirect to the storage backend.
+	presignTTL = 5 * time.Minute
+)
+
+// uploadPolicy limits the uploads of an organization plan.
+type uploadPolicy struct {
+	maxBytes int64
+	types    map[string]bool
+}
+
+var (
+	documentTypes = []string{
+		"application/pdf",
+		"application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+		"application/vnd.openxmlformats-officedocument.presentationml.presentation",
+		"text/plain",
+		"text/html",
+	}
+	mediaTypes = []string{"image/png", "image/jpeg", "application/zip"}
+
+	uploadPolicies = map[string]uploadPolicy{
+		"Free":       {maxBytes: 25 << 20, types: typeSet(documentTypes)},
+		"Pro":        {maxBytes: 100 << 20, types: typeSet(documentTypes, mediaTypes)},
+		"Enterprise": {maxBytes: 500 << 20, types: typeSet(documentTypes, mediaTypes)},
+	}
+
+	// maxUploadRequest bounds the request body before the course, and so
+	// the plan, is known.
+	maxUploadRequest int64 = 500<<20 + 1<<20
+)
+
+func typeSet(lists ...[]string) map[string]bool {
+	set := make(map[string]bool)
+	for _, list := range lists {
+		for _, t := range list {
+			set[t] = true
+		}
+	}
+	return set
+}
+
+func policyFor(plan string) uploadPolicy {
+	if policy, ok := uploadPolicies[plan]; ok {
+		return policy
+	}
+	return uploadPolicies["Free"]
+}
+
+type FileHandler struct {
+	Orgs    repository.OrgRepository
+	Courses repository.CourseRepository
+	Files   repository.FileRepository
+	Storage storage.Storage
+	Signer  *storage.URLSigner
+}
+
+func NewFileHandler(repos *repository.Repositories, store storage.Storage, signer *storage.URLSigner) *FileHandler {
+	return &FileHandler{
+		Orgs:    repos.Orgs,
+		Courses: repos.Courses,
+		Files:   repos.Files,
+		Storage: store,
+		Signer:  signer,
+	}
+}
+
+// UploadFile stores a multipart "file" for a course. Teachers upload
+// MATERIAL files for document imports; anyone enrolled uploads SUBMISSION
+// files for assignments.
+func (h *FileHandler) UploadFile(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxUploadRequest)
+	if err := c.Request.ParseMultipartForm(32 << 20); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File is too large"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Expected a multipart form"})
+		return
+	}
+
+	courseID, err := uuid.Parse(c.PostForm("courseId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid course ID"})
+		return
+	}
+	purpose := strings.ToUpper(c.DefaultPostForm("purpose", FilePurposeMaterial))
+	if purpose != FilePurposeMaterial && purpose != FilePurposeSubmission {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "purpose must be MATERIAL or SUBMISSION"})
+		return
+	}
+
+	course, err := h.Courses.GetByID(courseID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Course not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch course"})
+		return
+	}
+	access, ok := requireCourseAccess(c, h.Orgs, h.Courses, userID, course)
+	if !ok {
+		return
+	}
+	if purpose == FilePurposeMaterial && !access.canTeach() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only course teachers can upload materials"})
+		return
+	}
+
+	org, err := h.Orgs.GetByID(course.OrgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch organization"})
+		return
+	}
+	policy := policyFor(org.Plan)
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+	if header.Size > policy.maxBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error": fmt.Sprintf("File exceeds the %d MB upload limit of the %s plan", policy.maxBytes>>20, org.Plan),
+		})
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read upload"})
+		return
+	}
+	defer file.Close()
+
+	contentType, digest, err := inspectUpload(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read upload"})
+		return
+	}
+	baseType, _, _ := mime.ParseMediaType(contentType)
+	if !policy.types[baseType] {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{
+			"error": fmt.Sprintf("Files of type %s cannot be uploaded on the %s plan", baseType, org.Plan),
+		})
+		return
+	}
+
+	// Objects are addressed by content, so uploading the same file again
+	// within the organization reuses the stored copy.
+	key := path.Join(course.OrgID.String(), "sha256", digest)
+	ctx := c.Request.Context()
+	exists, err := h.Storage.Exists(ctx, key)
+	if err == nil && !exists {
+		err = h.Storage.Put(ctx, key, storage.Object{Body: file, Size: header.Size, ContentType: contentType, SHA256: digest})
+	}
+	if err != nil {
+		log.Printf("Error storing upload: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store file"})
+		return
+	}
+
+	record := models.StoredFile{
+		OrgID:       course.OrgID,
+		CourseID:    course.ID,
+		UploadedBy:  userID,
+		Purpose:     purpose,
+		Backend:     h.Storage.Backend(),
+		StorageKey:  key,
+		FileName:    uploadName(header.Filename),
+		ContentType: contentType,
+		Size:        header.Size,
+		SHA256:      digest,
+	}
+	if err := h.Files.Create(&record); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, h.fileResponse(&record))
+}
+
+// inspectUpload sniffs the content type and hashes the file, leaving it
+// rewound for storage.
+func inspectUpload(file multipart.File) (string, string, error) {
+	detected, err := mimetype.DetectReader(file)
+	if err != nil {
+		return "", "", err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", "", err
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", "", err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", "", err
+	}
+	return detected.String(), hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func uploadName(name string) string {
+	name = strings.TrimSpace(path.Base(strings.ReplaceAll(name, "\\", "/")))
+	if name == "" || name == "." || name == "/" {
+		return "upload"
+	}
+	return name
+}
+
+// GetFile returns the file's details with a short-lived download link.
+func (h *FileHandler) GetFile(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+	fileID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file ID"})
+		return
+	}
+
+	file, err := h.Files.GetByID(fileID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch file"})
+		return
+	}
+
+	course, err := h.Courses.GetByID(file.CourseID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch course"})
+		return
+	}
+	access, ok := requireCourseAccess(c, h.Orgs, h.Courses, userID, course)
+	if !ok {
+		return
+	}
+	// Submissions are private to the student and the course staff.
+	if file.Purpose == FilePurposeSubmission && file.UploadedBy != userID && !access.isStaff() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have access to this file"})
+		return
+	}
+
+	c.JSON(http.StatusOK, h.fileResponse(file))
+}
+
+// DownloadFile serves a file to anyone holding a valid signed link, so it
+// is mounted outside the authenticated routes.
+func (h *FileHandler) DownloadFile(c *gin.Context) {
+	id := c.Param("id")
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil || !h.Signer.Verify(id, expires, c.Query("signature"), time.Now()) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Download link is invalid or has expired"})
+		return
+	}
+
+	fileID, err := uuid.Parse(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file ID"})
+		return
+	}
+	file, err := h.Files.GetByID(fileID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+	if file.Backend != h.Storage.Backend() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "File is held by a storage backend this server does not use"})
+		return
+	}
+
+	if presigner, ok := h.Storage.(storage.Presigner); ok {
+		url, err := presigner.PresignGet(file.StorageKey, file.FileName, presignTTL)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign download"})
+			return
+		}
+		c.Redirect(http.StatusFound, url)
+		return
+	}
+
+	body, err := h.Storage.Open(c.Request.Context(), file.StorageKey)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "File content is missing"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
+		return
+	}
+	defer body.Close()
+
+	c.DataFromReader(http.StatusOK, file.Size, file.ContentType, body, map[string]string{
+		"Content-Disposition":    mime.FormatMediaType("attachment", map[string]string{"filename": file.FileName}),
+		"X-Content-Type-Options": "nosniff",
+		"Cache-Control":          "private, no-store",
+	})
+}
+
+// downloadURL signs a link to the file's content.
+func (h *FileHandler) downloadURL(id uuid.UUID, expires time.Time) string {
+	return fmt.Sprintf("/files/%s/content?expires=%d&signature=%s", id, expires.Unix(), h.Signer.Sign(id.String(), expires))
+}
+
+func (h *FileHandler) fileResponse(file *models.StoredFile) gin.H {
+	expires := time.Now().Add(downloadLinkTTL).Truncate(time.Second)
+	return gin.H{
+		"id":             file.ID,
+		"courseId":       file.CourseID,
+		"purpose":        file.Purpose,
+		"fileName":       file.FileName,
+		"contentType":    file.ContentType,
+		"size":           file.Size,
+		"sha256":         file.SHA256,
+		"uploadedBy":     file.UploadedBy,
+		"createdAt":      file.CreatedAt,
+		"downloadUrl":    h.downloadURL(file.ID, expires),
+		"downloadExpiry": expires,
+	}
+}
+
+// attachedFile loads an uploaded file that is being attached to course
+// content, checking it was uploaded to that course for that purpose.
+func attachedFile(c *gin.Context, files repository.FileRepository, rawID string, courseID uuid.UUID, purpose string) (*models.StoredFile, bool) {
+	fileID, err := uuid.Parse(rawID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file ID"})
+		return nil, false
+	}
+	file, err := files.GetByID(fileID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "File not found"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch file"})
+		return nil, false
+	}
+	if file.CourseID != courseID || file.Purpose != purpose {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("File was not uploaded as a %s for this course", strings.ToLower(purpose))})
+		return nil, false
+	}
+	return file, true
+}
@@ -2,18 +2,32 @@ package handlers
 
 import (
 	"encoding/json"
-	"myway-backend/internal/database"
+	"errors"
 	"myway-backend/internal/models"
+	"myway-backend/internal/repository"
+	"myway-backend/internal/srs"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
-type FlashcardHandler struct{}
+type FlashcardHandler struct {
+	Orgs       repository.OrgRepository
+	Courses    repository.CourseRepository
+	StudyPacks repository.StudyPackRepository
+	Flashcards repository.FlashcardRepository
+}
 
-func NewFlashcardHandler() *FlashcardHandler {
-	return &FlashcardHandler{}
+func NewFlashcardHandler(repos *repository.Repositories) *FlashcardHandler {
+	return &FlashcardHandler{
+		Orgs:       repos.Orgs,
+		Courses:    repos.Courses,
+		StudyPacks: repos.StudyPacks,
+		Flashcards: repos.Flashcards,
+	}
 }
 
 func (h *FlashcardHandler) GetFlashcardsByStudyPack(c *gin.Context) {
@@ -22,11 +36,12 @@ func (h *FlashcardHandler) GetFlashcardsByStudyPack(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid study pack ID"})
 		return
 	}
+	if _, ok := requireStudyPackAccess(c, h.Orgs, h.Courses, h.StudyPacks, c.MustGet("userID").(uuid.UUID), studyPackID); !ok {
+		return
+	}
 
-	var flashcards []models.Flashcard
-	if err := database.GetDB().
-		Where("study_pack_id = ?", studyPackID).
-		Find(&flashcards).Error; err != nil {
+	flashcards, err := h.Flashcards.ListByStudyPack(studyPackID)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch flashcards"})
 		return
 	}
@@ -35,9 +50,13 @@ func (h *FlashcardHandler) GetFlashcardsByStudyPack(c *gin.Context) {
 }
 
 type FlashcardSessionRequest struct {
-	StudyPackID uuid.UUID                    `json:"studyPackId" binding:"required"`
-	Responses   map[uuid.UUID]string `json:"responses" binding:"required"` // flashcardID -> "known" or "unknown"
-	DurationSec int                          `json:"durationSec" binding:"required"`
+	StudyPackID uuid.UUID            `json:"studyPackId" binding:"required"`
+	Responses   map[uuid.UUID]string `json:"responses" binding:"required"` // flashcardID -> "again", "hard", "good" or "easy"; "known"/"unknown" still accepted
+	DurationSec int                  `json:"durationSec" binding:"required"`
+}
+
+type FlashcardReviewRequest struct {
+	Grade string `json:"grade" binding:"required"`
 }
 
 func (h *FlashcardHandler) RecordSession(c *gin.Context) {
@@ -48,17 +67,42 @@ func (h *FlashcardHandler) RecordSession(c *gin.Context) {
 		return
 	}
 
-	// Count known/unknown
+	if _, ok := requireStudyPackAccess(c, h.Orgs, h.Courses, h.StudyPacks, userID, req.StudyPackID); !ok {
+		return
+	}
+
+	grades := make(map[uuid.UUID]srs.Grade, len(req.Responses))
 	knownCount := 0
 	unknownCount := 0
-	for _, response := range req.Responses {
-		if response == "known" {
+	for flashcardID, response := range req.Responses {
+		grade, err := srs.ParseGrade(response)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Grade must be again, hard, good or easy"})
+			return
+		}
+		grades[flashcardID] = grade
+		if grade.Recalled() {
 			knownCount++
 		} else {
 			unknownCount++
 		}
 	}
 
+	// Every graded card must belong to the study pack
+	flashcardIDs := make([]uuid.UUID, 0, len(grades))
+	for flashcardID := range grades {
+		flashcardIDs = append(flashcardIDs, flashcardID)
+	}
+	matched, err := h.Flashcards.CountInStudyPack(req.StudyPackID, flashcardIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch flashcards"})
+		return
+	}
+	if int(matched) != len(flashcardIDs) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Responses include flashcards outside this study pack"})
+		return
+	}
+
 	// Create session
 	session := models.FlashcardSession{
 		StudyPackID:  req.StudyPackID,
@@ -68,46 +112,161 @@ func (h *FlashcardHandler) RecordSession(c *gin.Context) {
 		DurationSec:  req.DurationSec,
 	}
 
-	if err := database.GetDB().Create(&session).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record session"})
-		return
-	}
-
-	// Record progress event
+	// The session is saved with its progress event
 	responsesJSON, _ := json.Marshal(req.Responses)
 	progressEvent := models.ProgressEvent{
 		UserID:    userID,
-		CourseID:  "", // Will be set from study pack material
+		CourseID:  "", // Set from the study pack's course
 		EventType: "FLASHCARD_SESSION",
 		Payload:   string(responsesJSON),
 	}
 
-	// Get study pack to find course
-	var studyPack models.StudyPack
-	if err := database.GetDB().Preload("Material.Module.Course").First(&studyPack, req.StudyPackID).Error; err == nil {
-		if studyPack.Material.Module.Course.ID != uuid.Nil {
-			progressEvent.CourseID = studyPack.Material.Module.Course.ID.String()
+	if err := h.Flashcards.CreateSession(&session, grades, &progressEvent, time.Now()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, session)
+}
+
+// ReviewFlashcard grades a single card and returns its next review.
+func (h *FlashcardHandler) ReviewFlashcard(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+	flashcardID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid flashcard ID"})
+		return
+	}
+
+	var req FlashcardReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	grade, err := srs.ParseGrade(req.Grade)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Grade must be again, hard, good or easy"})
+		return
+	}
+
+	flashcard, err := h.Flashcards.GetByID(flashcardID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Flashcard not found"})
+			return
 		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch flashcard"})
+		return
+	}
+	if _, ok := requireStudyPackAccess(c, h.Orgs, h.Courses, h.StudyPacks, userID, flashcard.StudyPackID); !ok {
+		return
+	}
+
+	schedule, err := h.Flashcards.Review(userID, flashcard.ID, grade, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record review"})
+		return
 	}
 
-	database.GetDB().Create(&progressEvent)
+	c.JSON(http.StatusOK, scheduleResponse(schedule))
+}
 
-	c.JSON(http.StatusOK, session)
+// GetDueFlashcards lists the caller's cards that are due for review across
+// the ready study packs of every course they are enrolled in, followed by
+// cards they have not studied yet.
+func (h *FlashcardHandler) GetDueFlashcards(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+	limit := queryLimit(c, "limit", 50, 200)
+	newLimit := queryLimit(c, "newLimit", 20, 200)
+
+	// Only study packs the caller can see through an enrollment count
+	var courseID *uuid.UUID
+	if courseIDParam := c.Query("courseId"); courseIDParam != "" {
+		id, err := uuid.Parse(courseIDParam)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid course ID"})
+			return
+		}
+		courseID = &id
+	}
+
+	schedules, err := h.Flashcards.ListDue(userID, courseID, time.Now(), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch due flashcards"})
+		return
+	}
+
+	var newCards []models.Flashcard
+	if newLimit > 0 {
+		if newCards, err = h.Flashcards.ListNew(userID, courseID, newLimit); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch new flashcards"})
+			return
+		}
+	}
+
+	due := make([]gin.H, 0, len(schedules))
+	for i := range schedules {
+		card := flashcardResponse(schedules[i].Flashcard)
+		card["schedule"] = scheduleResponse(&schedules[i])
+		due = append(due, card)
+	}
+	fresh := make([]gin.H, 0, len(newCards))
+	for _, flashcard := range newCards {
+		fresh = append(fresh, flashcardResponse(flashcard))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"due": due,
+		"new": fresh,
+	})
+}
+
+func flashcardResponse(flashcard models.Flashcard) gin.H {
+	var tags []string
+	if flashcard.Tags != nil {
+		json.Unmarshal([]byte(*flashcard.Tags), &tags)
+	}
+	return gin.H{
+		"id":          flashcard.ID,
+		"studyPackId": flashcard.StudyPackID,
+		"front":       flashcard.Front,
+		"back":        flashcard.Back,
+		"tags":        tags,
+	}
+}
+
+func scheduleResponse(schedule *models.FlashcardSchedule) gin.H {
+	return gin.H{
+		"flashcardId":    schedule.FlashcardID,
+		"ease":           schedule.Ease,
+		"intervalDays":   schedule.IntervalDays,
+		"repetitions":    schedule.Repetitions,
+		"lapses":         schedule.Lapses,
+		"dueAt":          schedule.DueAt,
+		"lastReviewedAt": schedule.LastReviewedAt,
+	}
 }
 
 func (h *FlashcardHandler) GetSessionsByUser(c *gin.Context) {
 	userID := c.MustGet("userID").(uuid.UUID)
 
-	var sessions []models.FlashcardSession
-	if err := database.GetDB().
-		Preload("StudyPack.Material").
-		Where("user_id = ?", userID).
-		Order("created_at DESC").
-		Limit(20).
-		Find(&sessions).Error; err != nil {
+	sessions, err := h.Flashcards.ListSessions(userID, 20)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sessions"})
 		return
 	}
 
 	c.JSON(http.StatusOK, sessions)
 }
+
+// queryLimit reads a non-negative integer query parameter capped at max.
+func queryLimit(c *gin.Context, name string, fallback, max int) int {
+	value, err := strconv.Atoi(c.Query(name))
+	if err != nil || value < 0 {
+		return fallback
+	}
+	if value > max {
+		return max
+	}
+	return value
+}
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"encoding/json"
+	"myway-backend/internal/llm"
+	"myway-backend/internal/models"
+	"myway-backend/internal/repository"
+	"myway-backend/internal/studypack"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// readyStudyPack publishes a generated study pack for the material and
+// returns it with its flashcards.
+func (s *testServer) readyStudyPack(material *models.Material) *models.StudyPack {
+	s.t.Helper()
+	pack := &models.StudyPack{MaterialID: material.ID, CreatedBy: "test", Status: "PROCESSING"}
+	if err := s.repos.StudyPacks.Create(pack); err != nil {
+		s.t.Fatal(err)
+	}
+	var content studypack.Content
+	if err := json.Unmarshal([]byte(studyPackReply(llm.Request{}, nil)), &content); err != nil {
+		s.t.Fatal(err)
+	}
+	if err := s.repos.StudyPacks.SaveDraft(pack.ID, &content); err != nil {
+		s.t.Fatal(err)
+	}
+	if err := s.repos.StudyPacks.Publish(pack.ID, "test", time.Now()); err != nil {
+		s.t.Fatal(err)
+	}
+	pack, err := s.repos.StudyPacks.GetLatestByMaterial(material.ID)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return pack
+}
+
+func TestFlashcardReviewSchedules(t *testing.T) {
+	s := newTestServer(t)
+	teacher := s.user("teacher@example.com", repository.RoleTeacher)
+	student := s.user("student@example.com", repository.RoleStudent)
+	org := s.org(teacher)
+	s.join(org, student, repository.RoleStudent)
+	course := s.course(org, teacher)
+	s.enroll(course, student, repository.EnrollmentStudent)
+	pack := s.readyStudyPack(s.material(course))
+	if len(pack.Flashcards) != 2 {
+		t.Fatalf("study pack has %d flashcards, want 2", len(pack.Flashcards))
+	}
+	token := s.token(student, false)
+	card := pack.Flashcards[0].ID.String()
+
+	due := s.expect(s.do(http.MethodGet, "/flashcards/due", token, nil), http.StatusOK)
+	if fresh := due["new"].([]interface{}); len(fresh) != 2 {
+		t.Fatalf("new cards = %d, want 2", len(fresh))
+	}
+
+	s.expect(s.do(http.MethodPost, "/flashcards/"+card+"/review", token, map[string]string{"grade": "perfect"}), http.StatusBadRequest)
+	s.expect(s.do(http.MethodPost, "/flashcards/"+uuid.NewString()+"/review", token, map[string]string{"grade": "good"}), http.StatusNotFound)
+
+	// The first review creates the schedule and later ones update it
+	first := s.expect(s.do(http.MethodPost, "/flashcards/"+card+"/review", token, map[string]string{"grade": "good"}), http.StatusOK)
+	second := s.expect(s.do(http.MethodPost, "/flashcards/"+card+"/review", token, map[string]string{"grade": "good"}), http.StatusOK)
+	if first["repetitions"] != float64(1) || second["repetitions"] != float64(2) {
+		t.Fatalf("repetitions = %v then %v, want 1 then 2", first["repetitions"], second["repetitions"])
+	}
+	if second["intervalDays"].(float64) <= first["intervalDays"].(float64) {
+		t.Fatalf("interval did not grow: %v then %v", first["intervalDays"], second["intervalDays"])
+	}
+
+	due = s.expect(s.do(http.MethodGet, "/flashcards/due", token, nil), http.StatusOK)
+	if len(due["due"].([]interface{})) != 0 || len(due["new"].([]interface{})) != 1 {
+		t.Fatalf("due = %v, want the reviewed card scheduled later and one new card", due)
+	}
+
+	// Cards of courses the caller is not enrolled in are not studyable
+	outsider := s.user("outsider@example.com", repository.RoleStudent)
+	s.join(org, outsider, repository.RoleStudent)
+	due = s.expect(s.do(http.MethodGet, "/flashcards/due", s.token(outsider, false), nil), http.StatusOK)
+	if len(due["new"].([]interface{})) != 0 {
+		t.Fatalf("unenrolled user sees new cards: %v", due["new"])
+	}
+}
+
+func TestFlashcardSessionRejectsForeignCards(t *testing.T) {
+	s := newTestServer(t)
+	teacher := s.user("teacher@example.com", repository.RoleTeacher)
+	student := s.user("student@example.com", repository.RoleStudent)
+	org := s.org(teacher)
+	s.join(org, student, repository.RoleStudent)
+	course := s.course(org, teacher)
+	s.enroll(course, student, repository.EnrollmentStudent)
+	pack := s.readyStudyPack(s.material(course))
+	other := s.readyStudyPack(s.material(course))
+	token := s.token(student, false)
+
+	s.expect(s.do(http.MethodPost, "/flashcards/sessions", token, map[string]interface{}{
+		"studyPackId": pack.ID,
+		"responses":   map[string]string{other.Flashcards[0].ID.String(): "good"},
+		"durationSec": 30,
+	}), http.StatusBadRequest)
+
+	session := s.expect(s.do(http.MethodPost, "/flashcards/sessions", token, map[string]interface{}{
+		"studyPackId": pack.ID,
+		"responses": map[string]string{
+			pack.Flashcards[0].ID.String(): "known",
+			pack.Flashcards[1].ID.String(): "again",
+		},
+		"durationSec": 30,
+	}), http.StatusOK)
+	if session["KnownCount"] != float64(1) || session["UnknownCount"] != float64(1) {
+		t.Fatalf("session = %v, want one known and one unknown card", session)
+	}
+
+	due := s.expect(s.do(http.MethodGet, "/flashcards/due?courseId="+course.ID.String(), token, nil), http.StatusOK)
+	if fresh := due["new"].([]interface{}); len(fresh) != 2 {
+		t.Fatalf("new cards = %d, want the two of the other study pack", len(fresh))
+	}
+}
+
+func TestFlashcardWritesRequireEnrollment(t *testing.T) {
+	s := newTestServer(t)
+	teacher := s.user("teacher@example.com", repository.RoleTeacher)
+	student := s.user("student@example.com", repository.RoleStudent)
+	outsider := s.user("outsider@example.com", repository.RoleStudent)
+	org := s.org(teacher)
+	s.join(org, student, repository.RoleStudent)
+	s.join(org, outsider, repository.RoleStudent)
+	course := s.course(org, teacher)
+	s.enroll(course, student, repository.EnrollmentStudent)
+	pack := s.readyStudyPack(s.material(course))
+	card := pack.Flashcards[0].ID.String()
+	session := map[string]interface{}{
+		"studyPackId": pack.ID,
+		"responses":   map[string]string{card: "good"},
+		"durationSec": 30,
+	}
+
+	// Members of the organization who are not enrolled cannot study the cards
+	outsiderToken := s.token(outsider, false)
+	s.expect(s.do(http.MethodPost, "/flashcards/"+card+"/review", outsiderToken, map[string]string{"grade": "good"}), http.StatusForbidden)
+	s.expect(s.do(http.MethodPost, "/flashcards/sessions", outsiderToken, session), http.StatusForbidden)
+
+	// Nor can anyone outside the organization
+	stranger := s.user("stranger@example.com", repository.RoleStudent)
+	s.expect(s.do(http.MethodPost, "/flashcards/"+card+"/review", s.token(stranger, false), map[string]string{"grade": "good"}), http.StatusForbidden)
+
+	// Enrolled students cannot study a study pack before it is approved
+	draft := &models.StudyPack{MaterialID: pack.MaterialID, CreatedBy: "test", Status: "PROCESSING"}
+	if err := s.repos.StudyPacks.Create(draft); err != nil {
+		t.Fatal(err)
+	}
+	token := s.token(student, false)
+	s.expect(s.do(http.MethodPost, "/flashcards/sessions", token, map[string]interface{}{
+		"studyPackId": draft.ID,
+		"responses":   map[string]string{},
+		"durationSec": 30,
+	}), http.StatusNotFound)
+
+	s.expect(s.do(http.MethodPost, "/flashcards/"+card+"/review", token, map[string]string{"grade": "good"}), http.StatusOK)
+	s.expect(s.do(http.MethodPost, "/flashcards/sessions", token, session), http.StatusOK)
+}
@@ -0,0 +1,386 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"myway-backend/internal/llm"
+	"myway-backend/internal/mail"
+	"myway-backend/internal/middleware"
+	"myway-backend/internal/models"
+	"myway-backend/internal/oidc"
+	"myway-backend/internal/repository"
+	"myway-backend/internal/retrieval"
+	"myway-backend/internal/studypack"
+	jwtutil "myway-backend/pkg/jwt"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const testPassword = "correct horse"
+
+// testPublicURL is the API base URL providers redirect back to.
+const testPublicURL = "http://api.test"
+
+// testServer wires the handlers to the in-memory repositories, the fake LLM
+// provider and a fake search index behind the routes of cmd/server.
+type testServer struct {
+	t      *testing.T
+	repos  *repository.Repositories
+	keys   *jwtutil.Keys
+	mail   *testMailer
+	llm    *llm.Fake
+	index  *testIndex
+	auth   *AuthHandler
+	router *gin.Engine
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	s := &testServer{
+		t:     t,
+		repos: repository.NewMemory(),
+		keys:  jwtutil.NewSecretKeys("test-secret"),
+		mail:  &testMailer{sent: make(chan mail.Message, 16)},
+		llm:   llm.NewFake(),
+		index: &testIndex{},
+	}
+	s.llm.StructuredReply = studyPackReply
+	s.auth = NewAuthHandler(s.keys, s.repos, s.mail, "http://app.test", false)
+	invitations := NewInvitationHandler(s.repos, s.auth)
+	apiTokens := NewAPITokenHandler(s.repos)
+	orgs := NewOrganizationHandler(s.repos)
+	audit := NewAuditHandler(s.repos)
+	courses := NewCourseHandler(s.repos)
+	enrollments := NewEnrollmentHandler(s.repos)
+	modules := NewModuleHandler(s.repos)
+	assignments := NewAssignmentHandler(s.repos)
+	flashcards := NewFlashcardHandler(s.repos)
+	ai := NewAIHandler(s.llm, studypack.NewGenerator(s.llm), s.index, s.repos)
+	imports := NewImportsHandler(s.repos, s.index, nil, nil)
+	sso := NewSSOHandler(s.keys, s.repos, oidc.NewClient(true), testPublicURL, "http://app.test")
+
+	router := gin.New()
+	auth := router.Group("/auth")
+	auth.POST("/signup", s.auth.SignUp)
+	auth.POST("/signin", s.auth.SignIn)
+	auth.POST("/refresh", s.auth.RefreshToken)
+	auth.POST("/verify-email", s.auth.VerifyEmail)
+	auth.POST("/verify-email/request", s.auth.RequestEmailVerification)
+	auth.POST("/password/forgot", s.auth.ForgotPassword)
+	auth.POST("/password/reset", s.auth.ResetPassword)
+	auth.POST("/mfa/verify", s.auth.VerifyMFA)
+	auth.POST("/invitations/accept", invitations.AcceptInvitation)
+	auth.GET("/sso/:orgId/start", sso.StartSSO)
+	auth.GET("/sso/callback", sso.SSOCallback)
+	auth.GET("/me", middleware.AuthMiddleware(s.keys, s.repos.APITokens), s.auth.GetMe)
+
+	api := router.Group("")
+	api.Use(middleware.AuthMiddleware(s.keys, s.repos.APITokens))
+	api.POST("/auth/logout", s.auth.Logout)
+	api.POST("/auth/mfa/totp", s.auth.EnrollTOTP)
+	api.POST("/auth/mfa/totp/confirm", s.auth.ConfirmTOTP)
+	api.POST("/auth/mfa/disable", s.auth.DisableMFA)
+
+	admin := api.Group("/admin", middleware.PlatformRoleMiddleware(s.repos.Users, repository.RoleAdmin))
+	admin.PUT("/users/:id/role", NewAdminHandler(s.repos).SetUserRole)
+
+	api.POST("/api-tokens", apiTokens.CreatePersonalToken)
+	api.GET("/api-tokens", apiTokens.ListPersonalTokens)
+	api.DELETE("/api-tokens/:id", apiTokens.RevokePersonalToken)
+	api.POST("/organizations/:id/api-keys", apiTokens.CreateOrgKey)
+
+	api.POST("/organizations", orgs.CreateOrganization)
+	api.GET("/organizations", orgs.GetOrganizations)
+	api.DELETE("/organizations/:id", orgs.DeleteOrganization)
+	api.POST("/organizations/:id/join", orgs.JoinOrganization)
+	api.POST("/organizations/:id/invite", invitations.CreateInvitation)
+	api.GET("/organizations/:id/members", orgs.ListMembers)
+	api.PUT("/organizations/:id/members/:userId", orgs.UpdateMember)
+	api.POST("/organizations/:id/members/:userId/suspend", orgs.SuspendMember)
+	api.DELETE("/organizations/:id/members/:userId", orgs.RemoveMember)
+	api.POST("/organizations/:id/transfer-ownership", orgs.TransferOwnership)
+	api.GET("/organizations/:id/audit-events", audit.ListAuditEvents)
+	api.GET("/organizations/:id/audit-events/export", audit.ExportAuditEvents)
+	api.PUT("/organizations/:id/mfa-policy", orgs.SetMFAPolicy)
+	api.GET("/organizations/:id/sso", sso.GetSSOConfig)
+	api.PUT("/organizations/:id/sso", sso.PutSSOConfig)
+
+	api.POST("/courses", courses.CreateCourse)
+	api.DELETE("/courses/:id", courses.DeleteCourse)
+	api.GET("/courses/:id", courses.GetCourse)
+	api.GET("/courses/org/:orgId", courses.GetCoursesByOrg)
+	api.POST("/courses/:id/enroll", enrollments.Enroll)
+
+	api.POST("/modules", modules.CreateModule)
+	api.GET("/modules/course/:courseId", modules.GetModulesByCourse)
+
+	api.POST("/assignments", assignments.CreateAssignment)
+	api.GET("/assignments/course/:courseId", assignments.GetAssignmentsByCourse)
+	api.GET("/assignments/:id", assignments.GetAssignment)
+	api.POST("/assignments/:id/submit", assignments.SubmitAssignment)
+	api.PUT("/submissions/:id/grade", assignments.GradeSubmission)
+
+	api.GET("/flashcards/studypack/:studyPackId", flashcards.GetFlashcardsByStudyPack)
+	api.POST("/flashcards/sessions", flashcards.RecordSession)
+	api.GET("/flashcards/due", flashcards.GetDueFlashcards)
+	api.POST("/flashcards/:id/review", flashcards.ReviewFlashcard)
+
+	api.GET("/ai/studypack/:materialId", ai.GetStudyPack)
+	api.POST("/ai/review/:materialId/approve", ai.ApproveStudyPack)
+	api.POST("/ai/review/:materialId/regenerate", ai.RegenerateStudyPack)
+	api.POST("/ai/tutor", ai.TutorChat)
+	api.GET("/ai/tutor/conversations", ai.ListTutorConversations)
+	api.GET("/ai/tutor/conversations/:id", ai.GetTutorConversation)
+	api.DELETE("/ai/tutor/conversations/:id", ai.DeleteTutorConversation)
+
+	api.POST("/imports/youtube", imports.ImportYouTube)
+	api.POST("/imports/document", imports.ImportDocument)
+	api.GET("/imports/status/:materialId", imports.GetImportStatus)
+
+	s.router = router
+	return s
+}
+
+// do sends a JSON request, authenticated when token is not empty.
+func (s *testServer) do(method, path, token string, body interface{}) *httptest.ResponseRecorder {
+	s.t.Helper()
+	var payload bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			s.t.Fatalf("encode request: %v", err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &payload)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+// expect fails the test unless the response has the status, and returns its
+// JSON object body.
+func (s *testServer) expect(w *httptest.ResponseRecorder, status int) map[string]interface{} {
+	s.t.Helper()
+	if w.Code != status {
+		s.t.Fatalf("status = %d, want %d; body %s", w.Code, status, w.Body.String())
+	}
+	var body map[string]interface{}
+	if len(bytes.TrimSpace(w.Body.Bytes())) > 0 && w.Body.Bytes()[0] == '{' {
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			s.t.Fatalf("decode response: %v", err)
+		}
+	}
+	return body
+}
+
+// decodeList returns the JSON array body of a response.
+func decodeList(t *testing.T, w *httptest.ResponseRecorder) []map[string]interface{} {
+	t.Helper()
+	var list []map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatalf("decode response: %v; body %s", err, w.Body.String())
+	}
+	return list
+}
+
+func mustParse(t *testing.T, id string) uuid.UUID {
+	t.Helper()
+	parsed, err := uuid.Parse(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed
+}
+
+// user creates a user with testPassword and a verified email address.
+func (s *testServer) user(email, role string) *models.User {
+	s.t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	verified := time.Now()
+	user := &models.User{Email: email, PasswordHash: string(hash), Name: email, Role: role, EmailVerifiedAt: &verified}
+	if err := s.repos.Users.Create(user); err != nil {
+		s.t.Fatalf("create user: %v", err)
+	}
+	return user
+}
+
+// token returns an access token of the user; mfa marks a session that
+// passed a second factor.
+func (s *testServer) token(user *models.User, mfa bool) string {
+	s.t.Helper()
+	token, err := jwtutil.GenerateToken(user.ID, user.Email, mfa, s.keys)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return token
+}
+
+// org creates an organization with owner as its organizer.
+func (s *testServer) org(owner *models.User) *models.Organization {
+	s.t.Helper()
+	org := &models.Organization{Name: "Org of " + owner.Email, Plan: "Free"}
+	if _, err := s.repos.Orgs.CreateWithOwner(org, owner.ID); err != nil {
+		s.t.Fatalf("create organization: %v", err)
+	}
+	return org
+}
+
+func (s *testServer) join(org *models.Organization, user *models.User, role string) {
+	s.t.Helper()
+	membership := &models.OrgMembership{OrgID: org.ID, UserID: user.ID, Role: role, Status: repository.MembershipActive}
+	if err := s.repos.Orgs.SaveMembership(membership); err != nil {
+		s.t.Fatalf("save membership: %v", err)
+	}
+}
+
+// course creates a course in the organization taught by teacher.
+func (s *testServer) course(org *models.Organization, teacher *models.User) *models.Course {
+	s.t.Helper()
+	course := &models.Course{OrgID: org.ID, Code: "CS101", Title: "Algorithms", Description: "Sorting and searching", CreatedBy: teacher.ID}
+	if err := s.repos.Courses.Create(course); err != nil {
+		s.t.Fatalf("create course: %v", err)
+	}
+	return course
+}
+
+func (s *testServer) enroll(course *models.Course, user *models.User, role string) {
+	s.t.Helper()
+	if err := s.repos.Courses.SaveEnrollment(&models.Enrollment{CourseID: course.ID, UserID: user.ID, Role: role}); err != nil {
+		s.t.Fatalf("save enrollment: %v", err)
+	}
+}
+
+// material creates a material with a transcript in a new module of the
+// course.
+func (s *testServer) material(course *models.Course) *models.Material {
+	s.t.Helper()
+	module := &models.Module{CourseID: course.ID, Title: "Week 1", Order: 1}
+	if err := s.repos.Courses.CreateModule(module); err != nil {
+		s.t.Fatalf("create module: %v", err)
+	}
+	transcript := "Binary search halves the sorted range on every comparison."
+	material := &models.Material{ModuleID: module.ID, Type: "VIDEO", Title: "Binary search", TranscriptText: &transcript}
+	if err := s.repos.Courses.CreateMaterial(material); err != nil {
+		s.t.Fatalf("create material: %v", err)
+	}
+	return material
+}
+
+// upload records a file the user uploaded to the course for purpose.
+func (s *testServer) upload(course *models.Course, user *models.User, purpose string) *models.StoredFile {
+	s.t.Helper()
+	file := &models.StoredFile{
+		OrgID:       course.OrgID,
+		CourseID:    course.ID,
+		UploadedBy:  user.ID,
+		Purpose:     purpose,
+		Backend:     "local",
+		StorageKey:  uuid.NewString(),
+		FileName:    "answers.pdf",
+		ContentType: "application/pdf",
+	}
+	if err := s.repos.Files.Create(file); err != nil {
+		s.t.Fatalf("create file: %v", err)
+	}
+	return file
+}
+
+// studyPackReply answers study pack generation with content that passes
+// validation, which the fake's schema example does not.
+func studyPackReply(req llm.Request, schema llm.Schema) string {
+	content := studypack.Content{
+		Summary:   "Binary search halves a sorted range.",
+		KeyPoints: []string{"The range must be sorted", "Each comparison halves the range"},
+		Flashcards: []studypack.Flashcard{
+			{Front: "What does binary search require?", Back: "A sorted range"},
+			{Front: "How many comparisons for n items?", Back: "About log2 n"},
+		},
+	}
+	for i := 0; i < studypack.MaxQuestionCount; i++ {
+		content.Quiz = append(content.Quiz, studypack.Question{
+			Prompt:  fmt.Sprintf("Question %d: what must the input be?", i+1),
+			Options: []string{"Sorted", "Random"},
+			Answer:  "Sorted",
+		})
+	}
+	data, _ := json.Marshal(content)
+	return string(data)
+}
+
+// testMailer records sent messages. AuthHandler sends in the background, so
+// tests wait for them with next.
+type testMailer struct {
+	sent chan mail.Message
+}
+
+func (m *testMailer) Send(ctx context.Context, msg mail.Message) error {
+	m.sent <- msg
+	return nil
+}
+
+// nextMail returns the next message sent.
+func (s *testServer) nextMail() mail.Message {
+	s.t.Helper()
+	select {
+	case msg := <-s.mail.sent:
+		return msg
+	case <-time.After(2 * time.Second):
+		s.t.Fatal("no email sent")
+		return mail.Message{}
+	}
+}
+
+// mailToken returns the token of the link in the next message sent.
+func (s *testServer) mailToken() string {
+	s.t.Helper()
+	msg := s.nextMail()
+	match := regexp.MustCompile(`token=(\S+)`).FindStringSubmatch(msg.Text)
+	if match == nil {
+		s.t.Fatalf("no token link in email %q", msg.Text)
+	}
+	token, err := url.QueryUnescape(match[1])
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return token
+}
+
+// testIndex returns Results for every search and records indexed materials.
+type testIndex struct {
+	mu       sync.Mutex
+	Results  []retrieval.Result
+	indexed  []uuid.UUID
+	searches []string
+}
+
+func (i *testIndex) Search(courseID uuid.UUID, query string, k int) ([]retrieval.Result, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.searches = append(i.searches, query)
+	return i.Results, nil
+}
+
+func (i *testIndex) IndexMaterial(materialID uuid.UUID) (int, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.indexed = append(i.indexed, materialID)
+	return 1, nil
+}
@@ -1,33 +1,57 @@
 package handlers
 
 import (
-	"encoding/json"
-	"encoding/xml"
-	"io"
+	"errors"
 	"log"
-	"myway-backend/internal/database"
+	"myway-backend/internal/extract"
 	"myway-backend/internal/models"
+	"myway-backend/internal/playlist"
+	"myway-backend/internal/repository"
+	"myway-backend/internal/retrieval"
+	"myway-backend/internal/transcript"
 	"net/http"
-	"regexp"
+	"net/url"
+	"path"
 	"strings"
-	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"github.com/kkdai/youtube/v2"
 )
 
-type ImportsHandler struct{}
+type ImportsHandler struct {
+	Orgs        repository.OrgRepository
+	Courses     repository.CourseRepository
+	StudyPacks  repository.StudyPackRepository
+	Files       repository.FileRepository
+	Imports     repository.ImportRepository
+	Index       retrieval.Index
+	Transcripts transcript.Provider
+	// Languages are the default transcript language preferences.
+	Languages []string
+}
 
-func NewImportsHandler() *ImportsHandler {
-	return &ImportsHandler{}
+func NewImportsHandler(repos *repository.Repositories, index retrieval.Index, transcripts transcript.Provider, languages []string) *ImportsHandler {
+	return &ImportsHandler{
+		Orgs:        repos.Orgs,
+		Courses:     repos.Courses,
+		StudyPacks:  repos.StudyPacks,
+		Files:       repos.Files,
+		Imports:     repos.Imports,
+		Index:       index,
+		Transcripts: transcripts,
+		Languages:   languages,
+	}
 }
 
 type ImportYouTubeRequest struct {
 	CourseID   string  `json:"courseId" binding:"required"`
 	ModuleID   *string `json:"moduleId"`
 	YouTubeURL string  `json:"youtubeUrl" binding:"required"`
+	// Transcript is plain text, or the content of an SRT, WebVTT or YouTube
+	// json3/srv3 caption file whose timing is kept.
 	Transcript *string `json:"transcript"`
+	// Language is the preferred caption language, e.g. "en" or "pt-BR".
+	Language string `json:"language"`
 }
 
 func (h *ImportsHandler) ImportYouTube(c *gin.Context) {
@@ -38,39 +62,18 @@ func (h *ImportsHandler) ImportYouTube(c *gin.Context) {
 		return
 	}
 
-	courseID, err := uuid.Parse(req.CourseID)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid course ID"})
-		return
-	}
-
-	// Resolve module ID
-	var moduleID uuid.UUID
-	if req.ModuleID != nil {
-		moduleID, err = uuid.Parse(*req.ModuleID)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid module ID"})
+	// Validate YouTube URL
+	if transcript.VideoID(req.YouTubeURL) == "" {
+		if _, err := playlist.ParseURL(req.YouTubeURL); err == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "This is a playlist or channel link; import it with POST /imports/playlist"})
 			return
 		}
-	} else {
-		// Find or create Resources module
-		var module models.Module
-		result := database.GetDB().Where("course_id = ? AND title = ?", courseID, "Resources").First(&module)
-		if result.Error != nil {
-			// Create Resources module
-			module = models.Module{
-				CourseID: courseID,
-				Title:    "Resources",
-				Order:    999,
-			}
-			database.GetDB().Create(&module)
-		}
-		moduleID = module.ID
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid YouTube URL"})
+		return
 	}
 
-	// Validate YouTube URL
-	if !isValidYouTubeURL(req.YouTubeURL) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid YouTube URL"})
+	_, moduleID, ok := h.importModule(c, userID, req.CourseID, req.ModuleID)
+	if !ok {
 		return
 	}
 
@@ -83,434 +86,242 @@ func (h *ImportsHandler) ImportYouTube(c *gin.Context) {
 		TranscriptText: req.Transcript,
 	}
 
-	if err := database.GetDB().Create(&material).Error; err != nil {
-		log.Printf("Error creating material: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create material"})
-		return
-	}
-
-	// Create study pack with initial status
-	status := "QUEUED"
-	if req.Transcript != nil && *req.Transcript != "" {
-		status = "PROCESSING"
-	}
-
-	studyPack := models.StudyPack{
-		MaterialID:       material.ID,
-		CreatedBy:        userID.String(),
-		Status:           status,
-		RequiresApproval: false,
+	var captions *transcript.Transcript
+	if req.Transcript != nil {
+		if parsed, err := transcript.ParseCaptions([]byte(*req.Transcript)); err == nil {
+			captions = captionTranscript(&material, parsed, req.Language)
+			text := captions.Text()
+			material.TranscriptText = &text
+		}
 	}
 
-	if err := database.GetDB().Create(&studyPack).Error; err != nil {
-		log.Printf("Error creating study pack: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create study pack"})
+	studyPack, job, ok := h.createImport(c, userID, &material, req.Language, captions)
+	if !ok {
 		return
 	}
 
-	log.Printf("Created material %s with study pack %s, status: %s", material.ID, studyPack.ID, status)
-
-	// If transcript provided, process immediately
-	if req.Transcript != nil && *req.Transcript != "" {
-		go h.processStudyPack(studyPack.ID, *req.Transcript)
-	} else {
-		// In a real implementation, this would queue a job to fetch transcript
-		log.Printf("Material %s queued for transcript extraction", material.ID)
-	}
-
 	c.JSON(http.StatusCreated, gin.H{
 		"material": material,
 		"studyPack": gin.H{
 			"id":     studyPack.ID,
 			"status": studyPack.Status,
 		},
+		"jobId": job.ID,
 	})
 }
 
-func isValidYouTubeURL(url string) bool {
-	return len(url) > 0 && (url[:16] == "https://youtu.be" || url[:23] == "https://www.youtube.com" || url[:17] == "https://youtube.com")
-}
-
-func (h *ImportsHandler) GetImportStatus(c *gin.Context) {
-	materialID, err := uuid.Parse(c.Param("materialId"))
+// createImport stores the material, its QUEUED study pack and the generation
+// job in one transaction so an import is never left without a job. language
+// is the preferred transcript language of video imports; captions, when
+// given, are stored as the material's timed transcript.
+func (h *ImportsHandler) createImport(c *gin.Context, userID uuid.UUID, material *models.Material, language string, captions *transcript.Transcript) (*models.StudyPack, *models.Job, bool) {
+	studyPack, job, err := h.Imports.QueueMaterial(material, userID, language, captions)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid material ID"})
-		return
+		log.Printf("Error creating import: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create import"})
+		return nil, nil, false
 	}
 
-	var studyPack models.StudyPack
-	if err := database.GetDB().
-		Where("material_id = ?", materialID).
-		Order("created_at DESC").
-		First(&studyPack).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Study pack not found"})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"status":      studyPack.Status,
-		"studyPackId": studyPack.ID,
-		"createdAt":   studyPack.CreatedAt,
-		"publishedAt": studyPack.PublishedAt,
-	})
+	log.Printf("Created material %s with study pack %s, job %s", material.ID, studyPack.ID, job.ID)
+	return studyPack, job, true
 }
 
-type ImportDocumentRequest struct {
-	CourseID string  `json:"courseId" binding:"required"`
-	ModuleID *string `json:"moduleId"`
-	FileURL  string  `json:"fileUrl" binding:"required"`
-	Title    string  `json:"title" binding:"required"`
-}
-
-func (h *ImportsHandler) ImportDocument(c *gin.Context) {
-	userID := c.MustGet("userID").(uuid.UUID)
-	var req ImportDocumentRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	courseID, err := uuid.Parse(req.CourseID)
+// importModule checks the caller can teach the course and returns the module
+// an import goes into: the given one, which must belong to the course, or
+// the course's Resources module.
+func (h *ImportsHandler) importModule(c *gin.Context, userID uuid.UUID, rawCourseID string, rawModuleID *string) (uuid.UUID, uuid.UUID, bool) {
+	courseID, err := uuid.Parse(rawCourseID)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid course ID"})
-		return
+		return uuid.Nil, uuid.Nil, false
+	}
+	course, err := h.Courses.GetByID(courseID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Course not found"})
+			return uuid.Nil, uuid.Nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch course"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	access, ok := requireCourseAccess(c, h.Orgs, h.Courses, userID, course)
+	if !ok {
+		return uuid.Nil, uuid.Nil, false
+	}
+	if !access.canTeach() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only course teachers can import materials"})
+		return uuid.Nil, uuid.Nil, false
 	}
 
-	// Resolve module ID
-	var moduleID uuid.UUID
-	if req.ModuleID != nil {
-		moduleID, err = uuid.Parse(*req.ModuleID)
+	if rawModuleID != nil {
+		moduleID, err := uuid.Parse(*rawModuleID)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid module ID"})
-			return
+			return uuid.Nil, uuid.Nil, false
 		}
-	} else {
-		// Find or create Resources module
-		var module models.Module
-		result := database.GetDB().Where("course_id = ? AND title = ?", courseID, "Resources").First(&module)
-		if result.Error != nil {
-			module = models.Module{
-				CourseID: courseID,
-				Title:    "Resources",
-				Order:    999,
-			}
-			database.GetDB().Create(&module)
+		module, err := h.Courses.GetModule(moduleID)
+		if err != nil || module.CourseID != courseID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Module not found in this course"})
+			return uuid.Nil, uuid.Nil, false
 		}
-		moduleID = module.ID
+		return courseID, moduleID, true
 	}
-
-	// Determine file type
-	fileType := "DOC"
-	if len(req.FileURL) > 4 {
-		ext := req.FileURL[len(req.FileURL)-4:]
-		if ext == ".pdf" || ext == ".PDF" {
-			fileType = "PDF"
-		}
+	module, err := h.resourcesModule(courseID)
+	if err != nil {
+		log.Printf("Error finding Resources module: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create import"})
+		return uuid.Nil, uuid.Nil, false
 	}
+	return courseID, module.ID, true
+}
 
-	// Create material
-	material := models.Material{
-		ModuleID: moduleID,
-		Type:     fileType,
-		Title:    req.Title,
-		FileURL:  &req.FileURL,
+// resourcesModule returns the course's catch-all module for imports without
+// a module, creating it on first use.
+func (h *ImportsHandler) resourcesModule(courseID uuid.UUID) (*models.Module, error) {
+	module, err := h.Courses.GetModuleByTitle(courseID, "Resources")
+	if err == nil {
+		return module, nil
 	}
-
-	if err := database.GetDB().Create(&material).Error; err != nil {
-		log.Printf("Error creating material: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create material"})
-		return
+	if !errors.Is(err, repository.ErrNotFound) {
+		return nil, err
 	}
-
-	// Create study pack with PROCESSING status
-	studyPack := models.StudyPack{
-		MaterialID:       material.ID,
-		CreatedBy:        userID.String(),
-		Status:           "PROCESSING",
-		RequiresApproval: false,
+	module = &models.Module{
+		CourseID: courseID,
+		Title:    "Resources",
+		Order:    999,
 	}
-
-	if err := database.GetDB().Create(&studyPack).Error; err != nil {
-		log.Printf("Error creating study pack: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create study pack"})
-		return
+	if err := h.Courses.CreateModule(module); err != nil {
+		return nil, err
 	}
-
-	log.Printf("Created document material %s with study pack %s", material.ID, studyPack.ID)
-
-	// Process document (in real implementation, this would extract text from PDF/DOCX)
-	go h.processDocumentStudyPack(studyPack.ID, req.FileURL)
-
-	c.JSON(http.StatusCreated, gin.H{
-		"material": material,
-		"studyPack": gin.H{
-			"id":     studyPack.ID,
-			"status": studyPack.Status,
-		},
-	})
+	return module, nil
 }
 
-func (h *ImportsHandler) processStudyPack(studyPackID uuid.UUID, transcript string) {
-	log.Printf("Processing study pack %s", studyPackID)
-
-	// Update status to PROCESSING
-	database.GetDB().Model(&models.StudyPack{}).Where("id = ?", studyPackID).Update("status", "PROCESSING")
-
-	// Simulate processing delay
-	time.Sleep(2 * time.Second)
-
-	// Generate study content
-	h.generateStudyContentSeed(studyPackID)
-
-	log.Printf("Study pack %s processed successfully", studyPackID)
+// documentType guesses a material type from the file URL's extension.
+func documentType(fileURL string) string {
+	name := fileURL
+	if parsed, err := url.Parse(fileURL); err == nil {
+		name = parsed.Path
+	}
+	switch strings.ToLower(path.Ext(name)) {
+	case ".pdf":
+		return extract.KindPDF
+	case ".docx":
+		return extract.KindDOCX
+	case ".pptx":
+		return extract.KindPPTX
+	case ".md", ".markdown":
+		return extract.KindMarkdown
+	case ".html", ".htm":
+		return extract.KindHTML
+	}
+	return "DOC"
 }
 
-func (h *ImportsHandler) processDocumentStudyPack(studyPackID uuid.UUID, fileURL string) {
-	log.Printf("Processing document study pack %s from %s", studyPackID, fileURL)
-
-	// In a real implementation, this would:
-	// 1. Download the file
-	// 2. Extract text (using a library like go-pdf, docx, etc.)
-	// 3. Generate study content from extracted text
-
-	// For MVP, simulate with generated seed content
-	time.Sleep(2 * time.Second)
-	h.generateStudyContentSeed(studyPackID)
-
-	log.Printf("Document study pack %s processed successfully", studyPackID)
-}
-
-func (h *ImportsHandler) generateStudyContentSeed(studyPackID uuid.UUID) {
-	// Create generated summary
-	summaryContent := map[string]interface{}{
-		"summary": "This is a generated summary of the material.",
-		"bullets": []string{
-			"Key Concept 1: Foundation",
-			"Key Concept 2: Implementation",
-			"Key Concept 3: Verification",
-		},
+func (h *ImportsHandler) GetImportStatus(c *gin.Context) {
+	materialID, err := uuid.Parse(c.Param("materialId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid material ID"})
+		return
 	}
-	summaryJSON, _ := json.Marshal(summaryContent)
-
-	summary := models.Summary{
-		StudyPackID: studyPackID,
-		Content:     string(summaryJSON),
+	if _, _, ok := requireMaterialAccess(c, h.Orgs, h.Courses, c.MustGet("userID").(uuid.UUID), materialID); !ok {
+		return
 	}
-	database.GetDB().Create(&summary)
 
-	// Create generated quiz
-	quiz := models.Quiz{
-		StudyPackID: studyPackID,
-		Version:     1,
-		Metadata:    `{"difficulty":"Adaptive"}`,
+	studyPack, err := h.StudyPacks.GetLatestByMaterial(materialID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Study pack not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch study pack"})
+		return
 	}
-	database.GetDB().Create(&quiz)
 
-	// Add quiz questions
-	question := models.QuizQuestion{
-		QuizID:      quiz.ID,
-		Type:        "MCQ",
-		Prompt:      "What is the primary purpose of this topic?",
-		Options:     `["To confuse students","To solve a specific problem","To waste time","None of the above"]`,
-		AnswerKey:   `"To solve a specific problem"`,
-		Explanation: strPtr("Every educational topic aims to solve problems."),
-	}
-	database.GetDB().Create(&question)
-
-	// Create flashcards
-	flashcard1 := models.Flashcard{
-		StudyPackID: studyPackID,
-		Front:       "Define 'Abstraction'",
-		Back:        "Hiding complex reality while exposing only necessary parts.",
+	response := gin.H{
+		"status":       studyPack.Status,
+		"studyPackId":  studyPack.ID,
+		"createdAt":    studyPack.CreatedAt,
+		"publishedAt":  studyPack.PublishedAt,
+		"errorMessage": studyPack.ErrorMessage,
+		"job":          nil,
+	}
+
+	if job, err := h.Imports.GetLatestJob(materialID); err == nil {
+		response["job"] = gin.H{
+			"id":              job.ID,
+			"type":            job.Type,
+			"status":          job.Status,
+			"attempts":        job.Attempts,
+			"maxAttempts":     job.MaxAttempts,
+			"progress":        job.Progress,
+			"progressMessage": job.ProgressMessage,
+			"lastError":       job.LastError,
+			"nextRunAt":       job.RunAt,
+		}
 	}
-	database.GetDB().Create(&flashcard1)
 
-	// Update study pack status to READY
-	now := time.Now()
-	database.GetDB().Model(&models.StudyPack{}).Where("id = ?", studyPackID).Updates(map[string]interface{}{
-		"status":       "READY",
-		"published_at": &now,
-	})
-
-	log.Printf("Study pack %s marked as READY", studyPackID)
+	c.JSON(http.StatusOK, response)
 }
 
-func strPtr(s string) *string {
-	return &s
+type ImportDocumentRequest struct {
+	CourseID string  `json:"courseId" binding:"required"`
+	ModuleID *string `json:"moduleId"`
+	FileURL  string  `json:"fileUrl"`
+	FileID   *string `json:"fileId"`
+	Title    string  `json:"title" binding:"required"`
 }
 
-// GetYouTubeTranscript fetches the transcript/captions from a YouTube video
-func (h *ImportsHandler) GetYouTubeTranscript(c *gin.Context) {
-	videoURL := c.Query("url")
-	if videoURL == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing 'url' query parameter"})
+func (h *ImportsHandler) ImportDocument(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+	var req ImportDocumentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	log.Printf("Fetching YouTube transcript for: %s", videoURL)
-
-	// Extract video ID from URL
-	videoID := extractVideoID(videoURL)
-	if videoID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid YouTube URL - could not extract video ID"})
+	if (req.FileURL == "") == (req.FileID == nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Provide either fileUrl or fileId"})
 		return
 	}
 
-	log.Printf("Extracted video ID: %s", videoID)
-
-	// Create YouTube client
-	client := youtube.Client{}
-
-	// Get video details
-	video, err := client.GetVideo(videoID)
-	if err != nil {
-		log.Printf("Error fetching video: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to fetch video details",
-			"details": err.Error(),
-		})
+	courseID, moduleID, ok := h.importModule(c, userID, req.CourseID, req.ModuleID)
+	if !ok {
 		return
 	}
 
-	log.Printf("Video title: %s", video.Title)
-
-	// Get captions/transcripts
-	if len(video.CaptionTracks) == 0 {
-		log.Printf("No captions available for video %s", videoID)
-		c.JSON(http.StatusNotFound, gin.H{
-			"error":   "No captions available for this video",
-			"message": "This video does not have captions/subtitles enabled. Please provide the transcript manually or choose a different video.",
-		})
-		return
-	}
-
-	// Get the first available caption track (prefer English)
-	var selectedTrack *youtube.CaptionTrack
-	for i := range video.CaptionTracks {
-		track := &video.CaptionTracks[i]
-		if track.LanguageCode == "en" || track.LanguageCode == "en-US" {
-			selectedTrack = track
-			break
-		}
-	}
-
-	// If no English track, use the first available
-	if selectedTrack == nil {
-		selectedTrack = &video.CaptionTracks[0]
-	}
-
-	log.Printf("Using caption track: %s (%s)", selectedTrack.LanguageCode, selectedTrack.Name)
-
-	// Fetch the transcript
-	var transcriptText string
-
-	// Try using the library first
-	transcript, err := client.GetTranscript(video, selectedTrack.LanguageCode)
-	if err == nil {
-		// Combine all transcript segments into a single text
-		for _, segment := range transcript {
-			transcriptText += segment.Text + " "
-		}
-	} else {
-		log.Printf("Library fetch failed: %v. Trying manual fetch...", err)
-		log.Printf("Selected track: %s, BaseURL: %s", selectedTrack.LanguageCode, selectedTrack.BaseURL)
-
-		// Fallback: Manually fetch the transcript URL
-		if selectedTrack.BaseURL == "" {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error":   "Failed to fetch transcript and no BaseURL available",
-				"details": err.Error(),
-			})
-			return
-		}
-
-		resp, err := http.Get(selectedTrack.BaseURL)
-		if err != nil {
-			log.Printf("Manual fetch failed: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error":   "Failed to manually fetch transcript",
-				"details": err.Error(),
-			})
+	var file *models.StoredFile
+	if req.FileID != nil {
+		if file, ok = attachedFile(c, h.Files, *req.FileID, courseID, FilePurposeMaterial); !ok {
 			return
 		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			body, _ := io.ReadAll(resp.Body)
-			log.Printf("Manual fetch returned status %d: %s", resp.StatusCode, string(body))
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error":  "Manual fetch returned error status",
-				"status": resp.StatusCode,
-			})
-			return
-		}
-
-		// Parse XML - Generic Token Parser
-		// This handles any XML structure (transcript/text, timedtext/body/p, etc)
-		// It simply extracts all character data from the XML
-		
-		// Create a decoder for the response body
-		decoder := xml.NewDecoder(resp.Body)
-		for {
-			t, err := decoder.Token()
-			if err == io.EOF {
-				break
-			}
-			if err != nil {
-				log.Printf("XML decode error: %v", err)
-				break 
-			}
-			
-			// For every CharData token (text content), append it
-			switch se := t.(type) {
-			case xml.CharData:
-				text := string(se)
-				// Clean parsing artifacts
-				text = strings.TrimSpace(text)
-				if len(text) > 0 {
-					transcriptText += text + " "
-				}
-			}
-		}
-		
-		log.Printf("Manual fetch successful! Extracted chars: %d", len(transcriptText))
 	}
 
-	log.Printf("✅ Successfully fetched transcript (%d characters)", len(transcriptText))
-
-	c.JSON(http.StatusOK, gin.H{
-		"videoId":    videoID,
-		"title":      video.Title,
-		"transcript": transcriptText,
-		"language":   selectedTrack.LanguageCode,
-		"duration":   video.Duration.Seconds(),
-	})
-}
-
-// extractVideoID extracts the video ID from various YouTube URL formats
-func extractVideoID(url string) string {
-	// Match patterns:
-	// - https://www.youtube.com/watch?v=VIDEO_ID
-	// - https://youtu.be/VIDEO_ID
-	// - https://youtube.com/watch?v=VIDEO_ID
-
-	patterns := []string{
-		`(?:youtube\.com/watch\?v=|youtu\.be/)([a-zA-Z0-9_-]{11})`,
-		`youtube\.com/embed/([a-zA-Z0-9_-]{11})`,
+	// Create material. The type is a guess from the file name; the pipeline
+	// replaces it with the sniffed type once the file is read.
+	material := models.Material{
+		ModuleID: moduleID,
+		Title:    req.Title,
 	}
-
-	for _, pattern := range patterns {
-		re := regexp.MustCompile(pattern)
-		matches := re.FindStringSubmatch(url)
-		if len(matches) > 1 {
-			return matches[1]
-		}
+	if file != nil {
+		material.Type = documentType(file.FileName)
+		material.FileID = &file.ID
+		material.MimeType = &file.ContentType
+	} else {
+		material.Type = documentType(req.FileURL)
+		material.FileURL = &req.FileURL
 	}
 
-	// If no pattern matched, check if the input is already a video ID
-	if len(url) == 11 && regexp.MustCompile(`^[a-zA-Z0-9_-]{11}$`).MatchString(url) {
-		return url
+	studyPack, job, ok := h.createImport(c, userID, &material, "", nil)
+	if !ok {
+		return
 	}
 
-	return ""
+	c.JSON(http.StatusCreated, gin.H{
+		"material": material,
+		"studyPack": gin.H{
+			"id":     studyPack.ID,
+			"status": studyPack.Status,
+		},
+		"jobId": job.ID,
+	})
 }
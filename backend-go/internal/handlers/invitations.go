@@ -0,0 +1,427 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"myway-backend/internal/mail"
+	"myway-backend/internal/middleware"
+	"myway-backend/internal/models"
+	"myway-backend/internal/repository"
+	jwtutil "myway-backend/pkg/jwt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	invitationTTL = 7 * 24 * time.Hour
+	// invitationResendInterval is the least time between two emails of the
+	// same invitation.
+	invitationResendInterval = time.Minute
+)
+
+// InvitationHandler invites people to organizations by email, whether or not
+// they have an account yet. It sends mail and starts sessions through Auth.
+type InvitationHandler struct {
+	Auth  *AuthHandler
+	Users repository.UserRepository
+	Orgs  repository.OrgRepository
+	Audit repository.AuditRepository
+}
+
+func NewInvitationHandler(repos *repository.Repositories, auth *AuthHandler) *InvitationHandler {
+	return &InvitationHandler{Auth: auth, Users: repos.Users, Orgs: repos.Orgs, Audit: repos.Audit}
+}
+
+type InviteToOrganizationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+	Role  string `json:"role"`
+}
+
+type InvitationTokenRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type AcceptInvitationRequest struct {
+	Token string `json:"token" binding:"required"`
+	// Name and Password create the account when the email has none.
+	Name     string `json:"name"`
+	Password string `json:"password"`
+}
+
+// CreateInvitation emails an invitation to join the organization with a role.
+func (h *InvitationHandler) CreateInvitation(c *gin.Context) {
+	inviter, ok := requireOrgRole(c, h.Orgs, "Only organizers can invite users", "ORGANIZER")
+	if !ok {
+		return
+	}
+	orgID := inviter.OrgID
+
+	var req InviteToOrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	role := strings.ToUpper(strings.TrimSpace(req.Role))
+	if role == "" {
+		role = "STUDENT"
+	}
+	if orgRoleRank[role] == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Role must be STUDENT, TEACHER, or ORGANIZER"})
+		return
+	}
+	email := repository.NormalizeEmail(req.Email)
+
+	if user, err := h.Users.GetByEmail(email); err == nil {
+		if membership, err := h.Orgs.GetMembership(user.ID, orgID); err == nil {
+			switch membership.Status {
+			case repository.MembershipActive:
+				c.JSON(http.StatusConflict, gin.H{"error": "User is already an active member of this organization"})
+				return
+			case repository.MembershipSuspended:
+				c.JSON(http.StatusConflict, gin.H{"error": "User is a suspended member of this organization; reactivate them instead"})
+				return
+			}
+		}
+	} else if !errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+	if open, err := h.Orgs.GetOpenInvitation(orgID, email); err == nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":        "An invitation is already open for this email; resend it instead",
+			"invitationId": open.ID,
+		})
+		return
+	} else if !errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invitations"})
+		return
+	}
+
+	invitation := models.OrgInvitation{
+		OrgID:     orgID,
+		Email:     email,
+		Role:      role,
+		InvitedBy: inviter.UserID,
+	}
+	token, err := h.renew(&invitation)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invitation"})
+		return
+	}
+	h.send(&invitation, token)
+
+	c.JSON(http.StatusCreated, invitationResponse(&invitation))
+}
+
+// ListInvitations returns the invitations not yet accepted or revoked.
+func (h *InvitationHandler) ListInvitations(c *gin.Context) {
+	membership, ok := requireOrgRole(c, h.Orgs, "Only organizers can invite users", "ORGANIZER")
+	if !ok {
+		return
+	}
+
+	invitations, err := h.Orgs.ListOpenInvitations(membership.OrgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invitations"})
+		return
+	}
+	list := make([]gin.H, len(invitations))
+	for i := range invitations {
+		list[i] = invitationResponse(&invitations[i])
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// ResendInvitation emails a new link, which replaces the previous one and
+// runs for another seven days.
+func (h *InvitationHandler) ResendInvitation(c *gin.Context) {
+	invitation, ok := h.loadOpenInvitation(c)
+	if !ok {
+		return
+	}
+	if time.Since(invitation.SentAt) < invitationResendInterval {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Wait a minute before resending the invitation"})
+		return
+	}
+
+	token, err := h.renew(invitation)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update invitation"})
+		return
+	}
+	h.send(invitation, token)
+
+	c.JSON(http.StatusOK, invitationResponse(invitation))
+}
+
+func (h *InvitationHandler) RevokeInvitation(c *gin.Context) {
+	invitation, ok := h.loadOpenInvitation(c)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	invitation.RevokedAt = &now
+	if err := h.Orgs.SaveInvitation(invitation); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke invitation"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation revoked"})
+}
+
+// PreviewInvitation tells the invitee what they were invited to and whether
+// accepting needs a name and password for a new account.
+func (h *InvitationHandler) PreviewInvitation(c *gin.Context) {
+	var req InvitationTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	invitation, ok := h.loadPendingInvitation(c, req.Token)
+	if !ok {
+		return
+	}
+
+	_, err := h.Users.GetByEmail(invitation.Email)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"organization": gin.H{
+			"id":   invitation.Organization.ID,
+			"name": invitation.Organization.Name,
+		},
+		"email":         invitation.Email,
+		"role":          invitation.Role,
+		"expiresAt":     invitation.ExpiresAt,
+		"accountExists": err == nil,
+	})
+}
+
+// AcceptInvitation makes the invitee a member. An existing account with the
+// invited email is linked and signs in as usual afterwards; otherwise the
+// account is created from the name and password and signed in. Either way
+// the email counts as verified, since the token was sent to it.
+func (h *InvitationHandler) AcceptInvitation(c *gin.Context) {
+	var req AcceptInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	invitation, ok := h.loadPendingInvitation(c, req.Token)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	user, err := h.Users.GetByEmail(invitation.Email)
+	accountCreated := false
+	switch {
+	case err == nil:
+		if user.EmailVerifiedAt == nil {
+			user.EmailVerifiedAt = &now
+			if err := h.Users.Update(user); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+				return
+			}
+		}
+	case errors.Is(err, repository.ErrNotFound):
+		name := strings.TrimSpace(req.Name)
+		if name == "" || len(req.Password) < 6 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A name and a password of at least 6 characters are required to create the account"})
+			return
+		}
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+			return
+		}
+		user = &models.User{
+			Email:           invitation.Email,
+			PasswordHash:    string(hashedPassword),
+			Name:            name,
+			Role:            repository.RoleStudent,
+			EmailVerifiedAt: &now,
+		}
+		if err := h.Users.Create(user); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+			return
+		}
+		accountCreated = true
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+
+	// Members who joined in the meantime keep their role
+	membership, err := h.Orgs.GetMembership(user.ID, invitation.OrgID)
+	if err != nil {
+		membership = &models.OrgMembership{OrgID: invitation.OrgID, UserID: user.ID}
+	}
+	if membership.Status == repository.MembershipSuspended {
+		c.JSON(http.StatusForbidden, middleware.SuspendedResponse)
+		return
+	}
+	var before gin.H
+	if membership.ID != uuid.Nil {
+		before = memberAudit(membership)
+	}
+	if membership.ID == uuid.Nil || membership.Status != repository.MembershipActive {
+		membership.Role = invitation.Role
+		membership.Status = repository.MembershipActive
+	}
+	after := memberAudit(membership)
+	after["invitationId"] = invitation.ID
+	after["invitedBy"] = invitation.InvitedBy
+	event := newAuditEvent(c, invitation.OrgID, auditMemberJoin, "member", user.ID, before, after)
+	// The invitee acts on the strength of the token, without a session
+	event.ActorID = user.ID
+	err = h.Audit.Record(event, func(tx *repository.Repositories) error {
+		return tx.Orgs.AcceptInvitation(invitation, membership, now)
+	})
+	if err != nil {
+		if errors.Is(err, repository.ErrTokenUsed) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired invitation"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to accept invitation"})
+		return
+	}
+
+	response := gin.H{
+		"organizationId": invitation.OrgID,
+		"role":           membership.Role,
+		"status":         membership.Status,
+		"accountCreated": accountCreated,
+	}
+	if !accountCreated {
+		response["message"] = "Invitation accepted; sign in to continue"
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	accessToken, refreshToken, err := h.Auth.newSession(user, false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+	response["accessToken"] = accessToken
+	response["refreshToken"] = refreshToken
+	response["user"] = gin.H{
+		"id":            user.ID,
+		"email":         user.Email,
+		"name":          user.Name,
+		"role":          user.Role,
+		"emailVerified": true,
+	}
+	c.JSON(http.StatusCreated, response)
+}
+
+// renew gives the invitation a new token and expiry and saves it.
+func (h *InvitationHandler) renew(invitation *models.OrgInvitation) (string, error) {
+	token, hash, err := jwtutil.NewOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	invitation.TokenHash = hash
+	invitation.ExpiresAt = now.Add(invitationTTL)
+	invitation.SentAt = now
+	if err := h.Orgs.SaveInvitation(invitation); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (h *InvitationHandler) send(invitation *models.OrgInvitation, token string) {
+	org, err := h.Orgs.GetByID(invitation.OrgID)
+	if err != nil {
+		log.Printf("Failed to fetch organization %s for invitation %s: %v", invitation.OrgID, invitation.ID, err)
+		return
+	}
+	inviter := "Someone"
+	if user, err := h.Users.GetByID(invitation.InvitedBy); err == nil {
+		inviter = user.Name
+	}
+	h.Auth.deliver(mail.Message{
+		To:      invitation.Email,
+		Subject: fmt.Sprintf("You're invited to %s on MyWay", org.Name),
+		Text: fmt.Sprintf("Hi,\n\n%s invited you to join %s on MyWay as a %s. Accept the invitation by opening this link:\n\n%s\n\nThe link expires in 7 days. If you were not expecting this invitation, ignore this email.\n",
+			inviter, org.Name, strings.ToLower(invitation.Role), h.Auth.link("/invitations/accept", token)),
+	})
+}
+
+// loadPendingInvitation finds the invitation of an emailed token, answering
+// the same way whether it does not exist or can no longer be accepted.
+func (h *InvitationHandler) loadPendingInvitation(c *gin.Context, token string) (*models.OrgInvitation, bool) {
+	invitation, err := h.Orgs.GetInvitationByHash(jwtutil.HashToken(token))
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check invitation"})
+		return nil, false
+	}
+	if err != nil || invitationStatus(invitation) != "PENDING" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired invitation"})
+		return nil, false
+	}
+	return invitation, true
+}
+
+// loadOpenInvitation loads the :invitationId invitation of the :id
+// organization for one of its organizers.
+func (h *InvitationHandler) loadOpenInvitation(c *gin.Context) (*models.OrgInvitation, bool) {
+	membership, ok := requireOrgRole(c, h.Orgs, "Only organizers can invite users", "ORGANIZER")
+	if !ok {
+		return nil, false
+	}
+	invitationID, err := uuid.Parse(c.Param("invitationId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invitation ID"})
+		return nil, false
+	}
+	invitation, err := h.Orgs.GetInvitation(invitationID)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invitation"})
+		return nil, false
+	}
+	if err != nil || invitation.OrgID != membership.OrgID || invitation.AcceptedAt != nil || invitation.RevokedAt != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invitation not found"})
+		return nil, false
+	}
+	return invitation, true
+}
+
+// invitationStatus is PENDING, EXPIRED, ACCEPTED or REVOKED.
+func invitationStatus(invitation *models.OrgInvitation) string {
+	switch {
+	case invitation.AcceptedAt != nil:
+		return "ACCEPTED"
+	case invitation.RevokedAt != nil:
+		return "REVOKED"
+	case !time.Now().Before(invitation.ExpiresAt):
+		return "EXPIRED"
+	}
+	return "PENDING"
+}
+
+func invitationResponse(invitation *models.OrgInvitation) gin.H {
+	return gin.H{
+		"id":             invitation.ID,
+		"organizationId": invitation.OrgID,
+		"email":          invitation.Email,
+		"role":           invitation.Role,
+		"status":         invitationStatus(invitation),
+		"invitedBy":      invitation.InvitedBy,
+		"sentAt":         invitation.SentAt,
+		"expiresAt":      invitation.ExpiresAt,
+		"createdAt":      invitation.CreatedAt,
+	}
+}
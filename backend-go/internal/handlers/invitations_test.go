@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"myway-backend/internal/repository"
+	"net/http"
+	"testing"
+)
+
+func TestInvitationMatchesEmailOfAnyCase(t *testing.T) {
+	s := newTestServer(t)
+	organizer := s.user("organizer@example.com", repository.RoleOrganizer)
+	org := s.org(organizer)
+
+	signup := s.expect(s.do(http.MethodPost, "/auth/signup", "", map[string]string{
+		"email": "Alice@Uni.edu", "password": testPassword, "name": "Alice",
+	}), http.StatusCreated)
+	if email := signup["user"].(map[string]interface{})["email"]; email != "alice@uni.edu" {
+		t.Fatalf("signed up as %v, want the lower-cased address", email)
+	}
+	s.nextMail()
+	s.expect(s.do(http.MethodPost, "/auth/signup", "", map[string]string{
+		"email": "alice@uni.edu", "password": testPassword, "name": "Alice again",
+	}), http.StatusConflict)
+
+	s.expect(s.do(http.MethodPost, "/organizations/"+org.ID.String()+"/invite", s.token(organizer, false), map[string]string{
+		"email": "ALICE@uni.edu", "role": "TEACHER",
+	}), http.StatusCreated)
+
+	// The invitation joins the existing account instead of creating another
+	accepted := s.expect(s.do(http.MethodPost, "/auth/invitations/accept", "", map[string]string{"token": s.mailToken()}), http.StatusOK)
+	if accepted["accountCreated"] != false || accepted["role"] != "TEACHER" {
+		t.Fatalf("accept response = %v, want the existing account to join as TEACHER", accepted)
+	}
+
+	signin := s.expect(s.do(http.MethodPost, "/auth/signin", "", map[string]string{
+		"email": "aLiCe@UNI.edu", "password": testPassword,
+	}), http.StatusOK)
+	w := s.do(http.MethodGet, "/organizations", signin["accessToken"].(string), nil)
+	s.expect(w, http.StatusOK)
+	if orgs := decodeList(t, w); len(orgs) != 1 {
+		t.Fatalf("organizations = %v, want the one the invitation was for", orgs)
+	}
+}
@@ -0,0 +1,312 @@
+package handlers
+
+import (
+	"errors"
+	"myway-backend/internal/models"
+	"myway-backend/internal/repository"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const defaultMembersPageSize = 25
+
+// lastOrganizerResponse answers a change the repository refused with
+// ErrLastOrganizer.
+var lastOrganizerResponse = gin.H{"error": "An organization needs at least one active organizer"}
+
+type UpdateMemberRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+type TransferOwnershipRequest struct {
+	UserID uuid.UUID `json:"userId" binding:"required"`
+	// Role is what the current organizer becomes: STUDENT or TEACHER, by
+	// default TEACHER.
+	Role string `json:"role"`
+}
+
+// ListMembers returns a page of the organization's members to its organizers
+// and teachers, optionally filtered by a search over names and emails, role
+// and status.
+func (h *OrganizationHandler) ListMembers(c *gin.Context) {
+	membership, ok := requireOrgRole(c, h.Orgs, "Only organizers and teachers can list members", "ORGANIZER", "TEACHER")
+	if !ok {
+		return
+	}
+
+	filter := repository.MemberFilter{
+		Search: strings.TrimSpace(c.Query("search")),
+		Role:   strings.ToUpper(strings.TrimSpace(c.Query("role"))),
+	}
+	if filter.Role != "" && orgRoleRank[filter.Role] == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role must be STUDENT, TEACHER, or ORGANIZER"})
+		return
+	}
+	switch strings.ToLower(c.Query("status")) {
+	case "":
+	case "active":
+		filter.Status = repository.MembershipActive
+	case "suspended":
+		filter.Status = repository.MembershipSuspended
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be active or suspended"})
+		return
+	}
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	limit := queryLimit(c, "limit", defaultMembersPageSize, 100)
+	if limit == 0 {
+		limit = defaultMembersPageSize
+	}
+	filter.Offset = (page - 1) * limit
+	filter.Limit = limit
+
+	members, total, err := h.Orgs.ListMembers(membership.OrgID, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch members"})
+		return
+	}
+	list := make([]gin.H, len(members))
+	for i, member := range members {
+		list[i] = memberResponse(member)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"members": list,
+		"total":   total,
+		"page":    page,
+		"limit":   limit,
+	})
+}
+
+// UpdateMember changes a member's role. The last active organizer cannot be
+// demoted.
+func (h *OrganizationHandler) UpdateMember(c *gin.Context) {
+	member, ok := h.loadMember(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	role := strings.ToUpper(strings.TrimSpace(req.Role))
+	if orgRoleRank[role] == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Role must be STUDENT, TEACHER, or ORGANIZER"})
+		return
+	}
+
+	before := memberAudit(member)
+	member.Role = role
+	if err := h.saveAudited(c, auditMemberRoleChange, before, member); err != nil {
+		if errors.Is(err, repository.ErrLastOrganizer) {
+			c.JSON(http.StatusConflict, lastOrganizerResponse)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update member"})
+		return
+	}
+	c.JSON(http.StatusOK, memberResponse(*member))
+}
+
+// SuspendMember denies the member access to the organization while keeping
+// their role and data.
+func (h *OrganizationHandler) SuspendMember(c *gin.Context) {
+	member, ok := h.loadMember(c)
+	if !ok {
+		return
+	}
+	if member.UserID == c.MustGet("userID").(uuid.UUID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot suspend yourself"})
+		return
+	}
+	if member.Status == repository.MembershipSuspended {
+		c.JSON(http.StatusConflict, gin.H{"error": "Member is already suspended"})
+		return
+	}
+
+	before := memberAudit(member)
+	member.Status = repository.MembershipSuspended
+	if err := h.saveAudited(c, auditMemberSuspend, before, member); err != nil {
+		if errors.Is(err, repository.ErrLastOrganizer) {
+			c.JSON(http.StatusConflict, lastOrganizerResponse)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to suspend member"})
+		return
+	}
+	c.JSON(http.StatusOK, memberResponse(*member))
+}
+
+func (h *OrganizationHandler) ReactivateMember(c *gin.Context) {
+	member, ok := h.loadMember(c)
+	if !ok {
+		return
+	}
+	if member.Status == repository.MembershipActive {
+		c.JSON(http.StatusConflict, gin.H{"error": "Member is already active"})
+		return
+	}
+
+	before := memberAudit(member)
+	member.Status = repository.MembershipActive
+	if err := h.saveAudited(c, auditMemberReactivate, before, member); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reactivate member"})
+		return
+	}
+	c.JSON(http.StatusOK, memberResponse(*member))
+}
+
+// RemoveMember takes the member out of the organization and its courses.
+// Their work stays for the record unless retainData=false is given.
+func (h *OrganizationHandler) RemoveMember(c *gin.Context) {
+	member, ok := h.loadMember(c)
+	if !ok {
+		return
+	}
+	retainData, err := strconv.ParseBool(c.DefaultQuery("retainData", "true"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "retainData must be true or false"})
+		return
+	}
+
+	event := newAuditEvent(c, member.OrgID, auditMemberRemove, "member", member.UserID, memberAudit(member), gin.H{"dataRetained": retainData})
+	err = h.Audit.Record(event, func(tx *repository.Repositories) error {
+		return tx.Orgs.RemoveMember(member.OrgID, member.UserID, retainData)
+	})
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+			return
+		}
+		if errors.Is(err, repository.ErrLastOrganizer) {
+			c.JSON(http.StatusConflict, lastOrganizerResponse)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove member"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Member removed", "dataRetained": retainData})
+}
+
+// TransferOwnership hands the caller's ORGANIZER role to another active
+// member and gives the caller a lesser role, both at once.
+func (h *OrganizationHandler) TransferOwnership(c *gin.Context) {
+	current, ok := requireOrgRole(c, h.Orgs, "Only organizers can transfer ownership", "ORGANIZER")
+	if !ok {
+		return
+	}
+
+	var req TransferOwnershipRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	role := strings.ToUpper(strings.TrimSpace(req.Role))
+	if role == "" {
+		role = "TEACHER"
+	}
+	if role != "STUDENT" && role != "TEACHER" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Role must be STUDENT or TEACHER"})
+		return
+	}
+	if req.UserID == current.UserID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Choose another member to transfer ownership to"})
+		return
+	}
+
+	target, err := h.Orgs.GetActiveMembership(req.UserID, current.OrgID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "The new organizer must be an active member"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch member"})
+		return
+	}
+
+	targetBefore, currentBefore := memberAudit(target), memberAudit(current)
+	target.Role = "ORGANIZER"
+	current.Role = role
+	// Both changes are logged: the new organizer's and the caller's
+	promoted := newAuditEvent(c, current.OrgID, auditOwnershipTransfer, "member", target.UserID, targetBefore, memberAudit(target))
+	demoted := newAuditEvent(c, current.OrgID, auditMemberRoleChange, "member", current.UserID, currentBefore, memberAudit(current))
+	err = h.Audit.Record(promoted, func(tx *repository.Repositories) error {
+		return tx.Audit.Record(demoted, func(tx *repository.Repositories) error {
+			return tx.Orgs.SaveMemberships(target, current)
+		})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to transfer ownership"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"organizationId": current.OrgID,
+		"organizerId":    target.UserID,
+		"role":           current.Role,
+	})
+}
+
+// loadMember loads the :userId membership of the :id organization, with its
+// user, for one of the organization's organizers.
+func (h *OrganizationHandler) loadMember(c *gin.Context) (*models.OrgMembership, bool) {
+	membership, ok := requireOrgRole(c, h.Orgs, "Only organizers can manage members", "ORGANIZER")
+	if !ok {
+		return nil, false
+	}
+	targetID, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return nil, false
+	}
+
+	member, err := h.Orgs.GetMembership(targetID, membership.OrgID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch member"})
+		return nil, false
+	}
+	user, err := h.Users.GetByID(targetID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return nil, false
+	}
+	member.User = *user
+	return member, true
+}
+
+// saveAudited saves the membership with its audit event. before is the
+// membership's memberAudit from before the change, nil for a new one.
+func (h *OrganizationHandler) saveAudited(c *gin.Context, action string, before gin.H, member *models.OrgMembership) error {
+	event := newAuditEvent(c, member.OrgID, action, "member", member.UserID, before, memberAudit(member))
+	return h.Audit.Record(event, func(tx *repository.Repositories) error {
+		return tx.Orgs.SaveMembership(member)
+	})
+}
+
+// memberAudit is what the audit log records of a membership.
+func memberAudit(member *models.OrgMembership) gin.H {
+	return gin.H{"role": member.Role, "status": member.Status}
+}
+
+func memberResponse(member models.OrgMembership) gin.H {
+	return gin.H{
+		"userId": member.UserID,
+		"name":   member.User.Name,
+		"email":  member.User.Email,
+		"role":   member.Role,
+		"status": member.Status,
+	}
+}
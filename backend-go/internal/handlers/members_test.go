@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"myway-backend/internal/repository"
+	"net/http"
+	"testing"
+)
+
+func TestLastOrganizerStays(t *testing.T) {
+	s := newTestServer(t)
+	organizer := s.user("organizer@example.com", repository.RoleOrganizer)
+	teacher := s.user("teacher@example.com", repository.RoleTeacher)
+	org := s.org(organizer)
+	s.join(org, teacher, repository.RoleTeacher)
+	token := s.token(organizer, false)
+	self := "/organizations/" + org.ID.String() + "/members/" + organizer.ID.String()
+
+	s.expect(s.do(http.MethodPut, self, token, map[string]string{"role": "TEACHER"}), http.StatusConflict)
+	s.expect(s.do(http.MethodPost, self+"/suspend", token, nil), http.StatusBadRequest)
+	s.expect(s.do(http.MethodDelete, self, token, nil), http.StatusConflict)
+
+	// Ownership moves in one step and leaves the caller a teacher
+	transfer := "/organizations/" + org.ID.String() + "/transfer-ownership"
+	s.expect(s.do(http.MethodPost, transfer, token, map[string]string{"userId": organizer.ID.String()}), http.StatusBadRequest)
+	moved := s.expect(s.do(http.MethodPost, transfer, token, map[string]string{"userId": teacher.ID.String()}), http.StatusOK)
+	if moved["role"] != "TEACHER" || moved["organizerId"] != teacher.ID.String() {
+		t.Fatalf("transfer response = %v", moved)
+	}
+	s.expect(s.do(http.MethodPut, self, token, map[string]string{"role": "STUDENT"}), http.StatusForbidden)
+
+	// With another organizer present, the new one can step down
+	newToken := s.token(teacher, false)
+	s.expect(s.do(http.MethodPut, self, newToken, map[string]string{"role": "ORGANIZER"}), http.StatusOK)
+	s.expect(s.do(http.MethodPut, "/organizations/"+org.ID.String()+"/members/"+teacher.ID.String(), newToken, map[string]string{"role": "TEACHER"}), http.StatusOK)
+	s.expect(s.do(http.MethodDelete, self, token, nil), http.StatusConflict)
+}
@@ -0,0 +1,378 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"myway-backend/internal/models"
+	"myway-backend/internal/repository"
+	"myway-backend/internal/totp"
+	jwtutil "myway-backend/pkg/jwt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// mfaChallengeTTL is how long the user has to enter a code after the
+	// password or the identity provider was accepted.
+	mfaChallengeTTL = 5 * time.Minute
+	// recoveryCodeCount is how many recovery codes are issued at a time.
+	recoveryCodeCount = 10
+	// totpIssuer names the account in authenticator apps.
+	totpIssuer = "MyWay"
+)
+
+type VerifyMFARequest struct {
+	MFAToken string `json:"mfaToken" binding:"required"`
+	// Code is an authenticator code; RecoveryCode is used instead when the
+	// authenticator is lost.
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recoveryCode"`
+}
+
+type PasswordRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+type TOTPCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type MFAChangeRequest struct {
+	Password string `json:"password" binding:"required"`
+	// Code is an authenticator code or, to disable MFA, a recovery code.
+	Code string `json:"code" binding:"required"`
+}
+
+var errInvalidCode = errors.New("invalid authentication code")
+
+// startMFAChallenge answers a correct password for a user with MFA on: no
+// tokens yet, only a short-lived challenge token to present with a code.
+func (h *AuthHandler) startMFAChallenge(c *gin.Context, user *models.User) {
+	token, err := newMFAChallenge(h.Users, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start sign-in"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"mfaRequired": true,
+		"mfaToken":    token,
+		"expiresIn":   int(mfaChallengeTTL.Seconds()),
+	})
+}
+
+// newMFAChallenge stores a challenge for VerifyMFA and returns its token.
+// Password and single sign-on sign-ins of users with MFA on both end here.
+func newMFAChallenge(users repository.UserRepository, userID uuid.UUID) (string, error) {
+	token, hash, err := jwtutil.NewOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	err = users.CreateUserToken(&models.UserToken{
+		UserID:    userID,
+		Purpose:   TokenMFAChallenge,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(mfaChallengeTTL),
+	})
+	return token, err
+}
+
+// VerifyMFA completes a sign-in with an authenticator or recovery code. A
+// challenge token takes one attempt, so a wrong code means entering the
+// password again, which keeps codes from being guessed.
+func (h *AuthHandler) VerifyMFA(c *gin.Context) {
+	var req VerifyMFARequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if (req.Code == "") == (req.RecoveryCode == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Provide either code or recoveryCode"})
+		return
+	}
+
+	userToken, err := h.Users.ConsumeUserToken(jwtutil.HashToken(req.MFAToken), TokenMFAChallenge, time.Now())
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Sign-in expired; enter your password again"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check sign-in"})
+		return
+	}
+	user, err := h.Users.GetByID(userToken.UserID)
+	if err != nil || user.MFAEnabledAt == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Sign-in expired; enter your password again"})
+		return
+	}
+
+	if req.RecoveryCode != "" {
+		err = h.useRecoveryCode(user, req.RecoveryCode)
+	} else {
+		err = h.checkTOTP(user, req.Code)
+	}
+	if err != nil {
+		if errors.Is(err, errInvalidCode) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authentication code; enter your password again"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check authentication code"})
+		return
+	}
+
+	h.completeSignIn(c, user, true)
+}
+
+// EnrollTOTP generates an authenticator secret for the signed-in user. MFA
+// is not on until ConfirmTOTP accepts a code from the authenticator.
+func (h *AuthHandler) EnrollTOTP(c *gin.Context) {
+	var req PasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, ok := h.requirePassword(c, req.Password)
+	if !ok {
+		return
+	}
+	if user.MFAEnabledAt != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Multi-factor authentication is already on"})
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate secret"})
+		return
+	}
+	user.TOTPSecret = &secret
+	if err := h.Users.Update(user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret":          secret,
+		"provisioningUri": totp.ProvisioningURI(totpIssuer, user.Email, secret),
+	})
+}
+
+// ConfirmTOTP turns MFA on with a first code from the authenticator. Every
+// other session is signed out, and this device gets a session that passed
+// MFA along with the recovery codes, which are shown only now.
+func (h *AuthHandler) ConfirmTOTP(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+	var req TOTPCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.Users.GetByID(userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if user.MFAEnabledAt != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Multi-factor authentication is already on"})
+		return
+	}
+	if user.TOTPSecret == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Start enrollment first"})
+		return
+	}
+	if !h.requireTOTP(c, user, req.Code) {
+		return
+	}
+
+	now := time.Now()
+	user.MFAEnabledAt = &now
+	if err := h.Users.Update(user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
+	}
+	recoveryCodes, err := h.issueRecoveryCodes(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+	if err := h.Users.RevokeUserRefreshTokens(user.ID, now); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign out other sessions"})
+		return
+	}
+	accessToken, refreshToken, err := h.newSession(user, true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"recoveryCodes": recoveryCodes,
+		"accessToken":   accessToken,
+		"refreshToken":  refreshToken,
+	})
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes; the old ones stop
+// working.
+func (h *AuthHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var req MFAChangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, ok := h.requirePassword(c, req.Password)
+	if !ok {
+		return
+	}
+	if user.MFAEnabledAt == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Multi-factor authentication is off"})
+		return
+	}
+	if !h.requireTOTP(c, user, req.Code) {
+		return
+	}
+
+	recoveryCodes, err := h.issueRecoveryCodes(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recoveryCodes": recoveryCodes})
+}
+
+// DisableMFA turns MFA off with the password and an authenticator or
+// recovery code.
+func (h *AuthHandler) DisableMFA(c *gin.Context) {
+	var req MFAChangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, ok := h.requirePassword(c, req.Password)
+	if !ok {
+		return
+	}
+	if user.MFAEnabledAt == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Multi-factor authentication is off"})
+		return
+	}
+
+	err := h.checkTOTP(user, req.Code)
+	if errors.Is(err, errInvalidCode) {
+		err = h.useRecoveryCode(user, req.Code)
+	}
+	if err != nil {
+		if errors.Is(err, errInvalidCode) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authentication code"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check authentication code"})
+		return
+	}
+
+	user.TOTPSecret = nil
+	user.MFAEnabledAt = nil
+	if err := h.Users.Update(user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
+	}
+	if err := h.Users.ReplaceRecoveryCodes(user.ID, nil); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove recovery codes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Multi-factor authentication turned off"})
+}
+
+// requirePassword loads the signed-in user and checks their password,
+// responding with 401 when it is wrong.
+func (h *AuthHandler) requirePassword(c *gin.Context, password string) (*models.User, bool) {
+	userID := c.MustGet("userID").(uuid.UUID)
+	user, err := h.Users.GetByID(userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return nil, false
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Password is incorrect"})
+		return nil, false
+	}
+	return user, true
+}
+
+// requireTOTP checks an authenticator code, responding with 401 when it is
+// wrong.
+func (h *AuthHandler) requireTOTP(c *gin.Context, user *models.User, code string) bool {
+	if err := h.checkTOTP(user, code); err != nil {
+		if errors.Is(err, errInvalidCode) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authentication code"})
+			return false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check authentication code"})
+		return false
+	}
+	return true
+}
+
+// checkTOTP accepts a code once: the step it matched must be later than the
+// last accepted one.
+func (h *AuthHandler) checkTOTP(user *models.User, code string) error {
+	if user.TOTPSecret == nil {
+		return errInvalidCode
+	}
+	step, ok := totp.Validate(*user.TOTPSecret, code, time.Now())
+	if !ok {
+		return errInvalidCode
+	}
+	if err := h.Users.UseTOTPStep(user.ID, step); err != nil {
+		if errors.Is(err, repository.ErrTokenUsed) {
+			return errInvalidCode
+		}
+		return err
+	}
+	user.TOTPLastStep = step
+	return nil
+}
+
+func (h *AuthHandler) useRecoveryCode(user *models.User, code string) error {
+	err := h.Users.UseRecoveryCode(user.ID, jwtutil.HashToken(normalizeRecoveryCode(code)), time.Now())
+	if errors.Is(err, repository.ErrNotFound) {
+		return errInvalidCode
+	}
+	return err
+}
+
+// issueRecoveryCodes replaces the user's recovery codes and returns the new
+// ones in the form shown to the user.
+func (h *AuthHandler) issueRecoveryCodes(userID uuid.UUID) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	for i := range codes {
+		buf := make([]byte, 10)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+		code := strings.ToLower(encoding.EncodeToString(buf))
+		codes[i] = code[0:4] + "-" + code[4:8] + "-" + code[8:12] + "-" + code[12:16]
+		hashes[i] = jwtutil.HashToken(code)
+	}
+	if err := h.Users.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// normalizeRecoveryCode drops the dashes, spaces and case a user may type.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"myway-backend/internal/middleware"
+	"myway-backend/internal/repository"
+	"myway-backend/internal/totp"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func totpCode(t *testing.T, secret string, step int64) string {
+	t.Helper()
+	code, err := totp.Code(secret, step)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+func TestTOTPSignIn(t *testing.T) {
+	s := newTestServer(t)
+	user := s.user("ada@example.com", repository.RoleStudent)
+	token := s.token(user, false)
+	credentials := map[string]string{"email": "ada@example.com", "password": testPassword}
+
+	s.expect(s.do(http.MethodPost, "/auth/mfa/totp", token, map[string]string{"password": "wrong password"}), http.StatusUnauthorized)
+	enrollment := s.expect(s.do(http.MethodPost, "/auth/mfa/totp", token, map[string]string{"password": testPassword}), http.StatusOK)
+	secret := enrollment["secret"].(string)
+
+	// Codes of later steps stay valid across a step boundary during the test
+	step := totp.Step(time.Now())
+	s.expect(s.do(http.MethodPost, "/auth/mfa/totp/confirm", token, map[string]string{"code": "000000"}), http.StatusUnauthorized)
+	confirmed := s.expect(s.do(http.MethodPost, "/auth/mfa/totp/confirm", token, map[string]string{"code": totpCode(t, secret, step)}), http.StatusOK)
+	recoveryCodes := confirmed["recoveryCodes"].([]interface{})
+	if len(recoveryCodes) == 0 || confirmed["accessToken"] == nil {
+		t.Fatalf("confirm response = %v, want recovery codes and a new session", confirmed)
+	}
+
+	// The password alone now only earns a challenge
+	challenge := s.expect(s.do(http.MethodPost, "/auth/signin", "", credentials), http.StatusOK)
+	if challenge["mfaRequired"] != true || challenge["accessToken"] != nil {
+		t.Fatalf("signin response = %v, want an MFA challenge", challenge)
+	}
+	mfaToken := challenge["mfaToken"].(string)
+
+	// A code from the step already used does not work again
+	s.expect(s.do(http.MethodPost, "/auth/mfa/verify", "", map[string]string{"mfaToken": mfaToken, "code": totpCode(t, secret, step)}), http.StatusUnauthorized)
+
+	// The challenge took its one attempt
+	next := totpCode(t, secret, step+1)
+	s.expect(s.do(http.MethodPost, "/auth/mfa/verify", "", map[string]string{"mfaToken": mfaToken, "code": next}), http.StatusUnauthorized)
+
+	challenge = s.expect(s.do(http.MethodPost, "/auth/signin", "", credentials), http.StatusOK)
+	signedIn := s.expect(s.do(http.MethodPost, "/auth/mfa/verify", "", map[string]string{"mfaToken": challenge["mfaToken"].(string), "code": next}), http.StatusOK)
+	me := s.expect(s.do(http.MethodGet, "/auth/me", signedIn["accessToken"].(string), nil), http.StatusOK)
+	if me["mfaEnabled"] != true {
+		t.Fatalf("me = %v, want MFA on", me)
+	}
+
+	// Recovery codes work once
+	recovery := map[string]string{"recoveryCode": recoveryCodes[0].(string)}
+	challenge = s.expect(s.do(http.MethodPost, "/auth/signin", "", credentials), http.StatusOK)
+	recovery["mfaToken"] = challenge["mfaToken"].(string)
+	s.expect(s.do(http.MethodPost, "/auth/mfa/verify", "", recovery), http.StatusOK)
+	challenge = s.expect(s.do(http.MethodPost, "/auth/signin", "", credentials), http.StatusOK)
+	recovery["mfaToken"] = challenge["mfaToken"].(string)
+	s.expect(s.do(http.MethodPost, "/auth/mfa/verify", "", recovery), http.StatusUnauthorized)
+}
+
+func TestOrganizationMFAPolicy(t *testing.T) {
+	s := newTestServer(t)
+	organizer := s.user("organizer@example.com", repository.RoleOrganizer)
+	student := s.user("student@example.com", repository.RoleStudent)
+	org := s.org(organizer)
+	s.join(org, student, repository.RoleStudent)
+	course := s.course(org, organizer)
+	s.enroll(course, student, repository.EnrollmentStudent)
+	policy := "/organizations/" + org.ID.String() + "/mfa-policy"
+	members := "/organizations/" + org.ID.String() + "/members"
+
+	s.expect(s.do(http.MethodPut, policy, s.token(organizer, false), map[string]bool{"requireMfa": true}), http.StatusForbidden)
+	s.expect(s.do(http.MethodPut, policy, s.token(organizer, true), map[string]bool{"requireMfa": true}), http.StatusOK)
+
+	// Staff need a session that passed MFA; students do not
+	w := s.do(http.MethodGet, members, s.token(organizer, false), nil)
+	if body := s.expect(w, http.StatusForbidden); body["error"] != middleware.MFARequiredResponse["error"] || body["mfaRequired"] != true {
+		t.Fatalf("response = %v, want %v", body, middleware.MFARequiredResponse)
+	}
+	s.expect(s.do(http.MethodGet, members, s.token(organizer, true), nil), http.StatusOK)
+	s.expect(s.do(http.MethodGet, "/courses/"+course.ID.String(), s.token(student, false), nil), http.StatusOK)
+}
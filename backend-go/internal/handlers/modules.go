@@ -1,18 +1,21 @@
 package handlers
 
 import (
-	"myway-backend/internal/database"
 	"myway-backend/internal/models"
+	"myway-backend/internal/repository"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
-type ModuleHandler struct{}
+type ModuleHandler struct {
+	Orgs    repository.OrgRepository
+	Courses repository.CourseRepository
+}
 
-func NewModuleHandler() *ModuleHandler {
-	return &ModuleHandler{}
+func NewModuleHandler(repos *repository.Repositories) *ModuleHandler {
+	return &ModuleHandler{Orgs: repos.Orgs, Courses: repos.Courses}
 }
 
 type CreateModuleRequest struct {
@@ -35,6 +38,10 @@ func (h *ModuleHandler) CreateModule(c *gin.Context) {
 		return
 	}
 
+	if _, ok := h.requireCourse(c, courseID, true); !ok {
+		return
+	}
+
 	module := models.Module{
 		CourseID:   courseID,
 		Title:      req.Title,
@@ -42,7 +49,7 @@ func (h *ModuleHandler) CreateModule(c *gin.Context) {
 		LockedRule: req.LockedRule,
 	}
 
-	if err := database.GetDB().Create(&module).Error; err != nil {
+	if err := h.Courses.CreateModule(&module); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create module"})
 		return
 	}
@@ -57,12 +64,12 @@ func (h *ModuleHandler) GetModulesByCourse(c *gin.Context) {
 		return
 	}
 
-	var modules []models.Module
-	if err := database.GetDB().
-		Preload("Materials").
-		Where("course_id = ?", courseID).
-		Order("`order` ASC").
-		Find(&modules).Error; err != nil {
+	if _, ok := h.requireCourse(c, courseID, false); !ok {
+		return
+	}
+
+	modules, err := h.Courses.ListModules(courseID)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch modules"})
 		return
 	}
@@ -77,15 +84,17 @@ func (h *ModuleHandler) GetModule(c *gin.Context) {
 		return
 	}
 
-	var module models.Module
-	if err := database.GetDB().
-		Preload("Materials.StudyPacks").
-		Preload("Course").
-		First(&module, moduleID).Error; err != nil {
+	module, err := h.Courses.GetModuleWithContent(moduleID)
+	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Module not found"})
 		return
 	}
 
+	userID := c.MustGet("userID").(uuid.UUID)
+	if _, ok := requireCourseAccess(c, h.Orgs, h.Courses, userID, &module.Course); !ok {
+		return
+	}
+
 	c.JSON(http.StatusOK, module)
 }
 
@@ -107,24 +116,27 @@ func (h *ModuleHandler) UpdateModule(c *gin.Context) {
 		return
 	}
 
-	var module models.Module
-	if err := database.GetDB().First(&module, moduleID).Error; err != nil {
+	module, err := h.Courses.GetModule(moduleID)
+	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Module not found"})
 		return
 	}
 
-	updates := make(map[string]interface{})
+	if _, ok := h.requireCourse(c, module.CourseID, true); !ok {
+		return
+	}
+
 	if req.Title != nil {
-		updates["title"] = *req.Title
+		module.Title = *req.Title
 	}
 	if req.Order != nil {
-		updates["order"] = *req.Order
+		module.Order = *req.Order
 	}
 	if req.LockedRule != nil {
-		updates["locked_rule"] = *req.LockedRule
+		module.LockedRule = req.LockedRule
 	}
 
-	if err := database.GetDB().Model(&module).Updates(updates).Error; err != nil {
+	if err := h.Courses.UpdateModule(module); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update module"})
 		return
 	}
@@ -139,10 +151,41 @@ func (h *ModuleHandler) DeleteModule(c *gin.Context) {
 		return
 	}
 
-	if err := database.GetDB().Delete(&models.Module{}, moduleID).Error; err != nil {
+	module, err := h.Courses.GetModule(moduleID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Module not found"})
+		return
+	}
+
+	if _, ok := h.requireCourse(c, module.CourseID, true); !ok {
+		return
+	}
+
+	if err := h.Courses.DeleteModule(moduleID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete module"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Module deleted successfully"})
 }
+
+// requireCourse checks the caller is enrolled in the course and, when
+// teaching is set, may edit its modules.
+func (h *ModuleHandler) requireCourse(c *gin.Context, courseID uuid.UUID, teaching bool) (*models.Course, bool) {
+	course, err := h.Courses.GetByID(courseID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Course not found"})
+		return nil, false
+	}
+
+	userID := c.MustGet("userID").(uuid.UUID)
+	access, ok := requireCourseAccess(c, h.Orgs, h.Courses, userID, course)
+	if !ok {
+		return nil, false
+	}
+	if teaching && !access.canTeach() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only course teachers or organizers can edit modules"})
+		return nil, false
+	}
+	return course, true
+}
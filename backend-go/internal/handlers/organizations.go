@@ -1,8 +1,12 @@
 package handlers
 
 import (
-	"myway-backend/internal/database"
+	"crypto/subtle"
+	"errors"
+	"io"
+	"myway-backend/internal/middleware"
 	"myway-backend/internal/models"
+	"myway-backend/internal/repository"
 	"net/http"
 	"strings"
 
@@ -10,10 +14,14 @@ import (
 	"github.com/google/uuid"
 )
 
-type OrganizationHandler struct{}
+type OrganizationHandler struct {
+	Users repository.UserRepository
+	Orgs  repository.OrgRepository
+	Audit repository.AuditRepository
+}
 
-func NewOrganizationHandler() *OrganizationHandler {
-	return &OrganizationHandler{}
+func NewOrganizationHandler(repos *repository.Repositories) *OrganizationHandler {
+	return &OrganizationHandler{Users: repos.Users, Orgs: repos.Orgs, Audit: repos.Audit}
 }
 
 type CreateOrganizationRequest struct {
@@ -23,13 +31,14 @@ type CreateOrganizationRequest struct {
 func (h *OrganizationHandler) CreateOrganization(c *gin.Context) {
 	userID := c.MustGet("userID").(uuid.UUID)
 
-	// Only users with ORGANIZER account role can create organizations
-	var creator models.User
-	if err := database.GetDB().Select("id", "role").First(&creator, userID).Error; err != nil {
+	// Only users granted the ORGANIZER or ADMIN platform role can create
+	// organizations
+	creator, err := h.Users.GetByID(userID)
+	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
 		return
 	}
-	if creator.Role != "ORGANIZER" {
+	if creator.Role != repository.RoleOrganizer && creator.Role != repository.RoleAdmin {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Only organizer role can create organizations"})
 		return
 	}
@@ -45,24 +54,12 @@ func (h *OrganizationHandler) CreateOrganization(c *gin.Context) {
 		Plan: "Free",
 	}
 
-	if err := database.GetDB().Create(&org).Error; err != nil {
+	// The creator becomes the first ORGANIZER member
+	if _, err := h.Orgs.CreateWithOwner(&org, userID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create organization"})
 		return
 	}
 
-	// Add creator as ORGANIZER member
-	membership := models.OrgMembership{
-		OrgID:  org.ID,
-		UserID: userID,
-		Role:   "ORGANIZER",
-		Status: "Active",
-	}
-
-	if err := database.GetDB().Create(&membership).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create membership"})
-		return
-	}
-
 	c.JSON(http.StatusCreated, org)
 }
 
@@ -70,11 +67,8 @@ func (h *OrganizationHandler) GetOrganizations(c *gin.Context) {
 	userID := c.MustGet("userID").(uuid.UUID)
 
 	// Get user's organizations through memberships
-	var memberships []models.OrgMembership
-	if err := database.GetDB().
-		Preload("Organization").
-		Where("user_id = ?", userID).
-		Find(&memberships).Error; err != nil {
+	memberships, err := h.Orgs.ListMemberships(userID)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch organizations"})
 		return
 	}
@@ -82,10 +76,12 @@ func (h *OrganizationHandler) GetOrganizations(c *gin.Context) {
 	orgs := make([]gin.H, len(memberships))
 	for i, m := range memberships {
 		orgs[i] = gin.H{
-			"id":   m.Organization.ID,
-			"name": m.Organization.Name,
-			"plan": m.Organization.Plan,
-			"role": m.Role,
+			"id":         m.Organization.ID,
+			"name":       m.Organization.Name,
+			"plan":       m.Organization.Plan,
+			"role":       m.Role,
+			"status":     m.Status,
+			"requireMfa": m.Organization.RequireMFA,
 		}
 	}
 
@@ -100,13 +96,8 @@ func (h *OrganizationHandler) SwitchOrganization(c *gin.Context) {
 		return
 	}
 
-	// Verify membership
-	var membership models.OrgMembership
-	if err := database.GetDB().
-		Preload("Organization").
-		Where("user_id = ? AND org_id = ? AND status = ?", userID, orgID, "Active").
-		First(&membership).Error; err != nil {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Not a member of this organization"})
+	membership, ok := requireOrgMember(c, h.Orgs, userID, orgID)
+	if !ok {
 		return
 	}
 
@@ -120,6 +111,13 @@ func (h *OrganizationHandler) SwitchOrganization(c *gin.Context) {
 	})
 }
 
+type JoinOrganizationRequest struct {
+	JoinCode string `json:"joinCode"`
+}
+
+// JoinOrganization lets a user join as a STUDENT with the organization's join
+// code, or with a verified email address from one of its allowed domains.
+// Anyone else needs an invitation.
 func (h *OrganizationHandler) JoinOrganization(c *gin.Context) {
 	userID := c.MustGet("userID").(uuid.UUID)
 	orgID, err := uuid.Parse(c.Param("id"))
@@ -128,22 +126,36 @@ func (h *OrganizationHandler) JoinOrganization(c *gin.Context) {
 		return
 	}
 
-	var org models.Organization
-	if err := database.GetDB().First(&org, orgID).Error; err != nil {
+	var req JoinOrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	org, err := h.Orgs.GetByID(orgID)
+	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
 		return
 	}
 
-	var existing models.OrgMembership
-	if err := database.GetDB().Where("user_id = ? AND org_id = ?", userID, orgID).First(&existing).Error; err == nil {
-		if existing.Status == "Active" {
-			c.JSON(http.StatusConflict, gin.H{"error": "Already a member of this organization"})
-			return
-		}
+	existing, err := h.Orgs.GetMembership(userID, orgID)
+	if err == nil && existing.Status == "Active" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Already a member of this organization"})
+		return
+	}
+	if err == nil && existing.Status == repository.MembershipSuspended {
+		c.JSON(http.StatusForbidden, middleware.SuspendedResponse)
+		return
+	}
+	if !h.mayJoin(c, org, userID, req.JoinCode) {
+		return
+	}
 
+	if existing != nil {
+		before := memberAudit(existing)
 		existing.Status = "Active"
 		existing.Role = "STUDENT"
-		if err := database.GetDB().Save(&existing).Error; err != nil {
+		if err := h.saveAudited(c, auditMemberJoin, before, existing); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to activate membership"})
 			return
 		}
@@ -163,7 +175,7 @@ func (h *OrganizationHandler) JoinOrganization(c *gin.Context) {
 		Status: "Active",
 	}
 
-	if err := database.GetDB().Create(&membership).Error; err != nil {
+	if err := h.saveAudited(c, auditMemberJoin, nil, &membership); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to join organization"})
 		return
 	}
@@ -176,302 +188,146 @@ func (h *OrganizationHandler) JoinOrganization(c *gin.Context) {
 }
 
 func (h *OrganizationHandler) DeleteOrganization(c *gin.Context) {
-	userID := c.MustGet("userID").(uuid.UUID)
-	orgID, err := uuid.Parse(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+	membership, ok := requireOrgRole(c, h.Orgs, "Only organizers can delete organizations", "ORGANIZER")
+	if !ok {
 		return
 	}
 
-	var membership models.OrgMembership
-	if err := database.GetDB().Where("user_id = ? AND org_id = ? AND status = ?", userID, orgID, "Active").First(&membership).Error; err != nil {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have access to this organization"})
+	org, orgID := membership.Organization, membership.OrgID
+	event := newAuditEvent(c, orgID, auditOrganizationDelete, "organization", orgID, gin.H{
+		"name": org.Name,
+		"plan": org.Plan,
+	}, nil)
+	err := h.Audit.Record(event, func(tx *repository.Repositories) error {
+		return tx.Orgs.Delete(orgID)
+	})
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete organization"})
 		return
 	}
-	if membership.Role != "ORGANIZER" {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Only organizers can delete organizations"})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Organization deleted successfully"})
+}
+
+type MFAPolicyRequest struct {
+	RequireMFA *bool `json:"requireMfa" binding:"required"`
+}
+
+// SetMFAPolicy makes TEACHER and ORGANIZER members sign in with a second
+// factor before they can act in the organization. Only a session that passed
+// MFA may turn the requirement on, so organizers cannot lock themselves out.
+func (h *OrganizationHandler) SetMFAPolicy(c *gin.Context) {
+	membership, ok := requireOrgRole(c, h.Orgs, "Only organizers can change the MFA policy", "ORGANIZER")
+	if !ok {
 		return
 	}
 
-	tx := database.GetDB().Begin()
-	if tx.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+	var req MFAPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-
-	var courseIDs []uuid.UUID
-	if err := tx.Model(&models.Course{}).Where("org_id = ?", orgID).Pluck("id", &courseIDs).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to prepare course cleanup"})
+	if *req.RequireMFA && !c.GetBool("mfa") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Turn on multi-factor authentication and sign in with it before requiring it"})
 		return
 	}
 
-	if len(courseIDs) > 0 {
-		var assignmentIDs []uuid.UUID
-		if err := tx.Model(&models.Assignment{}).Where("course_id IN ?", courseIDs).Pluck("id", &assignmentIDs).Error; err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to prepare assignment cleanup"})
-			return
-		}
-
-		var threadIDs []uuid.UUID
-		if err := tx.Model(&models.Thread{}).Where("course_id IN ?", courseIDs).Pluck("id", &threadIDs).Error; err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to prepare discussion cleanup"})
-			return
-		}
-
-		var moduleIDs []uuid.UUID
-		if err := tx.Model(&models.Module{}).Where("course_id IN ?", courseIDs).Pluck("id", &moduleIDs).Error; err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to prepare module cleanup"})
-			return
-		}
-
-		var materialIDs []uuid.UUID
-		if len(moduleIDs) > 0 {
-			if err := tx.Model(&models.Material{}).Where("module_id IN ?", moduleIDs).Pluck("id", &materialIDs).Error; err != nil {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to prepare material cleanup"})
-				return
-			}
-		}
-
-		var studyPackIDs []uuid.UUID
-		if len(materialIDs) > 0 {
-			if err := tx.Model(&models.StudyPack{}).Where("material_id IN ?", materialIDs).Pluck("id", &studyPackIDs).Error; err != nil {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to prepare study pack cleanup"})
-				return
-			}
-		}
-
-		var quizIDs []uuid.UUID
-		if len(studyPackIDs) > 0 {
-			if err := tx.Model(&models.Quiz{}).Where("study_pack_id IN ?", studyPackIDs).Pluck("id", &quizIDs).Error; err != nil {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to prepare quiz cleanup"})
-				return
-			}
-		}
-
-		if len(quizIDs) > 0 {
-			if err := tx.Where("quiz_id IN ?", quizIDs).Delete(&models.QuizAttempt{}).Error; err != nil {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete quiz attempts"})
-				return
-			}
-			if err := tx.Where("quiz_id IN ?", quizIDs).Delete(&models.QuizQuestion{}).Error; err != nil {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete quiz questions"})
-				return
-			}
-			if err := tx.Where("id IN ?", quizIDs).Delete(&models.Quiz{}).Error; err != nil {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete quizzes"})
-				return
-			}
-		}
-
-		if len(studyPackIDs) > 0 {
-			if err := tx.Where("study_pack_id IN ?", studyPackIDs).Delete(&models.FlashcardSession{}).Error; err != nil {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete flashcard sessions"})
-				return
-			}
-			if err := tx.Where("study_pack_id IN ?", studyPackIDs).Delete(&models.Flashcard{}).Error; err != nil {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete flashcards"})
-				return
-			}
-			if err := tx.Where("study_pack_id IN ?", studyPackIDs).Delete(&models.Summary{}).Error; err != nil {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete summaries"})
-				return
-			}
-			if err := tx.Where("id IN ?", studyPackIDs).Delete(&models.StudyPack{}).Error; err != nil {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete study packs"})
-				return
-			}
-		}
-
-		if len(materialIDs) > 0 {
-			if err := tx.Where("id IN ?", materialIDs).Delete(&models.Material{}).Error; err != nil {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete materials"})
-				return
-			}
-		}
+	org := membership.Organization
+	org.RequireMFA = *req.RequireMFA
+	if err := h.Orgs.Update(&org); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update organization"})
+		return
+	}
 
-		if len(moduleIDs) > 0 {
-			if err := tx.Where("id IN ?", moduleIDs).Delete(&models.Module{}).Error; err != nil {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete modules"})
-				return
-			}
-		}
+	c.JSON(http.StatusOK, gin.H{
+		"organizationId": org.ID,
+		"requireMfa":     org.RequireMFA,
+	})
+}
 
-		if len(assignmentIDs) > 0 {
-			if err := tx.Where("assignment_id IN ?", assignmentIDs).Delete(&models.Submission{}).Error; err != nil {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete submissions"})
-				return
-			}
-			if err := tx.Where("id IN ?", assignmentIDs).Delete(&models.Assignment{}).Error; err != nil {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete assignments"})
-				return
-			}
+// mayJoin responds with 403 unless the join code matches or the user's
+// verified email is in an allowed domain.
+func (h *OrganizationHandler) mayJoin(c *gin.Context, org *models.Organization, userID uuid.UUID, joinCode string) bool {
+	if org.JoinCode != nil && joinCode != "" &&
+		subtle.ConstantTimeCompare([]byte(strings.TrimSpace(joinCode)), []byte(*org.JoinCode)) == 1 {
+		return true
+	}
+	if org.AllowedDomains != "" {
+		user, err := h.Users.GetByID(userID)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			return false
 		}
-
-		if len(threadIDs) > 0 {
-			if err := tx.Where("thread_id IN ?", threadIDs).Delete(&models.Reply{}).Error; err != nil {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete replies"})
-				return
+		if domainAllowed(org.AllowedDomains, user.Email) {
+			if user.EmailVerifiedAt == nil {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Verify your email address to join this organization"})
+				return false
 			}
-			if err := tx.Where("id IN ?", threadIDs).Delete(&models.Thread{}).Error; err != nil {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete threads"})
-				return
-			}
-		}
-
-		if err := tx.Where("course_id IN ?", courseIDs).Delete(&models.Enrollment{}).Error; err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete enrollments"})
-			return
+			return true
 		}
-		if err := tx.Where("course_id IN ?", courseIDs).Delete(&models.CourseMetric{}).Error; err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete course metrics"})
-			return
-		}
-
-		if err := tx.Where("id IN ?", courseIDs).Delete(&models.Course{}).Error; err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete courses"})
-			return
-		}
-	}
-
-	if err := tx.Where("org_id = ?", orgID).Delete(&models.OrgMembership{}).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete memberships"})
-		return
-	}
-	if err := tx.Where("org_id = ?", orgID).Delete(&models.DailyOrgMetric{}).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete organization metrics"})
-		return
 	}
-
-	deleteResult := tx.Where("id = ?", orgID).Delete(&models.Organization{})
-	if deleteResult.Error != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete organization"})
-		return
+	if joinCode != "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid join code"})
+		return false
 	}
-	if deleteResult.RowsAffected == 0 {
-		tx.Rollback()
-		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
-		return
-	}
-
-	if err := tx.Commit().Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit organization deletion"})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{"message": "Organization deleted successfully"})
+	c.JSON(http.StatusForbidden, gin.H{"error": "Joining this organization requires an invitation or a join code"})
+	return false
 }
 
-type InviteToOrganizationRequest struct {
-	Email string `json:"email" binding:"required,email"`
-	Role  string `json:"role"`
+type JoinPolicyRequest struct {
+	// JoinCode lets anyone who gives it join; empty turns codes off.
+	JoinCode string `json:"joinCode"`
+	// AllowedDomains lets verified users with these email domains join.
+	AllowedDomains []string `json:"allowedDomains"`
 }
 
-func (h *OrganizationHandler) InviteToOrganization(c *gin.Context) {
-	inviterID := c.MustGet("userID").(uuid.UUID)
-	orgID, err := uuid.Parse(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+func (h *OrganizationHandler) GetJoinPolicy(c *gin.Context) {
+	membership, ok := requireOrgRole(c, h.Orgs, "Only organizers can manage how users join", "ORGANIZER")
+	if !ok {
 		return
 	}
+	c.JSON(http.StatusOK, joinPolicyResponse(&membership.Organization))
+}
 
-	// Only organizer of the organization can invite
-	var inviterMembership models.OrgMembership
-	if err := database.GetDB().Where("user_id = ? AND org_id = ? AND status = ?", inviterID, orgID, "Active").First(&inviterMembership).Error; err != nil {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have access to this organization"})
-		return
-	}
-	if inviterMembership.Role != "ORGANIZER" {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Only organizers can invite users"})
+// SetJoinPolicy replaces the join code and allowed email domains.
+func (h *OrganizationHandler) SetJoinPolicy(c *gin.Context) {
+	membership, ok := requireOrgRole(c, h.Orgs, "Only organizers can manage how users join", "ORGANIZER")
+	if !ok {
 		return
 	}
+	org := &membership.Organization
 
-	var req InviteToOrganizationRequest
+	var req JoinPolicyRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	role := strings.ToUpper(strings.TrimSpace(req.Role))
-	if role == "" {
-		role = "STUDENT"
-	}
-	if role != "STUDENT" && role != "TEACHER" && role != "ORGANIZER" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Role must be STUDENT, TEACHER, or ORGANIZER"})
-		return
-	}
-
-	var user models.User
-	if err := database.GetDB().Where("email = ?", strings.ToLower(strings.TrimSpace(req.Email))).First(&user).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found by email"})
-		return
-	}
-
-	var membership models.OrgMembership
-	if err := database.GetDB().Where("user_id = ? AND org_id = ?", user.ID, orgID).First(&membership).Error; err == nil {
-		if membership.Status == "Active" {
-			c.JSON(http.StatusConflict, gin.H{"error": "User is already an active member of this organization"})
+	org.JoinCode = nil
+	if code := strings.TrimSpace(req.JoinCode); code != "" {
+		if len(code) < 6 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "joinCode must be at least 6 characters"})
 			return
 		}
-
-		membership.Status = "Active"
-		membership.Role = role
-		if err := database.GetDB().Save(&membership).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to activate membership"})
-			return
-		}
-
-		c.JSON(http.StatusOK, gin.H{
-			"organizationId": orgID,
-			"userId":         user.ID,
-			"email":          user.Email,
-			"role":           membership.Role,
-			"status":         membership.Status,
-		})
-		return
+		org.JoinCode = &code
 	}
-
-	newMembership := models.OrgMembership{
-		OrgID:  orgID,
-		UserID: user.ID,
-		Role:   role,
-		Status: "Active",
-	}
-	if err := database.GetDB().Create(&newMembership).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to invite user"})
+	org.AllowedDomains = joinDomains(req.AllowedDomains)
+	if err := h.Orgs.Update(org); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update organization"})
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"organizationId": orgID,
-		"userId":         user.ID,
-		"email":          user.Email,
-		"role":           newMembership.Role,
-		"status":         newMembership.Status,
-	})
+	c.JSON(http.StatusOK, joinPolicyResponse(org))
+}
+
+func joinPolicyResponse(org *models.Organization) gin.H {
+	return gin.H{
+		"organizationId": org.ID,
+		"joinCode":       org.JoinCode,
+		"allowedDomains": splitDomains(org.AllowedDomains),
+	}
 }
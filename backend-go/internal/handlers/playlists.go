@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"myway-backend/internal/models"
+	"myway-backend/internal/pipeline"
+	"myway-backend/internal/playlist"
+	"myway-backend/internal/repository"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type ImportPlaylistRequest struct {
+	CourseID    string `json:"courseId" binding:"required"`
+	PlaylistURL string `json:"playlistUrl" binding:"required"`
+	// ModuleID puts every video in an existing module. Without it the
+	// videos go to the course module named after the playlist, which is
+	// created when missing.
+	ModuleID *string `json:"moduleId"`
+	Language string  `json:"language"`
+}
+
+// ImportPlaylist queues a playlist or channel import. The videos are listed
+// by the worker; poll GetImportBatch for progress.
+func (h *ImportsHandler) ImportPlaylist(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+	var req ImportPlaylistRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := playlist.ParseURL(req.PlaylistURL); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid YouTube playlist or channel URL"})
+		return
+	}
+
+	courseID, err := uuid.Parse(req.CourseID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid course ID"})
+		return
+	}
+	course, err := h.Courses.GetByID(courseID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Course not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch course"})
+		return
+	}
+	access, ok := requireCourseAccess(c, h.Orgs, h.Courses, userID, course)
+	if !ok {
+		return
+	}
+	if !access.canTeach() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only course teachers can import videos"})
+		return
+	}
+
+	batch := models.ImportBatch{
+		CourseID:  courseID,
+		CreatedBy: userID,
+		SourceURL: strings.TrimSpace(req.PlaylistURL),
+		Status:    pipeline.BatchQueued,
+	}
+	if req.ModuleID != nil {
+		moduleID, err := uuid.Parse(*req.ModuleID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid module ID"})
+			return
+		}
+		module, err := h.Courses.GetModule(moduleID)
+		if err != nil || module.CourseID != courseID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Module not found in this course"})
+			return
+		}
+		batch.ModuleID = &moduleID
+	}
+	if req.Language != "" {
+		batch.Language = &req.Language
+	}
+
+	job, err := h.Imports.CreateBatch(&batch)
+	if err != nil {
+		log.Printf("Error creating import batch: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create import"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"batchId": batch.ID,
+		"status":  batch.Status,
+		"jobId":   job.ID,
+	})
+}
+
+// GetImportBatch reports a batch with the study pack status of each of its
+// materials and an aggregate status: PROCESSING while any pack is pending,
+// then READY, PARTIAL or FAILED.
+func (h *ImportsHandler) GetImportBatch(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+	batchID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid batch ID"})
+		return
+	}
+
+	batch, err := h.Imports.GetBatch(batchID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Import batch not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch import batch"})
+		return
+	}
+	if _, ok := requireCourseAccess(c, h.Orgs, h.Courses, userID, &batch.Course); !ok {
+		return
+	}
+
+	materials, err := h.Imports.ListBatchMaterials(batch.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch batch materials"})
+		return
+	}
+
+	counts := map[string]int{"queued": 0, "processing": 0, "ready": 0, "failed": 0}
+	items := make([]gin.H, 0, len(materials))
+	for _, material := range materials {
+		item := gin.H{
+			"materialId":   material.ID,
+			"title":        material.Title,
+			"sourceUrl":    material.SourceURL,
+			"durationSec":  material.DurationSec,
+			"status":       "QUEUED",
+			"studyPackId":  nil,
+			"errorMessage": nil,
+		}
+		status := "QUEUED"
+		if len(material.StudyPacks) > 0 {
+			pack := material.StudyPacks[0]
+			status = pack.Status
+			item["studyPackId"] = pack.ID
+			item["errorMessage"] = pack.ErrorMessage
+		}
+		item["status"] = status
+		switch status {
+		case "QUEUED":
+			counts["queued"]++
+		case "PROCESSING":
+			counts["processing"]++
+		case "FAILED":
+			counts["failed"]++
+		default:
+			counts["ready"]++
+		}
+		items = append(items, item)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":           batch.ID,
+		"courseId":     batch.CourseID,
+		"moduleId":     batch.ModuleID,
+		"sourceUrl":    batch.SourceURL,
+		"title":        batch.Title,
+		"status":       batchStatus(*batch, counts),
+		"errorMessage": batch.ErrorMessage,
+		"videoCount":   batch.VideoCount,
+		"skippedCount": batch.SkippedCount,
+		"counts":       counts,
+		"materials":    items,
+		"createdAt":    batch.CreatedAt,
+	})
+}
+
+func batchStatus(batch models.ImportBatch, counts map[string]int) string {
+	switch {
+	case batch.Status != pipeline.BatchCreated:
+		return batch.Status
+	case counts["queued"]+counts["processing"] > 0:
+		return "PROCESSING"
+	case counts["failed"] == 0:
+		return "READY"
+	case counts["ready"] == 0:
+		return "FAILED"
+	default:
+		return "PARTIAL"
+	}
+}
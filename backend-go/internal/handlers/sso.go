@@ -0,0 +1,589 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"myway-backend/internal/models"
+	"myway-backend/internal/oidc"
+	"myway-backend/internal/repository"
+	jwtutil "myway-backend/pkg/jwt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ssoLoginTTL is how long a user has to finish signing in at the provider.
+const ssoLoginTTL = 10 * time.Minute
+
+// orgRoleRank orders organization roles so the most privileged mapped role
+// wins when a user matches several.
+var orgRoleRank = map[string]int{"STUDENT": 1, "TEACHER": 2, "ORGANIZER": 3}
+
+// SSOHandler signs organization members in through their OpenID Connect
+// provider and configures those providers.
+type SSOHandler struct {
+	JWTKeys *jwtutil.Keys
+	Users   repository.UserRepository
+	Orgs    repository.OrgRepository
+	SSO     repository.SSORepository
+	Audit   repository.AuditRepository
+	OIDC    *oidc.Client
+	// PublicURL is the API base URL the provider redirects back to.
+	PublicURL string
+	// AppURL is the frontend base URL the browser ends up on.
+	AppURL string
+}
+
+func NewSSOHandler(jwtKeys *jwtutil.Keys, repos *repository.Repositories, client *oidc.Client, publicURL, appURL string) *SSOHandler {
+	return &SSOHandler{
+		JWTKeys:   jwtKeys,
+		Users:     repos.Users,
+		Orgs:      repos.Orgs,
+		SSO:       repos.SSO,
+		Audit:     repos.Audit,
+		OIDC:      client,
+		PublicURL: publicURL,
+		AppURL:    appURL,
+	}
+}
+
+type SSOConfigRequest struct {
+	Issuer   string `json:"issuer" binding:"required,url"`
+	ClientID string `json:"clientId" binding:"required"`
+	// ClientSecret may be left out when updating to keep the stored one.
+	ClientSecret   string            `json:"clientSecret"`
+	Scopes         []string          `json:"scopes"`
+	RoleClaim      string            `json:"roleClaim"`
+	RoleMapping    map[string]string `json:"roleMapping"`
+	DefaultRole    string            `json:"defaultRole"`
+	AllowedDomains []string          `json:"allowedDomains"`
+	Enabled        *bool             `json:"enabled"`
+}
+
+// GetSSOConfig returns the organization's provider settings without the
+// client secret.
+func (h *SSOHandler) GetSSOConfig(c *gin.Context) {
+	membership, ok := requireOrgRole(c, h.Orgs, "Only organizers can manage single sign-on", "ORGANIZER")
+	if !ok {
+		return
+	}
+
+	provider, err := h.SSO.GetProvider(membership.OrgID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Single sign-on is not configured"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch single sign-on settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, h.ssoConfigResponse(provider))
+}
+
+// PutSSOConfig creates or replaces the organization's provider. The issuer
+// is discovered first so a typo is reported now rather than at sign-in.
+func (h *SSOHandler) PutSSOConfig(c *gin.Context) {
+	membership, ok := requireOrgRole(c, h.Orgs, "Only organizers can manage single sign-on", "ORGANIZER")
+	if !ok {
+		return
+	}
+
+	var req SSOConfigRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	provider, err := h.SSO.GetProvider(membership.OrgID)
+	if errors.Is(err, repository.ErrNotFound) {
+		provider, err = &models.OrgIdentityProvider{OrgID: membership.OrgID}, nil
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch single sign-on settings"})
+		return
+	}
+
+	provider.Issuer = strings.TrimRight(strings.TrimSpace(req.Issuer), "/")
+	provider.ClientID = strings.TrimSpace(req.ClientID)
+	if req.ClientSecret != "" {
+		provider.ClientSecret = req.ClientSecret
+	}
+	if provider.ClientSecret == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "clientSecret is required"})
+		return
+	}
+
+	scopes := req.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"email", "profile"}
+	}
+	provider.Scopes = strings.Join(scopes, " ")
+
+	provider.RoleClaim = nil
+	if claim := strings.TrimSpace(req.RoleClaim); claim != "" {
+		provider.RoleClaim = &claim
+	}
+	mapping := make(map[string]string, len(req.RoleMapping))
+	for value, role := range req.RoleMapping {
+		role = strings.ToUpper(strings.TrimSpace(role))
+		if orgRoleRank[role] == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Role for %q must be STUDENT, TEACHER, or ORGANIZER", value)})
+			return
+		}
+		mapping[value] = role
+	}
+	mappingJSON, _ := json.Marshal(mapping)
+	provider.RoleMapping = string(mappingJSON)
+
+	provider.DefaultRole = strings.ToUpper(strings.TrimSpace(req.DefaultRole))
+	if provider.DefaultRole == "" {
+		provider.DefaultRole = "STUDENT"
+	}
+	if orgRoleRank[provider.DefaultRole] == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "defaultRole must be STUDENT, TEACHER, or ORGANIZER"})
+		return
+	}
+
+	provider.AllowedDomains = joinDomains(req.AllowedDomains)
+
+	provider.Enabled = true
+	if req.Enabled != nil {
+		provider.Enabled = *req.Enabled
+	}
+
+	if _, err := h.OIDC.Discover(c.Request.Context(), provider.Issuer); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.SSO.SaveProvider(provider); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save single sign-on settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, h.ssoConfigResponse(provider))
+}
+
+// DeleteSSOConfig turns single sign-on off. Linked identities go with it;
+// the users keep their accounts.
+func (h *SSOHandler) DeleteSSOConfig(c *gin.Context) {
+	membership, ok := requireOrgRole(c, h.Orgs, "Only organizers can manage single sign-on", "ORGANIZER")
+	if !ok {
+		return
+	}
+
+	if err := h.SSO.DeleteProvider(membership.OrgID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete single sign-on settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Single sign-on disabled"})
+}
+
+// StartSSO redirects the browser to the organization's provider.
+// returnTo is a frontend path handed back after sign-in.
+func (h *SSOHandler) StartSSO(c *gin.Context) {
+	authURL, ok := h.startLogin(c, nil)
+	if !ok {
+		return
+	}
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// StartSSOLink returns the provider URL at which the signed-in user proves
+// they own an identity there; the callback then links it to their account.
+// This is how an account whose email the provider cannot vouch for, or that
+// lies outside the organization's allowed domains, gets single sign-on.
+func (h *SSOHandler) StartSSOLink(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+	authURL, ok := h.startLogin(c, &userID)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"url": authURL})
+}
+
+// startLogin records a sign-in with the :orgId organization's provider, or
+// the linking of userID's account when given, and returns the provider URL
+// to send the browser to.
+func (h *SSOHandler) startLogin(c *gin.Context, userID *uuid.UUID) (string, bool) {
+	orgID, err := uuid.Parse(c.Param("orgId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+		return "", false
+	}
+
+	provider, err := h.SSO.GetProvider(orgID)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch single sign-on settings"})
+		return "", false
+	}
+	if err != nil || !provider.Enabled {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Single sign-on is not enabled for this organization"})
+		return "", false
+	}
+
+	metadata, err := h.OIDC.Discover(c.Request.Context(), provider.Issuer)
+	if err != nil {
+		log.Printf("SSO discovery for org %s failed: %v", orgID, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Identity provider is unavailable"})
+		return "", false
+	}
+
+	login := models.SSOLogin{
+		ProviderID: provider.ID,
+		UserID:     userID,
+		ReturnTo:   safeReturnTo(c.Query("returnTo")),
+		ExpiresAt:  time.Now().Add(ssoLoginTTL),
+	}
+	for _, value := range []*string{&login.State, &login.Nonce, &login.CodeVerifier} {
+		if *value, err = oidc.RandomString(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start sign-in"})
+			return "", false
+		}
+	}
+	if err := h.SSO.CreateLogin(&login); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start sign-in"})
+		return "", false
+	}
+
+	return oidc.AuthCodeURL(metadata, h.oidcConfig(provider), login.State, login.Nonce, oidc.CodeChallenge(login.CodeVerifier)), true
+}
+
+// SSOCallback completes sign-in: it exchanges the code, verifies the ID
+// token, provisions the user and their membership, and hands the frontend an
+// access and refresh token pair in the URL fragment.
+func (h *SSOHandler) SSOCallback(c *gin.Context) {
+	state := c.Query("state")
+	if state == "" {
+		h.failSSO(c, "invalid_request")
+		return
+	}
+
+	login, err := h.SSO.TakeLogin(state)
+	if err != nil || time.Now().After(login.ExpiresAt) {
+		h.failSSO(c, "expired")
+		return
+	}
+	if providerError := c.Query("error"); providerError != "" {
+		log.Printf("SSO provider returned %s: %s", providerError, c.Query("error_description"))
+		h.failSSO(c, "denied")
+		return
+	}
+
+	provider, err := h.SSO.GetProviderByID(login.ProviderID)
+	if err != nil || !provider.Enabled {
+		h.failSSO(c, "disabled")
+		return
+	}
+
+	ctx := c.Request.Context()
+	claims, err := h.verifyCallback(ctx, provider, c.Query("code"), login)
+	if err != nil {
+		log.Printf("SSO sign-in for org %s failed: %v", provider.OrgID, err)
+		h.failSSO(c, "provider_error")
+		return
+	}
+	if claims.Email == "" {
+		h.failSSO(c, "email_missing")
+		return
+	}
+	if !domainAllowed(provider.AllowedDomains, claims.Email) {
+		h.failSSO(c, "domain_not_allowed")
+		return
+	}
+
+	user, err := h.provision(c, provider, claims, login.UserID)
+	if err != nil {
+		if errors.Is(err, errLinkRequired) {
+			h.failSSO(c, "link_required")
+			return
+		}
+		if errors.Is(err, errIdentityLinked) {
+			h.failSSO(c, "identity_linked")
+			return
+		}
+		log.Printf("SSO provisioning for org %s failed: %v", provider.OrgID, err)
+		h.failSSO(c, "server_error")
+		return
+	}
+
+	// Users with MFA on still enter their own code: the frontend posts the
+	// challenge to /auth/mfa/verify as after a password
+	if user.MFAEnabledAt != nil {
+		token, err := newMFAChallenge(h.Users, user.ID)
+		if err != nil {
+			h.failSSO(c, "server_error")
+			return
+		}
+		fragment := url.Values{
+			"mfaToken":  {token},
+			"expiresIn": {strconv.Itoa(int(mfaChallengeTTL.Seconds()))},
+			"orgId":     {provider.OrgID.String()},
+			"returnTo":  {login.ReturnTo},
+		}
+		c.Redirect(http.StatusFound, h.AppURL+"/sso/callback#"+fragment.Encode())
+		return
+	}
+
+	// The session counts as MFA when the provider says it used several
+	// factors (RFC 8176)
+	mfa := false
+	for _, method := range claims.Values("amr") {
+		mfa = mfa || method == "mfa"
+	}
+	accessToken, err := jwtutil.GenerateToken(user.ID, user.Email, mfa, h.JWTKeys)
+	if err != nil {
+		h.failSSO(c, "server_error")
+		return
+	}
+	refreshToken, refreshTokenModel, err := newRefreshToken(user.ID, uuid.New())
+	if err != nil {
+		h.failSSO(c, "server_error")
+		return
+	}
+	refreshTokenModel.MFA = mfa
+	if err := h.Users.CreateRefreshToken(refreshTokenModel); err != nil {
+		h.failSSO(c, "server_error")
+		return
+	}
+
+	fragment := url.Values{
+		"accessToken":  {accessToken},
+		"refreshToken": {refreshToken},
+		"orgId":        {provider.OrgID.String()},
+		"returnTo":     {login.ReturnTo},
+	}
+	c.Redirect(http.StatusFound, h.AppURL+"/sso/callback#"+fragment.Encode())
+}
+
+func (h *SSOHandler) verifyCallback(ctx context.Context, provider *models.OrgIdentityProvider, code string, login *models.SSOLogin) (*oidc.Claims, error) {
+	if code == "" {
+		return nil, errors.New("callback has no code")
+	}
+	metadata, err := h.OIDC.Discover(ctx, provider.Issuer)
+	if err != nil {
+		return nil, err
+	}
+	cfg := h.oidcConfig(provider)
+	tokens, err := h.OIDC.Exchange(ctx, metadata, cfg, code, login.CodeVerifier)
+	if err != nil {
+		return nil, err
+	}
+	return h.OIDC.VerifyIDToken(ctx, metadata, cfg, tokens.IDToken, login.Nonce)
+}
+
+var (
+	errLinkRequired   = errors.New("existing account must be linked from a signed-in session")
+	errIdentityLinked = errors.New("provider identity is linked to another account")
+)
+
+// provision finds or creates the user behind the provider subject and makes
+// sure they are an active member of the organization, recording the join in
+// the audit log. linkUserID is the signed-in user who started the sign-in to
+// link their account. Without it, an existing account with the same email is
+// only linked when autoLinks allows it. A join that fails after the user was
+// saved is retried at their next sign-in.
+func (h *SSOHandler) provision(c *gin.Context, provider *models.OrgIdentityProvider, claims *oidc.Claims, linkUserID *uuid.UUID) (*models.User, error) {
+	now := time.Now()
+	var user *models.User
+	identity, err := h.SSO.GetIdentity(provider.ID, claims.Subject)
+	switch {
+	case err == nil:
+		if linkUserID != nil && *linkUserID != identity.UserID {
+			return nil, errIdentityLinked
+		}
+		if user, err = h.Users.GetByID(identity.UserID); err != nil {
+			return nil, err
+		}
+	case errors.Is(err, repository.ErrNotFound) && linkUserID != nil:
+		if user, err = h.Users.GetByID(*linkUserID); err != nil {
+			return nil, err
+		}
+		identity = &models.UserIdentity{ProviderID: provider.ID, Subject: claims.Subject}
+	case errors.Is(err, repository.ErrNotFound):
+		user, err = h.Users.GetByEmail(repository.NormalizeEmail(claims.Email))
+		switch {
+		case err == nil:
+			if !autoLinks(provider, claims, user) {
+				return nil, errLinkRequired
+			}
+		case errors.Is(err, repository.ErrNotFound):
+			user = &models.User{
+				Email: repository.NormalizeEmail(claims.Email),
+				// No password: the user signs in through the provider,
+				// or sets one with a password reset.
+				PasswordHash: "",
+				Name:         claims.Name,
+				Role:         repository.RoleStudent,
+			}
+			if user.Name == "" {
+				user.Name = claims.Email
+			}
+			if claims.EmailVerified {
+				user.EmailVerifiedAt = &now
+			}
+		default:
+			return nil, err
+		}
+		identity = &models.UserIdentity{ProviderID: provider.ID, Subject: claims.Subject}
+	default:
+		return nil, err
+	}
+
+	identity.Email = claims.Email
+	if err := h.SSO.SaveIdentity(identity, user, now); err != nil {
+		return nil, err
+	}
+
+	// Members joining through the provider get the mapped role; the roles of
+	// existing active members are managed in the app, and suspended members
+	// stay suspended until an organizer reactivates them.
+	var before gin.H
+	membership, err := h.Orgs.GetMembership(user.ID, provider.OrgID)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		membership = &models.OrgMembership{OrgID: provider.OrgID, UserID: user.ID}
+	case err != nil:
+		return nil, err
+	case membership.Status == repository.MembershipActive, membership.Status == repository.MembershipSuspended:
+		return user, nil
+	default:
+		before = memberAudit(membership)
+	}
+	membership.Role = mappedRole(provider, claims)
+	membership.Status = repository.MembershipActive
+
+	after := memberAudit(membership)
+	after["identityProviderId"] = provider.ID
+	event := newAuditEvent(c, provider.OrgID, auditMemberJoin, "member", user.ID, before, after)
+	event.ActorID = user.ID
+	event.ActorEmail = user.Email
+	err = h.Audit.Record(event, func(tx *repository.Repositories) error {
+		return tx.Orgs.SaveMembership(membership)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// autoLinks reports whether the provider may sign in to an existing account
+// just by reporting its email. Organizers configure providers, so this takes
+// a verified email inside the organization's own allowed domains, and never
+// reaches platform admins; any other account has to be linked from a
+// signed-in session.
+func autoLinks(provider *models.OrgIdentityProvider, claims *oidc.Claims, user *models.User) bool {
+	return claims.EmailVerified &&
+		provider.AllowedDomains != "" &&
+		domainAllowed(provider.AllowedDomains, claims.Email) &&
+		user.Role != repository.RoleAdmin
+}
+
+// mappedRole is the most privileged organization role the claims map to,
+// else the provider's default role.
+func mappedRole(provider *models.OrgIdentityProvider, claims *oidc.Claims) string {
+	role := provider.DefaultRole
+	if provider.RoleClaim == nil {
+		return role
+	}
+	var mapping map[string]string
+	if err := json.Unmarshal([]byte(provider.RoleMapping), &mapping); err != nil {
+		return role
+	}
+	for _, value := range claims.Values(*provider.RoleClaim) {
+		if mapped, ok := mapping[value]; ok && orgRoleRank[mapped] > orgRoleRank[role] {
+			role = mapped
+		}
+	}
+	return role
+}
+
+func domainAllowed(allowed, email string) bool {
+	if allowed == "" {
+		return true
+	}
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	domain := strings.ToLower(email[at+1:])
+	for _, candidate := range strings.Split(allowed, ",") {
+		if domain == candidate {
+			return true
+		}
+	}
+	return false
+}
+
+// joinDomains normalizes email domains into the comma-separated list stored
+// as AllowedDomains.
+func joinDomains(domains []string) string {
+	normalized := make([]string, 0, len(domains))
+	for _, domain := range domains {
+		domain = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(domain), "@"))
+		if domain != "" {
+			normalized = append(normalized, domain)
+		}
+	}
+	return strings.Join(normalized, ",")
+}
+
+func splitDomains(allowed string) []string {
+	if allowed == "" {
+		return []string{}
+	}
+	return strings.Split(allowed, ",")
+}
+
+// safeReturnTo keeps only frontend-relative paths, so the redirect cannot be
+// pointed at another site.
+func safeReturnTo(path string) string {
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || strings.Contains(path, "\\") {
+		return "/"
+	}
+	return path
+}
+
+func (h *SSOHandler) failSSO(c *gin.Context, reason string) {
+	c.Redirect(http.StatusFound, h.AppURL+"/signin?ssoError="+url.QueryEscape(reason))
+}
+
+func (h *SSOHandler) oidcConfig(provider *models.OrgIdentityProvider) oidc.Config {
+	return oidc.Config{
+		Issuer:       provider.Issuer,
+		ClientID:     provider.ClientID,
+		ClientSecret: provider.ClientSecret,
+		RedirectURL:  h.PublicURL + "/auth/sso/callback",
+		Scopes:       strings.Fields(provider.Scopes),
+	}
+}
+
+func (h *SSOHandler) ssoConfigResponse(provider *models.OrgIdentityProvider) gin.H {
+	var mapping map[string]string
+	json.Unmarshal([]byte(provider.RoleMapping), &mapping)
+	return gin.H{
+		"organizationId":  provider.OrgID,
+		"issuer":          provider.Issuer,
+		"clientId":        provider.ClientID,
+		"hasClientSecret": provider.ClientSecret != "",
+		"scopes":          strings.Fields(provider.Scopes),
+		"roleClaim":       provider.RoleClaim,
+		"roleMapping":     mapping,
+		"defaultRole":     provider.DefaultRole,
+		"allowedDomains":  splitDomains(provider.AllowedDomains),
+		"enabled":         provider.Enabled,
+		"redirectUri":     h.PublicURL + "/auth/sso/callback",
+		"loginUrl":        h.PublicURL + "/auth/sso/" + provider.OrgID.String() + "/start",
+		"updatedAt":       provider.UpdatedAt,
+	}
+}
@@ -0,0 +1,304 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"myway-backend/internal/oidc"
+	"myway-backend/internal/repository"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// mockIdP is an OpenID provider that signs users in without asking. It
+// holds each authorization request by code and only redeems a code with
+// the PKCE verifier of its challenge.
+type mockIdP struct {
+	t      *testing.T
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	ClientID     string
+	ClientSecret string
+	// Claims are added to every ID token; Nonce overrides the requested one.
+	Claims map[string]interface{}
+	Nonce  string
+
+	mu       sync.Mutex
+	requests map[string]url.Values
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &mockIdP{
+		t:            t,
+		key:          key,
+		ClientID:     "myway",
+		ClientSecret: "idp-secret",
+		Claims:       map[string]interface{}{},
+		requests:     make(map[string]url.Values),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", idp.discovery)
+	mux.HandleFunc("/authorize", idp.authorize)
+	mux.HandleFunc("/token", idp.token)
+	mux.HandleFunc("/jwks", idp.jwks)
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+func (idp *mockIdP) URL() string { return idp.server.URL }
+
+func (idp *mockIdP) discovery(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(oidc.Metadata{
+		Issuer:                idp.URL(),
+		AuthorizationEndpoint: idp.URL() + "/authorize",
+		TokenEndpoint:         idp.URL() + "/token",
+		JWKSURI:               idp.URL() + "/jwks",
+	})
+}
+
+func (idp *mockIdP) jwks(w http.ResponseWriter, r *http.Request) {
+	encode := func(n *big.Int) string { return base64.RawURLEncoding.EncodeToString(n.Bytes()) }
+	json.NewEncoder(w).Encode(oidc.JWKS{Keys: []oidc.JWK{{
+		Kty: "RSA",
+		Kid: "idp-key",
+		Use: "sig",
+		Alg: "RS256",
+		N:   encode(idp.key.N),
+		E:   encode(big.NewInt(int64(idp.key.E))),
+	}}})
+}
+
+// authorize records the request and redirects back with a code.
+func (idp *mockIdP) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("client_id") != idp.ClientID || query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		http.Error(w, "bad authorization request", http.StatusBadRequest)
+		return
+	}
+	code, _ := oidc.RandomString()
+	idp.mu.Lock()
+	idp.requests[code] = query
+	idp.mu.Unlock()
+	callback := url.Values{"code": {code}, "state": {query.Get("state")}}
+	http.Redirect(w, r, query.Get("redirect_uri")+"?"+callback.Encode(), http.StatusFound)
+}
+
+// token redeems a code once, checking the client and the PKCE verifier.
+func (idp *mockIdP) token(w http.ResponseWriter, r *http.Request) {
+	fail := func(reason string) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant", "error_description": reason})
+	}
+	clientID, secret, _ := r.BasicAuth()
+	if clientID != idp.ClientID || secret != idp.ClientSecret {
+		fail("bad client credentials")
+		return
+	}
+	idp.mu.Lock()
+	request, ok := idp.requests[r.PostFormValue("code")]
+	delete(idp.requests, r.PostFormValue("code"))
+	idp.mu.Unlock()
+	if !ok {
+		fail("unknown code")
+		return
+	}
+	if oidc.CodeChallenge(r.PostFormValue("code_verifier")) != request.Get("code_challenge") {
+		fail("code verifier does not match the challenge")
+		return
+	}
+	if r.PostFormValue("redirect_uri") != request.Get("redirect_uri") {
+		fail("redirect URI does not match")
+		return
+	}
+
+	nonce := request.Get("nonce")
+	if idp.Nonce != "" {
+		nonce = idp.Nonce
+	}
+	claims := jwt.MapClaims{
+		"iss":   idp.URL(),
+		"aud":   idp.ClientID,
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(5 * time.Minute).Unix(),
+		"nonce": nonce,
+	}
+	for name, value := range idp.Claims {
+		claims[name] = value
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "idp-key"
+	idToken, err := token.SignedString(idp.key)
+	if err != nil {
+		idp.t.Error(err)
+	}
+	json.NewEncoder(w).Encode(oidc.Tokens{IDToken: idToken, AccessToken: "idp-access", TokenType: "Bearer"})
+}
+
+// ssoSignIn starts sign-in with the organization's provider, lets the
+// provider redirect back, and returns the callback request.
+func (s *testServer) ssoSignIn(orgID string) string {
+	s.t.Helper()
+	w := s.do("GET", "/auth/sso/"+orgID+"/start?returnTo=/courses", "", nil)
+	if w.Code != http.StatusFound {
+		s.t.Fatalf("start status = %d; body %s", w.Code, w.Body.String())
+	}
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(w.Header().Get("Location"))
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		s.t.Fatalf("provider status = %d", resp.StatusCode)
+	}
+	callback := resp.Header.Get("Location")
+	if !strings.HasPrefix(callback, testPublicURL+"/auth/sso/callback?") {
+		s.t.Fatalf("provider redirected to %s", callback)
+	}
+	return strings.TrimPrefix(callback, testPublicURL)
+}
+
+// ssoResult returns the fragment of a callback that signed in, or fails
+// with the error it redirected with.
+func (s *testServer) ssoResult(callback string) (url.Values, string) {
+	s.t.Helper()
+	w := s.do("GET", callback, "", nil)
+	if w.Code != http.StatusFound {
+		s.t.Fatalf("callback status = %d; body %s", w.Code, w.Body.String())
+	}
+	location, err := url.Parse(w.Header().Get("Location"))
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	if reason := location.Query().Get("ssoError"); reason != "" {
+		return nil, reason
+	}
+	fragment, err := url.ParseQuery(location.Fragment)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return fragment, ""
+}
+
+// configureSSO points the organization at the provider.
+func (s *testServer) configureSSO(idp *mockIdP, orgID, organizer string, config map[string]interface{}) {
+	s.t.Helper()
+	config["issuer"] = idp.URL()
+	config["clientId"] = idp.ClientID
+	config["clientSecret"] = idp.ClientSecret
+	s.expect(s.do("PUT", "/organizations/"+orgID+"/sso", organizer, config), http.StatusOK)
+}
+
+func TestSSOCallbackProvisionsUserThroughProvider(t *testing.T) {
+	s := newTestServer(t)
+	idp := newMockIdP(t)
+	owner := s.user("owner@uni.test", repository.RoleStudent)
+	org := s.org(owner)
+	s.configureSSO(idp, org.ID.String(), s.token(owner, false), map[string]interface{}{
+		"roleClaim":      "groups",
+		"roleMapping":    map[string]string{"staff": "TEACHER"},
+		"allowedDomains": []string{"uni.test"},
+	})
+	idp.Claims = map[string]interface{}{
+		"sub":            "idp-user-1",
+		"email":          "Ada@Uni.test",
+		"email_verified": true,
+		"name":           "Ada",
+		"groups":         []string{"staff", "library"},
+	}
+
+	callback := s.ssoSignIn(org.ID.String())
+	fragment, reason := s.ssoResult(callback)
+	if reason != "" {
+		t.Fatalf("sign-in failed with %s", reason)
+	}
+	if fragment.Get("orgId") != org.ID.String() || fragment.Get("returnTo") != "/courses" || fragment.Get("refreshToken") == "" {
+		t.Fatalf("fragment = %v", fragment)
+	}
+
+	user, err := s.repos.Users.GetByEmail("ada@uni.test")
+	if err != nil {
+		t.Fatalf("user was not provisioned: %v", err)
+	}
+	if user.Name != "Ada" || user.EmailVerifiedAt == nil || user.PasswordHash != "" {
+		t.Fatalf("provisioned user = %+v", user)
+	}
+	membership, err := s.repos.Orgs.GetMembership(user.ID, org.ID)
+	if err != nil || membership.Role != "TEACHER" || membership.Status != repository.MembershipActive {
+		t.Fatalf("membership = %+v, %v", membership, err)
+	}
+	events, _, err := s.repos.Audit.List(org.ID, repository.AuditFilter{Action: auditMemberJoin})
+	if err != nil || len(events) != 1 || events[0].ActorID != user.ID {
+		t.Fatalf("join events = %+v, %v", events, err)
+	}
+
+	// The access token in the fragment is the provisioned user's
+	me := s.expect(s.do("GET", "/auth/me", fragment.Get("accessToken"), nil), http.StatusOK)
+	if me["email"] != "ada@uni.test" {
+		t.Fatalf("me = %v", me)
+	}
+
+	// A login is used once
+	if _, reason := s.ssoResult(callback); reason != "expired" {
+		t.Fatalf("replayed callback failed with %q, want expired", reason)
+	}
+
+	// Signing in again finds the same user through the linked identity
+	if _, reason := s.ssoResult(s.ssoSignIn(org.ID.String())); reason != "" {
+		t.Fatalf("second sign-in failed with %s", reason)
+	}
+	members, total, err := s.repos.Orgs.ListMembers(org.ID, repository.MemberFilter{})
+	if err != nil || total != 2 || len(members) != 2 {
+		t.Fatalf("members = %d of %d, %v", len(members), total, err)
+	}
+}
+
+func TestSSOCallbackRejectsMismatchedVerifierAndNonce(t *testing.T) {
+	s := newTestServer(t)
+	idp := newMockIdP(t)
+	owner := s.user("owner@uni.test", repository.RoleStudent)
+	org := s.org(owner)
+	s.configureSSO(idp, org.ID.String(), s.token(owner, false), map[string]interface{}{})
+	idp.Claims = map[string]interface{}{"sub": "idp-user-1", "email": "ada@uni.test", "email_verified": true}
+
+	// A code issued for one login redeemed with another's state carries the
+	// wrong PKCE verifier, so the provider refuses it
+	first, err := url.Parse(s.ssoSignIn(org.ID.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := url.Parse(s.ssoSignIn(org.ID.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	swapped := url.Values{"code": {first.Query().Get("code")}, "state": {second.Query().Get("state")}}
+	if _, reason := s.ssoResult("/auth/sso/callback?" + swapped.Encode()); reason != "provider_error" {
+		t.Fatalf("swapped code failed with %q, want provider_error", reason)
+	}
+
+	// An ID token for another sign-in's nonce is refused
+	idp.Nonce = "replayed-nonce"
+	if _, reason := s.ssoResult(s.ssoSignIn(org.ID.String())); reason != "provider_error" {
+		t.Fatalf("wrong nonce failed with %q, want provider_error", reason)
+	}
+
+	if _, err := s.repos.Users.GetByEmail("ada@uni.test"); err == nil {
+		t.Fatal("a failed sign-in provisioned the user")
+	}
+}
@@ -1,177 +1,188 @@
 package handlers
 
 import (
-	"encoding/json"
-	"encoding/xml"
-	"fmt"
+	"errors"
 	"io"
+	"log"
+	"myway-backend/internal/models"
+	"myway-backend/internal/transcript"
 	"net/http"
-	"net/url"
-	"regexp"
-	"strings"
-	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
 type TranscriptRequest struct {
 	VideoURL string `json:"videoUrl"`
+	Language string `json:"language"`
 }
 
 type TranscriptResponse struct {
 	Transcript string `json:"transcript"`
 }
 
-// FetchTranscriptHandler handles the request to get a YouTube transcript
-func FetchTranscriptHandler(c *gin.Context) {
+// FetchTranscript returns a video's transcript as timestamped lines.
+func (h *ImportsHandler) FetchTranscript(c *gin.Context) {
 	var req TranscriptRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
 		return
 	}
 
-	videoID, err := extractVideoIDForTranscript(req.VideoURL)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid YouTube URL"})
-		return
-	}
-
-	transcript, err := getTranscript(videoID)
-	if err != nil {
-		// Fallback to detailed error message for debugging
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to fetch transcript: %v", err)})
+	t, ok := h.fetchTranscript(c, req.VideoURL, req.Language)
+	if !ok {
 		return
 	}
 
-	c.JSON(http.StatusOK, TranscriptResponse{Transcript: transcript})
+	c.JSON(http.StatusOK, TranscriptResponse{Transcript: t.Text()})
 }
 
-func extractVideoIDForTranscript(videoURL string) (string, error) {
-	u, err := url.Parse(videoURL)
-	if err != nil {
-		return "", err
+// GetYouTubeTranscript returns a video's transcript with its timed segments.
+func (h *ImportsHandler) GetYouTubeTranscript(c *gin.Context) {
+	videoURL := c.Query("url")
+	if videoURL == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing 'url' query parameter"})
+		return
 	}
 
-	if u.Host == "youtu.be" {
-		return strings.TrimPrefix(u.Path, "/"), nil
+	t, ok := h.fetchTranscript(c, videoURL, c.Query("lang"))
+	if !ok {
+		return
 	}
 
-	q := u.Query()
-	videoID := q.Get("v")
-	if videoID == "" {
-		return "", fmt.Errorf("no video ID found")
+	segments := make([]gin.H, len(t.Segments))
+	for i, segment := range t.Segments {
+		segments[i] = gin.H{
+			"start":    segment.Start.Seconds(),
+			"duration": segment.Duration.Seconds(),
+			"text":     segment.Text,
+		}
 	}
-	return videoID, nil
+
+	c.JSON(http.StatusOK, gin.H{
+		"videoId":    t.VideoID,
+		"title":      t.Title,
+		"transcript": t.PlainText(),
+		"language":   t.Language,
+		"duration":   t.Duration.Seconds(),
+		"segments":   segments,
+	})
 }
 
-func getTranscript(videoID string) (string, error) {
-	client := &http.Client{
-		Timeout: 10 * time.Second,
+func (h *ImportsHandler) fetchTranscript(c *gin.Context, videoURL, language string) (*transcript.Transcript, bool) {
+	videoID := transcript.VideoID(videoURL)
+	if videoID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid YouTube URL - could not extract video ID"})
+		return nil, false
 	}
 
-	// 1. Fetch the video page
-	resp, err := client.Get(fmt.Sprintf("https://www.youtube.com/watch?v=%s", videoID))
+	t, err := h.Transcripts.Fetch(c.Request.Context(), videoID, transcript.Languages(language, h.Languages))
 	if err != nil {
-		return "", err
+		switch {
+		case errors.Is(err, transcript.ErrNoCaptions):
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "No captions available for this video",
+				"message": "This video does not have captions/subtitles enabled. Paste the transcript, upload an SRT or WebVTT caption file, or choose a different video.",
+			})
+		case errors.Is(err, transcript.ErrUnavailable):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Video is unavailable"})
+		default:
+			log.Printf("Error fetching transcript for %s: %v", videoID, err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to fetch transcript"})
+		}
+		return nil, false
 	}
-	defer resp.Body.Close()
+	return t, true
+}
 
-	bodyBytes, err := io.ReadAll(resp.Body)
+// UploadCaptions attaches an SRT, WebVTT or YouTube json3/srv3 caption file
+// to a VIDEO material as its timed transcript. A study pack that failed, for
+// instance because the video had no captions, is queued again.
+func (h *ImportsHandler) UploadCaptions(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+	materialID, err := uuid.Parse(c.Param("materialId"))
 	if err != nil {
-		return "", err
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid material ID"})
+		return
 	}
-	html := string(bodyBytes)
 
-	// 2. Extract captionTracks JSON
-	// Look for "captionTracks":[{...}] inside the "player_response"
-	// Regex is fragile but often works for simple scraping
-	re := regexp.MustCompile(`"captionTracks":(\[.*?\])`)
-	matches := re.FindStringSubmatch(html)
-	if len(matches) < 2 {
-		return "", fmt.Errorf("no captions found (or scraping failed)")
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, transcript.MaxCaptionSize+1<<20)
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Caption file is too large"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing caption file"})
+		return
 	}
 
-	jsonStr := matches[1]
-
-	// 3. Parse JSON to find the English track (or first available)
-	var tracks []struct {
-		BaseURL string `json:"baseUrl"`
-		Name    struct {
-			SimpleText string `json:"simpleText"`
-		} `json:"name"`
-		LanguageCode string `json:"languageCode"`
+	material, ok := requireMaterialTeacher(c, h.Orgs, h.Courses, userID, materialID, "Only course teachers can upload captions")
+	if !ok {
+		return
 	}
-
-	if err := json.Unmarshal([]byte(jsonStr), &tracks); err != nil {
-		return "", fmt.Errorf("failed to parse caption tracks: %v", err)
+	if material.Type != "VIDEO" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Captions can only be attached to video materials"})
+		return
 	}
 
-	if len(tracks) == 0 {
-		return "", fmt.Errorf("no caption tracks available")
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read caption file"})
+		return
 	}
-
-	// Prefer English
-	var captionURL string
-	for _, t := range tracks {
-		if strings.HasPrefix(t.LanguageCode, "en") {
-			captionURL = t.BaseURL
-			break
-		}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, transcript.MaxCaptionSize+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read caption file"})
+		return
 	}
-	if captionURL == "" {
-		captionURL = tracks[0].BaseURL // Fallback to first
+	if len(data) > transcript.MaxCaptionSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Caption file is too large"})
+		return
 	}
 
-	// 4. Fetch the actual transcript XML
-	respCaptions, err := client.Get(captionURL)
+	captions, err := transcript.ParseCaptions(data)
 	if err != nil {
-		return "", fmt.Errorf("failed to fetch caption content: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
-	defer respCaptions.Body.Close()
+	t := captionTranscript(material, captions, c.PostForm("language"))
 
-	capBytes, err := io.ReadAll(respCaptions.Body)
+	job, err := h.Imports.AttachTranscript(material, t)
 	if err != nil {
-		return "", err
+		log.Printf("Error attaching captions to material %s: %v", material.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save captions"})
+		return
 	}
+	reindexMaterial(h.Index, material.ID)
 
-	// 5. Parse XML
-	// Format: <transcript><text start="0" dur="5">Hello</text>...</transcript>
-	type Text struct {
-		Start   string `xml:"start,attr"`
-		Dur     string `xml:"dur,attr"`
-		Content string `xml:",chardata"`
+	response := gin.H{
+		"materialId":   material.ID,
+		"format":       captions.Format,
+		"language":     t.Language,
+		"segmentCount": len(t.Segments),
+		"duration":     t.Duration.Seconds(),
+		"jobId":        nil,
 	}
-	type TranscriptXML struct {
-		Texts []Text `xml:"text"`
+	if job != nil {
+		response["jobId"] = job.ID
 	}
+	c.JSON(http.StatusOK, response)
+}
 
-	var tXML TranscriptXML
-	// Some tracks are actually JSON if 'fmt=json3' is in URL, but default scrape usually gives XML or we can force it.
-	// However, the scraped URL often doesn't have format specified, defaulting to XML.
-	// If it fails, we might need to handle JSON format (rare in this specific scrape method).
-	if err := xml.Unmarshal(capBytes, &tXML); err != nil {
-		// It might be JSON3 format if default changed
-		return "", fmt.Errorf("failed to parse XML captions: %v", err)
+// captionTranscript wraps parsed captions as the transcript of a material.
+// An explicit language overrides the one declared in the file.
+func captionTranscript(material *models.Material, captions *transcript.Captions, language string) *transcript.Transcript {
+	t := &transcript.Transcript{Language: captions.Language, Segments: captions.Segments}
+	if language != "" {
+		t.Language = language
 	}
-
-	// 6. Format into readable text
-	var sb strings.Builder
-	for _, text := range tXML.Texts {
-		// Simple decoding of HTML entities might be needed
-		decoded := strings.ReplaceAll(text.Content, "&#39;", "'")
-		decoded = strings.ReplaceAll(decoded, "&quot;", "\"")
-		decoded = strings.ReplaceAll(decoded, "&amp;", "&")
-
-		// Optional: Add timestamps? User wanted "Format: Time - Text"
-		// For now, let's look at the user's previous example:
-		// "0:00 - Introduction"
-		// The XML gives seconds (e.g., start="0.45"). We can format this.
-
-		startTime, _ := time.ParseDuration(text.Start + "s")
-		timestamp := fmt.Sprintf("%d:%02d", int(startTime.Minutes()), int(startTime.Seconds())%60)
-
-		sb.WriteString(fmt.Sprintf("%s - %s\n\n", timestamp, decoded))
+	if material.SourceURL != nil {
+		t.VideoID = transcript.VideoID(*material.SourceURL)
 	}
-
-	return sb.String(), nil
+	last := captions.Segments[len(captions.Segments)-1]
+	t.Duration = last.Start + last.Duration
+	return t
 }
@@ -0,0 +1,238 @@
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"myway-backend/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	StatusQueued    = "QUEUED"
+	StatusRunning   = "RUNNING"
+	StatusSucceeded = "SUCCEEDED"
+	StatusDead      = "DEAD"
+
+	DefaultMaxAttempts = 5
+
+	backoffBase = 10 * time.Second
+	backoffMax  = 10 * time.Minute
+)
+
+// ErrLeaseLost is returned when a worker updates a job it no longer holds
+// because its lease expired and the job was reclaimed.
+var ErrLeaseLost = errors.New("job lease lost")
+
+type EnqueueOptions struct {
+	MaxAttempts int
+	RunAt       time.Time
+	MaterialID  *uuid.UUID
+}
+
+// Enqueue inserts a job using db, which may be a transaction so the job only
+// becomes visible if the surrounding work commits.
+func Enqueue(db *gorm.DB, jobType string, payload interface{}, opts EnqueueOptions) (*models.Job, error) {
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = DefaultMaxAttempts
+	}
+	if opts.RunAt.IsZero() {
+		opts.RunAt = time.Now()
+	}
+
+	job := models.Job{
+		ID:          uuid.New(),
+		Type:        jobType,
+		Payload:     string(payloadJSON),
+		Status:      StatusQueued,
+		MaxAttempts: opts.MaxAttempts,
+		RunAt:       opts.RunAt,
+		MaterialID:  opts.MaterialID,
+	}
+	if err := db.Create(&job).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Claim leases the next runnable job of one of the given types to workerID.
+// Jobs whose lease expired (their worker died) are reclaimed as long as they
+// have attempts left. Returns nil when nothing is runnable.
+func Claim(db *gorm.DB, workerID string, types []string, lease time.Duration) (*models.Job, error) {
+	if len(types) == 0 {
+		return nil, nil
+	}
+
+	var job models.Job
+	result := db.Raw(`
+		UPDATE jobs
+		SET status = ?, attempts = attempts + 1, locked_by = ?, locked_until = ?, updated_at = NOW()
+		WHERE id = (
+			SELECT id FROM jobs
+			WHERE type IN ?
+			  AND ((status = ? AND run_at <= NOW())
+			    OR (status = ? AND locked_until < NOW() AND attempts < max_attempts))
+			ORDER BY run_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`,
+		StatusRunning, workerID, time.Now().Add(lease),
+		types, StatusQueued, StatusRunning,
+	).Scan(&job)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 || job.ID == uuid.Nil {
+		return nil, nil
+	}
+	return &job, nil
+}
+
+// ExtendLease pushes the lease forward while the worker is still busy.
+func ExtendLease(db *gorm.DB, job *models.Job, workerID string, lease time.Duration) error {
+	result := db.Model(&models.Job{}).
+		Where("id = ? AND locked_by = ? AND status = ?", job.ID, workerID, StatusRunning).
+		Update("locked_until", time.Now().Add(lease))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+// updateHeld applies updates to the job only while workerID still holds its
+// lease, so a worker whose lease expired cannot overwrite the outcome of the
+// worker that reclaimed the job.
+func updateHeld(db *gorm.DB, job *models.Job, workerID string, updates map[string]interface{}) error {
+	result := db.Model(&models.Job{}).
+		Where("id = ? AND locked_by = ? AND status = ?", job.ID, workerID, StatusRunning).
+		Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+func SetProgress(db *gorm.DB, jobID uuid.UUID, progress int, message string) error {
+	return db.Model(&models.Job{}).Where("id = ?", jobID).Updates(map[string]interface{}{
+		"progress":         progress,
+		"progress_message": message,
+	}).Error
+}
+
+// Complete marks the job workerID holds as succeeded.
+func Complete(db *gorm.DB, job *models.Job, workerID string) error {
+	now := time.Now()
+	return updateHeld(db, job, workerID, map[string]interface{}{
+		"status":       StatusSucceeded,
+		"progress":     100,
+		"locked_by":    nil,
+		"locked_until": nil,
+		"last_error":   nil,
+		"completed_at": &now,
+	})
+}
+
+// Fail records the error and either reschedules the job workerID holds with
+// exponential backoff or moves it to the dead-letter state. It reports
+// whether the job is dead.
+func Fail(db *gorm.DB, job *models.Job, workerID string, jobErr error) (bool, error) {
+	message := jobErr.Error()
+	dead := IsPermanent(jobErr) || job.Attempts >= job.MaxAttempts
+
+	updates := map[string]interface{}{
+		"locked_by":    nil,
+		"locked_until": nil,
+		"last_error":   message,
+	}
+	if dead {
+		now := time.Now()
+		updates["status"] = StatusDead
+		updates["completed_at"] = &now
+	} else {
+		updates["status"] = StatusQueued
+		updates["run_at"] = time.Now().Add(Backoff(job.Attempts))
+	}
+
+	if err := updateHeld(db, job, workerID, updates); err != nil {
+		return dead, err
+	}
+	return dead, nil
+}
+
+// Release hands a claimed job back without counting the attempt, e.g. when
+// the worker shuts down mid-job.
+func Release(db *gorm.DB, job *models.Job, workerID string) error {
+	return updateHeld(db, job, workerID, map[string]interface{}{
+		"status":       StatusQueued,
+		"attempts":     gorm.Expr("GREATEST(attempts - 1, 0)"),
+		"locked_by":    nil,
+		"locked_until": nil,
+		"run_at":       time.Now(),
+	})
+}
+
+// BuryExpired moves jobs whose lease expired on their final attempt to the
+// dead-letter state and returns them.
+func BuryExpired(db *gorm.DB) ([]models.Job, error) {
+	var buried []models.Job
+	err := db.Raw(`
+		UPDATE jobs
+		SET status = ?, locked_by = NULL, locked_until = NULL, completed_at = NOW(), updated_at = NOW(),
+		    last_error = COALESCE(last_error, 'worker lease expired on final attempt')
+		WHERE status = ? AND locked_until < NOW() AND attempts >= max_attempts
+		RETURNING *`,
+		StatusDead, StatusRunning,
+	).Scan(&buried).Error
+	return buried, err
+}
+
+// Backoff returns the delay before retry number attempt.
+func Backoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	delay := backoffBase
+	for i := 1; i < attempt && delay < backoffMax; i++ {
+		delay *= 2
+	}
+	if delay > backoffMax {
+		delay = backoffMax
+	}
+	// Up to 20% jitter so retries from a burst of failures spread out.
+	return delay + time.Duration(rand.Int63n(int64(delay/5)+1))
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as not worth retrying.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+func IsPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
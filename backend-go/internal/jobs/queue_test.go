@@ -0,0 +1,91 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"myway-backend/internal/database"
+	"myway-backend/internal/models"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// testDB returns a migrated Postgres database from TEST_DATABASE_URL,
+// skipping the test when none is configured.
+func testDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	db, err := gorm.Open(postgres.Open(url), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrator, err := database.NewMigrator(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := migrator.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestExpiredLeaseCannotOverwriteReclaimedJob(t *testing.T) {
+	db := testDB(t)
+	// A job type of its own keeps other jobs in the database out of the way
+	jobType := "test-" + uuid.NewString()
+	types := []string{jobType}
+	queued, err := Enqueue(db, jobType, map[string]string{}, EnqueueOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Delete(&models.Job{}, "id = ?", queued.ID) })
+
+	// The first worker's lease runs out before it finishes
+	stale, err := Claim(db, "worker-a", types, -time.Minute)
+	if err != nil || stale == nil {
+		t.Fatalf("Claim() = %v, %v", stale, err)
+	}
+	current, err := Claim(db, "worker-b", types, time.Minute)
+	if err != nil || current == nil || current.ID != stale.ID {
+		t.Fatalf("Claim() = %v, %v, want the expired job reclaimed", current, err)
+	}
+
+	if err := ExtendLease(db, stale, "worker-a", time.Minute); !errors.Is(err, ErrLeaseLost) {
+		t.Errorf("ExtendLease() error = %v, want ErrLeaseLost", err)
+	}
+	if err := Complete(db, stale, "worker-a"); !errors.Is(err, ErrLeaseLost) {
+		t.Errorf("Complete() error = %v, want ErrLeaseLost", err)
+	}
+	if _, err := Fail(db, stale, "worker-a", Permanent(errors.New("boom"))); !errors.Is(err, ErrLeaseLost) {
+		t.Errorf("Fail() error = %v, want ErrLeaseLost", err)
+	}
+	if err := Release(db, stale, "worker-a"); !errors.Is(err, ErrLeaseLost) {
+		t.Errorf("Release() error = %v, want ErrLeaseLost", err)
+	}
+
+	var job models.Job
+	if err := db.First(&job, "id = ?", queued.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != StatusRunning || job.LockedBy == nil || *job.LockedBy != "worker-b" || job.LastError != nil {
+		t.Fatalf("job = %s locked by %v, want still running for worker-b", job.Status, job.LockedBy)
+	}
+
+	if err := Complete(db, current, "worker-b"); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := db.First(&job, "id = ?", queued.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != StatusSucceeded {
+		t.Fatalf("job status = %s, want %s", job.Status, StatusSucceeded)
+	}
+}
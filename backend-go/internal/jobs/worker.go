@@ -0,0 +1,255 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"myway-backend/internal/models"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// HandlerFunc processes one job. Returning an error schedules a retry unless
+// the error is wrapped with Permanent.
+type HandlerFunc func(ctx context.Context, job *models.Job) error
+
+// DeadFunc runs once a job lands in the dead-letter state so the owning
+// resource can be marked as failed.
+type DeadFunc func(job *models.Job, err error)
+
+type registration struct {
+	handle HandlerFunc
+	onDead DeadFunc
+}
+
+// TaskFunc is maintenance work run on an interval rather than from the queue.
+// Every worker runs it, so it must be safe to run concurrently.
+type TaskFunc func(ctx context.Context) error
+
+type periodicTask struct {
+	name     string
+	interval time.Duration
+	run      TaskFunc
+}
+
+type Worker struct {
+	DB           *gorm.DB
+	ID           string
+	Concurrency  int
+	PollInterval time.Duration
+	Lease        time.Duration
+
+	handlers map[string]registration
+	tasks    []periodicTask
+}
+
+func NewWorker(db *gorm.DB, concurrency int) *Worker {
+	hostname, _ := os.Hostname()
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &Worker{
+		DB:           db,
+		ID:           fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), uuid.New().String()[:8]),
+		Concurrency:  concurrency,
+		PollInterval: 2 * time.Second,
+		Lease:        2 * time.Minute,
+		handlers:     make(map[string]registration),
+	}
+}
+
+func (w *Worker) Register(jobType string, handle HandlerFunc, onDead DeadFunc) {
+	w.handlers[jobType] = registration{handle: handle, onDead: onDead}
+}
+
+// Every runs task each interval while the worker runs, starting with one run
+// at startup.
+func (w *Worker) Every(name string, interval time.Duration, task TaskFunc) {
+	w.tasks = append(w.tasks, periodicTask{name: name, interval: interval, run: task})
+}
+
+func (w *Worker) types() []string {
+	types := make([]string, 0, len(w.handlers))
+	for jobType := range w.handlers {
+		types = append(types, jobType)
+	}
+	return types
+}
+
+// Run polls for jobs until ctx is cancelled, then waits for in-flight jobs.
+func (w *Worker) Run(ctx context.Context) {
+	log.Printf("Worker %s started with %d slots for %v", w.ID, w.Concurrency, w.types())
+
+	var wg sync.WaitGroup
+	for i := 0; i < w.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.loop(ctx)
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		w.sweep(ctx)
+	}()
+
+	for _, task := range w.tasks {
+		wg.Add(1)
+		go func(task periodicTask) {
+			defer wg.Done()
+			w.runTask(ctx, task)
+		}(task)
+	}
+
+	wg.Wait()
+	log.Printf("Worker %s stopped", w.ID)
+}
+
+func (w *Worker) loop(ctx context.Context) {
+	types := w.types()
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		job, err := Claim(w.DB, w.ID, types, w.Lease)
+		if err != nil {
+			log.Printf("Worker %s failed to claim job: %v", w.ID, err)
+		}
+		if job == nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(w.PollInterval):
+			}
+			continue
+		}
+
+		w.process(ctx, job)
+	}
+}
+
+func (w *Worker) process(ctx context.Context, job *models.Job) {
+	reg := w.handlers[job.Type]
+	log.Printf("Worker %s running job %s (%s), attempt %d/%d", w.ID, job.ID, job.Type, job.Attempts, job.MaxAttempts)
+
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Keep the lease alive while the handler runs.
+	heartbeatDone := make(chan struct{})
+	go func() {
+		defer close(heartbeatDone)
+		ticker := time.NewTicker(w.Lease / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-jobCtx.Done():
+				return
+			case <-ticker.C:
+				if err := ExtendLease(w.DB, job, w.ID, w.Lease); err != nil {
+					log.Printf("Worker %s lost lease on job %s: %v", w.ID, job.ID, err)
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	err := w.safeHandle(jobCtx, reg.handle, job)
+	cancel()
+	<-heartbeatDone
+
+	if err == nil {
+		if err := Complete(w.DB, job, w.ID); err != nil {
+			log.Printf("Worker %s failed to complete job %s: %v", w.ID, job.ID, err)
+			return
+		}
+		log.Printf("Job %s (%s) succeeded", job.ID, job.Type)
+		return
+	}
+
+	if ctx.Err() != nil && errors.Is(err, context.Canceled) {
+		log.Printf("Worker %s shutting down, releasing job %s", w.ID, job.ID)
+		if err := Release(w.DB, job, w.ID); err != nil {
+			log.Printf("Worker %s failed to release job %s: %v", w.ID, job.ID, err)
+		}
+		return
+	}
+
+	dead, failErr := Fail(w.DB, job, w.ID, err)
+	if failErr != nil {
+		log.Printf("Worker %s failed to record failure of job %s: %v", w.ID, job.ID, failErr)
+		return
+	}
+	if !dead {
+		log.Printf("Job %s (%s) failed, will retry: %v", job.ID, job.Type, err)
+		return
+	}
+
+	log.Printf("Job %s (%s) moved to dead-letter state: %v", job.ID, job.Type, err)
+	if reg.onDead != nil {
+		reg.onDead(job, err)
+	}
+}
+
+func (w *Worker) safeHandle(ctx context.Context, handle HandlerFunc, job *models.Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return handle(ctx, job)
+}
+
+// sweep buries jobs abandoned by crashed workers on their last attempt.
+func (w *Worker) sweep(ctx context.Context) {
+	ticker := time.NewTicker(w.Lease)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			buried, err := BuryExpired(w.DB)
+			if err != nil {
+				log.Printf("Worker %s failed to sweep expired jobs: %v", w.ID, err)
+				continue
+			}
+			for i := range buried {
+				job := &buried[i]
+				reg, ok := w.handlers[job.Type]
+				if !ok || reg.onDead == nil {
+					continue
+				}
+				message := "worker lease expired on final attempt"
+				if job.LastError != nil {
+					message = *job.LastError
+				}
+				reg.onDead(job, errors.New(message))
+			}
+		}
+	}
+}
+
+func (w *Worker) runTask(ctx context.Context, task periodicTask) {
+	ticker := time.NewTicker(task.interval)
+	defer ticker.Stop()
+	for {
+		if err := task.run(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Worker %s task %q failed: %v", w.ID, task.name, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
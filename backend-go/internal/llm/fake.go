@@ -0,0 +1,175 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Fake is a deterministic provider for tests and offline development. Unless
+// Reply or StructuredReply are set it echoes the last user message and builds
+// structured output from the requested schema.
+type Fake struct {
+	Reply           func(req Request) string
+	StructuredReply func(req Request, schema Schema) string
+
+	mu       sync.Mutex
+	requests []Request
+}
+
+func NewFake() *Fake {
+	return &Fake{}
+}
+
+func (f *Fake) Name() string  { return "fake" }
+func (f *Fake) Model() string { return "fake-model" }
+
+// Requests returns every request the fake has received, oldest first.
+func (f *Fake) Requests() []Request {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Request(nil), f.requests...)
+}
+
+func (f *Fake) record(req Request) {
+	f.mu.Lock()
+	f.requests = append(f.requests, req)
+	f.mu.Unlock()
+}
+
+func (f *Fake) reply(req Request) string {
+	if f.Reply != nil {
+		return f.Reply(req)
+	}
+	for i := len(req.Messages) - 1; i >= 0; i-- {
+		if req.Messages[i].Role == RoleUser {
+			return "Fake answer to: " + strings.TrimSpace(req.Messages[i].Content)
+		}
+	}
+	return "Fake answer."
+}
+
+func (f *Fake) response(req Request, text string) *Response {
+	prompt := len(strings.Fields(req.System))
+	for _, msg := range req.Messages {
+		prompt += len(strings.Fields(msg.Content))
+	}
+	completion := len(strings.Fields(text))
+	return &Response{
+		Text:     text,
+		Usage:    Usage{PromptTokens: prompt, CompletionTokens: completion, TotalTokens: prompt + completion},
+		Provider: f.Name(),
+		Model:    f.Model(),
+	}
+}
+
+func (f *Fake) Generate(ctx context.Context, req Request) (*Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	f.record(req)
+	return f.response(req, f.reply(req)), nil
+}
+
+func (f *Fake) GenerateStructured(ctx context.Context, req Request, schema Schema, out interface{}) (*Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	f.record(req)
+
+	var text string
+	if f.StructuredReply != nil {
+		text = f.StructuredReply(req, schema)
+	} else {
+		value, _ := json.Marshal(exampleFromSchema(map[string]interface{}(schema), "value"))
+		text = string(value)
+	}
+
+	resp := f.response(req, text)
+	if err := decodeStructured(text, out); err != nil {
+		return resp, err
+	}
+	return resp, nil
+}
+
+func (f *Fake) Stream(ctx context.Context, req Request, onDelta func(delta string) error) (*Response, error) {
+	f.record(req)
+	text := f.reply(req)
+	for i, word := range strings.Fields(text) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		delta := word
+		if i > 0 {
+			delta = " " + word
+		}
+		if err := onDelta(delta); err != nil {
+			return nil, err
+		}
+	}
+	return f.response(req, text), nil
+}
+
+// exampleFromSchema builds the smallest value that satisfies schema.
+func exampleFromSchema(schema map[string]interface{}, name string) interface{} {
+	if enum, ok := schema["enum"].([]interface{}); ok && len(enum) > 0 {
+		return enum[0]
+	}
+
+	schemaType, _ := schema["type"].(string)
+	switch strings.ToLower(schemaType) {
+	case "object":
+		properties, _ := schema["properties"].(map[string]interface{})
+		keys := make([]string, 0, len(properties))
+		for key := range properties {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		obj := make(map[string]interface{}, len(keys))
+		for _, key := range keys {
+			if prop, ok := properties[key].(map[string]interface{}); ok {
+				obj[key] = exampleFromSchema(prop, key)
+			}
+		}
+		return obj
+	case "array":
+		count := 1
+		if minItems, ok := toInt(schema["minItems"]); ok && minItems > count {
+			count = minItems
+		}
+		items, _ := schema["items"].(map[string]interface{})
+		arr := make([]interface{}, count)
+		for i := range arr {
+			arr[i] = exampleFromSchema(items, fmt.Sprintf("%s %d", name, i+1))
+		}
+		return arr
+	case "integer":
+		if minimum, ok := toInt(schema["minimum"]); ok {
+			return minimum
+		}
+		return 0
+	case "number":
+		if minimum, ok := toInt(schema["minimum"]); ok {
+			return minimum
+		}
+		return 0
+	case "boolean":
+		return false
+	default:
+		return "Fake " + name
+	}
+}
+
+func toInt(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case int:
+		return n, true
+	case float64:
+		return int(n), true
+	}
+	return 0, false
+}
@@ -0,0 +1,246 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	defaultGeminiModel   = "gemini-3-flash-preview"
+	defaultGeminiBaseURL = "https://generativelanguage.googleapis.com/v1beta"
+)
+
+type Gemini struct {
+	apiKey  string
+	model   string
+	baseURL string
+	timeout time.Duration
+	client  *http.Client
+}
+
+func NewGemini(cfg Config) *Gemini {
+	model := cfg.Model
+	if model == "" {
+		model = defaultGeminiModel
+	}
+	baseURL := strings.TrimRight(cfg.BaseURL, "/")
+	if baseURL == "" {
+		baseURL = defaultGeminiBaseURL
+	}
+	return &Gemini{
+		apiKey:  strings.TrimSpace(cfg.APIKey),
+		model:   model,
+		baseURL: baseURL,
+		timeout: cfg.Timeout,
+		client:  &http.Client{},
+	}
+}
+
+func (g *Gemini) Name() string  { return "gemini" }
+func (g *Gemini) Model() string { return g.model }
+
+type geminiPart struct {
+	Text string `json:"text"`
+}
+
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
+type geminiGenerationConfig struct {
+	Temperature      float64 `json:"temperature,omitempty"`
+	MaxOutputTokens  int     `json:"maxOutputTokens,omitempty"`
+	ResponseMimeType string  `json:"responseMimeType,omitempty"`
+	ResponseSchema   Schema  `json:"responseSchema,omitempty"`
+}
+
+type geminiRequest struct {
+	SystemInstruction *geminiContent         `json:"systemInstruction,omitempty"`
+	Contents          []geminiContent        `json:"contents"`
+	GenerationConfig  geminiGenerationConfig `json:"generationConfig,omitempty"`
+}
+
+type geminiResponse struct {
+	Candidates []struct {
+		Content struct {
+			Parts []struct {
+				Text string `json:"text"`
+			} `json:"parts"`
+		} `json:"content"`
+	} `json:"candidates"`
+	UsageMetadata struct {
+		PromptTokenCount     int `json:"promptTokenCount"`
+		CandidatesTokenCount int `json:"candidatesTokenCount"`
+		TotalTokenCount      int `json:"totalTokenCount"`
+	} `json:"usageMetadata"`
+}
+
+func (r *geminiResponse) text() string {
+	var sb strings.Builder
+	for _, candidate := range r.Candidates {
+		for _, part := range candidate.Content.Parts {
+			sb.WriteString(part.Text)
+		}
+		// Only the first candidate is ever requested.
+		break
+	}
+	return sb.String()
+}
+
+func (r *geminiResponse) usage() Usage {
+	return Usage{
+		PromptTokens:     r.UsageMetadata.PromptTokenCount,
+		CompletionTokens: r.UsageMetadata.CandidatesTokenCount,
+		TotalTokens:      r.UsageMetadata.TotalTokenCount,
+	}
+}
+
+func (g *Gemini) buildRequest(req Request) geminiRequest {
+	body := geminiRequest{
+		GenerationConfig: geminiGenerationConfig{
+			Temperature:     req.Temperature,
+			MaxOutputTokens: req.MaxTokens,
+		},
+	}
+	if strings.TrimSpace(req.System) != "" {
+		body.SystemInstruction = &geminiContent{Parts: []geminiPart{{Text: req.System}}}
+	}
+	for _, msg := range req.Messages {
+		role := "user"
+		if msg.Role == RoleAssistant {
+			role = "model"
+		}
+		body.Contents = append(body.Contents, geminiContent{
+			Role:  role,
+			Parts: []geminiPart{{Text: msg.Content}},
+		})
+	}
+	return body
+}
+
+func (g *Gemini) post(ctx context.Context, method string, body geminiRequest) (*http.Response, error) {
+	if g.apiKey == "" {
+		return nil, ErrNotConfigured
+	}
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	url := g.baseURL + "/models/" + g.model + ":" + method
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	// In a header rather than the query, the key stays out of the URLs that
+	// request errors quote and that end up in logs
+	httpReq.Header.Set("x-goog-api-key", g.apiKey)
+
+	resp, err := g.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		errBody, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+		return nil, fmt.Errorf("gemini returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(errBody)))
+	}
+	return resp, nil
+}
+
+func (g *Gemini) generate(ctx context.Context, body geminiRequest) (*Response, error) {
+	ctx, cancel := context.WithTimeout(ctx, g.timeout)
+	defer cancel()
+
+	resp, err := g.post(ctx, "generateContent", body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var parsed geminiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return nil, err
+	}
+
+	text := strings.TrimSpace(parsed.text())
+	if text == "" {
+		return nil, ErrEmptyResponse
+	}
+
+	return &Response{
+		Text:     text,
+		Usage:    parsed.usage(),
+		Provider: g.Name(),
+		Model:    g.model,
+	}, nil
+}
+
+func (g *Gemini) Generate(ctx context.Context, req Request) (*Response, error) {
+	return g.generate(ctx, g.buildRequest(req))
+}
+
+func (g *Gemini) GenerateStructured(ctx context.Context, req Request, schema Schema, out interface{}) (*Response, error) {
+	body := g.buildRequest(req)
+	body.GenerationConfig.ResponseMimeType = "application/json"
+	body.GenerationConfig.ResponseSchema = schema
+
+	resp, err := g.generate(ctx, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := decodeStructured(resp.Text, out); err != nil {
+		return resp, err
+	}
+	return resp, nil
+}
+
+func (g *Gemini) Stream(ctx context.Context, req Request, onDelta func(delta string) error) (*Response, error) {
+	resp, err := g.post(ctx, "streamGenerateContent?alt=sse", g.buildRequest(req))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var full strings.Builder
+	var usage Usage
+	err = readSSE(resp.Body, func(data []byte) error {
+		var chunk geminiResponse
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return err
+		}
+		if chunk.UsageMetadata.TotalTokenCount > 0 {
+			usage = chunk.usage()
+		}
+		delta := chunk.text()
+		if delta == "" {
+			return nil
+		}
+		full.WriteString(delta)
+		return onDelta(delta)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	text := strings.TrimSpace(full.String())
+	if text == "" {
+		return nil, ErrEmptyResponse
+	}
+
+	return &Response{
+		Text:     text,
+		Usage:    usage,
+		Provider: g.Name(),
+		Model:    g.model,
+	}, nil
+}
@@ -0,0 +1,106 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+var (
+	ErrNotConfigured = errors.New("llm provider is not configured")
+	ErrEmptyResponse = errors.New("empty response from llm provider")
+)
+
+// Message is a single conversation turn. Role is RoleUser or RoleAssistant;
+// providers translate it to their own vocabulary.
+type Message struct {
+	Role    string
+	Content string
+}
+
+type Request struct {
+	System      string
+	Messages    []Message
+	Temperature float64
+	MaxTokens   int
+}
+
+type Usage struct {
+	PromptTokens     int `json:"promptTokens"`
+	CompletionTokens int `json:"completionTokens"`
+	TotalTokens      int `json:"totalTokens"`
+}
+
+type Response struct {
+	Text     string
+	Usage    Usage
+	Provider string
+	Model    string
+}
+
+// Schema is a JSON schema object describing the expected structured output.
+type Schema map[string]interface{}
+
+// Provider is implemented by every LLM backend the server can talk to.
+type Provider interface {
+	Name() string
+	Model() string
+	Generate(ctx context.Context, req Request) (*Response, error)
+	// GenerateStructured asks for JSON matching schema and decodes it into out.
+	GenerateStructured(ctx context.Context, req Request, schema Schema, out interface{}) (*Response, error)
+	// Stream calls onDelta for every text fragment as it arrives and returns
+	// the aggregated response once the provider finishes.
+	Stream(ctx context.Context, req Request, onDelta func(delta string) error) (*Response, error)
+}
+
+type Config struct {
+	Provider string
+	Model    string
+	BaseURL  string
+	APIKey   string
+	Timeout  time.Duration
+}
+
+func New(cfg Config) (Provider, error) {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+
+	switch strings.ToLower(strings.TrimSpace(cfg.Provider)) {
+	case "", "gemini":
+		return NewGemini(cfg), nil
+	case "openai":
+		return NewOpenAI(cfg), nil
+	case "fake":
+		return NewFake(), nil
+	default:
+		return nil, fmt.Errorf("unknown llm provider %q", cfg.Provider)
+	}
+}
+
+// decodeStructured parses model output as JSON, tolerating markdown code fences.
+func decodeStructured(text string, out interface{}) error {
+	clean := strings.TrimSpace(text)
+	if strings.HasPrefix(clean, "```") {
+		clean = strings.TrimPrefix(clean, "```json")
+		clean = strings.TrimPrefix(clean, "```")
+		clean = strings.TrimSuffix(strings.TrimSpace(clean), "```")
+	}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(clean)), out); err != nil {
+		return fmt.Errorf("failed to decode structured output: %w", err)
+	}
+	return nil
+}
+
+func schemaInstruction(schema Schema) string {
+	schemaJSON, _ := json.Marshal(schema)
+	return "Respond with a single JSON object only, no prose and no code fences. It must match this JSON schema:\n" + string(schemaJSON)
+}
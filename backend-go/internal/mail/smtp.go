@@ -0,0 +1,69 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+)
+
+// SMTP delivers through a relay, upgrading to TLS when the server offers
+// STARTTLS.
+type SMTP struct {
+	addr     string
+	from     string
+	envelope string
+	auth     smtp.Auth
+}
+
+func NewSMTP(cfg Config) (*SMTP, error) {
+	if cfg.SMTPHost == "" {
+		return nil, fmt.Errorf("SMTP host is not set")
+	}
+	sender, err := mail.ParseAddress(cfg.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sender address %q: %w", cfg.From, err)
+	}
+	port := cfg.SMTPPort
+	if port == 0 {
+		port = 587
+	}
+
+	s := &SMTP{
+		addr:     net.JoinHostPort(cfg.SMTPHost, strconv.Itoa(port)),
+		from:     cfg.From,
+		envelope: sender.Address,
+	}
+	if cfg.SMTPUsername != "" {
+		s.auth = smtp.PlainAuth("", cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPHost)
+	}
+	return s, nil
+}
+
+func (s *SMTP) Send(ctx context.Context, msg Message) error {
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return fmt.Errorf("invalid recipient %q: %w", msg.To, err)
+	}
+	data, err := format(s.from, msg)
+	if err != nil {
+		return err
+	}
+
+	// smtp.SendMail cannot be cancelled, so the context only bounds the wait.
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(s.addr, s.auth, s.envelope, []string{to.Address}, data)
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("send mail to %s: %w", to.Address, err)
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
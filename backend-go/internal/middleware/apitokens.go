@@ -0,0 +1,160 @@
+package middleware
+
+import (
+	"errors"
+	"log"
+	"myway-backend/internal/models"
+	"myway-backend/internal/repository"
+	jwtutil "myway-backend/pkg/jwt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// lastUsedInterval limits how often a token's LastUsedAt is written.
+const lastUsedInterval = time.Minute
+
+// routeScope is what an API token needs to call a route. orgScoped routes
+// check membership of the organization they act on, so organization API
+// keys may call them; the others act on the caller's own data or check no
+// organization, and only personal access tokens reach them.
+type routeScope struct {
+	scope     string
+	orgScoped bool
+}
+
+// apiTokenRoutes lists every route an API token may call, keyed by method
+// and route pattern. Routes that are missing, such as sign-in, account and
+// security settings, token management and interactive study, take an access
+// token from a sign-in.
+var apiTokenRoutes = map[string]routeScope{
+	"GET /auth/me": {scope: ""},
+
+	"GET /organizations/:id/invitations":                       {scope: "orgs:read", orgScoped: true},
+	"POST /organizations/:id/invitations/:invitationId/resend": {scope: "orgs:write", orgScoped: true},
+	"DELETE /organizations/:id/invitations/:invitationId":      {scope: "orgs:write", orgScoped: true},
+
+	"GET /organizations/:id/members":                     {scope: "orgs:read", orgScoped: true},
+	"PUT /organizations/:id/members/:userId":             {scope: "orgs:write", orgScoped: true},
+	"POST /organizations/:id/members/:userId/suspend":    {scope: "orgs:write", orgScoped: true},
+	"POST /organizations/:id/members/:userId/reactivate": {scope: "orgs:write", orgScoped: true},
+	"DELETE /organizations/:id/members/:userId":          {scope: "orgs:write", orgScoped: true},
+
+	"GET /organizations":                {scope: "orgs:read"},
+	"POST /organizations/:id/switch":    {scope: "orgs:read", orgScoped: true},
+	"POST /organizations/:id/invite":    {scope: "orgs:write", orgScoped: true},
+	"GET /courses/org/:orgId":           {scope: "courses:read", orgScoped: true},
+	"GET /courses/:id":                  {scope: "courses:read", orgScoped: true},
+	"POST /courses":                     {scope: "courses:write", orgScoped: true},
+	"DELETE /courses/:id":               {scope: "courses:write", orgScoped: true},
+	"PUT /courses/:id/enrollment-key":   {scope: "courses:write", orgScoped: true},
+	"GET /modules/course/:courseId":     {scope: "courses:read", orgScoped: true},
+	"GET /modules/:id":                  {scope: "courses:read", orgScoped: true},
+	"POST /modules":                     {scope: "courses:write", orgScoped: true},
+	"PUT /modules/:id":                  {scope: "courses:write", orgScoped: true},
+	"DELETE /modules/:id":               {scope: "courses:write", orgScoped: true},
+	"GET /assignments/course/:courseId": {scope: "courses:read", orgScoped: true},
+	"GET /assignments/:id":              {scope: "courses:read", orgScoped: true},
+	"POST /assignments":                 {scope: "courses:write", orgScoped: true},
+
+	"POST /courses/:id/enroll":                    {scope: "roster:write"},
+	"DELETE /courses/:id/enroll":                  {scope: "roster:write"},
+	"GET /courses/:id/enrollments":                {scope: "roster:read", orgScoped: true},
+	"POST /courses/:id/enrollments":               {scope: "roster:write", orgScoped: true},
+	"POST /courses/:id/enrollments/import":        {scope: "roster:write", orgScoped: true},
+	"PUT /courses/:id/enrollments/:userId":        {scope: "roster:write", orgScoped: true},
+	"DELETE /courses/:id/enrollments/:userId":     {scope: "roster:write", orgScoped: true},
+	"GET /progress/course/:courseId":              {scope: "grades:read"},
+	"GET /progress/org":                           {scope: "grades:read", orgScoped: true},
+	"POST /assignments/:id/submit":                {scope: "grades:write"},
+	"PUT /submissions/:id/grade":                  {scope: "grades:write", orgScoped: true},
+	"GET /discussions/threads/course/:courseId":   {scope: "discussions:read"},
+	"GET /discussions/threads/:id":                {scope: "discussions:read"},
+	"POST /discussions/threads":                   {scope: "discussions:write"},
+	"POST /discussions/threads/:threadId/replies": {scope: "discussions:write"},
+	"POST /discussions/replies":                   {scope: "discussions:write"},
+
+	"GET /ai/studypack/:materialId":          {scope: "content:read"},
+	"GET /ai/review/:materialId":             {scope: "content:read", orgScoped: true},
+	"POST /ai/review/:materialId/approve":    {scope: "content:write", orgScoped: true},
+	"POST /ai/review/:materialId/regenerate": {scope: "content:write", orgScoped: true},
+	"GET /files/:id":                         {scope: "content:read", orgScoped: true},
+	"POST /files":                            {scope: "content:write", orgScoped: true},
+	"GET /imports/status/:materialId":        {scope: "content:read"},
+	"GET /imports/batches/:id":               {scope: "content:read", orgScoped: true},
+	"POST /imports/youtube":                  {scope: "content:write"},
+	"POST /imports/document":                 {scope: "content:write"},
+	"POST /imports/playlist":                 {scope: "content:write", orgScoped: true},
+	"POST /imports/captions/:materialId":     {scope: "content:write", orgScoped: true},
+
+	"GET /analytics/student":   {scope: "analytics:read"},
+	"GET /analytics/teacher":   {scope: "analytics:read"},
+	"GET /analytics/organizer": {scope: "analytics:read", orgScoped: true},
+
+	"GET /organizations/:id/audit-events":        {scope: "audit:read", orgScoped: true},
+	"GET /organizations/:id/audit-events/export": {scope: "audit:read", orgScoped: true},
+}
+
+// authenticateAPIToken admits a request carrying a personal access token or
+// an organization API key that is unexpired, unrevoked and has the scope of
+// the route. It sets the same context keys as an access token, plus
+// "apiToken" and, for organization keys, "apiKeyOrgID".
+func authenticateAPIToken(c *gin.Context, apiTokens repository.APITokenRepository, raw string) {
+	now := time.Now()
+	token, err := apiTokens.GetByHash(jwtutil.HashToken(raw))
+	if err != nil {
+		if !errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check token"})
+			c.Abort()
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		c.Abort()
+		return
+	}
+	if token.RevokedAt != nil || (token.ExpiresAt != nil && !token.ExpiresAt.After(now)) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Token expired or revoked"})
+		c.Abort()
+		return
+	}
+
+	route, ok := apiTokenRoutes[c.Request.Method+" "+c.FullPath()]
+	if !ok || (token.OrgID != nil && !route.orgScoped) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "This endpoint is not available to API tokens"})
+		c.Abort()
+		return
+	}
+	if route.scope != "" && !HasScope(token, route.scope) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Token is missing the " + route.scope + " scope"})
+		c.Abort()
+		return
+	}
+
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) >= lastUsedInterval {
+		if err := apiTokens.TouchLastUsed(token.ID, now); err != nil {
+			log.Printf("Failed to record use of API token %s: %v", token.ID, err)
+		}
+	}
+
+	c.Set("userID", token.UserID)
+	c.Set("mfa", token.MFA)
+	c.Set("apiToken", token)
+	if token.OrgID != nil {
+		c.Set("apiKeyOrgID", *token.OrgID)
+	}
+	c.Next()
+}
+
+// HasScope reports whether the token was granted the scope. A write scope
+// includes the read scope of the same resource.
+func HasScope(token *models.APIToken, scope string) bool {
+	resource, _, _ := strings.Cut(scope, ":")
+	for _, granted := range strings.Fields(token.Scopes) {
+		if granted == scope || (strings.HasSuffix(scope, ":read") && granted == resource+":write") {
+			return true
+		}
+	}
+	return false
+}
@@ -1,8 +1,7 @@
 package middleware
 
 import (
-	"myway-backend/internal/database"
-	"myway-backend/internal/models"
+	"myway-backend/internal/repository"
 	jwtutil "myway-backend/pkg/jwt"
 	"net/http"
 	"strings"
@@ -11,7 +10,9 @@ import (
 	"github.com/google/uuid"
 )
 
-func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
+// AuthMiddleware accepts an access token from a sign-in, or a personal access
+// token or organization API key limited to the routes its scopes cover.
+func AuthMiddleware(jwtKeys *jwtutil.Keys, apiTokens repository.APITokenRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -27,7 +28,12 @@ func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
 			return
 		}
 
-		claims, err := jwtutil.ValidateToken(tokenString, jwtSecret)
+		if strings.HasPrefix(tokenString, jwtutil.APITokenPrefix) {
+			authenticateAPIToken(c, apiTokens, tokenString)
+			return
+		}
+
+		claims, err := jwtutil.ValidateToken(tokenString, jwtKeys)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
 			c.Abort()
@@ -36,6 +42,7 @@ func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
 
 		c.Set("userID", claims.UserID)
 		c.Set("email", claims.Email)
+		c.Set("mfa", claims.MFA)
 		c.Next()
 	}
 }
@@ -56,8 +63,21 @@ func CORSMiddleware() gin.HandlerFunc {
 	}
 }
 
+// MFARequiredResponse is the 403 body for staff of an organization that
+// requires MFA whose session did not pass it.
+var MFARequiredResponse = gin.H{
+	"error":       "This organization requires multi-factor authentication; sign in again with an authenticator code",
+	"mfaRequired": true,
+}
+
+// SuspendedResponse is the 403 body for members an organizer suspended.
+var SuspendedResponse = gin.H{
+	"error":     "Your membership of this organization is suspended",
+	"suspended": true,
+}
+
 // OrgMembershipMiddleware ensures user is a member of the organization
-func OrgMembershipMiddleware() gin.HandlerFunc {
+func OrgMembershipMiddleware(orgs repository.OrgRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID := c.MustGet("userID").(uuid.UUID)
 
@@ -84,9 +104,24 @@ func OrgMembershipMiddleware() gin.HandlerFunc {
 		}
 
 		// Check membership
-		var membership models.OrgMembership
-		if err := database.GetDB().Where("user_id = ? AND org_id = ? AND status = ?", userID, orgID, "Active").First(&membership).Error; err != nil {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Not a member of this organization"})
+		if keyOrgID, ok := c.Get("apiKeyOrgID"); ok && keyOrgID.(uuid.UUID) != orgID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This API key belongs to another organization"})
+			c.Abort()
+			return
+		}
+
+		membership, err := orgs.GetActiveMembership(userID, orgID)
+		if err != nil {
+			if repository.Suspended(orgs, userID, orgID) {
+				c.JSON(http.StatusForbidden, SuspendedResponse)
+			} else {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Not a member of this organization"})
+			}
+			c.Abort()
+			return
+		}
+		if repository.MFARequired(membership) && !c.GetBool("mfa") {
+			c.JSON(http.StatusForbidden, MFARequiredResponse)
 			c.Abort()
 			return
 		}
@@ -98,22 +133,21 @@ func OrgMembershipMiddleware() gin.HandlerFunc {
 }
 
 // RBACMiddleware checks if user has required role
-func RBACMiddleware(allowedRoles ...string) gin.HandlerFunc {
+func RBACMiddleware(orgs repository.OrgRepository, allowedRoles ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		role := c.GetString("orgRole")
 		if role == "" {
 			// Try to get from membership if orgRole not set
 			userID := c.MustGet("userID").(uuid.UUID)
-			orgID := c.GetString("orgID")
-			if orgID == "" {
+			orgID, ok := c.Get("orgID")
+			if !ok {
 				c.JSON(http.StatusBadRequest, gin.H{"error": "Organization context required for RBAC"})
 				c.Abort()
 				return
 			}
 
-			orgUUID, _ := uuid.Parse(orgID)
-			var membership models.OrgMembership
-			if err := database.GetDB().Where("user_id = ? AND org_id = ?", userID, orgUUID).First(&membership).Error; err != nil {
+			membership, err := orgs.GetActiveMembership(userID, orgID.(uuid.UUID))
+			if err != nil {
 				c.JSON(http.StatusForbidden, gin.H{"error": "Not a member of this organization"})
 				c.Abort()
 				return
@@ -138,3 +172,28 @@ func RBACMiddleware(allowedRoles ...string) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// PlatformRoleMiddleware admits users whose platform role (User.Role) is one
+// of allowedRoles. The role is read from the database so that a revoked role
+// takes effect before the access token expires.
+func PlatformRoleMiddleware(users repository.UserRepository, allowedRoles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.MustGet("userID").(uuid.UUID)
+		user, err := users.GetByID(userID)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			c.Abort()
+			return
+		}
+
+		for _, allowedRole := range allowedRoles {
+			if user.Role == allowedRole {
+				c.Next()
+				return
+			}
+		}
+
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		c.Abort()
+	}
+}
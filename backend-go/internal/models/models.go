@@ -16,6 +16,17 @@ type User struct {
 	Role         string    `gorm:"not null;default:'STUDENT'"`
 	CreatedAt    time.Time
 	LastLogin    *time.Time
+	// EmailVerifiedAt is set once the user follows a verification link or
+	// resets their password.
+	EmailVerifiedAt *time.Time
+	// TOTPSecret is the authenticator secret, stored at enrollment.
+	// MFAEnabledAt is set once a code has confirmed it, and from then on
+	// sign-in asks for a code.
+	TOTPSecret   *string
+	MFAEnabledAt *time.Time
+	// TOTPLastStep is the time step of the last accepted code, so that a
+	// code works once.
+	TOTPLastStep int64 `gorm:"not null;default:0"`
 
 	Memberships       []OrgMembership    `gorm:"foreignKey:UserID"`
 	Enrollments       []Enrollment       `gorm:"foreignKey:UserID"`
@@ -27,25 +38,93 @@ type User struct {
 	Threads           []Thread           `gorm:"foreignKey:CreatedBy"`
 	Replies           []Reply            `gorm:"foreignKey:CreatedBy"`
 	RefreshTokens     []RefreshToken     `gorm:"foreignKey:UserID"`
+	RecoveryCodes     []RecoveryCode     `gorm:"foreignKey:UserID"`
 }
 
-// RefreshToken model
+// RefreshToken model. Only the SHA-256 hash of the token is stored. Tokens
+// issued from one sign-in share a FamilyID: each refresh marks the presented
+// token used and issues its successor in the same family.
 type RefreshToken struct {
 	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
-	UserID    uuid.UUID `gorm:"type:uuid;not null"`
-	Token     string    `gorm:"unique;not null"`
-	ExpiresAt time.Time `gorm:"not null"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;index"`
+	FamilyID  uuid.UUID `gorm:"type:uuid;not null;index"`
+	TokenHash string    `gorm:"unique;not null"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+	// MFA records that the sign-in that started the family passed a second
+	// factor; access tokens issued from the family carry it.
+	MFA       bool `gorm:"not null;default:false"`
+	CreatedAt time.Time
+
+	User User `gorm:"foreignKey:UserID;references:ID"`
+}
+
+// UserToken model: a single-use token sent by email to verify an address or
+// reset a password. Only its SHA-256 hash is stored.
+type UserToken struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;index"`
+	Purpose   string    `gorm:"not null"`
+	TokenHash string    `gorm:"unique;not null"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+
+	User User `gorm:"foreignKey:UserID;references:ID"`
+}
+
+// RecoveryCode model: a single-use code that stands in for an authenticator
+// code when the device is lost. Only its SHA-256 hash is stored.
+type RecoveryCode struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;index"`
+	CodeHash  string    `gorm:"not null"`
+	UsedAt    *time.Time
 	CreatedAt time.Time
 
 	User User `gorm:"foreignKey:UserID;references:ID"`
 }
 
+// APIToken model: a long-lived credential for scripts and integrations. A
+// personal access token acts for UserID everywhere; an organization API key
+// (OrgID set) acts for the organizer who created it, UserID, within that
+// organization only. Scopes is a space-separated list. Only the SHA-256 hash
+// is stored; Prefix tells tokens apart in listings.
+type APIToken struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	UserID    uuid.UUID  `gorm:"type:uuid;not null;index"`
+	OrgID     *uuid.UUID `gorm:"type:uuid;index"`
+	Name      string     `gorm:"not null"`
+	Prefix    string     `gorm:"not null"`
+	TokenHash string     `gorm:"unique;not null"`
+	Scopes    string     `gorm:"not null"`
+	// MFA records that the session creating the token passed MFA, as
+	// organizations requiring MFA of staff expect.
+	MFA        bool `gorm:"not null;default:false"`
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+	CreatedAt  time.Time
+
+	User         User         `gorm:"foreignKey:UserID;references:ID"`
+	Organization Organization `gorm:"foreignKey:OrgID;references:ID"`
+}
+
 // Organization model
 type Organization struct {
 	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
 	Name      string    `gorm:"not null"`
 	Plan      string    `gorm:"default:'Free'"`
 	CreatedAt time.Time
+	// RequireMFA limits TEACHER and ORGANIZER members to sessions that
+	// passed multi-factor authentication.
+	RequireMFA bool `gorm:"not null;default:false"`
+	// JoinCode, when set, lets anyone who gives it join as a STUDENT.
+	JoinCode *string `json:"-"`
+	// AllowedDomains is a comma-separated list of email domains whose
+	// verified users may join as a STUDENT without a code.
+	AllowedDomains string `gorm:"not null;default:''" json:"-"`
 
 	Memberships  []OrgMembership  `gorm:"foreignKey:OrgID"`
 	Courses      []Course         `gorm:"foreignKey:OrgID"`
@@ -64,6 +143,103 @@ type OrgMembership struct {
 	User         User         `gorm:"foreignKey:UserID;references:ID"`
 }
 
+// OrgInvitation model: an invitation to join an organization with a role,
+// accepted with the emailed single-use token. Only the token's SHA-256 hash
+// is stored; resending replaces it.
+type OrgInvitation struct {
+	ID         uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	OrgID      uuid.UUID `gorm:"type:uuid;not null;index"`
+	Email      string    `gorm:"not null"`
+	Role       string    `gorm:"not null"`
+	TokenHash  string    `gorm:"unique;not null"`
+	InvitedBy  uuid.UUID `gorm:"type:uuid;not null"`
+	ExpiresAt  time.Time `gorm:"not null"`
+	SentAt     time.Time `gorm:"not null"`
+	AcceptedAt *time.Time
+	AcceptedBy *uuid.UUID `gorm:"type:uuid"`
+	RevokedAt  *time.Time
+	CreatedAt  time.Time
+
+	Organization Organization `gorm:"foreignKey:OrgID;references:ID"`
+	Inviter      User         `gorm:"foreignKey:InvitedBy;references:ID"`
+}
+
+// AuditEvent model: a privileged action in an organization, written in the
+// same transaction as the action. Events are append-only and keep no foreign
+// keys, so they outlive the organization, course or user they name; the
+// actor's email is copied for the same reason. Before and After are JSON
+// objects holding only the fields the action changed.
+type AuditEvent struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	OrgID      uuid.UUID  `gorm:"type:uuid;not null;index:idx_audit_events_org_id_created_at,priority:1"`
+	ActorID    uuid.UUID  `gorm:"type:uuid;not null"`
+	ActorEmail string     `gorm:"not null"`
+	APITokenID *uuid.UUID `gorm:"type:uuid"`
+	Action     string     `gorm:"not null"`
+	TargetType string     `gorm:"not null"`
+	TargetID   uuid.UUID  `gorm:"type:uuid;not null"`
+	Before     *string    `gorm:"type:jsonb"`
+	After      *string    `gorm:"type:jsonb"`
+	IP         string     `gorm:"not null;default:''"`
+	UserAgent  string     `gorm:"not null;default:''"`
+	CreatedAt  time.Time  `gorm:"not null;index:idx_audit_events_org_id_created_at,priority:2"`
+}
+
+// OrgIdentityProvider model: the OpenID Connect provider an organization's
+// members sign in with. RoleMapping is a JSON object from values of RoleClaim
+// to organization roles; AllowedDomains is a comma-separated list of email
+// domains, empty for any.
+type OrgIdentityProvider struct {
+	ID             uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	OrgID          uuid.UUID `gorm:"type:uuid;not null;unique"`
+	Issuer         string    `gorm:"not null"`
+	ClientID       string    `gorm:"not null"`
+	ClientSecret   string    `gorm:"not null"`
+	Scopes         string    `gorm:"not null;default:'email profile'"`
+	RoleClaim      *string
+	RoleMapping    string `gorm:"type:jsonb;not null;default:'{}'"`
+	DefaultRole    string `gorm:"not null;default:'STUDENT'"`
+	AllowedDomains string `gorm:"not null;default:''"`
+	Enabled        bool   `gorm:"not null;default:true"`
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+
+	Organization Organization `gorm:"foreignKey:OrgID;references:ID"`
+}
+
+// UserIdentity model: links a user to the subject an identity provider
+// knows them by.
+type UserIdentity struct {
+	ID         uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	UserID     uuid.UUID `gorm:"type:uuid;not null;index"`
+	ProviderID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_user_identities_provider_subject"`
+	Subject    string    `gorm:"not null;uniqueIndex:idx_user_identities_provider_subject"`
+	Email      string
+	CreatedAt  time.Time
+	LastLogin  *time.Time
+
+	User     User                `gorm:"foreignKey:UserID;references:ID"`
+	Provider OrgIdentityProvider `gorm:"foreignKey:ProviderID;references:ID"`
+}
+
+// SSOLogin model: an authorization request in flight, looked up by its
+// state when the provider redirects back.
+type SSOLogin struct {
+	ID           uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	ProviderID   uuid.UUID `gorm:"type:uuid;not null"`
+	State        string    `gorm:"not null;unique"`
+	Nonce        string    `gorm:"not null"`
+	CodeVerifier string    `gorm:"not null"`
+	// UserID is the signed-in user linking their account, nil for a
+	// sign-in.
+	UserID    *uuid.UUID `gorm:"type:uuid"`
+	ReturnTo  string
+	ExpiresAt time.Time `gorm:"not null;index"`
+	CreatedAt time.Time
+
+	Provider OrgIdentityProvider `gorm:"foreignKey:ProviderID;references:ID"`
+}
+
 // Course model
 type Course struct {
 	ID          uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
@@ -72,6 +248,8 @@ type Course struct {
 	Title       string    `gorm:"not null"`
 	Description string    `gorm:"not null"`
 	CreatedBy   uuid.UUID `gorm:"type:uuid;not null"`
+	// EnrollmentKey, when set, must be given to self-enroll.
+	EnrollmentKey *string `json:"-"`
 
 	Organization Organization   `gorm:"foreignKey:OrgID;references:ID"`
 	Creator      User           `gorm:"foreignKey:CreatedBy;references:ID"`
@@ -85,9 +263,9 @@ type Course struct {
 // Enrollment model
 type Enrollment struct {
 	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
-	CourseID  uuid.UUID `gorm:"type:uuid;not null"`
-	UserID    uuid.UUID `gorm:"type:uuid;not null"`
-	Role      string    `gorm:"not null"`
+	CourseID  uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_enrollments_course_user"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_enrollments_course_user"`
+	Role      string    `gorm:"not null"` // STUDENT, TA, TEACHER
 	CreatedAt time.Time
 
 	Course Course `gorm:"foreignKey:CourseID;references:ID"`
@@ -110,13 +288,22 @@ type Module struct {
 type Material struct {
 	ID             uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
 	ModuleID       uuid.UUID `gorm:"type:uuid;not null"`
-	Type           string    `gorm:"not null"` // VIDEO, TEXT, DOC
+	Type           string    `gorm:"not null"` // VIDEO, TEXT, DOC, PDF, DOCX, PPTX, MARKDOWN, HTML
 	Title          string    `gorm:"not null"`
 	SourceURL      *string
 	FileURL        *string
+	FileID         *uuid.UUID `gorm:"type:uuid"`
+	MimeType       *string
 	TranscriptText *string `gorm:"type:text"`
+	// ExtractedText is the document's text with "[Page N]"/"[Slide N]"
+	// marker lines between pages.
+	ExtractedText *string `gorm:"type:text"`
+	DurationSec   *int
+	// BatchID is set on materials created by a playlist or channel import.
+	BatchID *uuid.UUID `gorm:"type:uuid;index"`
 
 	Module     Module      `gorm:"foreignKey:ModuleID;references:ID"`
+	File       *StoredFile `gorm:"foreignKey:FileID;references:ID"`
 	StudyPacks []StudyPack `gorm:"foreignKey:MaterialID"`
 }
 
@@ -130,6 +317,7 @@ type StudyPack struct {
 	PublishedAt      *time.Time
 	RequiresApproval bool `gorm:"default:false"`
 	ApprovedBy       *string
+	ErrorMessage     *string `gorm:"type:text"`
 
 	Material   Material           `gorm:"foreignKey:MaterialID;references:ID"`
 	Summary    *Summary           `gorm:"foreignKey:StudyPackID"`
@@ -210,6 +398,41 @@ type FlashcardSession struct {
 	User      User      `gorm:"foreignKey:UserID;references:ID"`
 }
 
+// FlashcardSchedule model holds one learner's spaced-repetition state for a card
+type FlashcardSchedule struct {
+	ID             uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	FlashcardID    uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_flashcard_schedules_card_user"`
+	UserID         uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_flashcard_schedules_card_user;index:idx_flashcard_schedules_user_due"`
+	Ease           float64   `gorm:"not null"`
+	IntervalDays   int       `gorm:"not null"`
+	Repetitions    int       `gorm:"not null"`
+	Lapses         int       `gorm:"not null"`
+	DueAt          time.Time `gorm:"not null;index:idx_flashcard_schedules_user_due"`
+	LastReviewedAt time.Time `gorm:"not null"`
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+
+	Flashcard Flashcard `gorm:"foreignKey:FlashcardID;references:ID"`
+	User      User      `gorm:"foreignKey:UserID;references:ID"`
+}
+
+// FlashcardReview model is the log of every answer given to a card
+type FlashcardReview struct {
+	ID           uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	FlashcardID  uuid.UUID  `gorm:"type:uuid;not null;index"`
+	UserID       uuid.UUID  `gorm:"type:uuid;not null;index"`
+	SessionID    *uuid.UUID `gorm:"type:uuid"`
+	Grade        string     `gorm:"not null"` // again, hard, good, easy
+	Ease         float64    `gorm:"not null"`
+	IntervalDays int        `gorm:"not null"`
+	DueAt        time.Time  `gorm:"not null"`
+	ReviewedAt   time.Time  `gorm:"not null"`
+
+	Flashcard Flashcard        `gorm:"foreignKey:FlashcardID;references:ID"`
+	User      User             `gorm:"foreignKey:UserID;references:ID"`
+	Session   FlashcardSession `gorm:"foreignKey:SessionID;references:ID"`
+}
+
 // ProgressEvent model
 type ProgressEvent struct {
 	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
@@ -243,12 +466,14 @@ type Submission struct {
 	UserID       uuid.UUID `gorm:"type:uuid;not null"`
 	Status       string    `gorm:"not null"` // SUBMITTED, GRADED, RE_SUBMIT_REQUESTED
 	FileURL      *string
+	FileID       *uuid.UUID `gorm:"type:uuid"`
 	SubmittedAt  time.Time
 	Grade        *string
 	Feedback     *string
 
-	Assignment Assignment `gorm:"foreignKey:AssignmentID;references:ID"`
-	User       User       `gorm:"foreignKey:UserID;references:ID"`
+	Assignment Assignment  `gorm:"foreignKey:AssignmentID;references:ID"`
+	User       User        `gorm:"foreignKey:UserID;references:ID"`
+	File       *StoredFile `gorm:"foreignKey:FileID;references:ID"`
 }
 
 // Thread model
@@ -304,6 +529,133 @@ type CourseMetric struct {
 	Course Course `gorm:"foreignKey:CourseID;references:ID"`
 }
 
+// Job model
+type Job struct {
+	ID              uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	Type            string    `gorm:"not null;index"`
+	Payload         string    `gorm:"type:jsonb;not null"`
+	Status          string    `gorm:"not null;index"` // QUEUED, RUNNING, SUCCEEDED, DEAD
+	Attempts        int       `gorm:"not null;default:0"`
+	MaxAttempts     int       `gorm:"not null;default:5"`
+	RunAt           time.Time `gorm:"not null;index"`
+	LockedBy        *string
+	LockedUntil     *time.Time
+	LastError       *string `gorm:"type:text"`
+	Progress        int     `gorm:"not null;default:0"`
+	ProgressMessage *string
+	MaterialID      *uuid.UUID `gorm:"type:uuid;index"`
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	CompletedAt     *time.Time
+}
+
+// MaterialChunk model
+type MaterialChunk struct {
+	ID         uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	MaterialID uuid.UUID `gorm:"type:uuid;not null;index"`
+	Ordinal    int       `gorm:"not null"`
+	Source     string    `gorm:"not null"` // TRANSCRIPT, SUMMARY, DOCUMENT
+	Content    string    `gorm:"type:text;not null"`
+	StartSec   *float64
+	EndSec     *float64
+	Page       *int
+	CreatedAt  time.Time
+
+	Material Material `gorm:"foreignKey:MaterialID;references:ID"`
+}
+
+// ImportBatch model tracks a playlist or channel import. Status covers
+// listing the videos (QUEUED, CREATED, FAILED); once the materials exist,
+// the batch's progress is read from their study packs.
+type ImportBatch struct {
+	ID           uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	CourseID     uuid.UUID  `gorm:"type:uuid;not null;index"`
+	ModuleID     *uuid.UUID `gorm:"type:uuid"`
+	CreatedBy    uuid.UUID  `gorm:"type:uuid;not null"`
+	SourceURL    string     `gorm:"not null"`
+	Title        *string
+	Language     *string
+	Status       string  `gorm:"not null;default:QUEUED"`
+	ErrorMessage *string `gorm:"type:text"`
+	VideoCount   int     `gorm:"not null;default:0"`
+	SkippedCount int     `gorm:"not null;default:0"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+
+	Course    Course     `gorm:"foreignKey:CourseID;references:ID"`
+	Module    *Module    `gorm:"foreignKey:ModuleID;references:ID"`
+	Creator   User       `gorm:"foreignKey:CreatedBy;references:ID"`
+	Materials []Material `gorm:"foreignKey:BatchID"`
+}
+
+// TranscriptSegment model holds one timed caption line of a video material.
+// VideoID lets later imports of the same video reuse the transcript.
+type TranscriptSegment struct {
+	ID         uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	MaterialID uuid.UUID `gorm:"type:uuid;not null;index"`
+	VideoID    string    `gorm:"not null;index"`
+	Language   string    `gorm:"not null"`
+	Ordinal    int       `gorm:"not null"`
+	StartMs    int       `gorm:"not null"`
+	DurationMs int       `gorm:"not null"`
+	Text       string    `gorm:"type:text;not null"`
+	CreatedAt  time.Time
+
+	Material Material `gorm:"foreignKey:MaterialID;references:ID"`
+}
+
+// StoredFile model records an upload held by the storage backend. Uploads
+// with the same content in one organization share a storage key.
+type StoredFile struct {
+	ID          uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	OrgID       uuid.UUID `gorm:"type:uuid;not null;index"`
+	CourseID    uuid.UUID `gorm:"type:uuid;not null;index"`
+	UploadedBy  uuid.UUID `gorm:"type:uuid;not null"`
+	Purpose     string    `gorm:"not null"` // MATERIAL, SUBMISSION
+	Backend     string    `gorm:"not null"` // local, s3
+	StorageKey  string    `gorm:"not null;index"`
+	FileName    string    `gorm:"not null"`
+	ContentType string    `gorm:"not null"`
+	Size        int64     `gorm:"not null"`
+	SHA256      string    `gorm:"column:sha256;not null"`
+	CreatedAt   time.Time
+
+	Organization Organization `gorm:"foreignKey:OrgID;references:ID"`
+	Course       Course       `gorm:"foreignKey:CourseID;references:ID"`
+	Uploader     User         `gorm:"foreignKey:UploadedBy;references:ID"`
+}
+
+// Conversation model
+type Conversation struct {
+	ID              uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	UserID          uuid.UUID `gorm:"type:uuid;not null;index"`
+	CourseID        uuid.UUID `gorm:"type:uuid;not null;index"`
+	Title           string    `gorm:"not null"`
+	Summary         *string   `gorm:"type:text"` // rolling summary of turns that left the history window
+	SummarizedCount int       `gorm:"not null;default:0"`
+	LastMessageAt   time.Time `gorm:"not null"`
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+
+	User     User      `gorm:"foreignKey:UserID;references:ID"`
+	Course   Course    `gorm:"foreignKey:CourseID;references:ID"`
+	Messages []Message `gorm:"foreignKey:ConversationID"`
+}
+
+// Message model
+type Message struct {
+	ID               uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	ConversationID   uuid.UUID `gorm:"type:uuid;not null;index"`
+	Role             string    `gorm:"not null"` // USER, ASSISTANT
+	Content          string    `gorm:"type:text;not null"`
+	SourceReferences *string   `gorm:"type:jsonb"`
+	PromptTokens     int       `gorm:"not null;default:0"`
+	CompletionTokens int       `gorm:"not null;default:0"`
+	CreatedAt        time.Time
+
+	Conversation Conversation `gorm:"foreignKey:ConversationID;references:ID"`
+}
+
 // BeforeCreate hooks to ensure UUID generation
 func (u *User) BeforeCreate(tx *gorm.DB) error {
 	if u.ID == uuid.Nil {
@@ -0,0 +1,39 @@
+package oidc
+
+import (
+	"fmt"
+	"myway-backend/internal/safehttp"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// ErrDisallowedURL is returned for provider URLs the client will not fetch.
+var ErrDisallowedURL = safehttp.ErrDisallowed
+
+// requestTimeout bounds every request to a provider, redirects included.
+const requestTimeout = 10 * time.Second
+
+// newHTTPClient returns the client for provider requests. Issuers are set
+// by organizers, so unless allowPrivate is set it only speaks https and
+// refuses to connect to loopback, private and link-local addresses.
+func newHTTPClient(allowPrivate bool) *http.Client {
+	opts := safehttp.Options{Timeout: requestTimeout, MaxRedirects: 5, AllowPrivate: allowPrivate}
+	if !allowPrivate {
+		opts.Schemes = []string{"https"}
+	}
+	return safehttp.NewClient(opts)
+}
+
+// checkURL refuses URLs a request must not be sent to: anything but https
+// unless private networks are allowed.
+func (c *Client) checkURL(target string) error {
+	u, err := url.Parse(target)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrDisallowedURL, err)
+	}
+	if u.Host == "" || (u.Scheme != "https" && !(c.allowPrivate && u.Scheme == "http")) {
+		return fmt.Errorf("%w: %s must be an https URL", ErrDisallowedURL, target)
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDiscoverRefusesPrivateIssuers(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request reached the provider")
+	}))
+	defer server.Close()
+
+	client := NewClient(false)
+	for _, issuer := range []string{"http://example.com", "file:///etc/passwd", server.URL} {
+		if _, err := client.Discover(context.Background(), issuer); !errors.Is(err, ErrDisallowedURL) {
+			t.Errorf("Discover(%s) error = %v, want ErrDisallowedURL", issuer, err)
+		}
+	}
+}
+
+func TestDiscoverAllowsPrivateIssuersWhenConfigured(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"issuer":"` + server.URL + `","authorization_endpoint":"` + server.URL + `/authorize","token_endpoint":"` + server.URL + `/token","jwks_uri":"` + server.URL + `/jwks"}`))
+	}))
+	defer server.Close()
+
+	if _, err := NewClient(true).Discover(context.Background(), server.URL); err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+}
@@ -0,0 +1,213 @@
+// Package oidc is a small OpenID Connect relying party: discovery, the
+// authorization-code flow with PKCE, and ID token verification against the
+// provider's published keys.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrDiscovery = errors.New("OpenID provider discovery failed")
+	ErrExchange  = errors.New("authorization code exchange failed")
+	ErrIDToken   = errors.New("invalid ID token")
+)
+
+// metadataTTL bounds how long discovery documents and key sets are reused.
+const metadataTTL = time.Hour
+
+// Metadata is the part of a discovery document the relying party uses.
+type Metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Config identifies this application to one provider.
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	// Scopes are requested besides "openid".
+	Scopes []string
+}
+
+// Tokens is the token endpoint response.
+type Tokens struct {
+	IDToken     string `json:"id_token"`
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+}
+
+// Client talks to any number of providers, caching their discovery
+// documents and keys.
+type Client struct {
+	http         *http.Client
+	allowPrivate bool
+
+	mu       sync.Mutex
+	metadata map[string]cachedMetadata
+	keys     map[string]cachedKeys
+}
+
+type cachedMetadata struct {
+	metadata  *Metadata
+	fetchedAt time.Time
+}
+
+// NewClient returns a client for providers on the internet. allowPrivate
+// lets it reach providers over plain http and on private networks, such as
+// a development provider on localhost.
+func NewClient(allowPrivate bool) *Client {
+	return &Client{
+		http:         newHTTPClient(allowPrivate),
+		allowPrivate: allowPrivate,
+		metadata:     make(map[string]cachedMetadata),
+		keys:         make(map[string]cachedKeys),
+	}
+}
+
+// Discover returns the provider metadata published under issuer.
+func (c *Client) Discover(ctx context.Context, issuer string) (*Metadata, error) {
+	issuer = strings.TrimRight(issuer, "/")
+	c.mu.Lock()
+	cached, ok := c.metadata[issuer]
+	c.mu.Unlock()
+	if ok && time.Since(cached.fetchedAt) < metadataTTL {
+		return cached.metadata, nil
+	}
+
+	var metadata Metadata
+	if err := c.getJSON(ctx, issuer+"/.well-known/openid-configuration", &metadata); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDiscovery, err)
+	}
+	// The issuer in the document must be the one it was fetched for, or a
+	// provider could vouch for tokens of another.
+	if strings.TrimRight(metadata.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("%w: document issuer %q does not match %q", ErrDiscovery, metadata.Issuer, issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, fmt.Errorf("%w: document is missing endpoints", ErrDiscovery)
+	}
+
+	c.mu.Lock()
+	c.metadata[issuer] = cachedMetadata{metadata: &metadata, fetchedAt: time.Now()}
+	c.mu.Unlock()
+	return &metadata, nil
+}
+
+// AuthCodeURL is where the browser is sent to sign in.
+func AuthCodeURL(metadata *Metadata, cfg Config, state, nonce, codeChallenge string) string {
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {cfg.ClientID},
+		"redirect_uri":          {cfg.RedirectURL},
+		"scope":                 {strings.Join(append([]string{"openid"}, cfg.Scopes...), " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {codeChallenge},
+		"code_challenge_method": {"S256"},
+	}
+	separator := "?"
+	if strings.Contains(metadata.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return metadata.AuthorizationEndpoint + separator + query.Encode()
+}
+
+// Exchange trades an authorization code and its PKCE verifier for tokens.
+func (c *Client) Exchange(ctx context.Context, metadata *Metadata, cfg Config, code, codeVerifier string) (*Tokens, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {cfg.RedirectURL},
+		"code_verifier": {codeVerifier},
+	}
+	if err := c.checkURL(metadata.TokenEndpoint); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrExchange, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(cfg.ClientID), url.QueryEscape(cfg.ClientSecret))
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrExchange, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrExchange, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Error       string `json:"error"`
+			Description string `json:"error_description"`
+		}
+		json.Unmarshal(body, &failure)
+		return nil, fmt.Errorf("%w: HTTP %d %s %s", ErrExchange, resp.StatusCode, failure.Error, failure.Description)
+	}
+
+	var tokens Tokens
+	if err := json.Unmarshal(body, &tokens); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrExchange, err)
+	}
+	if tokens.IDToken == "" {
+		return nil, fmt.Errorf("%w: response has no id_token", ErrExchange)
+	}
+	return &tokens, nil
+}
+
+// RandomString returns a URL-safe random value for states, nonces and PKCE
+// verifiers.
+func RandomString() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// CodeChallenge is the S256 PKCE challenge of a verifier.
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func (c *Client) getJSON(ctx context.Context, target string, v interface{}) error {
+	if err := c.checkURL(target); err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: HTTP %d", target, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
@@ -0,0 +1,261 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"myway-backend/internal/extract"
+	"myway-backend/internal/jobs"
+	"myway-backend/internal/llm"
+	"myway-backend/internal/models"
+	"myway-backend/internal/retrieval"
+	"myway-backend/internal/storage"
+	"myway-backend/internal/studypack"
+	"myway-backend/internal/transcript"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const JobGenerateStudyPack = "studypack.generate"
+
+type StudyPackPayload struct {
+	StudyPackID uuid.UUID `json:"studyPackId"`
+	MaterialID  uuid.UUID `json:"materialId"`
+	// Language is the preferred transcript language of a video import.
+	Language string `json:"language,omitempty"`
+}
+
+// EnqueueStudyPack schedules study pack generation for a material.
+func EnqueueStudyPack(db *gorm.DB, payload StudyPackPayload) (*models.Job, error) {
+	return jobs.Enqueue(db, JobGenerateStudyPack, payload, jobs.EnqueueOptions{MaterialID: &payload.MaterialID})
+}
+
+// Processor runs the import and study pack jobs.
+type Processor struct {
+	DB        *gorm.DB
+	Generator *studypack.Generator
+	Storage   storage.Storage
+
+	Transcripts transcript.Provider
+	// Languages are the default transcript language preferences.
+	Languages []string
+}
+
+func NewProcessor(db *gorm.DB, generator *studypack.Generator, store storage.Storage, transcripts transcript.Provider, languages []string) *Processor {
+	return &Processor{DB: db, Generator: generator, Storage: store, Transcripts: transcripts, Languages: languages}
+}
+
+func (p *Processor) Register(w *jobs.Worker) {
+	w.Register(JobGenerateStudyPack, p.GenerateStudyPack, p.studyPackDead)
+}
+
+func (p *Processor) GenerateStudyPack(ctx context.Context, job *models.Job) error {
+	var payload StudyPackPayload
+	if err := json.Unmarshal([]byte(job.Payload), &payload); err != nil {
+		return jobs.Permanent(fmt.Errorf("invalid payload: %w", err))
+	}
+
+	p.setStudyPackStatus(payload.StudyPackID, "PROCESSING", nil)
+	jobs.SetProgress(p.DB, job.ID, 10, "Loading material")
+
+	var material models.Material
+	if err := p.DB.First(&material, payload.MaterialID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return jobs.Permanent(fmt.Errorf("material %s not found", payload.MaterialID))
+		}
+		return err
+	}
+
+	if !hasText(material.TranscriptText) && !hasText(material.ExtractedText) {
+		if material.Type == "VIDEO" {
+			jobs.SetProgress(p.DB, job.ID, 20, "Fetching transcript")
+			if err := p.fetchTranscript(ctx, &material, payload.Language); err != nil {
+				return err
+			}
+		} else {
+			if material.FileID == nil && (material.FileURL == nil || *material.FileURL == "") {
+				return jobs.Permanent(errors.New("material has no file to extract text from"))
+			}
+			jobs.SetProgress(p.DB, job.ID, 20, "Extracting document text")
+			if err := p.extractDocument(ctx, &material); err != nil {
+				return err
+			}
+		}
+	}
+
+	jobs.SetProgress(p.DB, job.ID, 30, "Generating study pack")
+	content, err := p.Generator.Generate(ctx, material, studypack.Options{})
+	if err != nil {
+		if errors.Is(err, studypack.ErrNoSourceText) || errors.Is(err, llm.ErrNotConfigured) {
+			return jobs.Permanent(err)
+		}
+		p.setStudyPackStatus(payload.StudyPackID, "QUEUED", err)
+		return err
+	}
+
+	jobs.SetProgress(p.DB, job.ID, 80, "Saving study pack")
+	if err := studypack.Persist(p.DB, payload.StudyPackID, content); err != nil {
+		return err
+	}
+
+	jobs.SetProgress(p.DB, job.ID, 90, "Indexing material for the tutor")
+	if _, err := retrieval.IndexMaterial(p.DB, material.ID); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if err := p.DB.Model(&models.StudyPack{}).Where("id = ?", payload.StudyPackID).Updates(map[string]interface{}{
+		"status":        "READY",
+		"published_at":  &now,
+		"error_message": nil,
+	}).Error; err != nil {
+		return err
+	}
+
+	log.Printf("Study pack %s marked as READY", payload.StudyPackID)
+	return nil
+}
+
+// fetchTranscript stores the video's timed transcript with the material and
+// uses it as the material's transcript text.
+func (p *Processor) fetchTranscript(ctx context.Context, material *models.Material, language string) error {
+	noTranscript := errors.New("no transcript available for this video; paste the transcript or upload captions")
+	if p.Transcripts == nil {
+		return jobs.Permanent(noTranscript)
+	}
+	videoID := ""
+	if material.SourceURL != nil {
+		videoID = transcript.VideoID(*material.SourceURL)
+	}
+	if videoID == "" {
+		return jobs.Permanent(errors.New("material has no YouTube video to fetch a transcript for"))
+	}
+
+	t, err := p.Transcripts.Fetch(ctx, videoID, transcript.Languages(language, p.Languages))
+	if err != nil {
+		if errors.Is(err, transcript.ErrNoCaptions) {
+			return jobs.Permanent(noTranscript)
+		}
+		if errors.Is(err, transcript.ErrUnavailable) {
+			return jobs.Permanent(err)
+		}
+		return err
+	}
+
+	text := t.Text()
+	updates := map[string]interface{}{"transcript_text": text}
+	if t.Title != "" && material.Title == "YouTube Import" {
+		updates["title"] = t.Title
+		material.Title = t.Title
+	}
+	if t.Duration > 0 && material.DurationSec == nil {
+		seconds := int(t.Duration.Seconds())
+		updates["duration_sec"] = seconds
+		material.DurationSec = &seconds
+	}
+	material.TranscriptText = &text
+	return p.DB.Transaction(func(tx *gorm.DB) error {
+		if err := transcript.Store(tx, material.ID, t); err != nil {
+			return err
+		}
+		return tx.Model(material).Updates(updates).Error
+	})
+}
+
+// extractDocument reads the material's uploaded or linked file and stores its
+// text. Files that cannot be read will not become readable on retry, so those
+// errors are permanent.
+func (p *Processor) extractDocument(ctx context.Context, material *models.Material) error {
+	var file *extract.File
+	var err error
+	if material.FileID != nil {
+		file, err = p.openStoredFile(ctx, *material.FileID)
+	} else {
+		file, err = extract.Fetch(ctx, *material.FileURL)
+	}
+	if err != nil {
+		var httpErr *extract.HTTPError
+		if errors.Is(err, extract.ErrUnsupported) || errors.As(err, &httpErr) && httpErr.Permanent() {
+			return jobs.Permanent(err)
+		}
+		return err
+	}
+
+	doc, err := extract.Extract(file.Data, file.Name, file.ContentType)
+	if err != nil {
+		if errors.Is(err, extract.ErrUnsupported) || errors.Is(err, extract.ErrEncrypted) || errors.Is(err, extract.ErrNoText) {
+			return jobs.Permanent(err)
+		}
+		return jobs.Permanent(fmt.Errorf("could not read document: %w", err))
+	}
+
+	text := doc.Text()
+	material.ExtractedText = &text
+	material.MimeType = &doc.MimeType
+	material.Type = doc.Kind
+	return p.DB.Model(material).Updates(map[string]interface{}{
+		"extracted_text": text,
+		"mime_type":      doc.MimeType,
+		"type":           doc.Kind,
+	}).Error
+}
+
+// openStoredFile loads an uploaded file from storage.
+func (p *Processor) openStoredFile(ctx context.Context, fileID uuid.UUID) (*extract.File, error) {
+	var stored models.StoredFile
+	if err := p.DB.First(&stored, fileID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, jobs.Permanent(fmt.Errorf("file %s not found", fileID))
+		}
+		return nil, err
+	}
+	if p.Storage == nil || p.Storage.Backend() != stored.Backend {
+		return nil, jobs.Permanent(fmt.Errorf("file %s is kept in %s storage, which is not configured", fileID, stored.Backend))
+	}
+	if stored.Size > extract.MaxFileSize {
+		return nil, jobs.Permanent(fmt.Errorf("%w: file is larger than %d MB", extract.ErrUnsupported, extract.MaxFileSize>>20))
+	}
+
+	body, err := p.Storage.Open(ctx, stored.StorageKey)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, jobs.Permanent(fmt.Errorf("file %s is missing from storage", fileID))
+		}
+		return nil, err
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(body, extract.MaxFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+	return &extract.File{Data: data, Name: stored.FileName, ContentType: stored.ContentType}, nil
+}
+
+func hasText(text *string) bool {
+	return text != nil && strings.TrimSpace(*text) != ""
+}
+
+func (p *Processor) studyPackDead(job *models.Job, err error) {
+	var payload StudyPackPayload
+	if json.Unmarshal([]byte(job.Payload), &payload) != nil {
+		return
+	}
+	p.setStudyPackStatus(payload.StudyPackID, "FAILED", err)
+}
+
+func (p *Processor) setStudyPackStatus(studyPackID uuid.UUID, status string, cause error) {
+	updates := map[string]interface{}{"status": status, "error_message": nil}
+	if cause != nil {
+		updates["error_message"] = cause.Error()
+	}
+	if err := p.DB.Model(&models.StudyPack{}).Where("id = ?", studyPackID).Updates(updates).Error; err != nil {
+		log.Printf("Failed to set study pack %s to %s: %v", studyPackID, status, err)
+	}
+}
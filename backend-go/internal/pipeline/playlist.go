@@ -0,0 +1,230 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"myway-backend/internal/jobs"
+	"myway-backend/internal/models"
+	"myway-backend/internal/playlist"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const JobImportPlaylist = "playlist.import"
+
+const (
+	BatchQueued  = "QUEUED"
+	BatchCreated = "CREATED"
+	BatchFailed  = "FAILED"
+)
+
+type PlaylistPayload struct {
+	BatchID uuid.UUID `json:"batchId"`
+}
+
+// EnqueuePlaylist schedules listing the videos of an import batch.
+func EnqueuePlaylist(db *gorm.DB, batchID uuid.UUID) (*models.Job, error) {
+	return jobs.Enqueue(db, JobImportPlaylist, PlaylistPayload{BatchID: batchID}, jobs.EnqueueOptions{})
+}
+
+// QueueMaterial creates the material with a QUEUED study pack and the job
+// that generates it. Call it inside a transaction so a material is never
+// left without a job.
+func QueueMaterial(tx *gorm.DB, material *models.Material, createdBy uuid.UUID, language string) (*models.StudyPack, *models.Job, error) {
+	if err := tx.Create(material).Error; err != nil {
+		return nil, nil, fmt.Errorf("create material: %w", err)
+	}
+
+	studyPack := models.StudyPack{
+		MaterialID:       material.ID,
+		CreatedBy:        createdBy.String(),
+		Status:           "QUEUED",
+		RequiresApproval: false,
+	}
+	if err := tx.Create(&studyPack).Error; err != nil {
+		return nil, nil, fmt.Errorf("create study pack: %w", err)
+	}
+
+	job, err := EnqueueStudyPack(tx, StudyPackPayload{
+		StudyPackID: studyPack.ID,
+		MaterialID:  material.ID,
+		Language:    language,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("enqueue job: %w", err)
+	}
+	return &studyPack, job, nil
+}
+
+// PlaylistImporter turns a playlist or channel into one VIDEO material per
+// video, each with its own study pack job.
+type PlaylistImporter struct {
+	DB     *gorm.DB
+	Source playlist.Source
+}
+
+func NewPlaylistImporter(db *gorm.DB, source playlist.Source) *PlaylistImporter {
+	return &PlaylistImporter{DB: db, Source: source}
+}
+
+func (p *PlaylistImporter) Register(w *jobs.Worker) {
+	w.Register(JobImportPlaylist, p.ImportPlaylist, p.batchDead)
+}
+
+func (p *PlaylistImporter) ImportPlaylist(ctx context.Context, job *models.Job) error {
+	var payload PlaylistPayload
+	if err := json.Unmarshal([]byte(job.Payload), &payload); err != nil {
+		return jobs.Permanent(fmt.Errorf("invalid payload: %w", err))
+	}
+
+	var batch models.ImportBatch
+	if err := p.DB.First(&batch, payload.BatchID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return jobs.Permanent(fmt.Errorf("import batch %s not found", payload.BatchID))
+		}
+		return err
+	}
+	if batch.Status != BatchQueued {
+		return nil
+	}
+
+	ref, err := playlist.ParseURL(batch.SourceURL)
+	if err != nil {
+		return jobs.Permanent(err)
+	}
+	jobs.SetProgress(p.DB, job.ID, 10, "Listing videos")
+	list, err := p.Source.List(ctx, ref)
+	if err != nil {
+		if errors.Is(err, playlist.ErrNotFound) || errors.Is(err, playlist.ErrInvalidURL) {
+			return jobs.Permanent(err)
+		}
+		return err
+	}
+	if len(list.Videos) == 0 {
+		return jobs.Permanent(errors.New("the playlist has no videos"))
+	}
+
+	jobs.SetProgress(p.DB, job.ID, 50, fmt.Sprintf("Creating %d materials", len(list.Videos)))
+	language := ""
+	if batch.Language != nil {
+		language = *batch.Language
+	}
+	var created, skipped int
+	err = p.DB.Transaction(func(tx *gorm.DB) error {
+		moduleID, err := p.resolveModule(tx, &batch, list.Title)
+		if err != nil {
+			return err
+		}
+
+		// Videos already in the course are skipped, so importing a playlist
+		// again only picks up what was added since.
+		urls := make([]string, len(list.Videos))
+		for i, video := range list.Videos {
+			urls[i] = video.URL()
+		}
+		var existing []string
+		if err := tx.Model(&models.Material{}).
+			Joins("JOIN modules ON modules.id = materials.module_id").
+			Where("modules.course_id = ? AND materials.source_url IN ?", batch.CourseID, urls).
+			Pluck("materials.source_url", &existing).Error; err != nil {
+			return err
+		}
+		seen := make(map[string]bool, len(existing))
+		for _, url := range existing {
+			seen[url] = true
+		}
+
+		for _, video := range list.Videos {
+			url := video.URL()
+			if seen[url] {
+				skipped++
+				continue
+			}
+			seen[url] = true
+
+			material := models.Material{
+				ModuleID:  moduleID,
+				Type:      "VIDEO",
+				Title:     video.Title,
+				SourceURL: &url,
+				BatchID:   &batch.ID,
+			}
+			if material.Title == "" {
+				material.Title = "YouTube Import"
+			}
+			if video.Duration > 0 {
+				seconds := int(video.Duration.Seconds())
+				material.DurationSec = &seconds
+			}
+			if _, _, err := QueueMaterial(tx, &material, batch.CreatedBy, language); err != nil {
+				return err
+			}
+			created++
+		}
+
+		return tx.Model(&batch).Updates(map[string]interface{}{
+			"status":        BatchCreated,
+			"title":         list.Title,
+			"module_id":     moduleID,
+			"video_count":   created,
+			"skipped_count": skipped,
+			"error_message": nil,
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Import batch %s created %d materials, skipped %d", batch.ID, created, skipped)
+	return nil
+}
+
+// resolveModule uses the batch's module, else the course module named after
+// the playlist, else a new module at the end of the course.
+func (p *PlaylistImporter) resolveModule(tx *gorm.DB, batch *models.ImportBatch, title string) (uuid.UUID, error) {
+	if batch.ModuleID != nil {
+		return *batch.ModuleID, nil
+	}
+	if title == "" {
+		title = "YouTube Playlist"
+	}
+
+	var module models.Module
+	err := tx.Where("course_id = ? AND title = ?", batch.CourseID, title).First(&module).Error
+	if err == nil {
+		return module.ID, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return uuid.Nil, err
+	}
+
+	var maxOrder int
+	if err := tx.Model(&models.Module{}).
+		Where("course_id = ? AND title <> ?", batch.CourseID, "Resources").
+		Select("COALESCE(MAX(\"order\"), 0)").
+		Scan(&maxOrder).Error; err != nil {
+		return uuid.Nil, err
+	}
+	module = models.Module{CourseID: batch.CourseID, Title: title, Order: maxOrder + 1}
+	if err := tx.Create(&module).Error; err != nil {
+		return uuid.Nil, err
+	}
+	return module.ID, nil
+}
+
+func (p *PlaylistImporter) batchDead(job *models.Job, err error) {
+	var payload PlaylistPayload
+	if json.Unmarshal([]byte(job.Payload), &payload) != nil {
+		return
+	}
+	if dbErr := p.DB.Model(&models.ImportBatch{}).Where("id = ?", payload.BatchID).Updates(map[string]interface{}{
+		"status":        BatchFailed,
+		"error_message": err.Error(),
+	}).Error; dbErr != nil {
+		log.Printf("Failed to mark import batch %s as failed: %v", payload.BatchID, dbErr)
+	}
+}
@@ -0,0 +1,83 @@
+// Package playlist lists the videos of YouTube playlists and channels for
+// bulk imports.
+package playlist
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// MaxVideos bounds a single bulk import.
+const MaxVideos = 200
+
+var (
+	ErrInvalidURL = errors.New("not a YouTube playlist or channel link")
+	ErrNotFound   = errors.New("playlist or channel not found")
+)
+
+type Video struct {
+	ID       string
+	Title    string
+	Duration time.Duration
+}
+
+// URL is the canonical watch link of the video.
+func (v Video) URL() string {
+	return "https://www.youtube.com/watch?v=" + v.ID
+}
+
+type Playlist struct {
+	ID     string
+	Title  string
+	Videos []Video
+}
+
+// Ref names a playlist, or a channel whose uploads are listed. A channel is
+// known by its ID or by its @handle.
+type Ref struct {
+	PlaylistID string
+	ChannelID  string
+	Handle     string
+}
+
+// Source is implemented by every playlist backend.
+type Source interface {
+	List(ctx context.Context, ref Ref) (*Playlist, error)
+}
+
+var (
+	playlistIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{13,42}$`)
+	channelIDPattern  = regexp.MustCompile(`^UC[A-Za-z0-9_-]{22}$`)
+	handlePattern     = regexp.MustCompile(`^@[A-Za-z0-9._-]{3,30}$`)
+)
+
+// ParseURL recognises playlist links (including watch links that carry a
+// list parameter), channel links by ID and @handle links.
+func ParseURL(raw string) (Ref, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return Ref{}, ErrInvalidURL
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	host = strings.TrimPrefix(host, "m.")
+	if host != "youtube.com" && host != "music.youtube.com" && host != "youtu.be" {
+		return Ref{}, ErrInvalidURL
+	}
+
+	if list := u.Query().Get("list"); playlistIDPattern.MatchString(list) {
+		return Ref{PlaylistID: list}, nil
+	}
+
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	switch {
+	case len(segments) >= 2 && segments[0] == "channel" && channelIDPattern.MatchString(segments[1]):
+		return Ref{ChannelID: segments[1]}, nil
+	case len(segments) >= 1 && handlePattern.MatchString(segments[0]):
+		return Ref{Handle: segments[0]}, nil
+	}
+	return Ref{}, ErrInvalidURL
+}
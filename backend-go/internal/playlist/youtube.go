@@ -0,0 +1,103 @@
+package playlist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+// channelIDInPage finds the channel ID on a channel page, which is all an
+// @handle resolves to.
+var channelIDInPage = regexp.MustCompile(`"(?:externalId|channelId)":"(UC[A-Za-z0-9_-]{22})"`)
+
+// YouTube lists playlists through the YouTube browse API. A channel is
+// listed through its uploads playlist.
+type YouTube struct {
+	client youtube.Client
+	http   *http.Client
+}
+
+func NewYouTube() *YouTube {
+	httpClient := &http.Client{Timeout: 30 * time.Second}
+	return &YouTube{
+		client: youtube.Client{HTTPClient: httpClient},
+		http:   httpClient,
+	}
+}
+
+func (y *YouTube) List(ctx context.Context, ref Ref) (*Playlist, error) {
+	if ref.Handle != "" {
+		channelID, err := y.resolveHandle(ctx, ref.Handle)
+		if err != nil {
+			return nil, err
+		}
+		ref.ChannelID = channelID
+	}
+	if ref.ChannelID != "" {
+		// A channel's uploads playlist shares its ID after the prefix.
+		ref.PlaylistID = "UU" + ref.ChannelID[2:]
+	}
+	if ref.PlaylistID == "" {
+		return nil, ErrInvalidURL
+	}
+
+	list, err := y.client.GetPlaylistContext(ctx, ref.PlaylistID)
+	if err != nil {
+		var status youtube.ErrPlaylistStatus
+		if errors.Is(err, youtube.ErrInvalidPlaylist) || errors.As(err, &status) {
+			return nil, fmt.Errorf("%w: %v", ErrNotFound, err)
+		}
+		return nil, fmt.Errorf("list playlist %s: %w", ref.PlaylistID, err)
+	}
+
+	playlist := &Playlist{ID: list.ID, Title: list.Title}
+	for _, entry := range list.Videos {
+		if entry == nil || entry.ID == "" {
+			continue
+		}
+		playlist.Videos = append(playlist.Videos, Video{
+			ID:       entry.ID,
+			Title:    entry.Title,
+			Duration: entry.Duration,
+		})
+		if len(playlist.Videos) == MaxVideos {
+			break
+		}
+	}
+	return playlist, nil
+}
+
+func (y *YouTube) resolveHandle(ctx context.Context, handle string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://www.youtube.com/"+handle, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept-Language", "en")
+	resp, err := y.http.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("resolve %s: %w", handle, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, handle)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("resolve %s: HTTP %d", handle, resp.StatusCode)
+	}
+
+	page, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return "", fmt.Errorf("resolve %s: %w", handle, err)
+	}
+	m := channelIDInPage.FindSubmatch(page)
+	if m == nil {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, handle)
+	}
+	return string(m[1]), nil
+}
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"myway-backend/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type apiTokenRepo struct {
+	db *gorm.DB
+}
+
+func (r *apiTokenRepo) Create(token *models.APIToken) error {
+	return r.db.Create(token).Error
+}
+
+func (r *apiTokenRepo) GetByID(id uuid.UUID) (*models.APIToken, error) {
+	var token models.APIToken
+	if err := r.db.First(&token, id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &token, nil
+}
+
+func (r *apiTokenRepo) GetByHash(tokenHash string) (*models.APIToken, error) {
+	var token models.APIToken
+	if err := r.db.Where("token_hash = ?", tokenHash).First(&token).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &token, nil
+}
+
+func (r *apiTokenRepo) ListPersonal(userID uuid.UUID) ([]models.APIToken, error) {
+	var tokens []models.APIToken
+	err := r.db.Where("user_id = ? AND org_id IS NULL", userID).Order("created_at DESC").Find(&tokens).Error
+	return tokens, err
+}
+
+func (r *apiTokenRepo) ListByOrg(orgID uuid.UUID) ([]models.APIToken, error) {
+	var tokens []models.APIToken
+	err := r.db.Where("org_id = ?", orgID).Order("created_at DESC").Find(&tokens).Error
+	return tokens, err
+}
+
+func (r *apiTokenRepo) Revoke(id uuid.UUID, now time.Time) error {
+	return r.db.Model(&models.APIToken{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", now).Error
+}
+
+func (r *apiTokenRepo) TouchLastUsed(id uuid.UUID, now time.Time) error {
+	return r.db.Model(&models.APIToken{}).Where("id = ?", id).Update("last_used_at", now).Error
+}
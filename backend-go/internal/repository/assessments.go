@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"myway-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type assessmentRepo struct {
+	db *gorm.DB
+}
+
+func (r *assessmentRepo) CreateAssignment(assignment *models.Assignment) error {
+	return r.db.Create(assignment).Error
+}
+
+func (r *assessmentRepo) GetAssignment(id uuid.UUID) (*models.Assignment, error) {
+	var assignment models.Assignment
+	if err := r.db.
+		Preload("Submissions").
+		Preload("Course").
+		First(&assignment, id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &assignment, nil
+}
+
+func (r *assessmentRepo) ListActiveAssignments(courseID uuid.UUID) ([]models.Assignment, error) {
+	var assignments []models.Assignment
+	err := r.db.
+		Where("course_id = ? AND status = ?", courseID, "ACTIVE").
+		Order("due_at ASC").
+		Find(&assignments).Error
+	return assignments, err
+}
+
+func (r *assessmentRepo) CountSubmissions(assignmentID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.Submission{}).Where("assignment_id = ?", assignmentID).Count(&count).Error
+	return count, err
+}
+
+func (r *assessmentRepo) ListSubmissionsByUser(userID uuid.UUID) ([]models.Submission, error) {
+	var submissions []models.Submission
+	err := r.db.Where("user_id = ?", userID).Find(&submissions).Error
+	return submissions, err
+}
+
+func (r *assessmentRepo) GetSubmission(assignmentID, userID uuid.UUID) (*models.Submission, error) {
+	var submission models.Submission
+	if err := r.db.Where("assignment_id = ? AND user_id = ?", assignmentID, userID).First(&submission).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &submission, nil
+}
+
+func (r *assessmentRepo) GetSubmissionWithAssignment(id uuid.UUID) (*models.Submission, error) {
+	var submission models.Submission
+	if err := r.db.Preload("Assignment.Course").First(&submission, id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &submission, nil
+}
+
+func (r *assessmentRepo) SaveSubmission(submission *models.Submission) error {
+	if submission.ID == uuid.Nil {
+		return r.db.Create(submission).Error
+	}
+	return r.db.Omit(clause.Associations).Save(submission).Error
+}
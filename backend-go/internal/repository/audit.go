@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"myway-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type auditRepo struct {
+	db *gorm.DB
+}
+
+func (r *auditRepo) Record(event *models.AuditEvent, fn func(tx *Repositories) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := fn(NewPostgres(tx)); err != nil {
+			return err
+		}
+		if event.ActorEmail == "" {
+			var actor models.User
+			if err := tx.Select("email").First(&actor, event.ActorID).Error; err != nil {
+				return translate(err)
+			}
+			event.ActorEmail = actor.Email
+		}
+		return tx.Create(event).Error
+	})
+}
+
+func (r *auditRepo) List(orgID uuid.UUID, filter AuditFilter) ([]models.AuditEvent, int64, error) {
+	query := r.db.Model(&models.AuditEvent{}).Where("org_id = ?", orgID)
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.ActorID != nil {
+		query = query.Where("actor_id = ?", *filter.ActorID)
+	}
+	if filter.TargetType != "" {
+		query = query.Where("target_type = ?", filter.TargetType)
+	}
+	if filter.TargetID != nil {
+		query = query.Where("target_id = ?", *filter.TargetID)
+	}
+	if filter.Since != nil {
+		query = query.Where("created_at >= ?", *filter.Since)
+	}
+	if filter.Until != nil {
+		query = query.Where("created_at < ?", *filter.Until)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var events []models.AuditEvent
+	err := query.
+		Order("created_at DESC, id DESC").
+		Offset(filter.Offset).
+		Limit(filter.Limit).
+		Find(&events).Error
+	return events, total, err
+}
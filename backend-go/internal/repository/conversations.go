@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"myway-backend/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type conversationRepo struct {
+	db *gorm.DB
+}
+
+func (r *conversationRepo) GetOwned(id, userID uuid.UUID) (*models.Conversation, error) {
+	var conversation models.Conversation
+	if err := r.db.Where("id = ? AND user_id = ?", id, userID).First(&conversation).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &conversation, nil
+}
+
+func (r *conversationRepo) ListByUser(userID uuid.UUID, courseID *uuid.UUID) ([]models.Conversation, error) {
+	query := r.db.Preload("Course").Where("user_id = ?", userID)
+	if courseID != nil {
+		query = query.Where("course_id = ?", *courseID)
+	}
+	var conversations []models.Conversation
+	err := query.Order("last_message_at DESC").Find(&conversations).Error
+	return conversations, err
+}
+
+func (r *conversationRepo) ListMessages(conversationID uuid.UUID, offset int) ([]models.Message, error) {
+	var messages []models.Message
+	err := r.db.
+		Where("conversation_id = ?", conversationID).
+		Order("created_at ASC").
+		Offset(offset).
+		Find(&messages).Error
+	return messages, err
+}
+
+func (r *conversationRepo) AppendMessages(conversation *models.Conversation, messages []models.Message, now time.Time) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		conversation.LastMessageAt = now
+		if conversation.ID == uuid.Nil {
+			conversation.ID = uuid.New()
+			if err := tx.Omit(clause.Associations).Create(conversation).Error; err != nil {
+				return err
+			}
+		} else if err := tx.Model(conversation).Update("last_message_at", now).Error; err != nil {
+			return err
+		}
+
+		for i := range messages {
+			messages[i].ConversationID = conversation.ID
+		}
+		return tx.Omit(clause.Associations).Create(&messages).Error
+	})
+}
+
+func (r *conversationRepo) SaveSummary(id uuid.UUID, summary string, summarizedCount int) error {
+	return r.db.Model(&models.Conversation{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"summary":          summary,
+		"summarized_count": summarizedCount,
+	}).Error
+}
+
+func (r *conversationRepo) Delete(id uuid.UUID) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("conversation_id = ?", id).Delete(&models.Message{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id = ?", id).Delete(&models.Conversation{}).Error
+	})
+}
@@ -0,0 +1,244 @@
+package repository
+
+import (
+	"myway-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type courseRepo struct {
+	db *gorm.DB
+}
+
+func (r *courseRepo) Create(course *models.Course) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(course).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.Enrollment{
+			CourseID: course.ID,
+			UserID:   course.CreatedBy,
+			Role:     EnrollmentTeacher,
+		}).Error
+	})
+}
+
+func (r *courseRepo) GetByID(id uuid.UUID) (*models.Course, error) {
+	var course models.Course
+	if err := r.db.First(&course, id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &course, nil
+}
+
+func (r *courseRepo) Update(course *models.Course) error {
+	return r.db.Omit(clause.Associations).Save(course).Error
+}
+
+func (r *courseRepo) GetWithContent(id uuid.UUID) (*models.Course, error) {
+	var course models.Course
+	if err := r.db.
+		Preload("Modules.Materials.StudyPacks").
+		Preload("Assignments").
+		First(&course, id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &course, nil
+}
+
+func (r *courseRepo) ListByOrg(orgID uuid.UUID) ([]models.Course, error) {
+	var courses []models.Course
+	err := r.db.Where("org_id = ?", orgID).Find(&courses).Error
+	return courses, err
+}
+
+func (r *courseRepo) Delete(id uuid.UUID) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.Course{}).Where("id = ?", id).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return ErrNotFound
+		}
+		return deleteCourses(tx, []uuid.UUID{id})
+	})
+}
+
+func (r *courseRepo) CreateModule(module *models.Module) error {
+	return r.db.Create(module).Error
+}
+
+func (r *courseRepo) GetModule(id uuid.UUID) (*models.Module, error) {
+	var module models.Module
+	if err := r.db.First(&module, id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &module, nil
+}
+
+func (r *courseRepo) GetModuleByTitle(courseID uuid.UUID, title string) (*models.Module, error) {
+	var module models.Module
+	if err := r.db.Where("course_id = ? AND title = ?", courseID, title).First(&module).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &module, nil
+}
+
+func (r *courseRepo) GetModuleWithContent(id uuid.UUID) (*models.Module, error) {
+	var module models.Module
+	if err := r.db.
+		Preload("Materials.StudyPacks").
+		Preload("Course").
+		First(&module, id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &module, nil
+}
+
+func (r *courseRepo) ListModules(courseID uuid.UUID) ([]models.Module, error) {
+	var modules []models.Module
+	err := r.db.
+		Preload("Materials").
+		Where("course_id = ?", courseID).
+		Order(`"order" ASC`).
+		Find(&modules).Error
+	return modules, err
+}
+
+func (r *courseRepo) UpdateModule(module *models.Module) error {
+	return r.db.Omit(clause.Associations).Save(module).Error
+}
+
+func (r *courseRepo) DeleteModule(id uuid.UUID) error {
+	return r.db.Delete(&models.Module{}, id).Error
+}
+
+func (r *courseRepo) CreateMaterial(material *models.Material) error {
+	return r.db.Create(material).Error
+}
+
+func (r *courseRepo) GetMaterial(id uuid.UUID) (*models.Material, error) {
+	var material models.Material
+	if err := r.db.First(&material, id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &material, nil
+}
+
+func (r *courseRepo) GetEnrollment(courseID, userID uuid.UUID) (*models.Enrollment, error) {
+	var enrollment models.Enrollment
+	if err := r.db.Where("course_id = ? AND user_id = ?", courseID, userID).First(&enrollment).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &enrollment, nil
+}
+
+func (r *courseRepo) ListEnrollments(courseID uuid.UUID) ([]models.Enrollment, error) {
+	var enrollments []models.Enrollment
+	err := r.db.
+		Preload("User").
+		Where("course_id = ?", courseID).
+		Order("created_at ASC").
+		Find(&enrollments).Error
+	return enrollments, err
+}
+
+func (r *courseRepo) CountEnrollments(courseID uuid.UUID, role string) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.Enrollment{}).Where("course_id = ? AND role = ?", courseID, role).Count(&count).Error
+	return count, err
+}
+
+func (r *courseRepo) SaveEnrollment(enrollment *models.Enrollment) error {
+	if enrollment.ID == uuid.Nil {
+		return r.db.Omit(clause.Associations).Create(enrollment).Error
+	}
+	return r.db.Model(enrollment).Update("role", enrollment.Role).Error
+}
+
+func (r *courseRepo) DeleteEnrollment(courseID, userID uuid.UUID) error {
+	return r.db.Where("course_id = ? AND user_id = ?", courseID, userID).Delete(&models.Enrollment{}).Error
+}
+
+// deleteCourses removes courses and every row that references them, children
+// first so foreign keys hold throughout.
+func deleteCourses(tx *gorm.DB, courseIDs []uuid.UUID) error {
+	if len(courseIDs) == 0 {
+		return nil
+	}
+
+	var assignmentIDs, threadIDs, moduleIDs, materialIDs, studyPackIDs, quizIDs, flashcardIDs, conversationIDs []uuid.UUID
+	if err := tx.Model(&models.Assignment{}).Where("course_id IN ?", courseIDs).Pluck("id", &assignmentIDs).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&models.Thread{}).Where("course_id IN ?", courseIDs).Pluck("id", &threadIDs).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&models.Conversation{}).Where("course_id IN ?", courseIDs).Pluck("id", &conversationIDs).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&models.Module{}).Where("course_id IN ?", courseIDs).Pluck("id", &moduleIDs).Error; err != nil {
+		return err
+	}
+	if len(moduleIDs) > 0 {
+		if err := tx.Model(&models.Material{}).Where("module_id IN ?", moduleIDs).Pluck("id", &materialIDs).Error; err != nil {
+			return err
+		}
+	}
+	if len(materialIDs) > 0 {
+		if err := tx.Model(&models.StudyPack{}).Where("material_id IN ?", materialIDs).Pluck("id", &studyPackIDs).Error; err != nil {
+			return err
+		}
+	}
+	if len(studyPackIDs) > 0 {
+		if err := tx.Model(&models.Quiz{}).Where("study_pack_id IN ?", studyPackIDs).Pluck("id", &quizIDs).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Flashcard{}).Where("study_pack_id IN ?", studyPackIDs).Pluck("id", &flashcardIDs).Error; err != nil {
+			return err
+		}
+	}
+
+	steps := []struct {
+		ids   []uuid.UUID
+		where string
+		model interface{}
+	}{
+		{quizIDs, "quiz_id IN ?", &models.QuizAttempt{}},
+		{quizIDs, "quiz_id IN ?", &models.QuizQuestion{}},
+		{quizIDs, "id IN ?", &models.Quiz{}},
+		{flashcardIDs, "flashcard_id IN ?", &models.FlashcardReview{}},
+		{flashcardIDs, "flashcard_id IN ?", &models.FlashcardSchedule{}},
+		{studyPackIDs, "study_pack_id IN ?", &models.FlashcardSession{}},
+		{studyPackIDs, "study_pack_id IN ?", &models.Flashcard{}},
+		{studyPackIDs, "study_pack_id IN ?", &models.Summary{}},
+		{studyPackIDs, "id IN ?", &models.StudyPack{}},
+		{materialIDs, "material_id IN ?", &models.MaterialChunk{}},
+		{materialIDs, "material_id IN ?", &models.TranscriptSegment{}},
+		{materialIDs, "id IN ?", &models.Material{}},
+		{courseIDs, "course_id IN ?", &models.ImportBatch{}},
+		{moduleIDs, "id IN ?", &models.Module{}},
+		{assignmentIDs, "assignment_id IN ?", &models.Submission{}},
+		{assignmentIDs, "id IN ?", &models.Assignment{}},
+		{threadIDs, "thread_id IN ?", &models.Reply{}},
+		{threadIDs, "id IN ?", &models.Thread{}},
+		{conversationIDs, "conversation_id IN ?", &models.Message{}},
+		{conversationIDs, "id IN ?", &models.Conversation{}},
+		{courseIDs, "course_id IN ?", &models.StoredFile{}},
+		{courseIDs, "course_id IN ?", &models.Enrollment{}},
+		{courseIDs, "course_id IN ?", &models.CourseMetric{}},
+		{courseIDs, "id IN ?", &models.Course{}},
+	}
+	for _, step := range steps {
+		if len(step.ids) == 0 {
+			continue
+		}
+		if err := tx.Where(step.where, step.ids).Delete(step.model).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
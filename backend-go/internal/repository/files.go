@@ -0,0 +1,24 @@
+package repository
+
+import (
+	"myway-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type fileRepo struct {
+	db *gorm.DB
+}
+
+func (r *fileRepo) Create(file *models.StoredFile) error {
+	return r.db.Create(file).Error
+}
+
+func (r *fileRepo) GetByID(id uuid.UUID) (*models.StoredFile, error) {
+	var file models.StoredFile
+	if err := r.db.First(&file, id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &file, nil
+}
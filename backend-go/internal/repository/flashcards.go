@@ -0,0 +1,200 @@
+package repository
+
+import (
+	"myway-backend/internal/models"
+	"myway-backend/internal/srs"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type flashcardRepo struct {
+	db *gorm.DB
+}
+
+func (r *flashcardRepo) GetByID(id uuid.UUID) (*models.Flashcard, error) {
+	var flashcard models.Flashcard
+	if err := r.db.First(&flashcard, id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &flashcard, nil
+}
+
+func (r *flashcardRepo) ListByStudyPack(studyPackID uuid.UUID) ([]models.Flashcard, error) {
+	var flashcards []models.Flashcard
+	err := r.db.Where("study_pack_id = ?", studyPackID).Find(&flashcards).Error
+	return flashcards, err
+}
+
+func (r *flashcardRepo) CountInStudyPack(studyPackID uuid.UUID, ids []uuid.UUID) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	var count int64
+	err := r.db.Model(&models.Flashcard{}).
+		Where("study_pack_id = ? AND id IN ?", studyPackID, ids).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *flashcardRepo) Review(userID, flashcardID uuid.UUID, grade srs.Grade, now time.Time) (*models.FlashcardSchedule, error) {
+	var schedule *models.FlashcardSchedule
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		schedule, err = reviewFlashcard(tx, userID, flashcardID, nil, grade, now)
+		return err
+	})
+	return schedule, err
+}
+
+func (r *flashcardRepo) CreateSession(session *models.FlashcardSession, grades map[uuid.UUID]srs.Grade, event *models.ProgressEvent, now time.Time) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Create(session).Error; err != nil {
+			return err
+		}
+		for flashcardID, grade := range grades {
+			if _, err := reviewFlashcard(tx, session.UserID, flashcardID, &session.ID, grade, now); err != nil {
+				return err
+			}
+		}
+
+		var courseIDs []uuid.UUID
+		if err := tx.Model(&models.StudyPack{}).
+			Select("modules.course_id").
+			Joins("JOIN materials ON materials.id = study_packs.material_id").
+			Joins("JOIN modules ON modules.id = materials.module_id").
+			Where("study_packs.id = ?", session.StudyPackID).
+			Pluck("modules.course_id", &courseIDs).Error; err != nil {
+			return err
+		}
+		if len(courseIDs) > 0 {
+			event.CourseID = courseIDs[0].String()
+		}
+		return tx.Create(event).Error
+	})
+}
+
+// reviewFlashcard applies grade to the user's schedule for the card and logs
+// the review. The schedule of a first review is inserted unless another
+// review got there first, then locked, so concurrent reviews of a card apply
+// one after the other instead of both starting from scratch.
+func reviewFlashcard(tx *gorm.DB, userID, flashcardID uuid.UUID, sessionID *uuid.UUID, grade srs.Grade, now time.Time) (*models.FlashcardSchedule, error) {
+	initial := newSchedule(userID, flashcardID, now)
+	if err := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "flashcard_id"}, {Name: "user_id"}},
+		DoNothing: true,
+	}).Create(&initial).Error; err != nil {
+		return nil, err
+	}
+
+	var schedule models.FlashcardSchedule
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("flashcard_id = ? AND user_id = ?", flashcardID, userID).
+		First(&schedule).Error; err != nil {
+		return nil, err
+	}
+
+	review := applyReview(&schedule, sessionID, grade, now)
+	if err := tx.Omit(clause.Associations).Save(&schedule).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Create(&review).Error; err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// newSchedule is the state of a card the user has never reviewed.
+func newSchedule(userID, flashcardID uuid.UUID, now time.Time) models.FlashcardSchedule {
+	state := srs.New(now)
+	return models.FlashcardSchedule{
+		FlashcardID:    flashcardID,
+		UserID:         userID,
+		Ease:           state.Ease,
+		IntervalDays:   state.IntervalDays,
+		Repetitions:    state.Repetitions,
+		Lapses:         state.Lapses,
+		DueAt:          state.DueAt,
+		LastReviewedAt: now,
+	}
+}
+
+// applyReview moves the schedule on by one review and returns its log entry.
+func applyReview(schedule *models.FlashcardSchedule, sessionID *uuid.UUID, grade srs.Grade, now time.Time) models.FlashcardReview {
+	state := srs.Review(srs.State{
+		Ease:         schedule.Ease,
+		IntervalDays: schedule.IntervalDays,
+		Repetitions:  schedule.Repetitions,
+		Lapses:       schedule.Lapses,
+		DueAt:        schedule.DueAt,
+	}, grade, now)
+	schedule.Ease = state.Ease
+	schedule.IntervalDays = state.IntervalDays
+	schedule.Repetitions = state.Repetitions
+	schedule.Lapses = state.Lapses
+	schedule.DueAt = state.DueAt
+	schedule.LastReviewedAt = now
+
+	return models.FlashcardReview{
+		FlashcardID:  schedule.FlashcardID,
+		UserID:       schedule.UserID,
+		SessionID:    sessionID,
+		Grade:        string(grade),
+		Ease:         state.Ease,
+		IntervalDays: state.IntervalDays,
+		DueAt:        state.DueAt,
+		ReviewedAt:   now,
+	}
+}
+
+func (r *flashcardRepo) ListSessions(userID uuid.UUID, limit int) ([]models.FlashcardSession, error) {
+	var sessions []models.FlashcardSession
+	err := r.db.
+		Preload("StudyPack.Material").
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Limit(limit).
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// studyablePacks selects the ids of the READY study packs in the courses the
+// user is enrolled in, or in one of them.
+func (r *flashcardRepo) studyablePacks(userID uuid.UUID, courseID *uuid.UUID) *gorm.DB {
+	query := r.db.Model(&models.StudyPack{}).
+		Select("study_packs.id").
+		Joins("JOIN materials ON materials.id = study_packs.material_id").
+		Joins("JOIN modules ON modules.id = materials.module_id").
+		Joins("JOIN enrollments ON enrollments.course_id = modules.course_id").
+		Where("enrollments.user_id = ? AND study_packs.status = ?", userID, "READY")
+	if courseID != nil {
+		query = query.Where("modules.course_id = ?", *courseID)
+	}
+	return query
+}
+
+func (r *flashcardRepo) ListDue(userID uuid.UUID, courseID *uuid.UUID, now time.Time, limit int) ([]models.FlashcardSchedule, error) {
+	flashcards := r.db.Model(&models.Flashcard{}).Select("id").Where("study_pack_id IN (?)", r.studyablePacks(userID, courseID))
+	var schedules []models.FlashcardSchedule
+	err := r.db.
+		Preload("Flashcard").
+		Where("user_id = ? AND due_at <= ?", userID, now).
+		Where("flashcard_id IN (?)", flashcards).
+		Order("due_at ASC").
+		Limit(limit).
+		Find(&schedules).Error
+	return schedules, err
+}
+
+func (r *flashcardRepo) ListNew(userID uuid.UUID, courseID *uuid.UUID, limit int) ([]models.Flashcard, error) {
+	var flashcards []models.Flashcard
+	err := r.db.
+		Where("study_pack_id IN (?)", r.studyablePacks(userID, courseID)).
+		Where("NOT EXISTS (SELECT 1 FROM flashcard_schedules WHERE flashcard_schedules.flashcard_id = flashcards.id AND flashcard_schedules.user_id = ?)", userID).
+		Order("study_pack_id, id").
+		Limit(limit).
+		Find(&flashcards).Error
+	return flashcards, err
+}
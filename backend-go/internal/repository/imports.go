@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"myway-backend/internal/models"
+	"myway-backend/internal/pipeline"
+	"myway-backend/internal/transcript"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type importRepo struct {
+	db *gorm.DB
+}
+
+func (r *importRepo) QueueMaterial(material *models.Material, createdBy uuid.UUID, language string, captions *transcript.Transcript) (*models.StudyPack, *models.Job, error) {
+	var studyPack *models.StudyPack
+	var job *models.Job
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		studyPack, job, err = pipeline.QueueMaterial(tx, material, createdBy, language)
+		if err != nil {
+			return err
+		}
+		if captions != nil {
+			if err := transcript.Store(tx, material.ID, captions); err != nil {
+				return fmt.Errorf("store captions: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return studyPack, job, nil
+}
+
+func (r *importRepo) AttachTranscript(material *models.Material, t *transcript.Transcript) (*models.Job, error) {
+	var job *models.Job
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := transcript.Store(tx, material.ID, t); err != nil {
+			return err
+		}
+		text := t.Text()
+		if err := tx.Model(material).Update("transcript_text", text).Error; err != nil {
+			return err
+		}
+		material.TranscriptText = &text
+
+		var studyPack models.StudyPack
+		if err := tx.Where("material_id = ?", material.ID).Order("created_at DESC").First(&studyPack).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+		if studyPack.Status != "FAILED" {
+			return nil
+		}
+		if err := tx.Model(&studyPack).Updates(map[string]interface{}{"status": "QUEUED", "error_message": nil}).Error; err != nil {
+			return err
+		}
+		var err error
+		job, err = pipeline.EnqueueStudyPack(tx, pipeline.StudyPackPayload{StudyPackID: studyPack.ID, MaterialID: material.ID})
+		return err
+	})
+	return job, err
+}
+
+func (r *importRepo) GetLatestJob(materialID uuid.UUID) (*models.Job, error) {
+	var job models.Job
+	if err := r.db.
+		Where("material_id = ?", materialID).
+		Order("created_at DESC").
+		First(&job).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &job, nil
+}
+
+func (r *importRepo) CreateBatch(batch *models.ImportBatch) (*models.Job, error) {
+	var job *models.Job
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(batch).Error; err != nil {
+			return err
+		}
+		var err error
+		job, err = pipeline.EnqueuePlaylist(tx, batch.ID)
+		return err
+	})
+	return job, err
+}
+
+func (r *importRepo) GetBatch(id uuid.UUID) (*models.ImportBatch, error) {
+	var batch models.ImportBatch
+	if err := r.db.Preload("Course").First(&batch, id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &batch, nil
+}
+
+func (r *importRepo) ListBatchMaterials(batchID uuid.UUID) ([]models.Material, error) {
+	var materials []models.Material
+	err := r.db.
+		Preload("StudyPacks", func(tx *gorm.DB) *gorm.DB { return tx.Order("created_at DESC") }).
+		Where("batch_id = ?", batchID).
+		Order("title").
+		Find(&materials).Error
+	return materials, err
+}
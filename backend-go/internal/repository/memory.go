@@ -0,0 +1,2092 @@
+package repository
+
+import (
+	"encoding/json"
+	"myway-backend/internal/jobs"
+	"myway-backend/internal/models"
+	"myway-backend/internal/pipeline"
+	"myway-backend/internal/srs"
+	"myway-backend/internal/studypack"
+	"myway-backend/internal/transcript"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// memoryStore keeps every aggregate in maps behind one lock. Reads return
+// copies with the same relations preloaded as the Postgres repositories.
+type memoryStore struct {
+	mu sync.Mutex
+
+	users         map[uuid.UUID]models.User
+	refreshTokens map[uuid.UUID]models.RefreshToken
+	userTokens    map[uuid.UUID]models.UserToken
+	recoveryCodes map[uuid.UUID]models.RecoveryCode
+	apiTokens     map[uuid.UUID]models.APIToken
+	orgs          map[uuid.UUID]models.Organization
+	memberships   map[uuid.UUID]models.OrgMembership
+	invitations   map[uuid.UUID]models.OrgInvitation
+	courses       map[uuid.UUID]models.Course
+	enrollments   map[uuid.UUID]models.Enrollment
+	modules       map[uuid.UUID]models.Module
+	materials     map[uuid.UUID]models.Material
+	studyPacks    map[uuid.UUID]models.StudyPack
+	summaries     map[uuid.UUID]models.Summary
+	quizzes       map[uuid.UUID]models.Quiz
+	flashcards    map[uuid.UUID]models.Flashcard
+	schedules     map[uuid.UUID]models.FlashcardSchedule
+	reviews       []models.FlashcardReview
+	sessions      map[uuid.UUID]models.FlashcardSession
+	progress      []models.ProgressEvent
+	conversations map[uuid.UUID]models.Conversation
+	messages      []models.Message
+	jobs          map[uuid.UUID]models.Job
+	batches       map[uuid.UUID]models.ImportBatch
+	assignments   map[uuid.UUID]models.Assignment
+	submissions   map[uuid.UUID]models.Submission
+	files         map[uuid.UUID]models.StoredFile
+	auditEvents   []models.AuditEvent
+	providers     map[uuid.UUID]models.OrgIdentityProvider
+	ssoLogins     map[uuid.UUID]models.SSOLogin
+	identities    map[uuid.UUID]models.UserIdentity
+}
+
+// NewMemory returns repositories backed by an in-memory store, for handler
+// tests and local experiments without Postgres.
+func NewMemory() *Repositories {
+	store := &memoryStore{
+		users:         make(map[uuid.UUID]models.User),
+		refreshTokens: make(map[uuid.UUID]models.RefreshToken),
+		userTokens:    make(map[uuid.UUID]models.UserToken),
+		recoveryCodes: make(map[uuid.UUID]models.RecoveryCode),
+		apiTokens:     make(map[uuid.UUID]models.APIToken),
+		orgs:          make(map[uuid.UUID]models.Organization),
+		memberships:   make(map[uuid.UUID]models.OrgMembership),
+		invitations:   make(map[uuid.UUID]models.OrgInvitation),
+		courses:       make(map[uuid.UUID]models.Course),
+		enrollments:   make(map[uuid.UUID]models.Enrollment),
+		modules:       make(map[uuid.UUID]models.Module),
+		materials:     make(map[uuid.UUID]models.Material),
+		studyPacks:    make(map[uuid.UUID]models.StudyPack),
+		summaries:     make(map[uuid.UUID]models.Summary),
+		quizzes:       make(map[uuid.UUID]models.Quiz),
+		flashcards:    make(map[uuid.UUID]models.Flashcard),
+		schedules:     make(map[uuid.UUID]models.FlashcardSchedule),
+		sessions:      make(map[uuid.UUID]models.FlashcardSession),
+		conversations: make(map[uuid.UUID]models.Conversation),
+		jobs:          make(map[uuid.UUID]models.Job),
+		batches:       make(map[uuid.UUID]models.ImportBatch),
+		assignments:   make(map[uuid.UUID]models.Assignment),
+		submissions:   make(map[uuid.UUID]models.Submission),
+		files:         make(map[uuid.UUID]models.StoredFile),
+		providers:     make(map[uuid.UUID]models.OrgIdentityProvider),
+		ssoLogins:     make(map[uuid.UUID]models.SSOLogin),
+		identities:    make(map[uuid.UUID]models.UserIdentity),
+	}
+	repos := &Repositories{
+		Users:         &memoryUsers{store},
+		Orgs:          &memoryOrgs{store},
+		Courses:       &memoryCourses{store},
+		StudyPacks:    &memoryStudyPacks{store},
+		Flashcards:    &memoryFlashcards{store},
+		Conversations: &memoryConversations{store},
+		Imports:       &memoryImports{store},
+		Assessments:   &memoryAssessments{store},
+		Files:         &memoryFiles{store},
+		APITokens:     &memoryAPITokens{store},
+		SSO:           &memorySSO{store},
+	}
+	repos.Audit = &memoryAudit{s: store, repos: repos}
+	return repos
+}
+
+func newID(id *uuid.UUID) {
+	if *id == uuid.Nil {
+		*id = uuid.New()
+	}
+}
+
+func stamp(t *time.Time) {
+	if t.IsZero() {
+		*t = time.Now()
+	}
+}
+
+type memoryUsers struct{ s *memoryStore }
+
+func (r *memoryUsers) Create(user *models.User) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, existing := range r.s.users {
+		if strings.EqualFold(existing.Email, user.Email) {
+			return errDuplicate("users.email")
+		}
+	}
+	newID(&user.ID)
+	stamp(&user.CreatedAt)
+	r.s.users[user.ID] = stripUser(*user)
+	return nil
+}
+
+func (r *memoryUsers) GetByID(id uuid.UUID) (*models.User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	user, ok := r.s.users[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &user, nil
+}
+
+func (r *memoryUsers) GetByEmail(email string) (*models.User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, user := range r.s.users {
+		if strings.EqualFold(user.Email, NormalizeEmail(email)) {
+			return &user, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryUsers) ListByIDs(ids []uuid.UUID) ([]models.User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var users []models.User
+	for _, id := range ids {
+		if user, ok := r.s.users[id]; ok {
+			users = append(users, user)
+		}
+	}
+	return users, nil
+}
+
+func (r *memoryUsers) ListByRoles(roles []string) ([]models.User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var users []models.User
+	for _, user := range r.s.users {
+		for _, role := range roles {
+			if user.Role == role {
+				users = append(users, user)
+				break
+			}
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].CreatedAt.Before(users[j].CreatedAt) })
+	return users, nil
+}
+
+func (r *memoryUsers) Update(user *models.User) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if _, ok := r.s.users[user.ID]; !ok {
+		return ErrNotFound
+	}
+	r.s.users[user.ID] = stripUser(*user)
+	return nil
+}
+
+func (r *memoryUsers) CreateRefreshToken(token *models.RefreshToken) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	newID(&token.ID)
+	stamp(&token.CreatedAt)
+	stored := *token
+	stored.User = models.User{}
+	r.s.refreshTokens[token.ID] = stored
+	return nil
+}
+
+func (r *memoryUsers) GetRefreshToken(tokenHash string) (*models.RefreshToken, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, stored := range r.s.refreshTokens {
+		if stored.TokenHash == tokenHash {
+			return &stored, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryUsers) RotateRefreshToken(used, next *models.RefreshToken, now time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	stored, ok := r.s.refreshTokens[used.ID]
+	if !ok || stored.UsedAt != nil || stored.RevokedAt != nil {
+		return ErrTokenUsed
+	}
+	stored.UsedAt = &now
+	r.s.refreshTokens[stored.ID] = stored
+
+	newID(&next.ID)
+	stamp(&next.CreatedAt)
+	created := *next
+	created.User = models.User{}
+	r.s.refreshTokens[next.ID] = created
+	return nil
+}
+
+func (r *memoryUsers) RevokeRefreshTokenFamily(familyID uuid.UUID, now time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for id, stored := range r.s.refreshTokens {
+		if stored.FamilyID == familyID && stored.RevokedAt == nil {
+			stored.RevokedAt = &now
+			r.s.refreshTokens[id] = stored
+		}
+	}
+	return nil
+}
+
+func (r *memoryUsers) RevokeUserRefreshTokens(userID uuid.UUID, now time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for id, stored := range r.s.refreshTokens {
+		if stored.UserID == userID && stored.RevokedAt == nil {
+			stored.RevokedAt = &now
+			r.s.refreshTokens[id] = stored
+		}
+	}
+	return nil
+}
+
+func (r *memoryUsers) DeleteExpiredRefreshTokens(before time.Time) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var deleted int64
+	for id, stored := range r.s.refreshTokens {
+		if stored.ExpiresAt.Before(before) {
+			delete(r.s.refreshTokens, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (r *memoryUsers) CreateUserToken(token *models.UserToken) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	newID(&token.ID)
+	stamp(&token.CreatedAt)
+	stored := *token
+	stored.User = models.User{}
+	r.s.userTokens[token.ID] = stored
+	return nil
+}
+
+func (r *memoryUsers) CountUserTokens(userID uuid.UUID, purpose string, since time.Time) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var count int64
+	for _, stored := range r.s.userTokens {
+		if stored.UserID == userID && stored.Purpose == purpose && !stored.CreatedAt.Before(since) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *memoryUsers) ConsumeUserToken(tokenHash, purpose string, now time.Time) (*models.UserToken, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var found *models.UserToken
+	for _, stored := range r.s.userTokens {
+		if stored.TokenHash == tokenHash && stored.Purpose == purpose && stored.UsedAt == nil && stored.ExpiresAt.After(now) {
+			found = &stored
+			break
+		}
+	}
+	if found == nil {
+		return nil, ErrNotFound
+	}
+	for id, stored := range r.s.userTokens {
+		if stored.UserID == found.UserID && stored.Purpose == purpose && stored.UsedAt == nil {
+			stored.UsedAt = &now
+			r.s.userTokens[id] = stored
+		}
+	}
+	found.UsedAt = &now
+	return found, nil
+}
+
+func (r *memoryUsers) DeleteExpiredUserTokens(before time.Time) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var deleted int64
+	for id, stored := range r.s.userTokens {
+		if stored.ExpiresAt.Before(before) {
+			delete(r.s.userTokens, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (r *memoryUsers) UseTOTPStep(userID uuid.UUID, step int64) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	user, ok := r.s.users[userID]
+	if !ok || user.TOTPLastStep >= step {
+		return ErrTokenUsed
+	}
+	user.TOTPLastStep = step
+	r.s.users[userID] = user
+	return nil
+}
+
+func (r *memoryUsers) ReplaceRecoveryCodes(userID uuid.UUID, codeHashes []string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for id, code := range r.s.recoveryCodes {
+		if code.UserID == userID {
+			delete(r.s.recoveryCodes, id)
+		}
+	}
+	for _, hash := range codeHashes {
+		code := models.RecoveryCode{ID: uuid.New(), UserID: userID, CodeHash: hash, CreatedAt: time.Now()}
+		r.s.recoveryCodes[code.ID] = code
+	}
+	return nil
+}
+
+func (r *memoryUsers) UseRecoveryCode(userID uuid.UUID, codeHash string, now time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for id, code := range r.s.recoveryCodes {
+		if code.UserID == userID && code.CodeHash == codeHash && code.UsedAt == nil {
+			code.UsedAt = &now
+			r.s.recoveryCodes[id] = code
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (r *memoryUsers) CountRecoveryCodes(userID uuid.UUID) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var count int64
+	for _, code := range r.s.recoveryCodes {
+		if code.UserID == userID && code.UsedAt == nil {
+			count++
+		}
+	}
+	return count, nil
+}
+
+type memoryOrgs struct{ s *memoryStore }
+
+func (r *memoryOrgs) GetByID(id uuid.UUID) (*models.Organization, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	org, ok := r.s.orgs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &org, nil
+}
+
+func (r *memoryOrgs) CreateWithOwner(org *models.Organization, ownerID uuid.UUID) (*models.OrgMembership, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	newID(&org.ID)
+	stamp(&org.CreatedAt)
+	r.s.orgs[org.ID] = stripOrg(*org)
+
+	membership := &models.OrgMembership{
+		ID:     uuid.New(),
+		OrgID:  org.ID,
+		UserID: ownerID,
+		Role:   "ORGANIZER",
+		Status: MembershipActive,
+	}
+	r.s.memberships[membership.ID] = *membership
+	return membership, nil
+}
+
+func (r *memoryOrgs) Update(org *models.Organization) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if _, ok := r.s.orgs[org.ID]; !ok {
+		return ErrNotFound
+	}
+	r.s.orgs[org.ID] = stripOrg(*org)
+	return nil
+}
+
+func (r *memoryOrgs) Delete(id uuid.UUID) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if _, ok := r.s.orgs[id]; !ok {
+		return ErrNotFound
+	}
+	for courseID, course := range r.s.courses {
+		if course.OrgID == id {
+			r.s.deleteCourse(courseID)
+		}
+	}
+	for membershipID, membership := range r.s.memberships {
+		if membership.OrgID == id {
+			delete(r.s.memberships, membershipID)
+		}
+	}
+	for tokenID, token := range r.s.apiTokens {
+		if token.OrgID != nil && *token.OrgID == id {
+			delete(r.s.apiTokens, tokenID)
+		}
+	}
+	for invitationID, invitation := range r.s.invitations {
+		if invitation.OrgID == id {
+			delete(r.s.invitations, invitationID)
+		}
+	}
+	r.s.deleteIdentityProvider(id)
+	delete(r.s.orgs, id)
+	return nil
+}
+
+func (r *memoryOrgs) GetActiveMembership(userID, orgID uuid.UUID) (*models.OrgMembership, error) {
+	membership, err := r.GetMembership(userID, orgID)
+	if err != nil {
+		return nil, err
+	}
+	if membership.Status != MembershipActive {
+		return nil, ErrNotFound
+	}
+
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	membership.Organization = r.s.orgs[orgID]
+	return membership, nil
+}
+
+func (r *memoryOrgs) GetMembership(userID, orgID uuid.UUID) (*models.OrgMembership, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, membership := range r.s.memberships {
+		if membership.UserID == userID && membership.OrgID == orgID {
+			return &membership, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryOrgs) ListMemberships(userID uuid.UUID) ([]models.OrgMembership, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var memberships []models.OrgMembership
+	for _, membership := range r.s.memberships {
+		if membership.UserID == userID {
+			membership.Organization = r.s.orgs[membership.OrgID]
+			memberships = append(memberships, membership)
+		}
+	}
+	sort.Slice(memberships, func(i, j int) bool {
+		return memberships[i].Organization.CreatedAt.Before(memberships[j].Organization.CreatedAt)
+	})
+	return memberships, nil
+}
+
+func (r *memoryOrgs) SaveMembership(membership *models.OrgMembership) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if membership.ID != uuid.Nil && !activeOrganizer(membership) {
+		if err := r.s.keepOrganizer(membership.OrgID, membership.UserID); err != nil {
+			return err
+		}
+	}
+	newID(&membership.ID)
+	if membership.Status == "" {
+		membership.Status = MembershipActive
+	}
+	stored := *membership
+	stored.Organization = models.Organization{}
+	stored.User = models.User{}
+	r.s.memberships[membership.ID] = stored
+	return nil
+}
+
+func (r *memoryOrgs) SaveMemberships(memberships ...*models.OrgMembership) error {
+	for _, membership := range memberships {
+		if err := r.SaveMembership(membership); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *memoryOrgs) ListMembers(orgID uuid.UUID, filter MemberFilter) ([]models.OrgMembership, int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	search := strings.ToLower(filter.Search)
+	var memberships []models.OrgMembership
+	for _, membership := range r.s.memberships {
+		user := r.s.users[membership.UserID]
+		if membership.OrgID != orgID ||
+			(filter.Role != "" && membership.Role != filter.Role) ||
+			(filter.Status != "" && membership.Status != filter.Status) ||
+			(search != "" && !strings.Contains(strings.ToLower(user.Name), search) && !strings.Contains(strings.ToLower(user.Email), search)) {
+			continue
+		}
+		membership.User = user
+		memberships = append(memberships, membership)
+	}
+	sort.Slice(memberships, func(i, j int) bool {
+		if memberships[i].User.Name != memberships[j].User.Name {
+			return memberships[i].User.Name < memberships[j].User.Name
+		}
+		return memberships[i].User.Email < memberships[j].User.Email
+	})
+
+	total := int64(len(memberships))
+	if filter.Offset >= len(memberships) {
+		return nil, total, nil
+	}
+	memberships = memberships[filter.Offset:]
+	if filter.Limit > 0 && filter.Limit < len(memberships) {
+		memberships = memberships[:filter.Limit]
+	}
+	return memberships, total, nil
+}
+
+// keepOrganizer is orgRepo's keepOrganizer, run under the store lock.
+func (s *memoryStore) keepOrganizer(orgID, userID uuid.UUID) error {
+	var organizers []uuid.UUID
+	for _, membership := range s.memberships {
+		if membership.OrgID == orgID && activeOrganizer(&membership) {
+			organizers = append(organizers, membership.UserID)
+		}
+	}
+	if len(organizers) == 1 && organizers[0] == userID {
+		return ErrLastOrganizer
+	}
+	return nil
+}
+
+func (r *memoryOrgs) RemoveMember(orgID, userID uuid.UUID, retainData bool) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if err := r.s.keepOrganizer(orgID, userID); err != nil {
+		return err
+	}
+	found := false
+	for id, membership := range r.s.memberships {
+		if membership.OrgID == orgID && membership.UserID == userID {
+			delete(r.s.memberships, id)
+			found = true
+		}
+	}
+	if !found {
+		return ErrNotFound
+	}
+
+	now := time.Now()
+	for id, token := range r.s.apiTokens {
+		if token.OrgID != nil && *token.OrgID == orgID && token.UserID == userID && token.RevokedAt == nil {
+			token.RevokedAt = &now
+			r.s.apiTokens[id] = token
+		}
+	}
+	for id, enrollment := range r.s.enrollments {
+		if enrollment.UserID == userID && r.s.courses[enrollment.CourseID].OrgID == orgID {
+			delete(r.s.enrollments, id)
+		}
+	}
+	if retainData {
+		return nil
+	}
+	for id, submission := range r.s.submissions {
+		if submission.UserID == userID && r.s.courses[r.s.assignments[submission.AssignmentID].CourseID].OrgID == orgID {
+			delete(r.s.submissions, id)
+		}
+	}
+	r.s.deleteFlashcardHistory(func(flashcardID, reviewer uuid.UUID) bool {
+		return reviewer == userID && r.s.studyPackOrg(r.s.flashcards[flashcardID].StudyPackID) == orgID
+	})
+	for id, session := range r.s.sessions {
+		if session.UserID == userID && r.s.studyPackOrg(session.StudyPackID) == orgID {
+			delete(r.s.sessions, id)
+		}
+	}
+	for id, conversation := range r.s.conversations {
+		if conversation.UserID == userID && r.s.courses[conversation.CourseID].OrgID == orgID {
+			r.s.deleteConversation(id)
+		}
+	}
+	return nil
+}
+
+func (r *memoryOrgs) SaveInvitation(invitation *models.OrgInvitation) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for id, existing := range r.s.invitations {
+		if id != invitation.ID && existing.TokenHash == invitation.TokenHash {
+			return errDuplicate("org_invitations.token_hash")
+		}
+	}
+	newID(&invitation.ID)
+	stamp(&invitation.CreatedAt)
+	stored := *invitation
+	stored.Organization = models.Organization{}
+	stored.Inviter = models.User{}
+	r.s.invitations[invitation.ID] = stored
+	return nil
+}
+
+func (r *memoryOrgs) GetInvitation(id uuid.UUID) (*models.OrgInvitation, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	invitation, ok := r.s.invitations[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &invitation, nil
+}
+
+func (r *memoryOrgs) GetInvitationByHash(tokenHash string) (*models.OrgInvitation, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, invitation := range r.s.invitations {
+		if invitation.TokenHash == tokenHash {
+			invitation.Organization = r.s.orgs[invitation.OrgID]
+			return &invitation, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryOrgs) GetOpenInvitation(orgID uuid.UUID, email string) (*models.OrgInvitation, error) {
+	invitations, err := r.ListOpenInvitations(orgID)
+	if err != nil {
+		return nil, err
+	}
+	for _, invitation := range invitations {
+		if invitation.Email == email {
+			return &invitation, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryOrgs) ListOpenInvitations(orgID uuid.UUID) ([]models.OrgInvitation, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var invitations []models.OrgInvitation
+	for _, invitation := range r.s.invitations {
+		if invitation.OrgID == orgID && invitation.AcceptedAt == nil && invitation.RevokedAt == nil {
+			invitations = append(invitations, invitation)
+		}
+	}
+	sort.Slice(invitations, func(i, j int) bool {
+		return invitations[i].CreatedAt.After(invitations[j].CreatedAt)
+	})
+	return invitations, nil
+}
+
+func (r *memoryOrgs) AcceptInvitation(invitation *models.OrgInvitation, membership *models.OrgMembership, now time.Time) error {
+	r.s.mu.Lock()
+	stored, ok := r.s.invitations[invitation.ID]
+	if !ok || stored.AcceptedAt != nil || stored.RevokedAt != nil || !stored.ExpiresAt.After(now) {
+		r.s.mu.Unlock()
+		return ErrTokenUsed
+	}
+	stored.AcceptedAt = &now
+	stored.AcceptedBy = &membership.UserID
+	r.s.invitations[invitation.ID] = stored
+	r.s.mu.Unlock()
+
+	invitation.AcceptedAt = stored.AcceptedAt
+	invitation.AcceptedBy = stored.AcceptedBy
+	return r.SaveMembership(membership)
+}
+
+type memoryCourses struct{ s *memoryStore }
+
+func (r *memoryCourses) Create(course *models.Course) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	newID(&course.ID)
+	r.s.courses[course.ID] = stripCourse(*course)
+	enrollment := models.Enrollment{
+		ID:        uuid.New(),
+		CourseID:  course.ID,
+		UserID:    course.CreatedBy,
+		Role:      EnrollmentTeacher,
+		CreatedAt: time.Now(),
+	}
+	r.s.enrollments[enrollment.ID] = enrollment
+	return nil
+}
+
+func (r *memoryCourses) Update(course *models.Course) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if _, ok := r.s.courses[course.ID]; !ok {
+		return ErrNotFound
+	}
+	r.s.courses[course.ID] = stripCourse(*course)
+	return nil
+}
+
+func (r *memoryCourses) GetByID(id uuid.UUID) (*models.Course, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	course, ok := r.s.courses[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &course, nil
+}
+
+func (r *memoryCourses) GetWithContent(id uuid.UUID) (*models.Course, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	course, ok := r.s.courses[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	for _, module := range r.s.modulesOf(id) {
+		module.Materials = r.s.materialsOf(module.ID, true)
+		course.Modules = append(course.Modules, module)
+	}
+	for _, assignment := range r.s.assignments {
+		if assignment.CourseID == id {
+			course.Assignments = append(course.Assignments, assignment)
+		}
+	}
+	return &course, nil
+}
+
+func (r *memoryCourses) ListByOrg(orgID uuid.UUID) ([]models.Course, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var courses []models.Course
+	for _, course := range r.s.courses {
+		if course.OrgID == orgID {
+			courses = append(courses, course)
+		}
+	}
+	sort.Slice(courses, func(i, j int) bool { return courses[i].Code < courses[j].Code })
+	return courses, nil
+}
+
+func (r *memoryCourses) Delete(id uuid.UUID) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if _, ok := r.s.courses[id]; !ok {
+		return ErrNotFound
+	}
+	r.s.deleteCourse(id)
+	return nil
+}
+
+func (r *memoryCourses) CreateModule(module *models.Module) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	newID(&module.ID)
+	stored := *module
+	stored.Course = models.Course{}
+	stored.Materials = nil
+	r.s.modules[module.ID] = stored
+	return nil
+}
+
+func (r *memoryCourses) GetModule(id uuid.UUID) (*models.Module, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	module, ok := r.s.modules[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &module, nil
+}
+
+func (r *memoryCourses) GetModuleByTitle(courseID uuid.UUID, title string) (*models.Module, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, module := range r.s.modules {
+		if module.CourseID == courseID && module.Title == title {
+			return &module, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryCourses) GetModuleWithContent(id uuid.UUID) (*models.Module, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	module, ok := r.s.modules[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	module.Course = r.s.courses[module.CourseID]
+	module.Materials = r.s.materialsOf(id, true)
+	return &module, nil
+}
+
+func (r *memoryCourses) ListModules(courseID uuid.UUID) ([]models.Module, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	modules := r.s.modulesOf(courseID)
+	for i := range modules {
+		modules[i].Materials = r.s.materialsOf(modules[i].ID, false)
+	}
+	return modules, nil
+}
+
+func (r *memoryCourses) UpdateModule(module *models.Module) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if _, ok := r.s.modules[module.ID]; !ok {
+		return ErrNotFound
+	}
+	stored := *module
+	stored.Course = models.Course{}
+	stored.Materials = nil
+	r.s.modules[module.ID] = stored
+	return nil
+}
+
+func (r *memoryCourses) DeleteModule(id uuid.UUID) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	delete(r.s.modules, id)
+	return nil
+}
+
+func (r *memoryCourses) CreateMaterial(material *models.Material) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	newID(&material.ID)
+	stored := *material
+	stored.Module = models.Module{}
+	stored.StudyPacks = nil
+	r.s.materials[material.ID] = stored
+	return nil
+}
+
+func (r *memoryCourses) GetMaterial(id uuid.UUID) (*models.Material, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	material, ok := r.s.materials[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &material, nil
+}
+
+func (r *memoryCourses) GetEnrollment(courseID, userID uuid.UUID) (*models.Enrollment, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, enrollment := range r.s.enrollments {
+		if enrollment.CourseID == courseID && enrollment.UserID == userID {
+			return &enrollment, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryCourses) ListEnrollments(courseID uuid.UUID) ([]models.Enrollment, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var enrollments []models.Enrollment
+	for _, enrollment := range r.s.enrollments {
+		if enrollment.CourseID == courseID {
+			enrollment.User = r.s.users[enrollment.UserID]
+			enrollments = append(enrollments, enrollment)
+		}
+	}
+	sort.Slice(enrollments, func(i, j int) bool { return enrollments[i].CreatedAt.Before(enrollments[j].CreatedAt) })
+	return enrollments, nil
+}
+
+func (r *memoryCourses) CountEnrollments(courseID uuid.UUID, role string) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var count int64
+	for _, enrollment := range r.s.enrollments {
+		if enrollment.CourseID == courseID && enrollment.Role == role {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *memoryCourses) SaveEnrollment(enrollment *models.Enrollment) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if enrollment.ID == uuid.Nil {
+		for _, existing := range r.s.enrollments {
+			if existing.CourseID == enrollment.CourseID && existing.UserID == enrollment.UserID {
+				return errDuplicate("enrollments.course_id, user_id")
+			}
+		}
+		enrollment.ID = uuid.New()
+		stamp(&enrollment.CreatedAt)
+	} else if _, ok := r.s.enrollments[enrollment.ID]; !ok {
+		return ErrNotFound
+	}
+	stored := *enrollment
+	stored.Course = models.Course{}
+	stored.User = models.User{}
+	r.s.enrollments[enrollment.ID] = stored
+	return nil
+}
+
+func (r *memoryCourses) DeleteEnrollment(courseID, userID uuid.UUID) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for id, enrollment := range r.s.enrollments {
+		if enrollment.CourseID == courseID && enrollment.UserID == userID {
+			delete(r.s.enrollments, id)
+		}
+	}
+	return nil
+}
+
+type memoryStudyPacks struct{ s *memoryStore }
+
+func (r *memoryStudyPacks) Create(studyPack *models.StudyPack) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	newID(&studyPack.ID)
+	stamp(&studyPack.CreatedAt)
+	r.s.studyPacks[studyPack.ID] = stripStudyPack(*studyPack)
+	return nil
+}
+
+func (r *memoryStudyPacks) GetByID(id uuid.UUID) (*models.StudyPack, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	studyPack, ok := r.s.studyPacks[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &studyPack, nil
+}
+
+func (r *memoryStudyPacks) GetLatestByMaterial(materialID uuid.UUID) (*models.StudyPack, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	packs := r.s.studyPacksOf(materialID)
+	if len(packs) == 0 {
+		return nil, ErrNotFound
+	}
+	latest := packs[len(packs)-1]
+
+	for _, summary := range r.s.summaries {
+		if summary.StudyPackID == latest.ID {
+			summary := summary
+			latest.Summary = &summary
+		}
+	}
+	for _, quiz := range r.s.quizzes {
+		if quiz.StudyPackID == latest.ID {
+			latest.Quizzes = append(latest.Quizzes, quiz)
+		}
+	}
+	sort.Slice(latest.Quizzes, func(i, j int) bool { return latest.Quizzes[i].Version < latest.Quizzes[j].Version })
+	for _, flashcard := range r.s.flashcards {
+		if flashcard.StudyPackID == latest.ID {
+			latest.Flashcards = append(latest.Flashcards, flashcard)
+		}
+	}
+	latest.Material = r.s.materials[materialID]
+	return &latest, nil
+}
+
+func (r *memoryStudyPacks) SaveSummary(studyPackID uuid.UUID, content string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	r.s.saveSummary(studyPackID, content)
+	return nil
+}
+
+func (r *memoryStudyPacks) Publish(id uuid.UUID, approvedBy string, at time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	studyPack, ok := r.s.studyPacks[id]
+	if !ok {
+		return ErrNotFound
+	}
+	studyPack.Status = "READY"
+	studyPack.PublishedAt = &at
+	studyPack.RequiresApproval = false
+	studyPack.ApprovedBy = &approvedBy
+	r.s.studyPacks[id] = studyPack
+	return nil
+}
+
+func (r *memoryStudyPacks) SaveDraft(id uuid.UUID, content *studypack.Content) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	studyPack, ok := r.s.studyPacks[id]
+	if !ok {
+		return ErrNotFound
+	}
+
+	r.s.saveSummary(id, studypack.SummaryContent(content))
+
+	// The fake has no quiz attempts, so every previous quiz is replaced.
+	maxVersion := 0
+	for quizID, quiz := range r.s.quizzes {
+		if quiz.StudyPackID == id {
+			if quiz.Version > maxVersion {
+				maxVersion = quiz.Version
+			}
+			delete(r.s.quizzes, quizID)
+		}
+	}
+	quiz := studypack.NewQuiz(id, maxVersion+1, content)
+	quiz.ID = uuid.New()
+	for i := range quiz.Questions {
+		quiz.Questions[i].ID = uuid.New()
+		quiz.Questions[i].QuizID = quiz.ID
+	}
+	r.s.quizzes[quiz.ID] = quiz
+
+	for flashcardID, flashcard := range r.s.flashcards {
+		if flashcard.StudyPackID == id {
+			delete(r.s.flashcards, flashcardID)
+		}
+	}
+	r.s.deleteFlashcardHistory(func(flashcardID, _ uuid.UUID) bool {
+		_, ok := r.s.flashcards[flashcardID]
+		return !ok
+	})
+	for _, flashcard := range studypack.NewFlashcards(id, content) {
+		flashcard.ID = uuid.New()
+		r.s.flashcards[flashcard.ID] = flashcard
+	}
+
+	studyPack.Status = "GENERATED"
+	studyPack.RequiresApproval = true
+	studyPack.ApprovedBy = nil
+	studyPack.PublishedAt = nil
+	r.s.studyPacks[id] = studyPack
+	return nil
+}
+
+type memoryAssessments struct{ s *memoryStore }
+
+func (r *memoryAssessments) CreateAssignment(assignment *models.Assignment) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	newID(&assignment.ID)
+	stored := *assignment
+	stored.Course = models.Course{}
+	stored.Submissions = nil
+	r.s.assignments[assignment.ID] = stored
+	return nil
+}
+
+func (r *memoryAssessments) GetAssignment(id uuid.UUID) (*models.Assignment, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	assignment, ok := r.s.assignments[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	assignment.Course = r.s.courses[assignment.CourseID]
+	for _, submission := range r.s.submissions {
+		if submission.AssignmentID == id {
+			assignment.Submissions = append(assignment.Submissions, submission)
+		}
+	}
+	sort.Slice(assignment.Submissions, func(i, j int) bool {
+		return assignment.Submissions[i].SubmittedAt.Before(assignment.Submissions[j].SubmittedAt)
+	})
+	return &assignment, nil
+}
+
+func (r *memoryAssessments) ListActiveAssignments(courseID uuid.UUID) ([]models.Assignment, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var assignments []models.Assignment
+	for _, assignment := range r.s.assignments {
+		if assignment.CourseID == courseID && assignment.Status == "ACTIVE" {
+			assignments = append(assignments, assignment)
+		}
+	}
+	sort.Slice(assignments, func(i, j int) bool { return assignments[i].DueAt.Before(assignments[j].DueAt) })
+	return assignments, nil
+}
+
+func (r *memoryAssessments) CountSubmissions(assignmentID uuid.UUID) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var count int64
+	for _, submission := range r.s.submissions {
+		if submission.AssignmentID == assignmentID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *memoryAssessments) ListSubmissionsByUser(userID uuid.UUID) ([]models.Submission, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var submissions []models.Submission
+	for _, submission := range r.s.submissions {
+		if submission.UserID == userID {
+			submissions = append(submissions, submission)
+		}
+	}
+	return submissions, nil
+}
+
+func (r *memoryAssessments) GetSubmission(assignmentID, userID uuid.UUID) (*models.Submission, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, submission := range r.s.submissions {
+		if submission.AssignmentID == assignmentID && submission.UserID == userID {
+			return &submission, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryAssessments) GetSubmissionWithAssignment(id uuid.UUID) (*models.Submission, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	submission, ok := r.s.submissions[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	submission.Assignment = r.s.assignments[submission.AssignmentID]
+	submission.Assignment.Course = r.s.courses[submission.Assignment.CourseID]
+	return &submission, nil
+}
+
+func (r *memoryAssessments) SaveSubmission(submission *models.Submission) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	newID(&submission.ID)
+	stored := *submission
+	stored.Assignment = models.Assignment{}
+	stored.User = models.User{}
+	r.s.submissions[submission.ID] = stored
+	return nil
+}
+
+// Helpers below expect the store lock to be held.
+
+type memoryAPITokens struct{ s *memoryStore }
+
+func (r *memoryAPITokens) Create(token *models.APIToken) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	newID(&token.ID)
+	stamp(&token.CreatedAt)
+	stored := *token
+	stored.User = models.User{}
+	stored.Organization = models.Organization{}
+	r.s.apiTokens[token.ID] = stored
+	return nil
+}
+
+func (r *memoryAPITokens) GetByID(id uuid.UUID) (*models.APIToken, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	token, ok := r.s.apiTokens[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &token, nil
+}
+
+func (r *memoryAPITokens) GetByHash(tokenHash string) (*models.APIToken, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, token := range r.s.apiTokens {
+		if token.TokenHash == tokenHash {
+			return &token, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryAPITokens) ListPersonal(userID uuid.UUID) ([]models.APIToken, error) {
+	return r.list(func(token models.APIToken) bool {
+		return token.UserID == userID && token.OrgID == nil
+	}), nil
+}
+
+func (r *memoryAPITokens) ListByOrg(orgID uuid.UUID) ([]models.APIToken, error) {
+	return r.list(func(token models.APIToken) bool {
+		return token.OrgID != nil && *token.OrgID == orgID
+	}), nil
+}
+
+func (r *memoryAPITokens) list(match func(models.APIToken) bool) []models.APIToken {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var tokens []models.APIToken
+	for _, token := range r.s.apiTokens {
+		if match(token) {
+			tokens = append(tokens, token)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].CreatedAt.After(tokens[j].CreatedAt) })
+	return tokens
+}
+
+func (r *memoryAPITokens) Revoke(id uuid.UUID, now time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if token, ok := r.s.apiTokens[id]; ok && token.RevokedAt == nil {
+		token.RevokedAt = &now
+		r.s.apiTokens[id] = token
+	}
+	return nil
+}
+
+func (r *memoryAPITokens) TouchLastUsed(id uuid.UUID, now time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if token, ok := r.s.apiTokens[id]; ok {
+		token.LastUsedAt = &now
+		r.s.apiTokens[id] = token
+	}
+	return nil
+}
+
+type memoryFiles struct{ s *memoryStore }
+
+func (r *memoryFiles) Create(file *models.StoredFile) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	newID(&file.ID)
+	stamp(&file.CreatedAt)
+	r.s.files[file.ID] = *file
+	return nil
+}
+
+func (r *memoryFiles) GetByID(id uuid.UUID) (*models.StoredFile, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	file, ok := r.s.files[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &file, nil
+}
+
+type memorySSO struct{ s *memoryStore }
+
+func (r *memorySSO) GetProvider(orgID uuid.UUID) (*models.OrgIdentityProvider, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, provider := range r.s.providers {
+		if provider.OrgID == orgID {
+			return &provider, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memorySSO) GetProviderByID(id uuid.UUID) (*models.OrgIdentityProvider, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	provider, ok := r.s.providers[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &provider, nil
+}
+
+func (r *memorySSO) SaveProvider(provider *models.OrgIdentityProvider) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, existing := range r.s.providers {
+		if existing.OrgID == provider.OrgID && existing.ID != provider.ID {
+			return errDuplicate("org_identity_providers.org_id")
+		}
+	}
+	newID(&provider.ID)
+	stamp(&provider.CreatedAt)
+	provider.UpdatedAt = time.Now()
+	stored := *provider
+	stored.Organization = models.Organization{}
+	r.s.providers[provider.ID] = stored
+	return nil
+}
+
+func (r *memorySSO) DeleteProvider(orgID uuid.UUID) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	r.s.deleteIdentityProvider(orgID)
+	return nil
+}
+
+func (r *memorySSO) CreateLogin(login *models.SSOLogin) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, existing := range r.s.ssoLogins {
+		if existing.State == login.State {
+			return errDuplicate("sso_logins.state")
+		}
+	}
+	newID(&login.ID)
+	stamp(&login.CreatedAt)
+	stored := *login
+	stored.Provider = models.OrgIdentityProvider{}
+	r.s.ssoLogins[login.ID] = stored
+	return nil
+}
+
+func (r *memorySSO) TakeLogin(state string) (*models.SSOLogin, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for id, login := range r.s.ssoLogins {
+		if login.State == state {
+			delete(r.s.ssoLogins, id)
+			return &login, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memorySSO) DeleteExpiredLogins(before time.Time) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var deleted int64
+	for id, login := range r.s.ssoLogins {
+		if login.ExpiresAt.Before(before) {
+			delete(r.s.ssoLogins, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (r *memorySSO) GetIdentity(providerID uuid.UUID, subject string) (*models.UserIdentity, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, identity := range r.s.identities {
+		if identity.ProviderID == providerID && identity.Subject == subject {
+			return &identity, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memorySSO) SaveIdentity(identity *models.UserIdentity, user *models.User, now time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, existing := range r.s.identities {
+		if existing.ProviderID == identity.ProviderID && existing.Subject == identity.Subject && existing.ID != identity.ID {
+			return errDuplicate("user_identities.provider_id, subject")
+		}
+	}
+	if user.ID == uuid.Nil {
+		for _, existing := range r.s.users {
+			if strings.EqualFold(existing.Email, user.Email) {
+				return errDuplicate("users.email")
+			}
+		}
+		newID(&user.ID)
+		stamp(&user.CreatedAt)
+		r.s.users[user.ID] = stripUser(*user)
+	} else if _, ok := r.s.users[user.ID]; !ok {
+		return ErrNotFound
+	}
+	user.LastLogin = &now
+	stored := r.s.users[user.ID]
+	stored.LastLogin = &now
+	r.s.users[user.ID] = stored
+
+	identity.UserID = user.ID
+	identity.LastLogin = &now
+	newID(&identity.ID)
+	stamp(&identity.CreatedAt)
+	saved := *identity
+	saved.User = models.User{}
+	saved.Provider = models.OrgIdentityProvider{}
+	r.s.identities[identity.ID] = saved
+	return nil
+}
+
+// deleteIdentityProvider removes the organization's provider with its
+// sign-ins and linked identities.
+func (s *memoryStore) deleteIdentityProvider(orgID uuid.UUID) {
+	for providerID, provider := range s.providers {
+		if provider.OrgID != orgID {
+			continue
+		}
+		for id, login := range s.ssoLogins {
+			if login.ProviderID == providerID {
+				delete(s.ssoLogins, id)
+			}
+		}
+		for id, identity := range s.identities {
+			if identity.ProviderID == providerID {
+				delete(s.identities, id)
+			}
+		}
+		delete(s.providers, providerID)
+	}
+}
+
+func (s *memoryStore) modulesOf(courseID uuid.UUID) []models.Module {
+	var modules []models.Module
+	for _, module := range s.modules {
+		if module.CourseID == courseID {
+			modules = append(modules, module)
+		}
+	}
+	sort.Slice(modules, func(i, j int) bool { return modules[i].Order < modules[j].Order })
+	return modules
+}
+
+func (s *memoryStore) materialsOf(moduleID uuid.UUID, withStudyPacks bool) []models.Material {
+	var materials []models.Material
+	for _, material := range s.materials {
+		if material.ModuleID == moduleID {
+			if withStudyPacks {
+				material.StudyPacks = s.studyPacksOf(material.ID)
+			}
+			materials = append(materials, material)
+		}
+	}
+	sort.Slice(materials, func(i, j int) bool { return materials[i].Title < materials[j].Title })
+	return materials
+}
+
+// studyPacksOf returns the material's study packs, oldest first.
+func (s *memoryStore) studyPacksOf(materialID uuid.UUID) []models.StudyPack {
+	var packs []models.StudyPack
+	for _, studyPack := range s.studyPacks {
+		if studyPack.MaterialID == materialID {
+			packs = append(packs, studyPack)
+		}
+	}
+	sort.Slice(packs, func(i, j int) bool { return packs[i].CreatedAt.Before(packs[j].CreatedAt) })
+	return packs
+}
+
+func (s *memoryStore) saveSummary(studyPackID uuid.UUID, content string) {
+	for id, summary := range s.summaries {
+		if summary.StudyPackID == studyPackID {
+			summary.Content = content
+			s.summaries[id] = summary
+			return
+		}
+	}
+	summary := models.Summary{ID: uuid.New(), StudyPackID: studyPackID, Content: content}
+	s.summaries[summary.ID] = summary
+}
+
+func (s *memoryStore) deleteCourse(courseID uuid.UUID) {
+	for moduleID, module := range s.modules {
+		if module.CourseID != courseID {
+			continue
+		}
+		for materialID, material := range s.materials {
+			if material.ModuleID != moduleID {
+				continue
+			}
+			for studyPackID, studyPack := range s.studyPacks {
+				if studyPack.MaterialID == materialID {
+					s.deleteStudyPackContent(studyPackID)
+					for sessionID, session := range s.sessions {
+						if session.StudyPackID == studyPackID {
+							delete(s.sessions, sessionID)
+						}
+					}
+					delete(s.studyPacks, studyPackID)
+				}
+			}
+			delete(s.materials, materialID)
+		}
+		delete(s.modules, moduleID)
+	}
+	for assignmentID, assignment := range s.assignments {
+		if assignment.CourseID != courseID {
+			continue
+		}
+		for submissionID, submission := range s.submissions {
+			if submission.AssignmentID == assignmentID {
+				delete(s.submissions, submissionID)
+			}
+		}
+		delete(s.assignments, assignmentID)
+	}
+	for fileID, file := range s.files {
+		if file.CourseID == courseID {
+			delete(s.files, fileID)
+		}
+	}
+	for enrollmentID, enrollment := range s.enrollments {
+		if enrollment.CourseID == courseID {
+			delete(s.enrollments, enrollmentID)
+		}
+	}
+	for conversationID, conversation := range s.conversations {
+		if conversation.CourseID == courseID {
+			s.deleteConversation(conversationID)
+		}
+	}
+	for batchID, batch := range s.batches {
+		if batch.CourseID == courseID {
+			delete(s.batches, batchID)
+		}
+	}
+	delete(s.courses, courseID)
+}
+
+func (s *memoryStore) deleteStudyPackContent(studyPackID uuid.UUID) {
+	for id, summary := range s.summaries {
+		if summary.StudyPackID == studyPackID {
+			delete(s.summaries, id)
+		}
+	}
+	for id, quiz := range s.quizzes {
+		if quiz.StudyPackID == studyPackID {
+			delete(s.quizzes, id)
+		}
+	}
+	for id, flashcard := range s.flashcards {
+		if flashcard.StudyPackID == studyPackID {
+			delete(s.flashcards, id)
+		}
+	}
+	s.deleteFlashcardHistory(func(flashcardID, _ uuid.UUID) bool {
+		_, ok := s.flashcards[flashcardID]
+		return !ok
+	})
+}
+
+// deleteFlashcardHistory removes the schedules and reviews that match, by
+// card and user.
+func (s *memoryStore) deleteFlashcardHistory(match func(flashcardID, userID uuid.UUID) bool) {
+	for id, schedule := range s.schedules {
+		if match(schedule.FlashcardID, schedule.UserID) {
+			delete(s.schedules, id)
+		}
+	}
+	reviews := s.reviews[:0]
+	for _, review := range s.reviews {
+		if !match(review.FlashcardID, review.UserID) {
+			reviews = append(reviews, review)
+		}
+	}
+	s.reviews = reviews
+}
+
+// studyPackCourse returns the course the study pack's material is in.
+func (s *memoryStore) studyPackCourse(studyPackID uuid.UUID) uuid.UUID {
+	material := s.materials[s.studyPacks[studyPackID].MaterialID]
+	return s.modules[material.ModuleID].CourseID
+}
+
+func (s *memoryStore) studyPackOrg(studyPackID uuid.UUID) uuid.UUID {
+	return s.courses[s.studyPackCourse(studyPackID)].OrgID
+}
+
+func stripUser(user models.User) models.User {
+	return models.User{
+		ID:              user.ID,
+		Email:           user.Email,
+		PasswordHash:    user.PasswordHash,
+		Name:            user.Name,
+		Role:            user.Role,
+		CreatedAt:       user.CreatedAt,
+		LastLogin:       user.LastLogin,
+		EmailVerifiedAt: user.EmailVerifiedAt,
+		TOTPSecret:      user.TOTPSecret,
+		MFAEnabledAt:    user.MFAEnabledAt,
+		TOTPLastStep:    user.TOTPLastStep,
+	}
+}
+
+func stripOrg(org models.Organization) models.Organization {
+	return models.Organization{
+		ID:             org.ID,
+		Name:           org.Name,
+		Plan:           org.Plan,
+		CreatedAt:      org.CreatedAt,
+		RequireMFA:     org.RequireMFA,
+		JoinCode:       org.JoinCode,
+		AllowedDomains: org.AllowedDomains,
+	}
+}
+
+func stripCourse(course models.Course) models.Course {
+	return models.Course{
+		ID:            course.ID,
+		OrgID:         course.OrgID,
+		Code:          course.Code,
+		Title:         course.Title,
+		Description:   course.Description,
+		CreatedBy:     course.CreatedBy,
+		EnrollmentKey: course.EnrollmentKey,
+	}
+}
+
+func stripStudyPack(studyPack models.StudyPack) models.StudyPack {
+	studyPack.Material = models.Material{}
+	studyPack.Summary = nil
+	studyPack.Quizzes = nil
+	studyPack.Flashcards = nil
+	studyPack.Sessions = nil
+	return studyPack
+}
+
+type errDuplicate string
+
+func (e errDuplicate) Error() string {
+	return "duplicate value for " + string(e)
+}
+
+type memoryAudit struct {
+	s     *memoryStore
+	repos *Repositories
+}
+
+// Record runs fn against the store itself: unlike Postgres, the memory store
+// does not undo fn's writes when it fails, but the event is only appended
+// when it succeeds.
+func (r *memoryAudit) Record(event *models.AuditEvent, fn func(tx *Repositories) error) error {
+	if err := fn(r.repos); err != nil {
+		return err
+	}
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if event.ActorEmail == "" {
+		actor, ok := r.s.users[event.ActorID]
+		if !ok {
+			return ErrNotFound
+		}
+		event.ActorEmail = actor.Email
+	}
+	newID(&event.ID)
+	stamp(&event.CreatedAt)
+	r.s.auditEvents = append(r.s.auditEvents, *event)
+	return nil
+}
+
+func (r *memoryAudit) List(orgID uuid.UUID, filter AuditFilter) ([]models.AuditEvent, int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var events []models.AuditEvent
+	for i := len(r.s.auditEvents) - 1; i >= 0; i-- {
+		event := r.s.auditEvents[i]
+		switch {
+		case event.OrgID != orgID,
+			filter.Action != "" && event.Action != filter.Action,
+			filter.ActorID != nil && event.ActorID != *filter.ActorID,
+			filter.TargetType != "" && event.TargetType != filter.TargetType,
+			filter.TargetID != nil && event.TargetID != *filter.TargetID,
+			filter.Since != nil && event.CreatedAt.Before(*filter.Since),
+			filter.Until != nil && !event.CreatedAt.Before(*filter.Until):
+			continue
+		}
+		events = append(events, event)
+	}
+	total := int64(len(events))
+	if filter.Offset >= len(events) {
+		return nil, total, nil
+	}
+	events = events[filter.Offset:]
+	if filter.Limit > 0 && filter.Limit < len(events) {
+		events = events[:filter.Limit]
+	}
+	return events, total, nil
+}
+
+type memoryFlashcards struct{ s *memoryStore }
+
+func (r *memoryFlashcards) GetByID(id uuid.UUID) (*models.Flashcard, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	flashcard, ok := r.s.flashcards[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &flashcard, nil
+}
+
+func (r *memoryFlashcards) ListByStudyPack(studyPackID uuid.UUID) ([]models.Flashcard, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var flashcards []models.Flashcard
+	for _, flashcard := range r.s.flashcards {
+		if flashcard.StudyPackID == studyPackID {
+			flashcards = append(flashcards, flashcard)
+		}
+	}
+	sort.Slice(flashcards, func(i, j int) bool { return flashcards[i].ID.String() < flashcards[j].ID.String() })
+	return flashcards, nil
+}
+
+func (r *memoryFlashcards) CountInStudyPack(studyPackID uuid.UUID, ids []uuid.UUID) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var count int64
+	for _, id := range ids {
+		if flashcard, ok := r.s.flashcards[id]; ok && flashcard.StudyPackID == studyPackID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *memoryFlashcards) Review(userID, flashcardID uuid.UUID, grade srs.Grade, now time.Time) (*models.FlashcardSchedule, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return r.s.reviewFlashcard(userID, flashcardID, nil, grade, now), nil
+}
+
+func (r *memoryFlashcards) CreateSession(session *models.FlashcardSession, grades map[uuid.UUID]srs.Grade, event *models.ProgressEvent, now time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	newID(&session.ID)
+	stamp(&session.CreatedAt)
+	stored := *session
+	stored.StudyPack = models.StudyPack{}
+	stored.User = models.User{}
+	r.s.sessions[session.ID] = stored
+	for flashcardID, grade := range grades {
+		r.s.reviewFlashcard(session.UserID, flashcardID, &session.ID, grade, now)
+	}
+
+	if courseID := r.s.studyPackCourse(session.StudyPackID); courseID != uuid.Nil {
+		event.CourseID = courseID.String()
+	}
+	newID(&event.ID)
+	stamp(&event.CreatedAt)
+	r.s.progress = append(r.s.progress, *event)
+	return nil
+}
+
+func (s *memoryStore) reviewFlashcard(userID, flashcardID uuid.UUID, sessionID *uuid.UUID, grade srs.Grade, now time.Time) *models.FlashcardSchedule {
+	schedule := newSchedule(userID, flashcardID, now)
+	for _, existing := range s.schedules {
+		if existing.FlashcardID == flashcardID && existing.UserID == userID {
+			schedule = existing
+			break
+		}
+	}
+	newID(&schedule.ID)
+	stamp(&schedule.CreatedAt)
+	schedule.UpdatedAt = now
+
+	review := applyReview(&schedule, sessionID, grade, now)
+	review.ID = uuid.New()
+	s.schedules[schedule.ID] = schedule
+	s.reviews = append(s.reviews, review)
+	return &schedule
+}
+
+func (r *memoryFlashcards) ListSessions(userID uuid.UUID, limit int) ([]models.FlashcardSession, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var sessions []models.FlashcardSession
+	for _, session := range r.s.sessions {
+		if session.UserID == userID {
+			session.StudyPack = stripStudyPack(r.s.studyPacks[session.StudyPackID])
+			session.StudyPack.Material = r.s.materials[session.StudyPack.MaterialID]
+			sessions = append(sessions, session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].CreatedAt.After(sessions[j].CreatedAt) })
+	if limit < len(sessions) {
+		sessions = sessions[:limit]
+	}
+	return sessions, nil
+}
+
+// studyable reports whether the flashcard is in a READY study pack of a
+// course the user is enrolled in, or of the course when given.
+func (s *memoryStore) studyable(flashcard models.Flashcard, userID uuid.UUID, courseID *uuid.UUID) bool {
+	if s.studyPacks[flashcard.StudyPackID].Status != "READY" {
+		return false
+	}
+	cardCourse := s.studyPackCourse(flashcard.StudyPackID)
+	if courseID != nil && cardCourse != *courseID {
+		return false
+	}
+	for _, enrollment := range s.enrollments {
+		if enrollment.UserID == userID && enrollment.CourseID == cardCourse {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *memoryFlashcards) ListDue(userID uuid.UUID, courseID *uuid.UUID, now time.Time, limit int) ([]models.FlashcardSchedule, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var schedules []models.FlashcardSchedule
+	for _, schedule := range r.s.schedules {
+		flashcard, ok := r.s.flashcards[schedule.FlashcardID]
+		if schedule.UserID != userID || schedule.DueAt.After(now) || !ok || !r.s.studyable(flashcard, userID, courseID) {
+			continue
+		}
+		schedule.Flashcard = flashcard
+		schedules = append(schedules, schedule)
+	}
+	sort.Slice(schedules, func(i, j int) bool { return schedules[i].DueAt.Before(schedules[j].DueAt) })
+	if limit < len(schedules) {
+		schedules = schedules[:limit]
+	}
+	return schedules, nil
+}
+
+func (r *memoryFlashcards) ListNew(userID uuid.UUID, courseID *uuid.UUID, limit int) ([]models.Flashcard, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	reviewed := make(map[uuid.UUID]bool)
+	for _, schedule := range r.s.schedules {
+		if schedule.UserID == userID {
+			reviewed[schedule.FlashcardID] = true
+		}
+	}
+	var flashcards []models.Flashcard
+	for _, flashcard := range r.s.flashcards {
+		if !reviewed[flashcard.ID] && r.s.studyable(flashcard, userID, courseID) {
+			flashcards = append(flashcards, flashcard)
+		}
+	}
+	sort.Slice(flashcards, func(i, j int) bool {
+		if flashcards[i].StudyPackID != flashcards[j].StudyPackID {
+			return flashcards[i].StudyPackID.String() < flashcards[j].StudyPackID.String()
+		}
+		return flashcards[i].ID.String() < flashcards[j].ID.String()
+	})
+	if limit < len(flashcards) {
+		flashcards = flashcards[:limit]
+	}
+	return flashcards, nil
+}
+
+type memoryConversations struct{ s *memoryStore }
+
+func (r *memoryConversations) GetOwned(id, userID uuid.UUID) (*models.Conversation, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	conversation, ok := r.s.conversations[id]
+	if !ok || conversation.UserID != userID {
+		return nil, ErrNotFound
+	}
+	return &conversation, nil
+}
+
+func (r *memoryConversations) ListByUser(userID uuid.UUID, courseID *uuid.UUID) ([]models.Conversation, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var conversations []models.Conversation
+	for _, conversation := range r.s.conversations {
+		if conversation.UserID == userID && (courseID == nil || conversation.CourseID == *courseID) {
+			conversation.Course = r.s.courses[conversation.CourseID]
+			conversations = append(conversations, conversation)
+		}
+	}
+	sort.Slice(conversations, func(i, j int) bool {
+		return conversations[i].LastMessageAt.After(conversations[j].LastMessageAt)
+	})
+	return conversations, nil
+}
+
+func (r *memoryConversations) ListMessages(conversationID uuid.UUID, offset int) ([]models.Message, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var messages []models.Message
+	for _, message := range r.s.messages {
+		if message.ConversationID == conversationID {
+			messages = append(messages, message)
+		}
+	}
+	sort.SliceStable(messages, func(i, j int) bool { return messages[i].CreatedAt.Before(messages[j].CreatedAt) })
+	if offset >= len(messages) {
+		return nil, nil
+	}
+	return messages[offset:], nil
+}
+
+func (r *memoryConversations) AppendMessages(conversation *models.Conversation, messages []models.Message, now time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	newID(&conversation.ID)
+	stamp(&conversation.CreatedAt)
+	conversation.LastMessageAt = now
+	stored := *conversation
+	stored.User = models.User{}
+	stored.Course = models.Course{}
+	stored.Messages = nil
+	r.s.conversations[conversation.ID] = stored
+
+	for i := range messages {
+		messages[i].ConversationID = conversation.ID
+		newID(&messages[i].ID)
+		stamp(&messages[i].CreatedAt)
+		r.s.messages = append(r.s.messages, messages[i])
+	}
+	return nil
+}
+
+func (r *memoryConversations) SaveSummary(id uuid.UUID, summary string, summarizedCount int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	conversation, ok := r.s.conversations[id]
+	if !ok {
+		return ErrNotFound
+	}
+	conversation.Summary = &summary
+	conversation.SummarizedCount = summarizedCount
+	r.s.conversations[id] = conversation
+	return nil
+}
+
+func (r *memoryConversations) Delete(id uuid.UUID) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	r.s.deleteConversation(id)
+	return nil
+}
+
+func (s *memoryStore) deleteConversation(id uuid.UUID) {
+	messages := s.messages[:0]
+	for _, message := range s.messages {
+		if message.ConversationID != id {
+			messages = append(messages, message)
+		}
+	}
+	s.messages = messages
+	delete(s.conversations, id)
+}
+
+// memoryImports records jobs without running them. The fake keeps the text
+// of a transcript but not its timed segments.
+type memoryImports struct{ s *memoryStore }
+
+func (r *memoryImports) QueueMaterial(material *models.Material, createdBy uuid.UUID, language string, captions *transcript.Transcript) (*models.StudyPack, *models.Job, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	newID(&material.ID)
+	stored := *material
+	stored.Module = models.Module{}
+	stored.StudyPacks = nil
+	r.s.materials[material.ID] = stored
+
+	studyPack := models.StudyPack{
+		ID:         uuid.New(),
+		MaterialID: material.ID,
+		CreatedBy:  createdBy.String(),
+		Status:     "QUEUED",
+		CreatedAt:  time.Now(),
+	}
+	r.s.studyPacks[studyPack.ID] = studyPack
+	job := r.s.enqueue(pipeline.JobGenerateStudyPack, pipeline.StudyPackPayload{
+		StudyPackID: studyPack.ID,
+		MaterialID:  material.ID,
+		Language:    language,
+	}, &material.ID)
+	return &studyPack, job, nil
+}
+
+func (r *memoryImports) AttachTranscript(material *models.Material, t *transcript.Transcript) (*models.Job, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	stored, ok := r.s.materials[material.ID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	text := t.Text()
+	stored.TranscriptText = &text
+	r.s.materials[material.ID] = stored
+	material.TranscriptText = &text
+
+	packs := r.s.studyPacksOf(material.ID)
+	if len(packs) == 0 || packs[len(packs)-1].Status != "FAILED" {
+		return nil, nil
+	}
+	studyPack := packs[len(packs)-1]
+	studyPack.Status = "QUEUED"
+	studyPack.ErrorMessage = nil
+	r.s.studyPacks[studyPack.ID] = stripStudyPack(studyPack)
+	return r.s.enqueue(pipeline.JobGenerateStudyPack, pipeline.StudyPackPayload{StudyPackID: studyPack.ID, MaterialID: material.ID}, &material.ID), nil
+}
+
+func (r *memoryImports) GetLatestJob(materialID uuid.UUID) (*models.Job, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var latest *models.Job
+	for _, job := range r.s.jobs {
+		if job.MaterialID != nil && *job.MaterialID == materialID && (latest == nil || !job.CreatedAt.Before(latest.CreatedAt)) {
+			job := job
+			latest = &job
+		}
+	}
+	if latest == nil {
+		return nil, ErrNotFound
+	}
+	return latest, nil
+}
+
+func (r *memoryImports) CreateBatch(batch *models.ImportBatch) (*models.Job, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	newID(&batch.ID)
+	stamp(&batch.CreatedAt)
+	stored := *batch
+	stored.Course = models.Course{}
+	stored.Module = nil
+	stored.Creator = models.User{}
+	stored.Materials = nil
+	r.s.batches[batch.ID] = stored
+	return r.s.enqueue(pipeline.JobImportPlaylist, pipeline.PlaylistPayload{BatchID: batch.ID}, nil), nil
+}
+
+func (r *memoryImports) GetBatch(id uuid.UUID) (*models.ImportBatch, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	batch, ok := r.s.batches[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	batch.Course = r.s.courses[batch.CourseID]
+	return &batch, nil
+}
+
+func (r *memoryImports) ListBatchMaterials(batchID uuid.UUID) ([]models.Material, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var materials []models.Material
+	for _, material := range r.s.materials {
+		if material.BatchID == nil || *material.BatchID != batchID {
+			continue
+		}
+		packs := r.s.studyPacksOf(material.ID)
+		for i := len(packs) - 1; i >= 0; i-- {
+			material.StudyPacks = append(material.StudyPacks, stripStudyPack(packs[i]))
+		}
+		materials = append(materials, material)
+	}
+	sort.Slice(materials, func(i, j int) bool { return materials[i].Title < materials[j].Title })
+	return materials, nil
+}
+
+// enqueue records a job as jobs.Enqueue would.
+func (s *memoryStore) enqueue(jobType string, payload interface{}, materialID *uuid.UUID) *models.Job {
+	payloadJSON, _ := json.Marshal(payload)
+	now := time.Now()
+	job := models.Job{
+		ID:          uuid.New(),
+		Type:        jobType,
+		Payload:     string(payloadJSON),
+		Status:      jobs.StatusQueued,
+		MaxAttempts: jobs.DefaultMaxAttempts,
+		RunAt:       now,
+		MaterialID:  materialID,
+		CreatedAt:   now,
+	}
+	s.jobs[job.ID] = job
+	return &job
+}
@@ -0,0 +1,318 @@
+package repository
+
+import (
+	"myway-backend/internal/models"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type orgRepo struct {
+	db *gorm.DB
+}
+
+func (r *orgRepo) GetByID(id uuid.UUID) (*models.Organization, error) {
+	var org models.Organization
+	if err := r.db.First(&org, id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &org, nil
+}
+
+func (r *orgRepo) CreateWithOwner(org *models.Organization, ownerID uuid.UUID) (*models.OrgMembership, error) {
+	membership := &models.OrgMembership{
+		UserID: ownerID,
+		Role:   "ORGANIZER",
+		Status: MembershipActive,
+	}
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(org).Error; err != nil {
+			return err
+		}
+		membership.OrgID = org.ID
+		return tx.Create(membership).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return membership, nil
+}
+
+func (r *orgRepo) Update(org *models.Organization) error {
+	return r.db.Omit(clause.Associations).Save(org).Error
+}
+
+func (r *orgRepo) Delete(id uuid.UUID) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var courseIDs []uuid.UUID
+		if err := tx.Model(&models.Course{}).Where("org_id = ?", id).Pluck("id", &courseIDs).Error; err != nil {
+			return err
+		}
+		if err := deleteCourses(tx, courseIDs); err != nil {
+			return err
+		}
+
+		if err := tx.Where("org_id = ?", id).Delete(&models.OrgMembership{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("org_id = ?", id).Delete(&models.APIToken{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("org_id = ?", id).Delete(&models.OrgInvitation{}).Error; err != nil {
+			return err
+		}
+		if err := deleteIdentityProvider(tx, id); err != nil {
+			return err
+		}
+		if err := tx.Where("org_id = ?", id).Delete(&models.DailyOrgMetric{}).Error; err != nil {
+			return err
+		}
+
+		result := tx.Where("id = ?", id).Delete(&models.Organization{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
+}
+
+func (r *orgRepo) GetActiveMembership(userID, orgID uuid.UUID) (*models.OrgMembership, error) {
+	var membership models.OrgMembership
+	if err := r.db.
+		Preload("Organization").
+		Where("user_id = ? AND org_id = ? AND status = ?", userID, orgID, MembershipActive).
+		First(&membership).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &membership, nil
+}
+
+func (r *orgRepo) GetMembership(userID, orgID uuid.UUID) (*models.OrgMembership, error) {
+	var membership models.OrgMembership
+	if err := r.db.Where("user_id = ? AND org_id = ?", userID, orgID).First(&membership).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &membership, nil
+}
+
+func (r *orgRepo) ListMemberships(userID uuid.UUID) ([]models.OrgMembership, error) {
+	var memberships []models.OrgMembership
+	err := r.db.Preload("Organization").Where("user_id = ?", userID).Find(&memberships).Error
+	return memberships, err
+}
+
+func (r *orgRepo) SaveMembership(membership *models.OrgMembership) error {
+	if membership.ID == uuid.Nil {
+		return r.db.Create(membership).Error
+	}
+	return r.SaveMemberships(membership)
+}
+
+func (r *orgRepo) SaveMemberships(memberships ...*models.OrgMembership) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for _, membership := range memberships {
+			if !activeOrganizer(membership) {
+				if err := keepOrganizer(tx, membership.OrgID, membership.UserID); err != nil {
+					return err
+				}
+			}
+			if err := tx.Omit(clause.Associations).Save(membership).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// activeOrganizer reports whether the membership counts as an organizer of
+// its organization.
+func activeOrganizer(membership *models.OrgMembership) bool {
+	return membership.Role == "ORGANIZER" && membership.Status == MembershipActive
+}
+
+// keepOrganizer returns ErrLastOrganizer when the user is the organization's
+// only active organizer, before their membership changes. The organizer rows
+// stay locked until the transaction ends, so two organizers demoting each
+// other at once cannot both succeed.
+func keepOrganizer(tx *gorm.DB, orgID, userID uuid.UUID) error {
+	var organizers []uuid.UUID
+	if err := tx.Model(&models.OrgMembership{}).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("org_id = ? AND role = ? AND status = ?", orgID, "ORGANIZER", MembershipActive).
+		Pluck("user_id", &organizers).Error; err != nil {
+		return err
+	}
+	if len(organizers) == 1 && organizers[0] == userID {
+		return ErrLastOrganizer
+	}
+	return nil
+}
+
+func (r *orgRepo) ListMembers(orgID uuid.UUID, filter MemberFilter) ([]models.OrgMembership, int64, error) {
+	query := r.db.Model(&models.OrgMembership{}).
+		Joins("JOIN users ON users.id = org_memberships.user_id").
+		Where("org_memberships.org_id = ?", orgID)
+	if filter.Search != "" {
+		pattern := "%" + escapeLike(filter.Search) + "%"
+		query = query.Where("users.name ILIKE ? OR users.email ILIKE ?", pattern, pattern)
+	}
+	if filter.Role != "" {
+		query = query.Where("org_memberships.role = ?", filter.Role)
+	}
+	if filter.Status != "" {
+		query = query.Where("org_memberships.status = ?", filter.Status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var memberships []models.OrgMembership
+	err := query.
+		Preload("User").
+		Order("users.name ASC, users.email ASC").
+		Offset(filter.Offset).
+		Limit(filter.Limit).
+		Find(&memberships).Error
+	return memberships, total, err
+}
+
+func (r *orgRepo) RemoveMember(orgID, userID uuid.UUID, retainData bool) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := keepOrganizer(tx, orgID, userID); err != nil {
+			return err
+		}
+		result := tx.Where("org_id = ? AND user_id = ?", orgID, userID).Delete(&models.OrgMembership{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+
+		if err := tx.Model(&models.APIToken{}).
+			Where("org_id = ? AND user_id = ? AND revoked_at IS NULL", orgID, userID).
+			Update("revoked_at", time.Now()).Error; err != nil {
+			return err
+		}
+		courses := tx.Model(&models.Course{}).Select("id").Where("org_id = ?", orgID)
+		if err := tx.Where("user_id = ? AND course_id IN (?)", userID, courses).Delete(&models.Enrollment{}).Error; err != nil {
+			return err
+		}
+		if retainData {
+			return nil
+		}
+		return deleteMemberData(tx, orgID, userID)
+	})
+}
+
+// escapeLike makes user input match literally in a LIKE pattern.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// deleteMemberData removes the records of a user's own learning in the
+// organization's courses. Discussion posts stay, since others reply to them.
+func deleteMemberData(tx *gorm.DB, orgID, userID uuid.UUID) error {
+	courses := tx.Model(&models.Course{}).Select("id").Where("org_id = ?", orgID)
+	modules := tx.Model(&models.Module{}).Select("id").Where("course_id IN (?)", courses)
+	materials := tx.Model(&models.Material{}).Select("id").Where("module_id IN (?)", modules)
+	studyPacks := tx.Model(&models.StudyPack{}).Select("id").Where("material_id IN (?)", materials)
+	quizzes := tx.Model(&models.Quiz{}).Select("id").Where("study_pack_id IN (?)", studyPacks)
+	flashcards := tx.Model(&models.Flashcard{}).Select("id").Where("study_pack_id IN (?)", studyPacks)
+	assignments := tx.Model(&models.Assignment{}).Select("id").Where("course_id IN (?)", courses)
+	conversations := tx.Model(&models.Conversation{}).Select("id").Where("user_id = ? AND course_id IN (?)", userID, courses)
+	// ProgressEvent.CourseID is text
+	courseKeys := tx.Model(&models.Course{}).Select("id::text").Where("org_id = ?", orgID)
+
+	steps := []struct {
+		where string
+		args  []interface{}
+		model interface{}
+	}{
+		{"user_id = ? AND quiz_id IN (?)", []interface{}{userID, quizzes}, &models.QuizAttempt{}},
+		{"user_id = ? AND flashcard_id IN (?)", []interface{}{userID, flashcards}, &models.FlashcardReview{}},
+		{"user_id = ? AND flashcard_id IN (?)", []interface{}{userID, flashcards}, &models.FlashcardSchedule{}},
+		{"user_id = ? AND study_pack_id IN (?)", []interface{}{userID, studyPacks}, &models.FlashcardSession{}},
+		{"user_id = ? AND assignment_id IN (?)", []interface{}{userID, assignments}, &models.Submission{}},
+		{"user_id = ? AND course_id IN (?)", []interface{}{userID, courseKeys}, &models.ProgressEvent{}},
+		{"conversation_id IN (?)", []interface{}{conversations}, &models.Message{}},
+		{"user_id = ? AND course_id IN (?)", []interface{}{userID, courses}, &models.Conversation{}},
+	}
+	for _, step := range steps {
+		if err := tx.Where(step.where, step.args...).Delete(step.model).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *orgRepo) SaveInvitation(invitation *models.OrgInvitation) error {
+	if invitation.ID == uuid.Nil {
+		return r.db.Omit(clause.Associations).Create(invitation).Error
+	}
+	return r.db.Omit(clause.Associations).Save(invitation).Error
+}
+
+func (r *orgRepo) GetInvitation(id uuid.UUID) (*models.OrgInvitation, error) {
+	var invitation models.OrgInvitation
+	if err := r.db.First(&invitation, id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &invitation, nil
+}
+
+func (r *orgRepo) GetInvitationByHash(tokenHash string) (*models.OrgInvitation, error) {
+	var invitation models.OrgInvitation
+	if err := r.db.Preload("Organization").Where("token_hash = ?", tokenHash).First(&invitation).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &invitation, nil
+}
+
+func (r *orgRepo) GetOpenInvitation(orgID uuid.UUID, email string) (*models.OrgInvitation, error) {
+	var invitation models.OrgInvitation
+	if err := r.db.
+		Where("org_id = ? AND email = ? AND accepted_at IS NULL AND revoked_at IS NULL", orgID, email).
+		Order("created_at DESC").
+		First(&invitation).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &invitation, nil
+}
+
+func (r *orgRepo) ListOpenInvitations(orgID uuid.UUID) ([]models.OrgInvitation, error) {
+	var invitations []models.OrgInvitation
+	err := r.db.
+		Where("org_id = ? AND accepted_at IS NULL AND revoked_at IS NULL", orgID).
+		Order("created_at DESC").
+		Find(&invitations).Error
+	return invitations, err
+}
+
+func (r *orgRepo) AcceptInvitation(invitation *models.OrgInvitation, membership *models.OrgMembership, now time.Time) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.OrgInvitation{}).
+			Where("id = ? AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?", invitation.ID, now).
+			Updates(map[string]interface{}{"accepted_at": now, "accepted_by": membership.UserID})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrTokenUsed
+		}
+		invitation.AcceptedAt = &now
+		invitation.AcceptedBy = &membership.UserID
+
+		if membership.ID == uuid.Nil {
+			return tx.Create(membership).Error
+		}
+		return tx.Omit(clause.Associations).Save(membership).Error
+	})
+}
@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// NewPostgres returns the GORM-backed repositories.
+func NewPostgres(db *gorm.DB) *Repositories {
+	return &Repositories{
+		Users:         &userRepo{db: db},
+		Orgs:          &orgRepo{db: db},
+		Courses:       &courseRepo{db: db},
+		StudyPacks:    &studyPackRepo{db: db},
+		Flashcards:    &flashcardRepo{db: db},
+		Conversations: &conversationRepo{db: db},
+		Imports:       &importRepo{db: db},
+		Assessments:   &assessmentRepo{db: db},
+		Files:         &fileRepo{db: db},
+		APITokens:     &apiTokenRepo{db: db},
+		Audit:         &auditRepo{db: db},
+		SSO:           &ssoRepo{db: db},
+	}
+}
+
+// translate maps GORM's not-found error to ErrNotFound.
+func translate(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
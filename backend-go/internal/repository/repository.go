@@ -0,0 +1,398 @@
+// Package repository hides persistence behind one interface per aggregate so
+// handlers can run against Postgres or the in-memory fake. The discussion,
+// progress and analytics handlers still query the database directly.
+package repository
+
+import (
+	"errors"
+	"myway-backend/internal/models"
+	"myway-backend/internal/srs"
+	"myway-backend/internal/studypack"
+	"myway-backend/internal/transcript"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrNotFound = errors.New("record not found")
+
+// ErrTokenUsed is returned when a refresh token is rotated a second time or
+// an authenticator code is presented again.
+var ErrTokenUsed = errors.New("refresh token already used")
+
+// ErrLastOrganizer is returned when a change would leave an organization
+// without an active organizer.
+var ErrLastOrganizer = errors.New("organization needs an active organizer")
+
+// Statuses of an OrgMembership.
+const (
+	MembershipActive = "Active"
+	// MembershipSuspended keeps the member's role and data but denies access
+	// to the organization until an organizer reactivates them.
+	MembershipSuspended = "Suspended"
+)
+
+// MemberFilter narrows ListMembers. Search matches names and emails; empty
+// fields match everything.
+type MemberFilter struct {
+	Search string
+	Role   string
+	Status string
+	Offset int
+	Limit  int
+}
+
+// Platform roles held on User.Role. Sign-up always creates a STUDENT; the
+// other roles are granted by an admin. ORGANIZER and ADMIN may create
+// organizations.
+const (
+	RoleStudent   = "STUDENT"
+	RoleTeacher   = "TEACHER"
+	RoleOrganizer = "ORGANIZER"
+	RoleAdmin     = "ADMIN"
+)
+
+// APIScopes are the scopes an API token can be granted. A read scope covers
+// the resource's GET endpoints, a write scope the endpoints that change it.
+var APIScopes = []string{
+	"orgs:read", "orgs:write",
+	"courses:read", "courses:write",
+	"roster:read", "roster:write",
+	"grades:read", "grades:write",
+	"discussions:read", "discussions:write",
+	"content:read", "content:write",
+	"analytics:read",
+	"audit:read",
+}
+
+// MFARequired reports whether the membership may only be used by a session
+// that passed multi-factor authentication: its organization requires MFA and
+// the member teaches or organizes there.
+func MFARequired(membership *models.OrgMembership) bool {
+	return membership.Organization.RequireMFA && (membership.Role == RoleTeacher || membership.Role == RoleOrganizer)
+}
+
+// Suspended reports whether the user's membership of the organization exists
+// but was suspended, to tell them apart from non-members.
+func Suspended(orgs OrgRepository, userID, orgID uuid.UUID) bool {
+	membership, err := orgs.GetMembership(userID, orgID)
+	return err == nil && membership.Status == MembershipSuspended
+}
+
+// Course roles held through an enrollment.
+const (
+	EnrollmentStudent = "STUDENT"
+	EnrollmentTA      = "TA"
+	EnrollmentTeacher = "TEACHER"
+)
+
+type UserRepository interface {
+	Create(user *models.User) error
+	GetByID(id uuid.UUID) (*models.User, error)
+	// GetByEmail matches the address case-insensitively.
+	GetByEmail(email string) (*models.User, error)
+	ListByIDs(ids []uuid.UUID) ([]models.User, error)
+	// ListByRoles returns the users holding any of the platform roles,
+	// oldest account first.
+	ListByRoles(roles []string) ([]models.User, error)
+	Update(user *models.User) error
+
+	CreateRefreshToken(token *models.RefreshToken) error
+	// GetRefreshToken returns the token stored under the hash whether or not
+	// it is still usable, so that reuse can be detected.
+	GetRefreshToken(tokenHash string) (*models.RefreshToken, error)
+	// RotateRefreshToken marks used as used and stores next in its family.
+	// It returns ErrTokenUsed when used was already used or revoked.
+	RotateRefreshToken(used, next *models.RefreshToken, now time.Time) error
+	RevokeRefreshTokenFamily(familyID uuid.UUID, now time.Time) error
+	// RevokeUserRefreshTokens signs the user out on every device.
+	RevokeUserRefreshTokens(userID uuid.UUID, now time.Time) error
+	// DeleteExpiredRefreshTokens removes tokens that expired before the
+	// given time and returns how many were removed.
+	DeleteExpiredRefreshTokens(before time.Time) (int64, error)
+
+	CreateUserToken(token *models.UserToken) error
+	// CountUserTokens counts the tokens issued for a purpose since the given
+	// time, for rate limiting.
+	CountUserTokens(userID uuid.UUID, purpose string, since time.Time) (int64, error)
+	// ConsumeUserToken marks an unused, unexpired token used together with
+	// every other outstanding token of the same user and purpose.
+	ConsumeUserToken(tokenHash, purpose string, now time.Time) (*models.UserToken, error)
+	DeleteExpiredUserTokens(before time.Time) (int64, error)
+
+	// UseTOTPStep records the time step of an accepted authenticator code.
+	// It returns ErrTokenUsed unless the step is later than the last one.
+	UseTOTPStep(userID uuid.UUID, step int64) error
+	// ReplaceRecoveryCodes swaps the user's recovery codes for new hashes;
+	// no hashes removes them.
+	ReplaceRecoveryCodes(userID uuid.UUID, codeHashes []string) error
+	// UseRecoveryCode marks an unused code used, or returns ErrNotFound.
+	UseRecoveryCode(userID uuid.UUID, codeHash string, now time.Time) error
+	CountRecoveryCodes(userID uuid.UUID) (int64, error)
+}
+
+type OrgRepository interface {
+	GetByID(id uuid.UUID) (*models.Organization, error)
+	// CreateWithOwner creates the organization and its first ORGANIZER
+	// membership together.
+	CreateWithOwner(org *models.Organization, ownerID uuid.UUID) (*models.OrgMembership, error)
+	Update(org *models.Organization) error
+	// Delete removes the organization with its courses and memberships.
+	Delete(id uuid.UUID) error
+
+	// GetActiveMembership is the access check used by handlers and
+	// middleware; the Organization is preloaded.
+	GetActiveMembership(userID, orgID uuid.UUID) (*models.OrgMembership, error)
+	// GetMembership returns the membership whatever its status.
+	GetMembership(userID, orgID uuid.UUID) (*models.OrgMembership, error)
+	ListMemberships(userID uuid.UUID) ([]models.OrgMembership, error)
+	// SaveMembership creates the membership if its ID is nil, otherwise
+	// saves it. It returns ErrLastOrganizer when the membership was the
+	// organization's last active organizer and no longer is.
+	SaveMembership(membership *models.OrgMembership) error
+	// SaveMemberships saves existing memberships together, in order, with
+	// the same check as SaveMembership.
+	SaveMemberships(memberships ...*models.OrgMembership) error
+	// ListMembers returns a page of the organization's members ordered by
+	// name, with each user preloaded, and how many match the filter.
+	ListMembers(orgID uuid.UUID, filter MemberFilter) ([]models.OrgMembership, int64, error)
+	// RemoveMember deletes the membership with the user's enrollments in the
+	// organization's courses, and revokes their API keys for it. Without
+	// retainData their submissions, quiz and flashcard history, progress
+	// events and tutor conversations in those courses are deleted too. It
+	// returns ErrLastOrganizer for the last active organizer.
+	RemoveMember(orgID, userID uuid.UUID, retainData bool) error
+
+	// SaveInvitation creates the invitation if its ID is nil, otherwise
+	// saves it.
+	SaveInvitation(invitation *models.OrgInvitation) error
+	GetInvitation(id uuid.UUID) (*models.OrgInvitation, error)
+	// GetInvitationByHash preloads the organization.
+	GetInvitationByHash(tokenHash string) (*models.OrgInvitation, error)
+	// GetOpenInvitation returns the organization's invitation for the email
+	// that was neither accepted nor revoked, expired or not.
+	GetOpenInvitation(orgID uuid.UUID, email string) (*models.OrgInvitation, error)
+	// ListOpenInvitations returns the invitations neither accepted nor
+	// revoked, newest first.
+	ListOpenInvitations(orgID uuid.UUID) ([]models.OrgInvitation, error)
+	// AcceptInvitation marks a pending invitation accepted by the user and
+	// saves the membership with it. It returns ErrTokenUsed when the
+	// invitation was accepted, revoked or expired in the meantime.
+	AcceptInvitation(invitation *models.OrgInvitation, membership *models.OrgMembership, now time.Time) error
+}
+
+type CourseRepository interface {
+	// Create also enrolls the creator as the course TEACHER.
+	Create(course *models.Course) error
+	GetByID(id uuid.UUID) (*models.Course, error)
+	Update(course *models.Course) error
+	// GetWithContent preloads modules, materials, study packs and assignments.
+	GetWithContent(id uuid.UUID) (*models.Course, error)
+	ListByOrg(orgID uuid.UUID) ([]models.Course, error)
+	// Delete removes the course with everything that belongs to it.
+	Delete(id uuid.UUID) error
+
+	CreateModule(module *models.Module) error
+	GetModule(id uuid.UUID) (*models.Module, error)
+	GetModuleByTitle(courseID uuid.UUID, title string) (*models.Module, error)
+	// GetModuleWithContent preloads the course, materials and study packs.
+	GetModuleWithContent(id uuid.UUID) (*models.Module, error)
+	ListModules(courseID uuid.UUID) ([]models.Module, error)
+	UpdateModule(module *models.Module) error
+	DeleteModule(id uuid.UUID) error
+
+	CreateMaterial(material *models.Material) error
+	GetMaterial(id uuid.UUID) (*models.Material, error)
+
+	GetEnrollment(courseID, userID uuid.UUID) (*models.Enrollment, error)
+	// ListEnrollments returns the roster with each user preloaded.
+	ListEnrollments(courseID uuid.UUID) ([]models.Enrollment, error)
+	CountEnrollments(courseID uuid.UUID, role string) (int64, error)
+	// SaveEnrollment creates the enrollment if its ID is nil, otherwise
+	// saves its role.
+	SaveEnrollment(enrollment *models.Enrollment) error
+	DeleteEnrollment(courseID, userID uuid.UUID) error
+}
+
+type StudyPackRepository interface {
+	Create(studyPack *models.StudyPack) error
+	// GetByID returns the study pack without its content.
+	GetByID(id uuid.UUID) (*models.StudyPack, error)
+	// GetLatestByMaterial preloads the summary, quizzes, flashcards and material.
+	GetLatestByMaterial(materialID uuid.UUID) (*models.StudyPack, error)
+	SaveSummary(studyPackID uuid.UUID, content string) error
+	Publish(id uuid.UUID, approvedBy string, at time.Time) error
+	// SaveDraft replaces the generated content and returns the pack to
+	// instructor review.
+	SaveDraft(id uuid.UUID, content *studypack.Content) error
+}
+
+// FlashcardRepository stores the cards of study packs and each learner's
+// spaced-repetition schedule, review log and study sessions.
+type FlashcardRepository interface {
+	GetByID(id uuid.UUID) (*models.Flashcard, error)
+	ListByStudyPack(studyPackID uuid.UUID) ([]models.Flashcard, error)
+	// CountInStudyPack counts how many of the cards belong to the study pack.
+	CountInStudyPack(studyPackID uuid.UUID, ids []uuid.UUID) (int64, error)
+
+	// Review applies the grade to the user's schedule for the card, starting
+	// one on the first review, and logs the review. Concurrent reviews of a
+	// card are applied one after the other.
+	Review(userID, flashcardID uuid.UUID, grade srs.Grade, now time.Time) (*models.FlashcardSchedule, error)
+	// CreateSession saves the session with a review of each graded card and
+	// the progress event, whose CourseID is set to the study pack's course.
+	CreateSession(session *models.FlashcardSession, grades map[uuid.UUID]srs.Grade, event *models.ProgressEvent, now time.Time) error
+	// ListSessions returns the user's latest sessions, newest first, with
+	// the study pack and its material preloaded.
+	ListSessions(userID uuid.UUID, limit int) ([]models.FlashcardSession, error)
+
+	// ListDue returns the user's schedules due by now, soonest first, with
+	// the card preloaded. Only cards of READY study packs in courses the
+	// user is enrolled in count, or in the one course when given.
+	ListDue(userID uuid.UUID, courseID *uuid.UUID, now time.Time, limit int) ([]models.FlashcardSchedule, error)
+	// ListNew returns cards of the same study packs the user has never
+	// reviewed, by study pack.
+	ListNew(userID uuid.UUID, courseID *uuid.UUID, limit int) ([]models.Flashcard, error)
+}
+
+// ConversationRepository stores the tutor conversations of students with
+// their messages.
+type ConversationRepository interface {
+	// GetOwned returns the conversation if it belongs to the user.
+	GetOwned(id, userID uuid.UUID) (*models.Conversation, error)
+	// ListByUser returns the user's conversations, in one course when
+	// given, most recently active first, with the course preloaded.
+	ListByUser(userID uuid.UUID, courseID *uuid.UUID) ([]models.Conversation, error)
+	// ListMessages returns the conversation's messages oldest first,
+	// skipping the first offset.
+	ListMessages(conversationID uuid.UUID, offset int) ([]models.Message, error)
+	// AppendMessages adds the messages to the conversation, creating it
+	// when its ID is nil, and sets LastMessageAt to now.
+	AppendMessages(conversation *models.Conversation, messages []models.Message, now time.Time) error
+	// SaveSummary records the rolling summary of the first summarizedCount
+	// messages.
+	SaveSummary(id uuid.UUID, summary string, summarizedCount int) error
+	// Delete removes the conversation with its messages.
+	Delete(id uuid.UUID) error
+}
+
+// ImportRepository queues imported materials for study pack generation and
+// keeps the batches of playlist imports.
+type ImportRepository interface {
+	// QueueMaterial creates the material with a QUEUED study pack and the
+	// job that generates it, and stores captions, when given, as its timed
+	// transcript, all at once so a material is never left without a job.
+	QueueMaterial(material *models.Material, createdBy uuid.UUID, language string, captions *transcript.Transcript) (*models.StudyPack, *models.Job, error)
+	// AttachTranscript replaces the material's timed transcript and text.
+	// When the latest study pack of the material failed it is queued again
+	// and the new job returned; otherwise the job is nil.
+	AttachTranscript(material *models.Material, t *transcript.Transcript) (*models.Job, error)
+	// GetLatestJob returns the newest job of the material.
+	GetLatestJob(materialID uuid.UUID) (*models.Job, error)
+
+	// CreateBatch saves the batch with the job that lists its videos.
+	CreateBatch(batch *models.ImportBatch) (*models.Job, error)
+	// GetBatch preloads the course.
+	GetBatch(id uuid.UUID) (*models.ImportBatch, error)
+	// ListBatchMaterials returns the batch's materials by title, each with
+	// its study packs newest first.
+	ListBatchMaterials(batchID uuid.UUID) ([]models.Material, error)
+}
+
+type AssessmentRepository interface {
+	CreateAssignment(assignment *models.Assignment) error
+	// GetAssignment preloads the course and submissions.
+	GetAssignment(id uuid.UUID) (*models.Assignment, error)
+	ListActiveAssignments(courseID uuid.UUID) ([]models.Assignment, error)
+
+	CountSubmissions(assignmentID uuid.UUID) (int64, error)
+	ListSubmissionsByUser(userID uuid.UUID) ([]models.Submission, error)
+	GetSubmission(assignmentID, userID uuid.UUID) (*models.Submission, error)
+	// GetSubmissionWithAssignment preloads the assignment and its course.
+	GetSubmissionWithAssignment(id uuid.UUID) (*models.Submission, error)
+	SaveSubmission(submission *models.Submission) error
+}
+
+type FileRepository interface {
+	Create(file *models.StoredFile) error
+	GetByID(id uuid.UUID) (*models.StoredFile, error)
+}
+
+// APITokenRepository stores personal access tokens and organization API
+// keys.
+type APITokenRepository interface {
+	Create(token *models.APIToken) error
+	GetByID(id uuid.UUID) (*models.APIToken, error)
+	// GetByHash returns the token whether or not it is still usable.
+	GetByHash(tokenHash string) (*models.APIToken, error)
+	// ListPersonal returns the user's personal access tokens, newest first.
+	ListPersonal(userID uuid.UUID) ([]models.APIToken, error)
+	// ListByOrg returns the organization's API keys, newest first.
+	ListByOrg(orgID uuid.UUID) ([]models.APIToken, error)
+	Revoke(id uuid.UUID, now time.Time) error
+	TouchLastUsed(id uuid.UUID, now time.Time) error
+}
+
+// AuditFilter narrows AuditRepository.List; zero fields match everything.
+// Since is inclusive and Until exclusive.
+type AuditFilter struct {
+	Action     string
+	ActorID    *uuid.UUID
+	TargetType string
+	TargetID   *uuid.UUID
+	Since      *time.Time
+	Until      *time.Time
+	Offset     int
+	Limit      int
+}
+
+// AuditRepository keeps the append-only log of privileged actions. Events
+// are only written through Record, together with the action they describe.
+type AuditRepository interface {
+	// Record runs fn with repositories bound to one transaction and appends
+	// the event in it, so that the action and its record commit or fail
+	// together. The actor's email is copied onto the event when it has none.
+	Record(event *models.AuditEvent, fn func(tx *Repositories) error) error
+	// List returns a page of the organization's events, newest first, and
+	// how many match the filter.
+	List(orgID uuid.UUID, filter AuditFilter) ([]models.AuditEvent, int64, error)
+}
+
+// SSORepository stores the organizations' OpenID Connect providers, the
+// sign-ins in flight with them and the identities linked through them.
+type SSORepository interface {
+	// GetProvider returns the organization's provider, enabled or not.
+	GetProvider(orgID uuid.UUID) (*models.OrgIdentityProvider, error)
+	GetProviderByID(id uuid.UUID) (*models.OrgIdentityProvider, error)
+	SaveProvider(provider *models.OrgIdentityProvider) error
+	// DeleteProvider removes the organization's provider with its sign-ins
+	// and linked identities; the users keep their accounts.
+	DeleteProvider(orgID uuid.UUID) error
+
+	CreateLogin(login *models.SSOLogin) error
+	// TakeLogin deletes and returns the sign-in with the state, so that a
+	// state works once.
+	TakeLogin(state string) (*models.SSOLogin, error)
+	// DeleteExpiredLogins removes sign-ins abandoned at the provider.
+	DeleteExpiredLogins(before time.Time) (int64, error)
+
+	GetIdentity(providerID uuid.UUID, subject string) (*models.UserIdentity, error)
+	// SaveIdentity saves the identity with its user, creating the user first
+	// when its ID is nil, and records the sign-in time on both.
+	SaveIdentity(identity *models.UserIdentity, user *models.User, now time.Time) error
+}
+
+type Repositories struct {
+	Users         UserRepository
+	Orgs          OrgRepository
+	Courses       CourseRepository
+	StudyPacks    StudyPackRepository
+	Flashcards    FlashcardRepository
+	Conversations ConversationRepository
+	Imports       ImportRepository
+	Assessments   AssessmentRepository
+	Files         FileRepository
+	APITokens     APITokenRepository
+	Audit         AuditRepository
+	SSO           SSORepository
+}
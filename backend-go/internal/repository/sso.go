@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"myway-backend/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ssoRepo struct {
+	db *gorm.DB
+}
+
+func (r *ssoRepo) GetProvider(orgID uuid.UUID) (*models.OrgIdentityProvider, error) {
+	var provider models.OrgIdentityProvider
+	if err := r.db.Where("org_id = ?", orgID).First(&provider).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &provider, nil
+}
+
+func (r *ssoRepo) GetProviderByID(id uuid.UUID) (*models.OrgIdentityProvider, error) {
+	var provider models.OrgIdentityProvider
+	if err := r.db.First(&provider, id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &provider, nil
+}
+
+func (r *ssoRepo) SaveProvider(provider *models.OrgIdentityProvider) error {
+	return r.db.Omit(clause.Associations).Save(provider).Error
+}
+
+func (r *ssoRepo) DeleteProvider(orgID uuid.UUID) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return deleteIdentityProvider(tx, orgID)
+	})
+}
+
+// deleteIdentityProvider removes the organization's provider with its
+// logins in flight and linked identities.
+func deleteIdentityProvider(tx *gorm.DB, orgID uuid.UUID) error {
+	providers := tx.Model(&models.OrgIdentityProvider{}).Select("id").Where("org_id = ?", orgID)
+	if err := tx.Where("provider_id IN (?)", providers).Delete(&models.SSOLogin{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("provider_id IN (?)", providers).Delete(&models.UserIdentity{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("org_id = ?", orgID).Delete(&models.OrgIdentityProvider{}).Error
+}
+
+func (r *ssoRepo) CreateLogin(login *models.SSOLogin) error {
+	return r.db.Create(login).Error
+}
+
+func (r *ssoRepo) TakeLogin(state string) (*models.SSOLogin, error) {
+	var login models.SSOLogin
+	result := r.db.Clauses(clause.Returning{}).Where("state = ?", state).Delete(&login)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrNotFound
+	}
+	return &login, nil
+}
+
+func (r *ssoRepo) DeleteExpiredLogins(before time.Time) (int64, error) {
+	result := r.db.Where("expires_at < ?", before).Delete(&models.SSOLogin{})
+	return result.RowsAffected, result.Error
+}
+
+func (r *ssoRepo) GetIdentity(providerID uuid.UUID, subject string) (*models.UserIdentity, error) {
+	var identity models.UserIdentity
+	if err := r.db.Where("provider_id = ? AND subject = ?", providerID, subject).First(&identity).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &identity, nil
+}
+
+func (r *ssoRepo) SaveIdentity(identity *models.UserIdentity, user *models.User, now time.Time) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if user.ID == uuid.Nil {
+			if err := tx.Create(user).Error; err != nil {
+				return err
+			}
+		}
+		identity.UserID = user.ID
+		identity.LastLogin = &now
+		if err := tx.Omit(clause.Associations).Save(identity).Error; err != nil {
+			return err
+		}
+		user.LastLogin = &now
+		return tx.Model(&models.User{}).Where("id = ?", user.ID).Update("last_login", now).Error
+	})
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"errors"
+	"myway-backend/internal/models"
+	"myway-backend/internal/studypack"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type studyPackRepo struct {
+	db *gorm.DB
+}
+
+func (r *studyPackRepo) Create(studyPack *models.StudyPack) error {
+	return r.db.Create(studyPack).Error
+}
+
+func (r *studyPackRepo) GetByID(id uuid.UUID) (*models.StudyPack, error) {
+	var studyPack models.StudyPack
+	if err := r.db.First(&studyPack, "id = ?", id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &studyPack, nil
+}
+
+func (r *studyPackRepo) GetLatestByMaterial(materialID uuid.UUID) (*models.StudyPack, error) {
+	var studyPack models.StudyPack
+	if err := r.db.
+		Preload("Summary").
+		Preload("Quizzes.Questions").
+		Preload("Flashcards").
+		Preload("Material").
+		Where("material_id = ?", materialID).
+		Order("created_at DESC").
+		First(&studyPack).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &studyPack, nil
+}
+
+func (r *studyPackRepo) SaveSummary(studyPackID uuid.UUID, content string) error {
+	var summary models.Summary
+	err := r.db.Where("study_pack_id = ?", studyPackID).First(&summary).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return r.db.Create(&models.Summary{StudyPackID: studyPackID, Content: content}).Error
+	}
+	if err != nil {
+		return err
+	}
+	return r.db.Model(&summary).Update("content", content).Error
+}
+
+func (r *studyPackRepo) Publish(id uuid.UUID, approvedBy string, at time.Time) error {
+	return r.db.Model(&models.StudyPack{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":            "READY",
+		"published_at":      &at,
+		"requires_approval": false,
+		"approved_by":       &approvedBy,
+	}).Error
+}
+
+func (r *studyPackRepo) SaveDraft(id uuid.UUID, content *studypack.Content) error {
+	if err := studypack.Persist(r.db, id, content); err != nil {
+		return err
+	}
+	return r.db.Model(&models.StudyPack{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":            "GENERATED",
+		"requires_approval": true,
+		"approved_by":       nil,
+		"published_at":      nil,
+	}).Error
+}
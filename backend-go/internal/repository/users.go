@@ -0,0 +1,200 @@
+package repository
+
+import (
+	"myway-backend/internal/models"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type userRepo struct {
+	db *gorm.DB
+}
+
+// NormalizeEmail returns the form emails are stored and compared in, so
+// "Alice@Uni.edu " and "alice@uni.edu" are one account.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func (r *userRepo) Create(user *models.User) error {
+	return r.db.Create(user).Error
+}
+
+func (r *userRepo) GetByID(id uuid.UUID) (*models.User, error) {
+	var user models.User
+	if err := r.db.First(&user, id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &user, nil
+}
+
+func (r *userRepo) GetByEmail(email string) (*models.User, error) {
+	var user models.User
+	if err := r.db.Where("lower(email) = ?", NormalizeEmail(email)).First(&user).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &user, nil
+}
+
+func (r *userRepo) ListByIDs(ids []uuid.UUID) ([]models.User, error) {
+	var users []models.User
+	if len(ids) == 0 {
+		return users, nil
+	}
+	err := r.db.Where("id IN ?", ids).Find(&users).Error
+	return users, err
+}
+
+func (r *userRepo) ListByRoles(roles []string) ([]models.User, error) {
+	var users []models.User
+	err := r.db.Where("role IN ?", roles).Order("created_at").Find(&users).Error
+	return users, err
+}
+
+func (r *userRepo) Update(user *models.User) error {
+	return r.db.Omit(clause.Associations).Save(user).Error
+}
+
+func (r *userRepo) CreateRefreshToken(token *models.RefreshToken) error {
+	return r.db.Create(token).Error
+}
+
+func (r *userRepo) GetRefreshToken(tokenHash string) (*models.RefreshToken, error) {
+	var refreshToken models.RefreshToken
+	if err := r.db.Where("token_hash = ?", tokenHash).First(&refreshToken).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &refreshToken, nil
+}
+
+func (r *userRepo) RotateRefreshToken(used, next *models.RefreshToken, now time.Time) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// The conditional update is the lock: of two concurrent refreshes
+		// with the same token only one marks it used.
+		result := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND used_at IS NULL AND revoked_at IS NULL", used.ID).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrTokenUsed
+		}
+		return tx.Create(next).Error
+	})
+}
+
+func (r *userRepo) RevokeRefreshTokenFamily(familyID uuid.UUID, now time.Time) error {
+	return r.db.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", now).Error
+}
+
+func (r *userRepo) RevokeUserRefreshTokens(userID uuid.UUID, now time.Time) error {
+	return r.db.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", now).Error
+}
+
+func (r *userRepo) DeleteExpiredRefreshTokens(before time.Time) (int64, error) {
+	result := r.db.Where("expires_at < ?", before).Delete(&models.RefreshToken{})
+	return result.RowsAffected, result.Error
+}
+
+func (r *userRepo) CreateUserToken(token *models.UserToken) error {
+	return r.db.Create(token).Error
+}
+
+func (r *userRepo) CountUserTokens(userID uuid.UUID, purpose string, since time.Time) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.UserToken{}).
+		Where("user_id = ? AND purpose = ? AND created_at >= ?", userID, purpose, since).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *userRepo) ConsumeUserToken(tokenHash, purpose string, now time.Time) (*models.UserToken, error) {
+	var token models.UserToken
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("token_hash = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?", tokenHash, purpose, now).
+			First(&token).Error; err != nil {
+			return err
+		}
+		result := tx.Model(&models.UserToken{}).
+			Where("id = ? AND used_at IS NULL", token.ID).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Model(&models.UserToken{}).
+			Where("user_id = ? AND purpose = ? AND used_at IS NULL", token.UserID, purpose).
+			Update("used_at", now).Error
+	})
+	if err != nil {
+		return nil, translate(err)
+	}
+	token.UsedAt = &now
+	return &token, nil
+}
+
+func (r *userRepo) DeleteExpiredUserTokens(before time.Time) (int64, error) {
+	result := r.db.Where("expires_at < ?", before).Delete(&models.UserToken{})
+	return result.RowsAffected, result.Error
+}
+
+func (r *userRepo) UseTOTPStep(userID uuid.UUID, step int64) error {
+	result := r.db.Model(&models.User{}).
+		Where("id = ? AND totp_last_step < ?", userID, step).
+		Update("totp_last_step", step)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrTokenUsed
+	}
+	return nil
+}
+
+func (r *userRepo) ReplaceRecoveryCodes(userID uuid.UUID, codeHashes []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		if len(codeHashes) == 0 {
+			return nil
+		}
+		codes := make([]models.RecoveryCode, len(codeHashes))
+		for i, hash := range codeHashes {
+			codes[i] = models.RecoveryCode{UserID: userID, CodeHash: hash}
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+func (r *userRepo) UseRecoveryCode(userID uuid.UUID, codeHash string, now time.Time) error {
+	result := r.db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", now)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *userRepo) CountRecoveryCodes(userID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,189 @@
+package retrieval
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	SourceTranscript = "TRANSCRIPT"
+	SourceSummary    = "SUMMARY"
+	SourceDocument   = "DOCUMENT"
+
+	// targetChunkChars is roughly 150-200 words, small enough that a handful
+	// of retrieved chunks fit in the tutor prompt.
+	targetChunkChars = 900
+)
+
+type Chunk struct {
+	Source   string
+	Content  string
+	StartSec *float64
+	EndSec   *float64
+	// Page is the page or slide the chunk starts on, for documents.
+	Page *int
+}
+
+// timestampLine matches transcript lines such as "3:10 - text" or
+// "1:02:45 text" produced by the transcript fetchers.
+var timestampLine = regexp.MustCompile(`^\[?(\d{1,2}(?::\d{2}){1,2})\]?\s*(?:-\s*)?(.*)$`)
+
+type line struct {
+	text  string
+	start *float64
+	page  *int
+}
+
+// ChunkTranscript splits a transcript into overlapping windows, keeping the
+// start and end time of each window when the transcript is timestamped.
+func ChunkTranscript(text string) []Chunk {
+	var lines []line
+	for _, raw := range strings.Split(text, "\n") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		if m := timestampLine.FindStringSubmatch(raw); m != nil {
+			if seconds, ok := parseTimestamp(m[1]); ok && strings.TrimSpace(m[2]) != "" {
+				lines = append(lines, line{text: strings.TrimSpace(m[2]), start: &seconds})
+				continue
+			}
+		}
+		for _, sentence := range splitSentences(raw) {
+			lines = append(lines, line{text: sentence})
+		}
+	}
+	return window(lines, SourceTranscript)
+}
+
+// ChunkText splits untimed prose such as summaries and documents.
+func ChunkText(text, source string) []Chunk {
+	var lines []line
+	for _, paragraph := range strings.Split(text, "\n") {
+		for _, sentence := range splitSentences(paragraph) {
+			lines = append(lines, line{text: sentence})
+		}
+	}
+	return window(lines, source)
+}
+
+// sectionMarker matches the "[Page N]" and "[Slide N]" lines that
+// extract.Document.Text writes between pages.
+var sectionMarker = regexp.MustCompile(`^\[(?:Page|Slide) (\d+)\]$`)
+
+// ChunkDocument splits extracted document text, tagging each chunk with the
+// page or slide it starts on.
+func ChunkDocument(text string) []Chunk {
+	var lines []line
+	var page *int
+	for _, paragraph := range strings.Split(text, "\n") {
+		if m := sectionMarker.FindStringSubmatch(strings.TrimSpace(paragraph)); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			page = &n
+			continue
+		}
+		for _, sentence := range splitSentences(paragraph) {
+			lines = append(lines, line{text: sentence, page: page})
+		}
+	}
+	return window(lines, SourceDocument)
+}
+
+func window(lines []line, source string) []Chunk {
+	var chunks []Chunk
+	var current []line
+	size := 0
+
+	emit := func() {
+		if len(current) == 0 {
+			return
+		}
+		parts := make([]string, len(current))
+		for i, l := range current {
+			parts[i] = l.text
+		}
+		chunk := Chunk{Source: source, Content: strings.Join(parts, " "), Page: current[0].page}
+		for _, l := range current {
+			if l.start != nil {
+				chunk.StartSec = l.start
+				break
+			}
+		}
+		for i := len(current) - 1; i >= 0; i-- {
+			if current[i].start != nil {
+				chunk.EndSec = current[i].start
+				break
+			}
+		}
+		chunks = append(chunks, chunk)
+	}
+
+	for _, l := range lines {
+		if size > 0 && size+len(l.text) > targetChunkChars {
+			emit()
+			// Carry the last line over so a thought split across the
+			// boundary is retrievable from either chunk.
+			if len(current) > 1 {
+				last := current[len(current)-1]
+				current = []line{last}
+				size = len(last.text) + 1
+			} else {
+				current = nil
+				size = 0
+			}
+		}
+		current = append(current, l)
+		size += len(l.text) + 1
+	}
+	emit()
+
+	return chunks
+}
+
+var sentenceEnd = regexp.MustCompile(`([.!?])\s+`)
+
+func splitSentences(text string) []string {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+	marked := sentenceEnd.ReplaceAllString(text, "$1\n")
+	var sentences []string
+	for _, s := range strings.Split(marked, "\n") {
+		if s = strings.TrimSpace(s); s != "" {
+			sentences = append(sentences, s)
+		}
+	}
+	return sentences
+}
+
+func parseTimestamp(value string) (float64, bool) {
+	parts := strings.Split(value, ":")
+	total := 0
+	for _, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return 0, false
+		}
+		total = total*60 + n
+	}
+	return float64(total), true
+}
+
+// FormatTimestamp renders seconds as m:ss or h:mm:ss.
+func FormatTimestamp(seconds float64) string {
+	total := int(seconds)
+	h, m, s := total/3600, (total%3600)/60, total%60
+	if h > 0 {
+		return strconv.Itoa(h) + ":" + pad2(m) + ":" + pad2(s)
+	}
+	return strconv.Itoa(m) + ":" + pad2(s)
+}
+
+func pad2(n int) string {
+	if n < 10 {
+		return "0" + strconv.Itoa(n)
+	}
+	return strconv.Itoa(n)
+}
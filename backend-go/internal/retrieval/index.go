@@ -0,0 +1,90 @@
+package retrieval
+
+import (
+	"encoding/json"
+	"errors"
+	"myway-backend/internal/models"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// IndexMaterial rebuilds the search chunks of a material from its transcript
+// or extracted document text and the summary of its latest study pack. It returns the chunk count.
+func IndexMaterial(db *gorm.DB, materialID uuid.UUID) (int, error) {
+	var material models.Material
+	if err := db.First(&material, materialID).Error; err != nil {
+		return 0, err
+	}
+
+	var chunks []Chunk
+	if material.TranscriptText != nil && strings.TrimSpace(*material.TranscriptText) != "" {
+		chunks = append(chunks, ChunkTranscript(*material.TranscriptText)...)
+	} else if material.ExtractedText != nil && strings.TrimSpace(*material.ExtractedText) != "" {
+		chunks = append(chunks, ChunkDocument(*material.ExtractedText)...)
+	}
+
+	summaryText, err := latestSummaryText(db, materialID)
+	if err != nil {
+		return 0, err
+	}
+	if summaryText != "" {
+		chunks = append(chunks, ChunkText(summaryText, SourceSummary)...)
+	}
+
+	rows := make([]models.MaterialChunk, len(chunks))
+	for i, chunk := range chunks {
+		rows[i] = models.MaterialChunk{
+			ID:         uuid.New(),
+			MaterialID: materialID,
+			Ordinal:    i,
+			Source:     chunk.Source,
+			Content:    chunk.Content,
+			StartSec:   chunk.StartSec,
+			EndSec:     chunk.EndSec,
+			Page:       chunk.Page,
+		}
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("material_id = ?", materialID).Delete(&models.MaterialChunk{}).Error; err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(rows, 100).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(rows), nil
+}
+
+func latestSummaryText(db *gorm.DB, materialID uuid.UUID) (string, error) {
+	var summary models.Summary
+	err := db.
+		Joins("JOIN study_packs ON study_packs.id = summaries.study_pack_id").
+		Where("study_packs.material_id = ?", materialID).
+		Order("study_packs.created_at DESC").
+		First(&summary).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	var parsed struct {
+		Summary string   `json:"summary"`
+		Bullets []string `json:"bullets"`
+	}
+	if err := json.Unmarshal([]byte(summary.Content), &parsed); err != nil {
+		return "", nil
+	}
+
+	parts := []string{strings.TrimSpace(parsed.Summary)}
+	parts = append(parts, parsed.Bullets...)
+	return strings.TrimSpace(strings.Join(parts, "\n")), nil
+}
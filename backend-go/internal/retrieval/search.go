@@ -0,0 +1,107 @@
+package retrieval
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const DefaultTopK = 6
+
+type Result struct {
+	ChunkID       uuid.UUID
+	MaterialID    uuid.UUID
+	MaterialTitle string
+	ModuleID      uuid.UUID
+	Source        string
+	Content       string
+	StartSec      *float64
+	EndSec        *float64
+	Page          *int
+	Rank          float64
+}
+
+// Index is the search index over course materials that the tutor answers
+// from.
+type Index interface {
+	// Search returns the k chunks of the course's materials that best
+	// match query.
+	Search(courseID uuid.UUID, query string, k int) ([]Result, error)
+	// IndexMaterial rebuilds the chunks of a material and returns their
+	// count.
+	IndexMaterial(materialID uuid.UUID) (int, error)
+}
+
+// NewIndex returns the Postgres full-text Index of db.
+func NewIndex(db *gorm.DB) Index {
+	return postgresIndex{db: db}
+}
+
+type postgresIndex struct {
+	db *gorm.DB
+}
+
+func (i postgresIndex) Search(courseID uuid.UUID, query string, k int) ([]Result, error) {
+	return Search(i.db, courseID, query, k)
+}
+
+func (i postgresIndex) IndexMaterial(materialID uuid.UUID) (int, error) {
+	return IndexMaterial(i.db, materialID)
+}
+
+var queryTerm = regexp.MustCompile(`[\p{L}\p{N}]{2,}`)
+
+// Search returns the k chunks of the course's materials that best match
+// query, walking the course's Module/Material tree so results never leak
+// across courses.
+func Search(db *gorm.DB, courseID uuid.UUID, query string, k int) ([]Result, error) {
+	tsQuery := buildTSQuery(query)
+	if tsQuery == "" {
+		return nil, nil
+	}
+	if k <= 0 {
+		k = DefaultTopK
+	}
+
+	var results []Result
+	err := db.Raw(`
+		SELECT material_chunks.id AS chunk_id,
+		       material_chunks.material_id,
+		       materials.title AS material_title,
+		       materials.module_id,
+		       material_chunks.source,
+		       material_chunks.content,
+		       material_chunks.start_sec,
+		       material_chunks.end_sec,
+		       material_chunks.page,
+		       ts_rank_cd(to_tsvector('english', material_chunks.content), query) AS rank
+		FROM material_chunks
+		JOIN materials ON materials.id = material_chunks.material_id
+		JOIN modules ON modules.id = materials.module_id,
+		     to_tsquery('english', ?) query
+		WHERE modules.course_id = ?
+		  AND to_tsvector('english', material_chunks.content) @@ query
+		ORDER BY rank DESC, material_chunks.ordinal ASC
+		LIMIT ?`,
+		tsQuery, courseID, k,
+	).Scan(&results).Error
+	return results, err
+}
+
+// buildTSQuery turns free text into an OR query so a question matches
+// chunks sharing any of its significant words; ts_rank_cd then favours the
+// chunks that share the most.
+func buildTSQuery(query string) string {
+	seen := make(map[string]bool)
+	var terms []string
+	for _, term := range queryTerm.FindAllString(strings.ToLower(query), -1) {
+		if seen[term] {
+			continue
+		}
+		seen[term] = true
+		terms = append(terms, term)
+	}
+	return strings.Join(terms, " | ")
+}
@@ -0,0 +1,87 @@
+// Package safehttp builds HTTP clients for URLs that users supply. Such a
+// client refuses to connect to this host or its internal network, so a URL
+// cannot be used to read cloud metadata or services behind the firewall.
+package safehttp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// ErrDisallowed is returned for URLs a client will not fetch.
+var ErrDisallowed = errors.New("URL is not allowed")
+
+// Options configures NewClient.
+type Options struct {
+	// Timeout bounds every request, redirects included.
+	Timeout time.Duration
+	// MaxRedirects is the number of redirects followed before giving up.
+	MaxRedirects int
+	// Schemes lists the schemes a redirect may lead to; by default http and
+	// https.
+	Schemes []string
+	// AllowPrivate turns the address check off, for development against
+	// services on localhost.
+	AllowPrivate bool
+}
+
+// NewClient returns a client that, unless opts.AllowPrivate is set, refuses
+// to connect to loopback, private and link-local addresses. The address is
+// checked when dialing, after DNS resolution, so neither a hostname nor a
+// redirect can point it at the internal network.
+func NewClient(opts Options) *http.Client {
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if !opts.AllowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !PublicIP(ip) {
+				return fmt.Errorf("%w: %s is not a public address", ErrDisallowed, host)
+			}
+			return nil
+		}
+		// A proxy would be dialed instead of the target and checked in its
+		// place
+		transport.Proxy = nil
+	}
+	transport.DialContext = dialer.DialContext
+
+	schemes := opts.Schemes
+	if len(schemes) == 0 {
+		schemes = []string{"http", "https"}
+	}
+	return &http.Client{
+		Timeout:   opts.Timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= opts.MaxRedirects {
+				return fmt.Errorf("stopped after %d redirects", opts.MaxRedirects)
+			}
+			for _, scheme := range schemes {
+				if req.URL.Scheme == scheme {
+					return nil
+				}
+			}
+			return fmt.Errorf("%w: redirect to %s", ErrDisallowed, req.URL.Redacted())
+		},
+	}
+}
+
+// PublicIP reports whether ip is reachable on the internet rather than this
+// host or its internal network.
+func PublicIP(ip net.IP) bool {
+	return !ip.IsLoopback() &&
+		!ip.IsPrivate() &&
+		!ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() &&
+		!ip.IsMulticast() &&
+		!ip.IsUnspecified()
+}
@@ -0,0 +1,89 @@
+package safehttp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPublicIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:4700::1111", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"0.0.0.0", false},
+		{"224.0.0.1", false},
+	}
+	for _, tt := range tests {
+		if got := PublicIP(net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("PublicIP(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func get(client *http.Client, url string) error {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func TestClientRefusesLoopback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request reached the server")
+	}))
+	defer server.Close()
+
+	client := NewClient(Options{Timeout: time.Second, MaxRedirects: 3})
+	if err := get(client, server.URL); !errors.Is(err, ErrDisallowed) {
+		t.Fatalf("get() error = %v, want ErrDisallowed", err)
+	}
+}
+
+func TestClientLimitsRedirects(t *testing.T) {
+	var hops int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hops++
+		http.Redirect(w, r, "/next", http.StatusFound)
+	}))
+	defer server.Close()
+
+	client := NewClient(Options{Timeout: time.Second, MaxRedirects: 3, AllowPrivate: true})
+	if err := get(client, server.URL); err == nil {
+		t.Fatal("get() followed redirects forever")
+	}
+	if hops != 3 {
+		t.Fatalf("server saw %d requests, want 3", hops)
+	}
+}
+
+func TestClientChecksRedirectSchemes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "ftp://example.com/file", http.StatusFound)
+	}))
+	defer server.Close()
+
+	client := NewClient(Options{Timeout: time.Second, MaxRedirects: 3, AllowPrivate: true})
+	if err := get(client, server.URL); !errors.Is(err, ErrDisallowed) {
+		t.Fatalf("get() error = %v, want ErrDisallowed", err)
+	}
+}
@@ -0,0 +1,128 @@
+// Package srs schedules flashcard reviews with the SM-2 algorithm, using the
+// four answer buttons popularized by Anki instead of SM-2's 0-5 quality scale.
+package srs
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+type Grade string
+
+const (
+	Again Grade = "again"
+	Hard  Grade = "hard"
+	Good  Grade = "good"
+	Easy  Grade = "easy"
+)
+
+const (
+	DefaultEase = 2.5
+	MinEase     = 1.3
+
+	// MaxIntervalDays keeps long-known cards coming back eventually.
+	MaxIntervalDays = 365
+
+	// relearnDelay is how soon a forgotten card is shown again.
+	relearnDelay = 10 * time.Minute
+
+	hardFactor  = 1.2
+	easyBonus   = 1.3
+	easeStep    = 0.15
+	againStep   = 0.2
+	firstGood   = 1
+	secondGood  = 6
+	firstEasy   = 4
+	dayDuration = 24 * time.Hour
+)
+
+// ParseGrade accepts the four grades and the older known/unknown answers.
+func ParseGrade(value string) (Grade, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "again", "unknown":
+		return Again, nil
+	case "hard":
+		return Hard, nil
+	case "good", "known":
+		return Good, nil
+	case "easy":
+		return Easy, nil
+	}
+	return "", fmt.Errorf("unknown grade %q", value)
+}
+
+// Recalled reports whether the grade counts as remembering the card.
+func (g Grade) Recalled() bool {
+	return g != Again
+}
+
+// State is a card's scheduling state for one learner.
+type State struct {
+	Ease         float64
+	IntervalDays int
+	Repetitions  int
+	Lapses       int
+	DueAt        time.Time
+}
+
+// New returns the state of a card that has never been reviewed.
+func New(now time.Time) State {
+	return State{Ease: DefaultEase, DueAt: now}
+}
+
+// Review returns the state after answering the card with grade at now.
+func Review(state State, grade Grade, now time.Time) State {
+	if state.Ease < MinEase {
+		state.Ease = DefaultEase
+	}
+
+	if grade == Again {
+		if state.Repetitions > 0 {
+			state.Lapses++
+		}
+		state.Repetitions = 0
+		state.IntervalDays = 0
+		state.Ease = math.Max(MinEase, state.Ease-againStep)
+		state.DueAt = now.Add(relearnDelay)
+		return state
+	}
+
+	previous := state.IntervalDays
+	var interval float64
+	switch grade {
+	case Hard:
+		interval = math.Max(float64(previous)*hardFactor, float64(previous+1))
+		state.Ease = math.Max(MinEase, state.Ease-easeStep)
+	case Good:
+		switch state.Repetitions {
+		case 0:
+			interval = firstGood
+		case 1:
+			interval = secondGood
+		default:
+			interval = float64(previous) * state.Ease
+		}
+	case Easy:
+		if state.Repetitions == 0 {
+			interval = firstEasy
+		} else {
+			interval = float64(previous) * state.Ease * easyBonus
+		}
+		state.Ease += easeStep
+	}
+
+	days := int(math.Round(interval))
+	if days < 1 {
+		days = 1
+	}
+	if days > MaxIntervalDays {
+		days = MaxIntervalDays
+	}
+
+	state.Repetitions++
+	state.IntervalDays = days
+	state.DueAt = now.Add(time.Duration(days) * dayDuration)
+	return state
+}
@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocalRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	local, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := local.Put(ctx, "org/file.txt", Object{Body: strings.NewReader("hello")}); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if ok, err := local.Exists(ctx, "org/file.txt"); !ok || err != nil {
+		t.Fatalf("Exists() = %v, %v, want true", ok, err)
+	}
+	body, err := local.Open(ctx, "org/file.txt")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	data, _ := io.ReadAll(body)
+	body.Close()
+	if string(data) != "hello" {
+		t.Fatalf("Open() read %q, want hello", data)
+	}
+
+	if err := local.Delete(ctx, "org/file.txt"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err := local.Delete(ctx, "org/file.txt"); err != nil {
+		t.Fatalf("Delete() of a missing object error = %v", err)
+	}
+	if _, err := local.Open(ctx, "org/file.txt"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Open() after delete error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestLocalRejectsKeysOutsideRoot(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	local, err := NewLocal(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "secret"), []byte("secret"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"", "..", "../secret", "a/../../secret", "/etc/passwd"} {
+		if _, err := local.Open(ctx, key); err == nil || errors.Is(err, ErrNotFound) {
+			t.Errorf("Open(%q) error = %v, want an invalid key", key, err)
+		}
+		if err := local.Put(ctx, key, Object{Body: strings.NewReader("x")}); err == nil {
+			t.Errorf("Put(%q) succeeded", key)
+		}
+	}
+
+	// Keys that stay below the root after cleaning are fine
+	if err := local.Put(ctx, "a/../b.txt", Object{Body: strings.NewReader("x")}); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "b.txt")); err != nil {
+		t.Fatalf("object not stored below the root: %v", err)
+	}
+}
@@ -0,0 +1,39 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestURLSigner(t *testing.T) {
+	signer := NewURLSigner("key")
+	now := time.Now()
+	expires := now.Add(time.Hour)
+	signature := signer.Sign("file-1", expires)
+
+	tests := []struct {
+		name      string
+		signer    *URLSigner
+		id        string
+		expires   int64
+		signature string
+		now       time.Time
+		want      bool
+	}{
+		{"valid", signer, "file-1", expires.Unix(), signature, now, true},
+		{"expired", signer, "file-1", expires.Unix(), signature, expires.Add(time.Second), false},
+		{"other file", signer, "file-2", expires.Unix(), signature, now, false},
+		{"extended expiry", signer, "file-1", expires.Add(time.Hour).Unix(), signature, now, false},
+		{"tampered signature", signer, "file-1", expires.Unix(), strings.Repeat("0", len(signature)), now, false},
+		{"other key", NewURLSigner("other"), "file-1", expires.Unix(), signature, now, false},
+		{"empty signature", signer, "file-1", expires.Unix(), "", now, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.signer.Verify(tt.id, tt.expires, tt.signature, tt.now); got != tt.want {
+				t.Errorf("Verify() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+// Package storage keeps uploaded files on local disk or in an S3-compatible
+// bucket, and signs the short-lived links they are downloaded through.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	BackendLocal = "local"
+	BackendS3    = "s3"
+)
+
+var ErrNotFound = errors.New("stored object not found")
+
+// Object is the content handed to Put.
+type Object struct {
+	Body        io.Reader
+	Size        int64
+	ContentType string
+	// SHA256 is the hex digest of Body. S3 verifies the upload against it.
+	SHA256 string
+}
+
+type Storage interface {
+	// Backend names the implementation, as recorded on StoredFile.
+	Backend() string
+	Put(ctx context.Context, key string, obj Object) error
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Exists(ctx context.Context, key string) (bool, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// Presigner is implemented by backends that can hand out direct, expiring
+// download URLs so file bytes do not pass through the API server.
+type Presigner interface {
+	PresignGet(key, fileName string, ttl time.Duration) (string, error)
+}
+
+type Config struct {
+	// Backend is "local" or "s3".
+	Backend string
+	// Dir is the root directory of the local backend.
+	Dir string
+
+	S3Endpoint  string
+	S3Region    string
+	S3Bucket    string
+	S3AccessKey string
+	S3SecretKey string
+	// S3PathStyle addresses the bucket as a path, as MinIO expects, instead
+	// of as a subdomain.
+	S3PathStyle bool
+}
+
+// New returns the backend selected by cfg.
+func New(cfg Config) (Storage, error) {
+	switch cfg.Backend {
+	case "", BackendLocal:
+		return NewLocal(cfg.Dir)
+	case BackendS3:
+		return NewS3(cfg)
+	}
+	return nil, fmt.Errorf("unknown storage backend %q", cfg.Backend)
+}
@@ -0,0 +1,223 @@
+package studypack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"myway-backend/internal/llm"
+	"myway-backend/internal/models"
+	"strings"
+)
+
+const (
+	DefaultQuestionCount  = 5
+	DefaultFlashcardCount = 8
+	MaxQuestionCount      = 20
+	MaxFlashcardCount     = 30
+
+	// maxSourceChars keeps prompts comfortably inside the model context window.
+	maxSourceChars = 60000
+	maxAttempts    = 2
+)
+
+var ErrNoSourceText = errors.New("material has no transcript or text to generate from")
+
+type Options struct {
+	QuestionCount   int
+	FlashcardCount  int
+	InstructorNotes string
+}
+
+func (o Options) normalized() Options {
+	if o.QuestionCount <= 0 {
+		o.QuestionCount = DefaultQuestionCount
+	}
+	if o.QuestionCount > MaxQuestionCount {
+		o.QuestionCount = MaxQuestionCount
+	}
+	if o.FlashcardCount <= 0 {
+		o.FlashcardCount = DefaultFlashcardCount
+	}
+	if o.FlashcardCount > MaxFlashcardCount {
+		o.FlashcardCount = MaxFlashcardCount
+	}
+	o.InstructorNotes = strings.TrimSpace(o.InstructorNotes)
+	return o
+}
+
+type Question struct {
+	Prompt      string   `json:"prompt"`
+	Options     []string `json:"options"`
+	Answer      string   `json:"answer"`
+	Explanation string   `json:"explanation"`
+}
+
+type Flashcard struct {
+	Front string   `json:"front"`
+	Back  string   `json:"back"`
+	Tags  []string `json:"tags"`
+}
+
+// Content is the structured study pack returned by the model.
+type Content struct {
+	Summary    string      `json:"summary"`
+	KeyPoints  []string    `json:"keyPoints"`
+	Quiz       []Question  `json:"quiz"`
+	Flashcards []Flashcard `json:"flashcards"`
+
+	Provider string `json:"-"`
+	Model    string `json:"-"`
+}
+
+type Generator struct {
+	Provider llm.Provider
+}
+
+func NewGenerator(provider llm.Provider) *Generator {
+	return &Generator{Provider: provider}
+}
+
+// Generate prompts the provider for a study pack built from the material's
+// transcript or extracted document text. Output that fails validation is retried once with the
+// validation error fed back to the model.
+func (g *Generator) Generate(ctx context.Context, material models.Material, opts Options) (*Content, error) {
+	opts = opts.normalized()
+
+	source := sourceText(material)
+	if source == "" {
+		return nil, ErrNoSourceText
+	}
+
+	req := llm.Request{
+		System:      generatorSystemPrompt,
+		Messages:    []llm.Message{{Role: llm.RoleUser, Content: buildPrompt(material, source, opts)}},
+		Temperature: 0.3,
+		MaxTokens:   4096,
+	}
+	schema := contentSchema(opts)
+
+	var lastErr error
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		var content Content
+		resp, err := g.Provider.GenerateStructured(ctx, req, schema, &content)
+		if err != nil {
+			if resp == nil {
+				return nil, err
+			}
+			lastErr = err
+		} else if err := content.Validate(opts); err != nil {
+			lastErr = err
+		} else {
+			content.Provider = resp.Provider
+			content.Model = resp.Model
+			return &content, nil
+		}
+
+		if resp != nil {
+			req.Messages = append(req.Messages, llm.Message{Role: llm.RoleAssistant, Content: resp.Text})
+		}
+		req.Messages = append(req.Messages, llm.Message{
+			Role:    llm.RoleUser,
+			Content: "The previous output was rejected: " + lastErr.Error() + ". Return the corrected JSON object.",
+		})
+	}
+
+	return nil, fmt.Errorf("generated study pack failed validation: %w", lastErr)
+}
+
+// sourceText prefers the transcript and falls back to text extracted from
+// an uploaded document.
+func sourceText(material models.Material) string {
+	var text string
+	if material.TranscriptText != nil {
+		text = strings.TrimSpace(*material.TranscriptText)
+	}
+	if text == "" && material.ExtractedText != nil {
+		text = strings.TrimSpace(*material.ExtractedText)
+	}
+	if len(text) > maxSourceChars {
+		text = text[:maxSourceChars]
+	}
+	return text
+}
+
+const generatorSystemPrompt = `You are MyWay's study pack author. You turn lecture transcripts and course documents into study material for students.
+
+Rules:
+- Use only facts stated in the transcript or document; never invent content.
+- Keep the summary to one or two short paragraphs.
+- Key points are short standalone sentences. Prefix a key point with its "m:ss - " timestamp when the transcript provides one.
+- Quiz questions are multiple choice with exactly one correct option; the answer must be copied verbatim from the options.
+- Explanations say why the answer is correct, referring back to the lecture.
+- Flashcards have a short prompt on the front and a concise answer on the back.`
+
+func buildPrompt(material models.Material, source string, opts Options) string {
+	var sb strings.Builder
+	sb.WriteString("Material title: " + material.Title + "\n")
+	sb.WriteString(fmt.Sprintf("Write a summary, 3 to 8 key points, exactly %d quiz questions and %d flashcards.\n", opts.QuestionCount, opts.FlashcardCount))
+	if opts.InstructorNotes != "" {
+		sb.WriteString("\nThe instructor reviewed the previous draft and asked for the following. Follow these instructions closely:\n")
+		sb.WriteString(opts.InstructorNotes + "\n")
+	}
+	if material.TranscriptText != nil && strings.TrimSpace(*material.TranscriptText) != "" {
+		sb.WriteString("\nTranscript:\n")
+	} else {
+		sb.WriteString("\nDocument (\"[Page N]\" and \"[Slide N]\" lines mark where pages and slides start):\n")
+	}
+	sb.WriteString(source)
+	return sb.String()
+}
+
+func contentSchema(opts Options) llm.Schema {
+	stringType := map[string]interface{}{"type": "STRING"}
+	return llm.Schema{
+		"type": "OBJECT",
+		"properties": map[string]interface{}{
+			"summary": stringType,
+			"keyPoints": map[string]interface{}{
+				"type":     "ARRAY",
+				"items":    stringType,
+				"minItems": 3,
+				"maxItems": 8,
+			},
+			"quiz": map[string]interface{}{
+				"type":     "ARRAY",
+				"minItems": opts.QuestionCount,
+				"maxItems": opts.QuestionCount,
+				"items": map[string]interface{}{
+					"type": "OBJECT",
+					"properties": map[string]interface{}{
+						"prompt": stringType,
+						"options": map[string]interface{}{
+							"type":     "ARRAY",
+							"items":    stringType,
+							"minItems": 4,
+							"maxItems": 4,
+						},
+						"answer":      stringType,
+						"explanation": stringType,
+					},
+					"required": []string{"prompt", "options", "answer", "explanation"},
+				},
+			},
+			"flashcards": map[string]interface{}{
+				"type":     "ARRAY",
+				"minItems": opts.FlashcardCount,
+				"maxItems": opts.FlashcardCount,
+				"items": map[string]interface{}{
+					"type": "OBJECT",
+					"properties": map[string]interface{}{
+						"front": stringType,
+						"back":  stringType,
+						"tags": map[string]interface{}{
+							"type":  "ARRAY",
+							"items": stringType,
+						},
+					},
+					"required": []string{"front", "back"},
+				},
+			},
+		},
+		"required": []string{"summary", "keyPoints", "quiz", "flashcards"},
+	}
+}
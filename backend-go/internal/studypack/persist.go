@@ -0,0 +1,142 @@
+package studypack
+
+import (
+	"encoding/json"
+	"errors"
+	"myway-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Persist replaces the summary, quiz and flashcards of a study pack with
+// content inside one transaction. Quizzes that students already attempted are
+// kept so their attempts stay valid; the new quiz gets the next version.
+// Flashcards are replaced along with their review schedules.
+func Persist(db *gorm.DB, studyPackID uuid.UUID, content *Content) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		summaryJSON := SummaryContent(content)
+
+		var summary models.Summary
+		err := tx.Where("study_pack_id = ?", studyPackID).First(&summary).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			summary = models.Summary{StudyPackID: studyPackID, Content: summaryJSON}
+			if err := tx.Create(&summary).Error; err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		default:
+			if err := tx.Model(&summary).Update("content", summaryJSON).Error; err != nil {
+				return err
+			}
+		}
+
+		var maxVersion int
+		if err := tx.Model(&models.Quiz{}).Where("study_pack_id = ?", studyPackID).
+			Select("COALESCE(MAX(version), 0)").Scan(&maxVersion).Error; err != nil {
+			return err
+		}
+
+		var unusedQuizIDs []uuid.UUID
+		if err := tx.Model(&models.Quiz{}).
+			Where("study_pack_id = ? AND NOT EXISTS (SELECT 1 FROM quiz_attempts WHERE quiz_attempts.quiz_id = quizzes.id)", studyPackID).
+			Pluck("id", &unusedQuizIDs).Error; err != nil {
+			return err
+		}
+		if len(unusedQuizIDs) > 0 {
+			if err := tx.Where("quiz_id IN ?", unusedQuizIDs).Delete(&models.QuizQuestion{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("id IN ?", unusedQuizIDs).Delete(&models.Quiz{}).Error; err != nil {
+				return err
+			}
+		}
+
+		// Questions are created with the quiz through the association.
+		quiz := NewQuiz(studyPackID, maxVersion+1, content)
+		if err := tx.Create(&quiz).Error; err != nil {
+			return err
+		}
+
+		// Regenerated cards start a fresh review history.
+		oldFlashcards := tx.Model(&models.Flashcard{}).Select("id").Where("study_pack_id = ?", studyPackID)
+		if err := tx.Where("flashcard_id IN (?)", oldFlashcards).Delete(&models.FlashcardReview{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("flashcard_id IN (?)", oldFlashcards).Delete(&models.FlashcardSchedule{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("study_pack_id = ?", studyPackID).Delete(&models.Flashcard{}).Error; err != nil {
+			return err
+		}
+		if flashcards := NewFlashcards(studyPackID, content); len(flashcards) > 0 {
+			if err := tx.Create(&flashcards).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// SummaryContent is the JSON stored in Summary.Content.
+func SummaryContent(content *Content) string {
+	summaryJSON, _ := json.Marshal(map[string]interface{}{
+		"summary": content.Summary,
+		"bullets": content.KeyPoints,
+	})
+	return string(summaryJSON)
+}
+
+// NewQuiz builds the quiz model for content with its MCQ questions.
+func NewQuiz(studyPackID uuid.UUID, version int, content *Content) models.Quiz {
+	metadataJSON, _ := json.Marshal(map[string]interface{}{
+		"difficulty":    "Adaptive",
+		"questionCount": len(content.Quiz),
+		"provider":      content.Provider,
+		"model":         content.Model,
+	})
+	quiz := models.Quiz{
+		StudyPackID: studyPackID,
+		Version:     version,
+		Metadata:    string(metadataJSON),
+	}
+
+	for _, q := range content.Quiz {
+		optionsJSON, _ := json.Marshal(q.Options)
+		answerJSON, _ := json.Marshal(q.Answer)
+		question := models.QuizQuestion{
+			Type:      "MCQ",
+			Prompt:    q.Prompt,
+			Options:   string(optionsJSON),
+			AnswerKey: string(answerJSON),
+		}
+		if q.Explanation != "" {
+			explanation := q.Explanation
+			question.Explanation = &explanation
+		}
+		quiz.Questions = append(quiz.Questions, question)
+	}
+	return quiz
+}
+
+// NewFlashcards builds the flashcard models for content.
+func NewFlashcards(studyPackID uuid.UUID, content *Content) []models.Flashcard {
+	flashcards := make([]models.Flashcard, 0, len(content.Flashcards))
+	for _, card := range content.Flashcards {
+		flashcard := models.Flashcard{
+			StudyPackID: studyPackID,
+			Front:       card.Front,
+			Back:        card.Back,
+		}
+		if len(card.Tags) > 0 {
+			tagsJSON, _ := json.Marshal(card.Tags)
+			tags := string(tagsJSON)
+			flashcard.Tags = &tags
+		}
+		flashcards = append(flashcards, flashcard)
+	}
+	return flashcards
+}
@@ -0,0 +1,116 @@
+package studypack
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Validate normalizes whitespace in place and checks the content against the
+// shape requested in opts.
+func (c *Content) Validate(opts Options) error {
+	opts = opts.normalized()
+
+	c.Summary = strings.TrimSpace(c.Summary)
+	if c.Summary == "" {
+		return fmt.Errorf("summary is empty")
+	}
+
+	c.KeyPoints = compactStrings(c.KeyPoints)
+	if len(c.KeyPoints) == 0 {
+		return fmt.Errorf("keyPoints is empty")
+	}
+
+	if len(c.Quiz) < opts.QuestionCount {
+		return fmt.Errorf("expected %d quiz questions, got %d", opts.QuestionCount, len(c.Quiz))
+	}
+	c.Quiz = c.Quiz[:opts.QuestionCount]
+	for i := range c.Quiz {
+		if err := c.Quiz[i].normalize(); err != nil {
+			return fmt.Errorf("quiz question %d: %w", i+1, err)
+		}
+	}
+
+	flashcards := make([]Flashcard, 0, len(c.Flashcards))
+	for _, card := range c.Flashcards {
+		card.Front = strings.TrimSpace(card.Front)
+		card.Back = strings.TrimSpace(card.Back)
+		card.Tags = compactStrings(card.Tags)
+		if card.Front == "" || card.Back == "" {
+			continue
+		}
+		flashcards = append(flashcards, card)
+	}
+	if len(flashcards) == 0 {
+		return fmt.Errorf("no usable flashcards")
+	}
+	if len(flashcards) > opts.FlashcardCount {
+		flashcards = flashcards[:opts.FlashcardCount]
+	}
+	c.Flashcards = flashcards
+
+	return nil
+}
+
+func (q *Question) normalize() error {
+	q.Prompt = strings.TrimSpace(q.Prompt)
+	if q.Prompt == "" {
+		return fmt.Errorf("prompt is empty")
+	}
+
+	q.Options = compactStrings(q.Options)
+	if len(q.Options) < 2 {
+		return fmt.Errorf("needs at least two options")
+	}
+	seen := make(map[string]bool, len(q.Options))
+	for _, option := range q.Options {
+		key := strings.ToLower(option)
+		if seen[key] {
+			return fmt.Errorf("duplicate option %q", option)
+		}
+		seen[key] = true
+	}
+
+	answer, ok := resolveAnswer(strings.TrimSpace(q.Answer), q.Options)
+	if !ok {
+		return fmt.Errorf("answer %q is not one of the options", q.Answer)
+	}
+	q.Answer = answer
+	q.Explanation = strings.TrimSpace(q.Explanation)
+	return nil
+}
+
+// resolveAnswer maps the model's answer onto the exact option text. Models
+// frequently answer with the option letter or 1-based index instead.
+func resolveAnswer(answer string, options []string) (string, bool) {
+	for _, option := range options {
+		if option == answer {
+			return option, true
+		}
+	}
+	for _, option := range options {
+		if strings.EqualFold(option, answer) {
+			return option, true
+		}
+	}
+	if len(answer) == 1 {
+		letter := strings.ToUpper(answer)[0]
+		if letter >= 'A' && int(letter-'A') < len(options) {
+			return options[letter-'A'], true
+		}
+	}
+	if index, err := strconv.Atoi(answer); err == nil && index >= 1 && index <= len(options) {
+		return options[index-1], true
+	}
+	return "", false
+}
+
+func compactStrings(values []string) []string {
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		if clean := strings.TrimSpace(value); clean != "" {
+			result = append(result, clean)
+		}
+	}
+	return result
+}
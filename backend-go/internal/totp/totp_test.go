@@ -0,0 +1,128 @@
+package totp
+
+import (
+	"encoding/base32"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 key of the RFC 6238 test vectors.
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+// TestCodeRFC6238 checks the SHA-1 vectors of RFC 6238 appendix B. The RFC
+// lists eight digits; six-digit codes are their last six.
+func TestCodeRFC6238(t *testing.T) {
+	for _, tc := range []struct {
+		unix int64
+		rfc  string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	} {
+		code, err := Code(rfcSecret, Step(time.Unix(tc.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := tc.rfc[len(tc.rfc)-Digits:]; code != want {
+			t.Errorf("T=%d: code = %s, want %s", tc.unix, code, want)
+		}
+	}
+}
+
+func TestCodeAcceptsSecretForms(t *testing.T) {
+	want, err := Code(rfcSecret, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Apps show secrets in lower case and some keep the padding
+	for _, secret := range []string{strings.ToLower(rfcSecret), rfcSecret + "===="} {
+		if code, err := Code(secret, 1); err != nil || code != want {
+			t.Errorf("Code(%q) = %s, %v; want %s", secret, code, err, want)
+		}
+	}
+	if _, err := Code("not base32!", 1); err == nil {
+		t.Error("invalid secret accepted")
+	}
+}
+
+func TestValidateSkewWindow(t *testing.T) {
+	const step = 1000
+	code, err := Code(rfcSecret, step)
+	if err != nil {
+		t.Fatal(err)
+	}
+	period := int64(Period.Seconds())
+	start := step * period
+
+	for _, tc := range []struct {
+		name string
+		unix int64
+		ok   bool
+	}{
+		{"before the window", start - Skew*period - 1, false},
+		{"first second of the window", start - Skew*period, true},
+		{"own step", start + period/2, true},
+		{"last second of the window", start + (Skew+1)*period - 1, true},
+		{"after the window", start + (Skew+1)*period, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			matched, ok := Validate(rfcSecret, code, time.Unix(tc.unix, 0))
+			if ok != tc.ok {
+				t.Fatalf("Validate at %d = %v, want %v", tc.unix, ok, tc.ok)
+			}
+			if ok && matched != step {
+				t.Fatalf("matched step %d, want %d", matched, step)
+			}
+		})
+	}
+}
+
+func TestValidateCodeFormat(t *testing.T) {
+	now := time.Unix(59, 0)
+	for _, tc := range []struct {
+		code string
+		ok   bool
+	}{
+		{"287082", true},
+		{" 287 082 ", true},
+		{"94287082", false},
+		{"28708", false},
+		{"287083", false},
+		{"", false},
+	} {
+		if _, ok := Validate(rfcSecret, tc.code, now); ok != tc.ok {
+			t.Errorf("Validate(%q) = %v, want %v", tc.code, ok, tc.ok)
+		}
+	}
+	if _, ok := Validate("not base32!", "287082", now); ok {
+		t.Error("code accepted for an invalid secret")
+	}
+}
+
+func TestGenerateSecretAndProvisioningURI(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key, err := encoding.DecodeString(secret); err != nil || len(key) != 20 {
+		t.Fatalf("secret %q decodes to %d bytes, %v", secret, len(key), err)
+	}
+
+	uri, err := url.Parse(ProvisioningURI("MyWay", "ada@example.com", secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := uri.Query()
+	if uri.Scheme != "otpauth" || uri.Host != "totp" || uri.Path != "/MyWay:ada@example.com" {
+		t.Fatalf("URI = %s", uri)
+	}
+	if query.Get("secret") != secret || query.Get("digits") != "6" || query.Get("period") != "30" || query.Get("algorithm") != "SHA1" {
+		t.Fatalf("query = %v", query)
+	}
+}
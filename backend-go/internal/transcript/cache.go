@@ -0,0 +1,134 @@
+package transcript
+
+import (
+	"context"
+	"errors"
+	"myway-backend/internal/models"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	cacheTTL     = time.Hour
+	cacheEntries = 256
+)
+
+// Cache answers from transcripts already stored for the video, then from
+// recent fetches held in memory, before asking the provider. Previews and
+// imports of the same video therefore share one download.
+type Cache struct {
+	DB       *gorm.DB
+	Provider Provider
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	transcript *Transcript
+	expires    time.Time
+}
+
+func NewCache(db *gorm.DB, provider Provider) *Cache {
+	return &Cache{DB: db, Provider: provider, entries: make(map[string]cacheEntry)}
+}
+
+func (c *Cache) Fetch(ctx context.Context, videoID string, languages []string) (*Transcript, error) {
+	key := videoID + "|" + strings.ToLower(strings.Join(languages, ","))
+	now := time.Now()
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.transcript, nil
+	}
+
+	// Only the first preference is looked up in the database: a stored
+	// fallback language must not hide a better track the provider has.
+	preferred := ""
+	if len(languages) > 0 {
+		preferred = languages[0]
+	}
+	t, err := Load(c.DB, videoID, preferred)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		t, err = c.Provider.Fetch(ctx, videoID, languages)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= cacheEntries {
+		for k, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= cacheEntries {
+			c.entries = make(map[string]cacheEntry)
+		}
+	}
+	c.entries[key] = cacheEntry{transcript: t, expires: now.Add(cacheTTL)}
+	return t, nil
+}
+
+// Load returns the most recently stored transcript of a video in the given
+// language, or in any language when language is empty. It returns
+// gorm.ErrRecordNotFound when there is none.
+func Load(db *gorm.DB, videoID, language string) (*Transcript, error) {
+	query := db.Model(&models.TranscriptSegment{}).Where("video_id = ?", videoID)
+	if language != "" {
+		query = query.Where("(LOWER(language) = LOWER(?) OR LOWER(language) LIKE LOWER(?))", language, language+"-%")
+	}
+	var latest models.TranscriptSegment
+	if err := query.Order("created_at DESC").First(&latest).Error; err != nil {
+		return nil, err
+	}
+
+	var rows []models.TranscriptSegment
+	if err := db.Where("material_id = ?", latest.MaterialID).Order("ordinal").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	t := &Transcript{VideoID: videoID, Language: latest.Language, Segments: make([]Segment, len(rows))}
+	for i, row := range rows {
+		t.Segments[i] = Segment{
+			Start:    time.Duration(row.StartMs) * time.Millisecond,
+			Duration: time.Duration(row.DurationMs) * time.Millisecond,
+			Text:     row.Text,
+		}
+	}
+	if n := len(t.Segments); n > 0 {
+		t.Duration = t.Segments[n-1].Start + t.Segments[n-1].Duration
+	}
+	return t, nil
+}
+
+// Store replaces the transcript segments of a material.
+func Store(db *gorm.DB, materialID uuid.UUID, t *Transcript) error {
+	if err := db.Where("material_id = ?", materialID).Delete(&models.TranscriptSegment{}).Error; err != nil {
+		return err
+	}
+	if len(t.Segments) == 0 {
+		return nil
+	}
+	rows := make([]models.TranscriptSegment, len(t.Segments))
+	for i, segment := range t.Segments {
+		rows[i] = models.TranscriptSegment{
+			MaterialID: materialID,
+			VideoID:    t.VideoID,
+			Language:   t.Language,
+			Ordinal:    i,
+			StartMs:    int(segment.Start / time.Millisecond),
+			DurationMs: int(segment.Duration / time.Millisecond),
+			Text:       segment.Text,
+		}
+	}
+	return db.CreateInBatches(rows, 500).Error
+}
@@ -0,0 +1,214 @@
+package transcript
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FormatSRT   = "srt"
+	FormatVTT   = "vtt"
+	FormatJSON3 = "json3"
+	FormatSRV3  = "srv3"
+
+	// MaxCaptionSize caps uploaded caption files.
+	MaxCaptionSize = 10 << 20
+)
+
+var ErrUnknownFormat = errors.New("unrecognized caption format; upload SRT, WebVTT or YouTube json3/srv3 captions")
+
+// Captions is a parsed caption file.
+type Captions struct {
+	Format string
+	// Language comes from the file's metadata when it declares one.
+	Language string
+	Segments []Segment
+}
+
+var (
+	cueTiming   = regexp.MustCompile(`^\s*(\S+)\s+-->\s+(\S+)`)
+	cueTime     = regexp.MustCompile(`^(?:(\d+):)?(\d{1,2}):(\d{1,2})(?:[,.](\d{1,3}))?$`)
+	markupTag   = regexp.MustCompile(`<[^>]*>`)
+	assOverride = regexp.MustCompile(`\{\\[^}]*\}`)
+	// wordTiming is the per-word timestamp tag of YouTube's automatic
+	// captions, e.g. "<00:00:01.319><c> word</c>".
+	wordTiming = regexp.MustCompile(`<(?:\d+:)?\d{2}:\d{2}\.\d{3}>`)
+)
+
+// ParseCaptions detects the format of a caption file from its content and
+// parses it into timed segments ordered by start time.
+func ParseCaptions(data []byte) (*Captions, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	trimmed := bytes.TrimSpace(data)
+
+	var captions *Captions
+	var err error
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("WEBVTT")):
+		captions, err = parseCues(string(trimmed), FormatVTT)
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		captions, err = parseJSON3(trimmed)
+	case bytes.HasPrefix(trimmed, []byte("<")):
+		var segments []Segment
+		segments, err = parseTimedText(trimmed)
+		captions = &Captions{Format: FormatSRV3, Segments: segments}
+	case bytes.Contains(trimmed, []byte("-->")):
+		captions, err = parseCues(string(trimmed), FormatSRT)
+	default:
+		return nil, ErrUnknownFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(captions.Segments) == 0 {
+		return nil, fmt.Errorf("the %s file has no captions", captions.Format)
+	}
+	sort.SliceStable(captions.Segments, func(i, j int) bool {
+		return captions.Segments[i].Start < captions.Segments[j].Start
+	})
+	return captions, nil
+}
+
+// parseCues reads SRT and WebVTT, which share the cue layout: an optional
+// identifier line, a "start --> end" line and the caption text. Blocks
+// without a timing line (the WebVTT header, NOTE, STYLE and REGION) are
+// skipped.
+func parseCues(text, format string) (*Captions, error) {
+	captions := &Captions{Format: format}
+	text = strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\r", "\n")
+	// Automatic YouTube captions roll: each cue repeats the lines of the one
+	// before it. Only their word timing tags tell them from ordinary
+	// subtitles, where a line may well repeat ("No." "No.").
+	rolling := format == FormatVTT && wordTiming.MatchString(text)
+
+	var previous []string
+	for i, block := range strings.Split(text, "\n\n") {
+		lines := strings.Split(strings.Trim(block, "\n"), "\n")
+		if i == 0 && format == FormatVTT {
+			for _, line := range lines[1:] {
+				if value, ok := strings.CutPrefix(line, "Language:"); ok {
+					captions.Language = strings.TrimSpace(value)
+				}
+			}
+		}
+
+		timing := -1
+		for j, line := range lines {
+			if cueTiming.MatchString(line) {
+				timing = j
+				break
+			}
+		}
+		if timing < 0 {
+			continue
+		}
+
+		m := cueTiming.FindStringSubmatch(lines[timing])
+		start, ok1 := parseCueTime(m[1])
+		end, ok2 := parseCueTime(m[2])
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("invalid %s cue timing %q", format, strings.TrimSpace(lines[timing]))
+		}
+
+		// Of rolling captions, keep only the lines new in this cue
+		var current, fresh []string
+		for _, line := range lines[timing+1:] {
+			line = cleanText(assOverride.ReplaceAllString(markupTag.ReplaceAllString(line, ""), ""))
+			if line == "" {
+				continue
+			}
+			current = append(current, line)
+			if !rolling || !contains(previous, line) {
+				fresh = append(fresh, line)
+			}
+		}
+		previous = current
+		if len(fresh) == 0 {
+			continue
+		}
+
+		duration := end - start
+		if duration < 0 {
+			duration = 0
+		}
+		captions.Segments = append(captions.Segments, Segment{
+			Start:    start,
+			Duration: duration,
+			Text:     strings.Join(fresh, " "),
+		})
+	}
+	return captions, nil
+}
+
+func parseCueTime(value string) (time.Duration, bool) {
+	m := cueTime.FindStringSubmatch(value)
+	if m == nil {
+		return 0, false
+	}
+	hours, _ := strconv.Atoi(m[1])
+	minutes, _ := strconv.Atoi(m[2])
+	secs, _ := strconv.Atoi(m[3])
+	fraction := m[4]
+	for len(fraction) < 3 {
+		fraction += "0"
+	}
+	millis, _ := strconv.Atoi(fraction)
+	return time.Duration(hours)*time.Hour +
+		time.Duration(minutes)*time.Minute +
+		time.Duration(secs)*time.Second +
+		time.Duration(millis)*time.Millisecond, true
+}
+
+func contains(lines []string, line string) bool {
+	for _, l := range lines {
+		if l == line {
+			return true
+		}
+	}
+	return false
+}
+
+// json3 is YouTube's JSON caption format; events without segs only
+// position the caption window.
+type json3 struct {
+	Events []struct {
+		StartMs    int64 `json:"tStartMs"`
+		DurationMs int64 `json:"dDurationMs"`
+		Segs       []struct {
+			UTF8 string `json:"utf8"`
+		} `json:"segs"`
+	} `json:"events"`
+}
+
+func parseJSON3(data []byte) (*Captions, error) {
+	var doc json3
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parse json3 captions: %w", err)
+	}
+	if doc.Events == nil {
+		return nil, ErrUnknownFormat
+	}
+
+	captions := &Captions{Format: FormatJSON3}
+	for _, event := range doc.Events {
+		var sb strings.Builder
+		for _, seg := range event.Segs {
+			sb.WriteString(seg.UTF8)
+		}
+		if text := cleanText(sb.String()); text != "" {
+			captions.Segments = append(captions.Segments, Segment{
+				Start:    time.Duration(event.StartMs) * time.Millisecond,
+				Duration: time.Duration(event.DurationMs) * time.Millisecond,
+				Text:     text,
+			})
+		}
+	}
+	return captions, nil
+}
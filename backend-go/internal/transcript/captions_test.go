@@ -0,0 +1,129 @@
+package transcript
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func ms(n int) time.Duration {
+	return time.Duration(n) * time.Millisecond
+}
+
+func TestParseCaptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		format   string
+		language string
+		want     []Segment
+	}{
+		{
+			name: "srt with BOM, CRLF and markup",
+			data: "\xef\xbb\xbf1\r\n00:00:01,000 --> 00:00:02,500\r\nHello <i>world</i>\r\n{\\an8}again\r\n\r\n" +
+				"2\r\n00:00:03,000 --> 00:00:04,000\r\nNo.\r\n\r\n" +
+				"3\r\n00:00:04,000 --> 00:00:05,000\r\nNo.\r\n",
+			format: FormatSRT,
+			want: []Segment{
+				{Start: ms(1000), Duration: ms(1500), Text: "Hello world again"},
+				{Start: ms(3000), Duration: ms(1000), Text: "No."},
+				{Start: ms(4000), Duration: ms(1000), Text: "No."},
+			},
+		},
+		{
+			name:   "srt out of order",
+			data:   "1\n00:00:05,000 --> 00:00:06,000\nSecond\n\n2\n00:00:01,000 --> 00:00:02,000\nFirst\n",
+			format: FormatSRT,
+			want: []Segment{
+				{Start: ms(1000), Duration: ms(1000), Text: "First"},
+				{Start: ms(5000), Duration: ms(1000), Text: "Second"},
+			},
+		},
+		{
+			name: "vtt keeps repeated lines",
+			data: "WEBVTT\nKind: captions\nLanguage: pt-BR\n\nNOTE a comment\n\n" +
+				"intro\n00:01.000 --> 00:02.000 align:start\n<v Ana>Yes.</v>\n\n" +
+				"00:02.000 --> 00:03.000\nYes.\n",
+			format:   FormatVTT,
+			language: "pt-BR",
+			want: []Segment{
+				{Start: ms(1000), Duration: ms(1000), Text: "Yes."},
+				{Start: ms(2000), Duration: ms(1000), Text: "Yes."},
+			},
+		},
+		{
+			name: "youtube automatic vtt drops rolled lines",
+			data: "WEBVTT\r\nKind: captions\r\nLanguage: en\r\n\r\n" +
+				"00:00:00.000 --> 00:00:02.000 align:start position:0%\r\n \r\nhello<00:00:00.500><c> there</c>\r\n\r\n" +
+				"00:00:02.000 --> 00:00:02.010 align:start position:0%\r\nhello there\r\n \r\n\r\n" +
+				"00:00:02.010 --> 00:00:04.000 align:start position:0%\r\nhello there\r\ngeneral<00:00:02.500><c> kenobi</c>\r\n",
+			format:   FormatVTT,
+			language: "en",
+			want: []Segment{
+				{Start: 0, Duration: ms(2000), Text: "hello there"},
+				{Start: ms(2010), Duration: ms(1990), Text: "general kenobi"},
+			},
+		},
+		{
+			name:   "json3 with numeric entities",
+			data:   `{"events":[{"tStartMs":0,"dDurationMs":1000},{"tStartMs":1000,"dDurationMs":2000,"segs":[{"utf8":"it&#39;s"},{"utf8":" fine\nnow"}]}]}`,
+			format: FormatJSON3,
+			want: []Segment{
+				{Start: ms(1000), Duration: ms(2000), Text: "it's fine now"},
+			},
+		},
+		{
+			name:   "srv3 with escaped entities and spans",
+			data:   `<?xml version="1.0" encoding="utf-8" ?><timedtext format="3"><body><p t="1200" d="3400">caf&amp;#233; <s>time</s></p><p t="5000" d="100"> </p></body></timedtext>`,
+			format: FormatSRV3,
+			want: []Segment{
+				{Start: ms(1200), Duration: ms(3400), Text: "café time"},
+			},
+		},
+		{
+			name:   "legacy timed text",
+			data:   `<transcript><text start="0.5" dur="1.25">I&amp;#39;m here<br/>now</text></transcript>`,
+			format: FormatSRV3,
+			want: []Segment{
+				{Start: ms(500), Duration: ms(1250), Text: "I'm here now"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			captions, err := ParseCaptions([]byte(tt.data))
+			if err != nil {
+				t.Fatalf("ParseCaptions() error = %v", err)
+			}
+			if captions.Format != tt.format || captions.Language != tt.language {
+				t.Errorf("format, language = %q, %q, want %q, %q", captions.Format, captions.Language, tt.format, tt.language)
+			}
+			if !reflect.DeepEqual(captions.Segments, tt.want) {
+				t.Errorf("segments = %+v\nwant %+v", captions.Segments, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCaptionsErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"plain text", "just some words"},
+		{"bad timing", "1\n00:00:xx,000 --> 00:00:02,000\nHello\n"},
+		{"no cues", "WEBVTT\n\nNOTE nothing here\n"},
+		{"json without events", `{"foo":1}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseCaptions([]byte(tt.data)); err == nil {
+				t.Fatal("ParseCaptions() succeeded")
+			}
+		})
+	}
+	if _, err := ParseCaptions([]byte("just some words")); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("ParseCaptions(text) error = %v, want ErrUnknownFormat", err)
+	}
+}
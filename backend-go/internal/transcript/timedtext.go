@@ -0,0 +1,99 @@
+package transcript
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseTimedText reads YouTube timedtext XML in either the legacy
+// <text start="1.2" dur="3.4"> form (seconds) or the format 3
+// <p t="1200" d="3400"> form (milliseconds).
+func parseTimedText(data []byte) ([]Segment, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+
+	var segments []Segment
+	var current *Segment
+	var text strings.Builder
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse timed text: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if current != nil {
+				if t.Name.Local == "br" {
+					text.WriteString(" ")
+				}
+				continue
+			}
+			switch t.Name.Local {
+			case "text":
+				current = &Segment{
+					Start:    seconds(attr(t, "start")),
+					Duration: seconds(attr(t, "dur")),
+				}
+			case "p":
+				current = &Segment{
+					Start:    millis(attr(t, "t")),
+					Duration: millis(attr(t, "d")),
+				}
+			}
+			text.Reset()
+		case xml.CharData:
+			if current != nil {
+				text.Write(t)
+			}
+		case xml.EndElement:
+			if current != nil && (t.Name.Local == "text" || t.Name.Local == "p") {
+				if current.Text = cleanText(text.String()); current.Text != "" {
+					segments = append(segments, *current)
+				}
+				current = nil
+			}
+		}
+	}
+	return segments, nil
+}
+
+func attr(element xml.StartElement, name string) string {
+	for _, a := range element.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+func seconds(value string) time.Duration {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0
+	}
+	return time.Duration(f * float64(time.Second))
+}
+
+func millis(value string) time.Duration {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0
+	}
+	return time.Duration(n) * time.Millisecond
+}
+
+// cleanText decodes the entities YouTube escapes twice and collapses
+// whitespace and line breaks.
+func cleanText(text string) string {
+	return strings.Join(strings.Fields(html.UnescapeString(text)), " ")
+}
@@ -0,0 +1,127 @@
+// Package transcript fetches timed video transcripts and keeps them with the
+// materials they were imported for.
+package transcript
+
+import (
+	"context"
+	"errors"
+	"myway-backend/internal/retrieval"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNoCaptions  = errors.New("no captions available for this video")
+	ErrUnavailable = errors.New("video is unavailable")
+)
+
+// Segment is one timed caption line.
+type Segment struct {
+	Start    time.Duration
+	Duration time.Duration
+	Text     string
+}
+
+type Transcript struct {
+	VideoID  string
+	Title    string
+	Language string
+	Duration time.Duration
+	Segments []Segment
+}
+
+// Text renders the transcript as "m:ss - text" lines, the format the
+// retrieval chunker reads timestamps from.
+func (t *Transcript) Text() string {
+	var sb strings.Builder
+	for _, segment := range t.Segments {
+		sb.WriteString(retrieval.FormatTimestamp(segment.Start.Seconds()))
+		sb.WriteString(" - ")
+		sb.WriteString(segment.Text)
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// PlainText joins the segments without timestamps.
+func (t *Transcript) PlainText() string {
+	parts := make([]string, len(t.Segments))
+	for i, segment := range t.Segments {
+		parts[i] = segment.Text
+	}
+	return strings.Join(parts, " ")
+}
+
+// Provider is implemented by every transcript source. languages lists the
+// preferred caption languages in order; providers fall back to any available
+// track when none of them exists.
+type Provider interface {
+	Fetch(ctx context.Context, videoID string, languages []string) (*Transcript, error)
+}
+
+var (
+	videoIDPattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{11}$`)
+	videoPaths     = []string{"/embed/", "/shorts/", "/live/", "/v/"}
+)
+
+// VideoID extracts the video ID from a YouTube URL or returns a bare ID as
+// is. It returns "" when the input is neither.
+func VideoID(raw string) string {
+	raw = strings.TrimSpace(raw)
+	if videoIDPattern.MatchString(raw) {
+		return raw
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	host = strings.TrimPrefix(host, "m.")
+
+	var id string
+	switch host {
+	case "youtu.be":
+		id = strings.Trim(u.Path, "/")
+	case "youtube.com", "music.youtube.com", "youtube-nocookie.com":
+		if u.Path == "/watch" {
+			id = u.Query().Get("v")
+			break
+		}
+		for _, prefix := range videoPaths {
+			if strings.HasPrefix(u.Path, prefix) {
+				id = strings.SplitN(strings.TrimPrefix(u.Path, prefix), "/", 2)[0]
+				break
+			}
+		}
+	}
+	if !videoIDPattern.MatchString(id) {
+		return ""
+	}
+	return id
+}
+
+// matchLanguage reports whether a caption language code satisfies a
+// preference, so "en" matches "en-GB".
+func matchLanguage(code, preferred string) bool {
+	return strings.EqualFold(code, preferred) ||
+		len(code) > len(preferred) && code[len(preferred)] == '-' && strings.EqualFold(code[:len(preferred)], preferred)
+}
+
+// Languages builds a preference list from a requested language followed by
+// the defaults, without duplicates.
+func Languages(requested string, defaults []string) []string {
+	var languages []string
+	seen := make(map[string]bool)
+	for _, lang := range append([]string{requested}, defaults...) {
+		lang = strings.TrimSpace(lang)
+		if lang == "" || seen[strings.ToLower(lang)] {
+			continue
+		}
+		seen[strings.ToLower(lang)] = true
+		languages = append(languages, lang)
+	}
+	return languages
+}
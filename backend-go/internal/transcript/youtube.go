@@ -0,0 +1,119 @@
+package transcript
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+// YouTube reads caption tracks through the YouTube player API, falling back
+// to the track's timedtext URL when the transcript endpoint fails.
+type YouTube struct {
+	client youtube.Client
+	http   *http.Client
+}
+
+func NewYouTube() *YouTube {
+	httpClient := &http.Client{Timeout: 30 * time.Second}
+	return &YouTube{
+		client: youtube.Client{HTTPClient: httpClient},
+		http:   httpClient,
+	}
+}
+
+func (y *YouTube) Fetch(ctx context.Context, videoID string, languages []string) (*Transcript, error) {
+	video, err := y.client.GetVideoContext(ctx, videoID)
+	if err != nil {
+		var status *youtube.ErrPlayabiltyStatus
+		if errors.Is(err, youtube.ErrVideoPrivate) || errors.Is(err, youtube.ErrLoginRequired) || errors.As(err, &status) {
+			return nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
+		}
+		return nil, fmt.Errorf("fetch video %s: %w", videoID, err)
+	}
+	if len(video.CaptionTracks) == 0 {
+		return nil, ErrNoCaptions
+	}
+
+	track := pickTrack(video.CaptionTracks, languages)
+	segments, err := y.segments(ctx, video, track)
+	if err != nil {
+		return nil, err
+	}
+	if len(segments) == 0 {
+		return nil, ErrNoCaptions
+	}
+
+	return &Transcript{
+		VideoID:  videoID,
+		Title:    video.Title,
+		Language: track.LanguageCode,
+		Duration: video.Duration,
+		Segments: segments,
+	}, nil
+}
+
+func (y *YouTube) segments(ctx context.Context, video *youtube.Video, track *youtube.CaptionTrack) ([]Segment, error) {
+	lines, err := y.client.GetTranscriptCtx(ctx, video, track.LanguageCode)
+	if err == nil && len(lines) > 0 {
+		segments := make([]Segment, 0, len(lines))
+		for _, line := range lines {
+			if text := cleanText(line.Text); text != "" {
+				segments = append(segments, Segment{
+					Start:    time.Duration(line.StartMs) * time.Millisecond,
+					Duration: time.Duration(line.Duration) * time.Millisecond,
+					Text:     text,
+				})
+			}
+		}
+		return segments, nil
+	}
+	if track.BaseURL == "" {
+		if err == nil || errors.Is(err, youtube.ErrTranscriptDisabled) {
+			return nil, ErrNoCaptions
+		}
+		return nil, fmt.Errorf("fetch transcript of %s: %w", video.ID, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, track.BaseURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := y.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch captions of %s: %w", video.ID, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch captions of %s: HTTP %d", video.ID, resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 10<<20))
+	if err != nil {
+		return nil, fmt.Errorf("fetch captions of %s: %w", video.ID, err)
+	}
+	return parseTimedText(data)
+}
+
+// pickTrack prefers manual captions over automatic ones in each preferred
+// language, then any manual track, then whatever exists.
+func pickTrack(tracks []youtube.CaptionTrack, languages []string) *youtube.CaptionTrack {
+	for _, lang := range languages {
+		for _, generated := range []bool{false, true} {
+			for i := range tracks {
+				if (tracks[i].Kind == "asr") == generated && matchLanguage(tracks[i].LanguageCode, lang) {
+					return &tracks[i]
+				}
+			}
+		}
+	}
+	for i := range tracks {
+		if tracks[i].Kind != "asr" {
+			return &tracks[i]
+		}
+	}
+	return &tracks[0]
+}
@@ -1,6 +1,10 @@
 package jwtutil
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"time"
 
@@ -11,45 +15,55 @@ import (
 type Claims struct {
 	UserID uuid.UUID `json:"sub"`
 	Email  string    `json:"email"`
-	Role   string    `json:"role,omitempty"`
+	// MFA is set when the session passed multi-factor authentication.
+	MFA bool `json:"mfa,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func GenerateToken(userID uuid.UUID, email string, secret string) (string, error) {
+func GenerateToken(userID uuid.UUID, email string, mfa bool, keys *Keys) (string, error) {
 	claims := &Claims{
 		UserID: userID,
 		Email:  email,
+		MFA:    mfa,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(15 * time.Minute)), // Access token: 15 min
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(secret))
+	return keys.sign(claims)
 }
 
-func GenerateRefreshToken(userID uuid.UUID, email string, secret string) (string, error) {
-	claims := &Claims{
-		UserID: userID,
-		Email:  email,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(7 * 24 * time.Hour)), // Refresh token: 7 days
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
+// RefreshTokenTTL is how long a refresh token stays usable. Every refresh
+// issues a new token with a fresh lifetime.
+const RefreshTokenTTL = 7 * 24 * time.Hour
+
+// APITokenPrefix starts every personal access token and organization API
+// key, so they are told apart from access tokens and easy to spot when
+// leaked.
+const APITokenPrefix = "myway_"
+
+// NewOpaqueToken returns a random token and the hash to store for it. Refresh
+// tokens and emailed tokens are opaque rather than JWTs, so they can never
+// pass as access tokens.
+func NewOpaqueToken() (token string, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
 	}
+	token = base64.RawURLEncoding.EncodeToString(buf)
+	return token, HashToken(token), nil
+}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(secret))
+// HashToken is the SHA-256 hex digest under which an opaque token is stored.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
 
-func ValidateToken(tokenString string, secret string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return []byte(secret), nil
-	})
+func ValidateToken(tokenString string, keys *Keys) (*Claims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, keys.keyFunc,
+		jwt.WithValidMethods([]string{"HS256", "RS256", "EdDSA"}))
 
 	if err != nil {
 		return nil, err
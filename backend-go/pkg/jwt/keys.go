@@ -0,0 +1,251 @@
+package jwtutil
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// minRSABits is the smallest RSA key accepted for signing or verification.
+const minRSABits = 2048
+
+// KeyConfig selects how access tokens are signed. With SigningKeyFile set
+// they are signed with that RSA (RS256) or Ed25519 (EdDSA) private key;
+// otherwise with HS256 and Secret. VerificationKeyFiles are further public
+// or private keys whose tokens are accepted and which are published in the
+// JWKS, for rotating keys without signing anyone out.
+type KeyConfig struct {
+	Secret               string
+	SigningKeyFile       string
+	VerificationKeyFiles []string
+}
+
+// Keys signs and verifies access tokens.
+type Keys struct {
+	secret     []byte
+	signingKID string
+	signing    crypto.Signer
+	method     jwt.SigningMethod
+	// verification holds every asymmetric key tokens are accepted from,
+	// the signing key included, by key ID.
+	verification map[string]publicKey
+}
+
+type publicKey struct {
+	key    crypto.PublicKey
+	method jwt.SigningMethod
+}
+
+// NewSecretKeys signs and verifies access tokens with HS256 only.
+func NewSecretKeys(secret string) *Keys {
+	return &Keys{secret: []byte(secret), method: jwt.SigningMethodHS256, verification: map[string]publicKey{}}
+}
+
+// LoadKeys reads the keys of cfg from PEM files.
+func LoadKeys(cfg KeyConfig) (*Keys, error) {
+	keys := NewSecretKeys(cfg.Secret)
+	if cfg.SigningKeyFile != "" {
+		private, err := readPEM(cfg.SigningKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		signer, err := parsePrivateKey(private)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", cfg.SigningKeyFile, err)
+		}
+		kid, err := keys.add(signer.Public())
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", cfg.SigningKeyFile, err)
+		}
+		keys.signing = signer
+		keys.signingKID = kid
+		keys.method = keys.verification[kid].method
+	}
+	for _, path := range cfg.VerificationKeyFiles {
+		block, err := readPEM(path)
+		if err != nil {
+			return nil, err
+		}
+		public, err := parsePublicKey(block)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if _, err := keys.add(public); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return keys, nil
+}
+
+// Asymmetric reports whether tokens are signed with a private key rather
+// than the shared secret.
+func (k *Keys) Asymmetric() bool {
+	return k.signing != nil
+}
+
+func (k *Keys) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(k.method, claims)
+	if k.signing == nil {
+		return token.SignedString(k.secret)
+	}
+	token.Header["kid"] = k.signingKID
+	return token.SignedString(k.signing)
+}
+
+// keyFunc picks the key a token is verified with. Tokens signed with a key
+// pair are found by their kid. HS256 tokens are only accepted while the
+// shared secret still signs, so that it stops mattering once a key pair is
+// configured.
+func (k *Keys) keyFunc(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+		if k.signing != nil {
+			return nil, errors.New("HS256 tokens are no longer accepted")
+		}
+		return k.secret, nil
+	}
+	kid, _ := token.Header["kid"].(string)
+	public, ok := k.verification[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if token.Method.Alg() != public.method.Alg() {
+		return nil, errors.New("unexpected signing method")
+	}
+	return public.key, nil
+}
+
+func (k *Keys) add(key crypto.PublicKey) (string, error) {
+	var method jwt.SigningMethod
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		if key.N.BitLen() < minRSABits {
+			return "", fmt.Errorf("RSA keys must have at least %d bits", minRSABits)
+		}
+		method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		method = jwt.SigningMethodEdDSA
+	default:
+		return "", fmt.Errorf("unsupported key type %T; use RSA or Ed25519", key)
+	}
+	jwk := newJWK(key, method)
+	k.verification[jwk.Kid] = publicKey{key: key, method: method}
+	return jwk.Kid, nil
+}
+
+// JWK is the public half of a verification key, as published in the JWKS.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Ed25519
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS lists the verification keys, the signing key first. It is empty
+// while tokens are signed with the shared secret only.
+func (k *Keys) JWKS() JWKS {
+	set := JWKS{Keys: []JWK{}}
+	for _, public := range k.verification {
+		set.Keys = append(set.Keys, newJWK(public.key, public.method))
+	}
+	sort.Slice(set.Keys, func(i, j int) bool {
+		if (set.Keys[i].Kid == k.signingKID) != (set.Keys[j].Kid == k.signingKID) {
+			return set.Keys[i].Kid == k.signingKID
+		}
+		return set.Keys[i].Kid < set.Keys[j].Kid
+	})
+	return set
+}
+
+// newJWK encodes a public key. Its key ID is the RFC 7638 thumbprint, so the
+// same key gets the same ID on every server without naming it.
+func newJWK(key crypto.PublicKey, method jwt.SigningMethod) JWK {
+	jwk := JWK{Use: "sig", Alg: method.Alg()}
+	var thumbprintInput string
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(key.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes())
+		thumbprintInput = fmt.Sprintf(`{"e":"%s","kty":"RSA","n":"%s"}`, jwk.E, jwk.N)
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(key)
+		thumbprintInput = fmt.Sprintf(`{"crv":"Ed25519","kty":"OKP","x":"%s"}`, jwk.X)
+	}
+	sum := sha256.Sum256([]byte(thumbprintInput))
+	jwk.Kid = base64.RawURLEncoding.EncodeToString(sum[:])
+	return jwk
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data", path)
+	}
+	return block, nil
+}
+
+// parsePrivateKey accepts PKCS #8 keys, as written by openssl genpkey, and
+// PKCS #1 RSA keys.
+func parsePrivateKey(block *pem.Block) (crypto.Signer, error) {
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("expected a private key, got %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+	return signer, nil
+}
+
+// parsePublicKey accepts a public key, or a private key whose public half is
+// wanted.
+func parsePublicKey(block *pem.Block) (crypto.PublicKey, error) {
+	switch block.Type {
+	case "PUBLIC KEY":
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	}
+	signer, err := parsePrivateKey(block)
+	if err != nil {
+		return nil, err
+	}
+	return signer.Public(), nil
+}
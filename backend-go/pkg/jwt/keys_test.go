@@ -0,0 +1,272 @@
+package jwtutil
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// writeKey saves a private key as a PKCS #8 PEM file and returns its path.
+func writeKey(t *testing.T, key crypto.Signer) string {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "key.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func newEd25519Key(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func mustLoadKeys(t *testing.T, cfg KeyConfig) *Keys {
+	t.Helper()
+	keys, err := LoadKeys(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+func testClaims() *Claims {
+	return &Claims{
+		UserID: uuid.New(),
+		Email:  "ada@example.com",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+	}
+}
+
+func TestSecretKeysRoundTrip(t *testing.T) {
+	keys := NewSecretKeys("secret")
+	userID := uuid.New()
+	token, err := GenerateToken(userID, "ada@example.com", true, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := ValidateToken(token, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.UserID != userID || claims.Email != "ada@example.com" || !claims.MFA {
+		t.Fatalf("claims = %+v", claims)
+	}
+	if _, err := ValidateToken(token, NewSecretKeys("other")); err == nil {
+		t.Fatal("token verified with another secret")
+	}
+	if len(keys.JWKS().Keys) != 0 {
+		t.Fatal("the shared secret was published")
+	}
+}
+
+func TestSigningKeySetsKid(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, minRSABits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, signer := range map[string]crypto.Signer{"RS256": rsaKey, "EdDSA": newEd25519Key(t)} {
+		t.Run(name, func(t *testing.T) {
+			retired := newEd25519Key(t)
+			keys := mustLoadKeys(t, KeyConfig{
+				Secret:               "secret",
+				SigningKeyFile:       writeKey(t, signer),
+				VerificationKeyFiles: []string{writeKey(t, retired)},
+			})
+
+			token, err := keys.sign(testClaims())
+			if err != nil {
+				t.Fatal(err)
+			}
+			parsed, _, err := jwt.NewParser().ParseUnverified(token, &Claims{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := newJWK(signer.Public(), keys.method)
+			if parsed.Method.Alg() != name || parsed.Header["kid"] != want.Kid {
+				t.Fatalf("header = %v, want alg %s and kid %s", parsed.Header, name, want.Kid)
+			}
+
+			// The signing key is published first
+			set := keys.JWKS()
+			if len(set.Keys) != 2 || set.Keys[0].Kid != want.Kid || set.Keys[1].Kid != newJWK(retired.Public(), jwt.SigningMethodEdDSA).Kid {
+				t.Fatalf("JWKS = %+v", set)
+			}
+			if _, err := ValidateToken(token, keys); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestRotationAcceptsRetiredKey(t *testing.T) {
+	oldKey, newKey := newEd25519Key(t), newEd25519Key(t)
+	oldFile, newFile := writeKey(t, oldKey), writeKey(t, newKey)
+
+	before := mustLoadKeys(t, KeyConfig{SigningKeyFile: oldFile})
+	oldToken, err := GenerateToken(uuid.New(), "ada@example.com", false, before)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// During rotation the new key signs and the old one still verifies
+	during := mustLoadKeys(t, KeyConfig{SigningKeyFile: newFile, VerificationKeyFiles: []string{oldFile}})
+	if _, err := ValidateToken(oldToken, during); err != nil {
+		t.Fatalf("token of the retired key: %v", err)
+	}
+	newToken, err := GenerateToken(uuid.New(), "ada@example.com", false, during)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ValidateToken(newToken, before); err == nil {
+		t.Fatal("a server without the new key accepted its token")
+	}
+
+	// Once the old key is dropped its tokens are refused
+	after := mustLoadKeys(t, KeyConfig{SigningKeyFile: newFile})
+	if _, err := ValidateToken(oldToken, after); err == nil {
+		t.Fatal("token of a dropped key accepted")
+	}
+	if _, err := ValidateToken(newToken, after); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRejectsUnknownKid(t *testing.T) {
+	keys := mustLoadKeys(t, KeyConfig{SigningKeyFile: writeKey(t, newEd25519Key(t))})
+	stranger := newEd25519Key(t)
+
+	for name, kid := range map[string]interface{}{
+		"stranger's kid":        newJWK(stranger.Public(), jwt.SigningMethodEdDSA).Kid,
+		"signing key's kid":     keys.signingKID,
+		"no kid":                nil,
+		"kid of the wrong type": 42,
+	} {
+		t.Run(name, func(t *testing.T) {
+			token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, testClaims())
+			if kid != nil {
+				token.Header["kid"] = kid
+			}
+			signed, err := token.SignedString(stranger)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := ValidateToken(signed, keys); err == nil {
+				t.Fatal("token of an unknown key accepted")
+			}
+		})
+	}
+}
+
+func TestRefusesNoneAndSymmetricAlgorithms(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, minRSABits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := mustLoadKeys(t, KeyConfig{Secret: "secret", SigningKeyFile: writeKey(t, rsaKey)})
+	publicDER, err := x509.MarshalPKIXPublicKey(rsaKey.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unsigned := jwt.NewWithClaims(jwt.SigningMethodNone, testClaims())
+	unsigned.Header["kid"] = keys.signingKID
+	none, err := unsigned.SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ValidateToken(none, keys); err == nil {
+		t.Fatal("alg none accepted with a key pair")
+	}
+	if _, err := ValidateToken(none, NewSecretKeys("secret")); err == nil {
+		t.Fatal("alg none accepted with the shared secret")
+	}
+
+	// Once a key pair signs, HS256 is refused whether it uses the old
+	// secret or, as in key confusion attacks, the published public key
+	for name, secret := range map[string][]byte{
+		"shared secret":  []byte("secret"),
+		"public key":     publicDER,
+		"PEM public key": pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}),
+	} {
+		t.Run(name, func(t *testing.T) {
+			token := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims())
+			token.Header["kid"] = keys.signingKID
+			signed, err := token.SignedString(secret)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := ValidateToken(signed, keys); err == nil {
+				t.Fatal("HS256 token accepted")
+			}
+		})
+	}
+
+	// Nor may a token claim another asymmetric algorithm than its key's
+	token := jwt.NewWithClaims(jwt.SigningMethodPS256, testClaims())
+	token.Header["kid"] = keys.signingKID
+	signed, err := token.SignedString(rsaKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ValidateToken(signed, keys); err == nil {
+		t.Fatal("PS256 token accepted for an RS256 key")
+	}
+}
+
+func TestLoadKeysRejectsWeakRSA(t *testing.T) {
+	weak, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadKeys(KeyConfig{SigningKeyFile: writeKey(t, weak)}); err == nil {
+		t.Fatal("1024-bit RSA key accepted")
+	}
+}
+
+// TestThumbprint checks key IDs against the examples of RFC 7638 section 3.1
+// and RFC 8037 appendix A.3.
+func TestThumbprint(t *testing.T) {
+	decode := func(value string) []byte {
+		data, err := base64.RawURLEncoding.DecodeString(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	rsaKey := &rsa.PublicKey{
+		N: new(big.Int).SetBytes(decode("0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw")),
+		E: 65537,
+	}
+	if jwk := newJWK(rsaKey, jwt.SigningMethodRS256); jwk.Kid != "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs" || jwk.E != "AQAB" {
+		t.Fatalf("RSA JWK = %+v", jwk)
+	}
+
+	edKey := ed25519.PublicKey(decode("11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"))
+	if jwk := newJWK(edKey, jwt.SigningMethodEdDSA); jwk.Kid != "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k" {
+		t.Fatalf("Ed25519 JWK = %+v", jwk)
+	}
+}